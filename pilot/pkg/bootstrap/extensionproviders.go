@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/kube/configmapwatcher"
+	"istio.io/pkg/log"
+)
+
+// extensionProvidersConfigMapKey is the key of the mesh ConfigMap with the extension providers of the types
+// MeshConfig does not support yet.
+const extensionProvidersConfigMapKey = "extensionProviders"
+
+// initExtensionProviders sets the extension providers from PILOT_EXTENSION_PROVIDERS and, with Kubernetes, watches
+// the extensionProviders key of the mesh ConfigMap, which overrides the variable. The proxies are pushed again
+// when the providers change.
+func (s *Server) initExtensionProviders(args *PilotArgs) {
+	providers, err := model.ParseExtensionProviders(features.ExtensionProviders, s.environment.Mesh())
+	if err != nil {
+		log.Errorf("ignoring PILOT_EXTENSION_PROVIDERS: %v", err)
+	}
+	s.environment.SetExtensionProviders(providers)
+	if s.kubeClient == nil {
+		return
+	}
+
+	var c *configmapwatcher.Controller
+	c = configmapwatcher.NewController(s.kubeClient, args.Namespace, getMeshConfigMapName(args.Revision), func(cm *v1.ConfigMap) {
+		value, found := "", false
+		if cm != nil {
+			value, found = cm.Data[extensionProvidersConfigMapKey]
+		}
+		if !found {
+			value = features.ExtensionProviders
+		}
+		providers, err := model.ParseExtensionProviders(value, s.environment.Mesh())
+		if err != nil {
+			// Keep the last known providers in case there's a misconfiguration issue.
+			log.Warnf("failed to read extension providers from ConfigMap: %v", err)
+			return
+		}
+		// The proxies are only pushed again for the changes after the initial load.
+		if !s.environment.SetExtensionProviders(providers) || !c.HasSynced() {
+			return
+		}
+		log.Infof("extension providers changed")
+		s.XDSServer.ConfigUpdate(&model.PushRequest{
+			Full:   true,
+			Reason: []model.TriggerReason{model.GlobalUpdate},
+		})
+	})
+	go c.Run(s.internalStop)
+	// Ensure the ConfigMap is initially loaded if present.
+	if !cache.WaitForCacheSync(s.internalStop, c.HasSynced) {
+		log.Error("failed to wait for cache sync")
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 
@@ -45,8 +46,10 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/keycertbundle"
+	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
@@ -237,6 +240,7 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 
 	s.initMeshNetworks(args, s.fileWatcher)
 	s.initMeshHandlers()
+	s.initExtensionProviders(args)
 	s.environment.Init()
 	if err := s.environment.InitNetworksManager(s.XDSServer); err != nil {
 		return nil, err
@@ -414,6 +418,10 @@ func (s *Server) Start(stop <-chan struct{}) error {
 	if features.UnsafeFeaturesEnabled() {
 		log.Warn("Server is starting with unsafe features enabled")
 	}
+	if features.EnableHealthAwareFailover && !features.SendUnhealthyEndpoints {
+		log.Warn("PILOT_ENABLE_HEALTH_AWARE_FAILOVER is enabled without PILOT_SEND_UNHEALTHY_ENDPOINTS: the unready " +
+			"Kubernetes endpoints are dropped, so their regions are never considered degraded")
+	}
 
 	// Now start all of the components.
 	if err := s.server.Start(stop); err != nil {
@@ -652,6 +660,9 @@ func (s *Server) initDiscoveryService(args *PilotArgs) {
 		return nil
 	})
 
+	s.initSharding(args)
+	s.initPushClasses()
+
 	s.initGrpcServer(args.KeepaliveOptions)
 
 	if args.ServerOptions.GRPCAddr != "" {
@@ -664,6 +675,68 @@ func (s *Server) initDiscoveryService(args *PilotArgs) {
 	}
 }
 
+// initSharding splits the proxies between the replicas of this revision, if enabled.
+func (s *Server) initSharding(args *PilotArgs) {
+	if features.ShardCount < 2 {
+		return
+	}
+	if s.kubeClient == nil {
+		log.Warnf("PILOT_SHARD_COUNT is set, but sharding requires Kubernetes; serving all proxies")
+		return
+	}
+	election := leaderelection.NewShardElection(args.Namespace, args.PodName, args.Revision, features.ShardCount, s.kubeClient)
+	owner := shardOwner{
+		ShardElection: election,
+		pods:          s.kubeClient.KubeInformer().Core().V1().Pods().Lister().Pods(args.Namespace),
+	}
+	sharding, err := xds.NewSharding(features.ShardCount, features.ShardBy, owner)
+	if err != nil {
+		log.Warnf("invalid sharding settings, serving all proxies: %v", err)
+		return
+	}
+	log.Infof("sharding proxies by %s into %d shards", sharding.By, sharding.Shards)
+	s.XDSServer.Sharding = sharding
+	s.environment.ServesNamespace = sharding.ServesNamespace
+	election.AddHandler(s.XDSServer.OnShardChange)
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		go election.Run(stop)
+		return nil
+	})
+}
+
+// shardOwner resolves the address of the replica owning a shard from the pod named after it.
+type shardOwner struct {
+	*leaderelection.ShardElection
+	pods listerv1.PodNamespaceLister
+}
+
+func (o shardOwner) OwnerAddress(shard int) string {
+	name := o.Owner(shard)
+	if name == "" {
+		return ""
+	}
+	pod, err := o.pods.Get(name)
+	if err != nil {
+		return ""
+	}
+	return pod.Status.PodIP
+}
+
+// initPushClasses lets namespaces select the push class of their proxies with the istio.io/push-class label.
+func (s *Server) initPushClasses() {
+	if s.kubeClient == nil {
+		return
+	}
+	namespaces := s.kubeClient.KubeInformer().Core().V1().Namespaces().Lister()
+	s.XDSServer.SetPushClassNamespaceLabels(func(name string) map[string]string {
+		ns, err := namespaces.Get(name)
+		if err != nil {
+			return nil
+		}
+		return ns.Labels
+	})
+}
+
 // Wait for the stop, and do cleanups
 func (s *Server) waitForShutdown(stop <-chan struct{}) {
 	go func() {
@@ -863,7 +936,7 @@ func (s *Server) cachesSynced() bool {
 func (s *Server) initRegistryEventHandlers() {
 	log.Info("initializing registry event handlers")
 	// Flush cached discovery responses whenever services configuration change.
-	serviceHandler := func(svc *model.Service, _ model.Event) {
+	serviceHandler := func(svc *model.Service, event model.Event) {
 		pushReq := &model.PushRequest{
 			Full: true,
 			ConfigsUpdated: map[model.ConfigKey]struct{}{{
@@ -872,6 +945,11 @@ func (s *Server) initRegistryEventHandlers() {
 				Namespace: svc.Attributes.Namespace,
 			}: {}},
 			Reason: []model.TriggerReason{model.ServiceUpdate},
+			Traces: pushtrace.Start("service.event", map[string]string{
+				"event":    event.String(),
+				"registry": string(svc.Attributes.ServiceRegistry),
+				"service":  string(svc.Hostname),
+			}),
 		}
 		s.XDSServer.ConfigUpdate(pushReq)
 	}
@@ -899,6 +977,11 @@ func (s *Server) initRegistryEventHandlers() {
 					Namespace: curr.Namespace,
 				}: {}},
 				Reason: []model.TriggerReason{model.ConfigUpdate},
+				Traces: pushtrace.Start("config.event", map[string]string{
+					"event":  event.String(),
+					"kind":   curr.GroupVersionKind.Kind,
+					"config": curr.Namespace + "/" + curr.Name,
+				}),
 			}
 			s.XDSServer.ConfigUpdate(pushReq)
 		}
@@ -37,7 +37,10 @@ import (
 	"sigs.k8s.io/gateway-api/pkg/client/listers/gateway/apis/v1alpha2"
 	"sigs.k8s.io/yaml"
 
+	istio "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config"
+	gatewaycfg "istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
@@ -181,7 +184,8 @@ func (d *DeploymentController) configureIstioGateway(log *istiolog.Scope, gw gat
 	}
 	log.Info("reconciling")
 
-	svc := serviceInput{Gateway: &gw, Ports: extractServicePorts(gw)}
+	quic := features.EnableQUICListeners && supportsMixedProtocol(gw, d.client)
+	svc := serviceInput{Gateway: &gw, Ports: extractServicePorts(gw, quic)}
 	if err := d.ApplyTemplate("service.yaml", svc); err != nil {
 		return fmt.Errorf("update service: %v", err)
 	}
@@ -283,7 +287,24 @@ type deploymentInput struct {
 	KubeVersion122 bool
 }
 
-func extractServicePorts(gw gateway.Gateway) []corev1.ServicePort {
+// serviceTypeAnnotation overrides the type of the Service of a gateway, LoadBalancer by default.
+const serviceTypeAnnotation = "networking.istio.io/service-type"
+
+// supportsMixedProtocol checks if the Service of a gateway can expose the same port over TCP and UDP. LoadBalancer
+// Services require the MixedProtocolLBService feature gate, enabled by default since Kubernetes 1.24, as well as
+// support from the load balancer implementation of the cluster.
+func supportsMixedProtocol(gw gateway.Gateway, client kube.Client) bool {
+	serviceType := gw.Annotations[serviceTypeAnnotation]
+	if serviceType != "" && serviceType != string(corev1.ServiceTypeLoadBalancer) {
+		return true
+	}
+	return kube.IsAtLeastVersion(client, 24)
+}
+
+// extractServicePorts returns the ports of the Service of a gateway. With quic, the HTTPS listeners eligible for
+// HTTP/3 are exposed over UDP as well, which requires QUIC listeners to be enabled and the Service to support mixed
+// protocols.
+func extractServicePorts(gw gateway.Gateway, quic bool) []corev1.ServicePort {
 	svcPorts := make([]corev1.ServicePort, 0, len(gw.Spec.Listeners)+1)
 	svcPorts = append(svcPorts, corev1.ServicePort{
 		Name: "status-port",
@@ -304,6 +325,26 @@ func extractServicePorts(gw gateway.Gateway) []corev1.ServicePort {
 			Name: name,
 			Port: int32(l.Port),
 		})
+		if quic && isHTTP3Eligible(gw, l) {
+			// Gateways only serve HTTP/3 on ports exposed over UDP as well.
+			svcPorts = append(svcPorts, corev1.ServicePort{
+				Name:     name + "-quic",
+				Port:     int32(l.Port),
+				Protocol: corev1.ProtocolUDP,
+			})
+		}
 	}
 	return svcPorts
 }
+
+// isHTTP3Eligible checks if the server built for the listener can be served over QUIC as well.
+func isHTTP3Eligible(gw gateway.Gateway, l gateway.Listener) bool {
+	tls, err := buildTLS(l.TLS, gw.Namespace, false)
+	if err != nil {
+		return false
+	}
+	return gatewaycfg.IsEligibleForHTTP3Upgrade(&istio.Server{
+		Port: &istio.Port{Number: uint32(l.Port), Protocol: listenerProtocolToIstio(l.Protocol)},
+		Tls:  tls,
+	})
+}
@@ -22,9 +22,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeVersion "k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 	"sigs.k8s.io/yaml"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/kube"
 	istiolog "istio.io/pkg/log"
@@ -34,6 +37,7 @@ func TestConfigureIstioGateway(t *testing.T) {
 	tests := []struct {
 		name string
 		gw   v1alpha2.Gateway
+		quic bool
 	}{
 		{
 			"simple",
@@ -44,6 +48,7 @@ func TestConfigureIstioGateway(t *testing.T) {
 				},
 				Spec: v1alpha2.GatewaySpec{},
 			},
+			false,
 		},
 		{
 			"manual-ip",
@@ -59,6 +64,7 @@ func TestConfigureIstioGateway(t *testing.T) {
 					}},
 				},
 			},
+			false,
 		},
 		{
 			"cluster-ip",
@@ -75,6 +81,7 @@ func TestConfigureIstioGateway(t *testing.T) {
 					}},
 				},
 			},
+			false,
 		},
 		{
 			"multinetwork",
@@ -91,10 +98,84 @@ func TestConfigureIstioGateway(t *testing.T) {
 					}},
 				},
 			},
+			false,
+		},
+		{
+			"http3",
+			v1alpha2.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default",
+					Namespace: "default",
+				},
+				Spec: v1alpha2.GatewaySpec{
+					Listeners: []v1alpha2.Listener{
+						{
+							Name:     "http",
+							Port:     v1alpha2.PortNumber(80),
+							Protocol: v1alpha2.HTTPProtocolType,
+						},
+						{
+							Name:     "https",
+							Port:     v1alpha2.PortNumber(443),
+							Protocol: v1alpha2.HTTPSProtocolType,
+							TLS: &v1alpha2.GatewayTLSConfig{
+								CertificateRefs: []*v1alpha2.SecretObjectReference{{Name: "cert"}},
+							},
+						},
+						{
+							Name:     "passthrough",
+							Port:     v1alpha2.PortNumber(8443),
+							Protocol: v1alpha2.HTTPSProtocolType,
+							TLS: &v1alpha2.GatewayTLSConfig{
+								Mode: func() *v1alpha2.TLSModeType { x := v1alpha2.TLSModePassthrough; return &x }(),
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		{
+			"http3-disabled",
+			v1alpha2.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default",
+					Namespace: "default",
+				},
+				Spec: v1alpha2.GatewaySpec{
+					Listeners: []v1alpha2.Listener{
+						{
+							Name:     "http",
+							Port:     v1alpha2.PortNumber(80),
+							Protocol: v1alpha2.HTTPProtocolType,
+						},
+						{
+							Name:     "https",
+							Port:     v1alpha2.PortNumber(443),
+							Protocol: v1alpha2.HTTPSProtocolType,
+							TLS: &v1alpha2.GatewayTLSConfig{
+								CertificateRefs: []*v1alpha2.SecretObjectReference{{Name: "cert"}},
+							},
+						},
+						{
+							Name:     "passthrough",
+							Port:     v1alpha2.PortNumber(8443),
+							Protocol: v1alpha2.HTTPSProtocolType,
+							TLS: &v1alpha2.GatewayTLSConfig{
+								Mode: func() *v1alpha2.TLSModeType { x := v1alpha2.TLSModePassthrough; return &x }(),
+							},
+						},
+					},
+				},
+			},
+			false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			old := features.EnableQUICListeners
+			features.EnableQUICListeners = tt.quic
+			t.Cleanup(func() { features.EnableQUICListeners = old })
 			buf := &bytes.Buffer{}
 			d := &DeploymentController{
 				client:    kube.NewFakeClient(),
@@ -119,3 +200,23 @@ func TestConfigureIstioGateway(t *testing.T) {
 		})
 	}
 }
+
+func TestSupportsMixedProtocol(t *testing.T) {
+	gw := func(serviceType string) v1alpha2.Gateway {
+		return v1alpha2.Gateway{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{serviceTypeAnnotation: serviceType}}}
+	}
+	client := kube.NewFakeClient()
+	client.Kube().Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &kubeVersion.Info{Major: "1", Minor: "23"}
+	if supportsMixedProtocol(gw(""), client) || supportsMixedProtocol(gw(string(corev1.ServiceTypeLoadBalancer)), client) {
+		t.Fatalf("expected LoadBalancer services not to support mixed protocols before Kubernetes 1.24")
+	}
+	if !supportsMixedProtocol(gw(string(corev1.ServiceTypeClusterIP)), client) {
+		t.Fatalf("expected ClusterIP services to support mixed protocols")
+	}
+
+	client = kube.NewFakeClient()
+	client.Kube().Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &kubeVersion.Info{Major: "1", Minor: "24"}
+	if !supportsMixedProtocol(gw(""), client) {
+		t.Fatalf("expected LoadBalancer services to support mixed protocols since Kubernetes 1.24")
+	}
+}
@@ -72,6 +72,14 @@ var (
 		"Limits the number of concurrent pushes allowed. On larger machines this can be increased for faster pushes",
 	).Get()
 
+	PushClassWeights = env.RegisterStringVar(
+		"PILOT_PUSH_CLASS_WEIGHTS",
+		"",
+		"Relative share of pushes given to each class of proxy while the push queue is backed up, in the form "+
+			"gateway=8,proxyless=2,sidecar=1 (the default). A workload can select its class explicitly "+
+			"with the istio.io/push-class label on its pod or namespace.",
+	).Get()
+
 	RequestLimit = env.RegisterFloatVar(
 		"PILOT_MAX_REQUESTS_PER_SECOND",
 		25.0,
@@ -98,6 +106,20 @@ var (
 			"for this time, we'll trigger a push.",
 	).Get()
 
+	EnableAdaptiveDebounce = env.RegisterBoolVar(
+		"PILOT_ENABLE_ADAPTIVE_DEBOUNCE",
+		false,
+		"If enabled, Pilot will tune the debounce quiet period between PILOT_DEBOUNCE_AFTER and PILOT_DEBOUNCE_MAX "+
+			"based on the observed event rate, push duration and push queue depth, rather than always using PILOT_DEBOUNCE_AFTER.",
+	).Get()
+
+	AdaptiveDebounceQueueScale = env.RegisterIntVar(
+		"PILOT_ADAPTIVE_DEBOUNCE_QUEUE_SCALE",
+		100,
+		"The push queue depth at which the adaptive debounce quiet period is doubled, up to PILOT_DEBOUNCE_MAX. "+
+			"Only used if PILOT_ENABLE_ADAPTIVE_DEBOUNCE is enabled.",
+	).Get()
+
 	EnableEDSDebounce = env.RegisterBoolVar(
 		"PILOT_ENABLE_EDS_DEBOUNCE",
 		true,
@@ -134,6 +156,34 @@ var (
 		"EnableRedisFilter enables injection of `envoy.filters.network.redis_proxy` in the filter chain.",
 	).Get()
 
+	// EnableKafkaFilter enables injection of `envoy.filters.network.kafka_broker` in the filter chain.
+	// Pilot injects this filter if the service port protocol is `kafka`.
+	EnableKafkaFilter = env.RegisterBoolVar(
+		"PILOT_ENABLE_KAFKA_FILTER",
+		false,
+		"EnableKafkaFilter enables injection of `envoy.filters.network.kafka_broker` in the filter chain.",
+	).Get()
+
+	// EnableDubboFilter enables injection of `envoy.filters.network.dubbo_proxy` in the filter chain.
+	// Pilot injects this filter if the service port protocol is `dubbo`.
+	EnableDubboFilter = env.RegisterBoolVar(
+		"PILOT_ENABLE_DUBBO_FILTER",
+		false,
+		"EnableDubboFilter enables injection of `envoy.filters.network.dubbo_proxy` in the filter chain. "+
+			"Calls are routed to the destinations of the TCP route, or to a subset of its first destination for the "+
+			"service methods listed in the networking.istio.io/rpcMethodRoutes annotation of the VirtualService.",
+	).Get()
+
+	// EnableThriftFilter enables injection of `envoy.filters.network.thrift_proxy` in the filter chain.
+	// Pilot injects this filter if the service port protocol is `thrift`.
+	EnableThriftFilter = env.RegisterBoolVar(
+		"PILOT_ENABLE_THRIFT_FILTER",
+		false,
+		"EnableThriftFilter enables injection of `envoy.filters.network.thrift_proxy` in the filter chain. "+
+			"Calls are routed to the destinations of the TCP route, or to a subset of its first destination for the "+
+			"service methods listed in the networking.istio.io/rpcMethodRoutes annotation of the VirtualService.",
+	).Get()
+
 	// EnableMongoFilter enables injection of `envoy.filters.network.mongo_proxy` in the filter chain.
 	EnableMongoFilter = env.RegisterBoolVar(
 		"PILOT_ENABLE_MONGO_FILTER",
@@ -291,6 +341,41 @@ var (
 			"default, ROUND_ROBIN. Care should be taken when using ROUND_ROBIN in general as it can "+
 			"overburden endpoints, especially when weights are used.").Get()
 
+	LeastRequestActiveRequestBias = env.RegisterFloatVar(
+		"PILOT_LEAST_REQUEST_ACTIVE_REQUEST_BIAS",
+		1.0,
+		"The active request bias of LEAST_REQUEST clusters, which only applies when the endpoints have different "+
+			"weights. Higher values favor the endpoints with fewer active requests over the endpoint weights, "+
+			"while 0 picks the endpoints by weight only. DestinationRules can override it with the "+
+			"networking.istio.io/activeRequestBias annotation.").Get()
+
+	EnableHealthAwareFailover = env.RegisterBoolVar(
+		"PILOT_ENABLE_HEALTH_AWARE_FAILOVER",
+		false,
+		"If enabled, locality failover also takes into account the aggregated health of the endpoints of each "+
+			"region, across clusters: the localities of the regions where the share of healthy endpoints, "+
+			"according to the registries and the outlier ejections reported by the agents of the proxies, drops "+
+			"below PILOT_HEALTH_AWARE_FAILOVER_MIN_HEALTHY_PERCENT get a lower priority than all the healthy ones. "+
+			"The unready Kubernetes endpoints are only known when PILOT_SEND_UNHEALTHY_ENDPOINTS is enabled.").Get()
+
+	HealthAwareFailoverMinHealthyPercent = env.RegisterIntVar(
+		"PILOT_HEALTH_AWARE_FAILOVER_MIN_HEALTHY_PERCENT",
+		50,
+		"The share of healthy endpoints, in percent, below which a region is considered degraded by "+
+			"health-aware failover.").Get()
+
+	HealthAwareFailoverMinReporters = env.RegisterIntVar(
+		"PILOT_HEALTH_AWARE_FAILOVER_MIN_REPORTERS",
+		2,
+		"The number of proxies that must report an endpoint as ejected by their outlier detection before "+
+			"health-aware failover counts it as unhealthy, so that a single proxy can not degrade a region.").Get()
+
+	HealthAwareFailoverReportInterval = env.RegisterDurationVar(
+		"PILOT_HEALTH_AWARE_FAILOVER_REPORT_INTERVAL",
+		5*time.Second,
+		"How often the agents read the outlier detection state of their proxy and report the ejected endpoints "+
+			"to health-aware failover, when they changed.").Get()
+
 	EnableAnalysis = env.RegisterBoolVar(
 		"PILOT_ENABLE_ANALYSIS",
 		false,
@@ -427,6 +512,13 @@ var (
 	XDSCacheMaxSize = env.RegisterIntVar("PILOT_XDS_CACHE_SIZE", 60000,
 		"The maximum number of cache entries for the XDS cache.").Get()
 
+	XDSCacheSnapshotPath = env.RegisterStringVar("PILOT_XDS_CACHE_SNAPSHOT_PATH", "",
+		"If set, Pilot will periodically persist the XDS cache to this file and restore it on startup, "+
+			"discarding the snapshot if it does not match the current configuration. Note: this depends on PILOT_ENABLE_XDS_CACHE.").Get()
+
+	XDSCacheSnapshotInterval = env.RegisterDurationVar("PILOT_XDS_CACHE_SNAPSHOT_INTERVAL", 5*time.Minute,
+		"The interval at which the XDS cache is persisted to PILOT_XDS_CACHE_SNAPSHOT_PATH.").Get()
+
 	// EnableLegacyFSGroupInjection has first-party-jwt as allowed because we only
 	// need the fsGroup configuration for the projected service account volume mount,
 	// which is only used by first-party-jwt. The installer will automatically
@@ -482,6 +574,45 @@ var (
 			"environments with high rates of push requests to each gateway. By default,"+
 			"this is false.").Get()
 
+	ConnectionPushQPS = env.RegisterFloatVar(
+		"PILOT_CONNECTION_PUSH_QPS",
+		0,
+		"Limits the number of pushes per second sent to a single proxy, across all types. Pushes over the limit "+
+			"are merged and sent once allowed, so the proxy always receives the latest config. Zero means unlimited.",
+	).Get()
+
+	ConnectionPushTypeQPS = env.RegisterStringVar(
+		"PILOT_CONNECTION_PUSH_TYPE_QPS",
+		"",
+		"Limits the number of pushes per second sent to a single proxy for each type, in the form eds=1,cds=0.5. "+
+			"Types that are not specified are unlimited.",
+	).Get()
+
+	ConnectionPushBurst = env.RegisterIntVar(
+		"PILOT_CONNECTION_PUSH_BURST",
+		10,
+		"The number of pushes that can be sent to a single proxy at once, overall and for each type, when "+
+			"PILOT_CONNECTION_PUSH_QPS or PILOT_CONNECTION_PUSH_TYPE_QPS are set.",
+	).Get()
+
+	ShardCount = env.RegisterIntVar(
+		"PILOT_SHARD_COUNT",
+		0,
+		"If set to 2 or more, the replicas of a revision split the proxies of the mesh into this many shards, and "+
+			"each replica only serves the proxies of the shards it owns, as coordinated by leader election. Proxies "+
+			"connecting through an istio-agent to a replica that does not own their shard are redirected to the "+
+			"replica that does; other clients, such as proxyless gRPC, are served by any replica. Every replica "+
+			"still watches all the config, but when sharding by namespace it only computes the sidecar scopes of "+
+			"the namespaces it serves. Requires Kubernetes.",
+	).Get()
+
+	ShardBy = env.RegisterStringVar(
+		"PILOT_SHARD_BY",
+		"namespace",
+		"The proxy property used to assign it to a shard when PILOT_SHARD_COUNT is set: "+
+			"namespace, or revision to use the revision tag the proxy was injected with.",
+	).Get()
+
 	FlowControlTimeout = env.RegisterDurationVar(
 		"PILOT_FLOW_CONTROL_TIMEOUT",
 		15*time.Second,
@@ -494,6 +625,13 @@ var (
 		"If set, workload specific DestinationRules will inherit configurations settings from mesh and namespace level rules",
 	).Get()
 
+	EnableIncrementalPushContext = env.RegisterBoolVar(
+		"PILOT_ENABLE_INCREMENTAL_PUSH_CONTEXT",
+		false,
+		"If enabled, pilot will only recompute the indexes of the namespaces affected by a config update "+
+			"when building a new PushContext, reusing the rest from the previous one.",
+	).Get()
+
 	WasmRemoteLoadConversion = env.RegisterBoolVar("ISTIO_AGENT_ENABLE_WASM_REMOTE_LOAD_CONVERSION", true,
 		"If enabled, Istio agent will intercept ECDS resource update, downloads Wasm module, "+
 			"and replaces Wasm module remote load with downloaded local module file.").Get()
@@ -531,6 +669,19 @@ var (
 		"If enabled, pilot will only send the delta configs as opposed to the state of the world on a "+
 			"Resource Request. This feature uses the delta xds api, but does not currently send the actual deltas.").Get()
 
+	EnableOnDemandXds = env.RegisterBoolVar("PILOT_ENABLE_ON_DEMAND_XDS", false,
+		"If enabled, sidecars that set the ENABLE_ON_DEMAND_XDS metadata will only receive the virtual hosts "+
+			"(VHDS) and clusters (ODCDS) of the HTTP hosts they call, as they are requested. The clusters of the "+
+			"non-HTTP ports are always sent. This requires delta xDS.").Get()
+
+	EnablePushTracing = env.RegisterBoolVar("PILOT_ENABLE_PUSH_TRACING", false,
+		"If enabled, pilot will trace each config push from the event that triggered it to the ACK of every proxy. "+
+			"The most recent traces are available at /debug/pushtrace.").Get()
+
+	PushTracingOTLPEndpoint = env.RegisterStringVar("PILOT_PUSH_TRACING_OTLP_ENDPOINT", "",
+		"If set along with PILOT_ENABLE_PUSH_TRACING, the OTLP/HTTP traces endpoint of an OpenTelemetry collector, "+
+			"such as http://otel-collector:4318/v1/traces, that push traces are exported to.").Get()
+
 	EnableLegacyIstioMutualCredentialName = env.RegisterBoolVar("PILOT_ENABLE_LEGACY_ISTIO_MUTUAL_CREDENTIAL_NAME",
 		false,
 		"If enabled, Gateway's with ISTIO_MUTUAL mode and credentialName configured will use simple TLS. "+
@@ -576,7 +727,37 @@ var (
 
 	EnableQUICListeners = env.RegisterBoolVar("PILOT_ENABLE_QUIC_LISTENERS", false,
 		"If true, QUIC listeners will be generated wherever there are listeners terminating TLS on gateways "+
-			"if the gateway service exposes a UDP port with the same number (for example 443/TCP and 443/UDP)").Get()
+			"if the gateway service exposes a UDP port with the same number (for example 443/TCP and 443/UDP). "+
+			"Gateways deployed for Kubernetes Gateway resources expose the UDP port of their HTTPS listeners automatically. "+
+			"For LoadBalancer Services, this requires the MixedProtocolLBService feature of Kubernetes 1.24+, and a load "+
+			"balancer supporting it.").Get()
+
+	EnableUDPListeners = env.RegisterBoolVar("PILOT_ENABLE_UDP_LISTENERS", false,
+		"If true, udp_proxy listeners will be generated for the UDP service ports of Sidecar egress listeners, and for "+
+			"UDP gateway servers, routing datagrams to the destinations of the matching VirtualService tcp routes. "+
+			"As UDP traffic is not captured, Sidecar egress listeners must set a bind address, or not capture traffic. "+
+			"Weighted routes are served by a cluster merging the endpoints of their destinations, which must not be "+
+			"resolved through DNS. ServiceEntries may also declare a port number once for UDP and once for another "+
+			"protocol.").Get()
+
+	EnableInternalListeners = env.RegisterBoolVar("PILOT_ENABLE_INTERNAL_LISTENERS", false,
+		"If true, Gateway servers and Sidecar egress listeners bound to envoy://<name> will be generated as Envoy "+
+			"internal listeners, and ServiceEntry endpoints with an envoy://<name> address will send traffic to "+
+			"the internal listener of the same proxy, allowing servers to be chained. The proxies must be set with "+
+			"the same variable, through proxyMetadata, to load the internal listener bootstrap extension, and "+
+			"internal listeners are only sent to the proxies whose agent advertises it in the node metadata.").Get()
+
+	EnableRateLimit = env.RegisterBoolVar("PILOT_ENABLE_RATE_LIMIT", false,
+		"If true, the rate limit filters will be added to HTTP connection managers, and the rate limit policies set "+
+			"with the networking.istio.io/rateLimit annotation of VirtualServices will be applied to their routes.").Get()
+
+	ExtensionProviders = env.RegisterStringVar("PILOT_EXTENSION_PROVIDERS", "",
+		"A YAML list of extension providers, in the format of MeshConfig.extensionProviders, for the provider types "+
+			"MeshConfig does not support yet: envoyRateLimit, envoyExtProc and opentelemetry. The opentelemetry tracer is "+
+			"only configured on 1.15+ proxies, and on 1.21+ proxies with the http protocol. It is read when istiod "+
+			"starts, and only used when the extensionProviders key of the mesh ConfigMap, which has the same format "+
+			"and is watched for changes, is not set. The providers with the name of a MeshConfig extension provider are "+
+			"rejected.").Get()
 
 	VerifyCertAtClient = env.RegisterBoolVar("VERIFY_CERTIFICATE_AT_CLIENT", false,
 		"If enabled, certificates received by the proxy will be verified against the OS CA certificate bundle.").Get()
@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
@@ -115,3 +116,38 @@ func IsExpectedGRPCError(err error) bool {
 
 	return false
 }
+
+// redirectReason is the reason of the errors telling a client to reconnect to another server.
+const redirectReason = "REDIRECT"
+
+// RedirectError returns an Unavailable error telling the client to reconnect to the given host, on the same port.
+func RedirectError(host string, format string, args ...interface{}) error {
+	s := status.Newf(codes.Unavailable, format, args...)
+	if host == "" {
+		return s.Err()
+	}
+	rs, err := s.WithDetails(&errdetails.ErrorInfo{
+		Reason:   redirectReason,
+		Domain:   "istio.io",
+		Metadata: map[string]string{"host": host},
+	})
+	if err != nil {
+		return s.Err()
+	}
+	return rs.Err()
+}
+
+// RedirectHost returns the host an error returned by RedirectError tells the client to reconnect to, or an empty
+// string for other errors.
+func RedirectHost(err error) string {
+	s, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Reason == redirectReason && info.Domain == "istio.io" {
+			return info.Metadata["host"]
+		}
+	}
+	return ""
+}
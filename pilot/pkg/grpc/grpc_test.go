@@ -17,6 +17,9 @@ package grpc
 import (
 	"errors"
 	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestIsExpectedGRPCError(t *testing.T) {
@@ -25,3 +28,19 @@ func TestIsExpectedGRPCError(t *testing.T) {
 		t.Fatalf("expected true, got %v", got)
 	}
 }
+
+func TestRedirect(t *testing.T) {
+	err := RedirectError("10.0.0.2", "proxy %s is served by another istiod", "app.default")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	if got := RedirectHost(err); got != "10.0.0.2" {
+		t.Fatalf("expected redirect to 10.0.0.2, got %q", got)
+	}
+	if got := RedirectHost(RedirectError("", "no owner")); got != "" {
+		t.Fatalf("expected no redirect without a host, got %q", got)
+	}
+	if got := RedirectHost(errors.New("other")); got != "" {
+		t.Fatalf("expected no redirect for other errors, got %q", got)
+	}
+}
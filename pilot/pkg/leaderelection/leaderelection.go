@@ -62,6 +62,11 @@ type LeaderElection struct {
 	revision       string
 	prioritized    bool
 	defaultWatcher revisions.DefaultWatcher
+	// key, if set, is recorded on the lock instead of the revision.
+	key string
+	// evicts, if set, decides whether to steal the lock from a leader holding the given key. It takes
+	// precedence over the revision based prioritization.
+	evicts func(currentKey string) bool
 
 	// Records which "cycle" the election is on. This is incremented each time an election is won and then lost
 	// This is mostly just for testing
@@ -119,12 +124,16 @@ func (l *LeaderElection) create() (*k8sleaderelection.LeaderElector, error) {
 			log.Infof("leader election lock lost: %v", l.electionID)
 		},
 	}
+	key := l.revision
+	if l.key != "" {
+		key = l.key
+	}
 	lock := k8sresourcelock.ConfigMapLock{
 		ConfigMapMeta: metaV1.ObjectMeta{Namespace: l.namespace, Name: l.electionID},
 		Client:        l.client.CoreV1(),
 		LockConfig: k8sresourcelock.ResourceLockConfig{
 			Identity: l.name,
-			Key:      l.revision,
+			Key:      key,
 		},
 	}
 	config := k8sleaderelection.LeaderElectionConfig{
@@ -139,7 +148,9 @@ func (l *LeaderElection) create() (*k8sleaderelection.LeaderElector, error) {
 		ReleaseOnCancel: true,
 	}
 
-	if l.prioritized {
+	if l.evicts != nil {
+		config.KeyComparison = l.evicts
+	} else if l.prioritized {
 		// Function to use to decide whether this revision should steal the existing lock.
 		config.KeyComparison = func(currentLeaderRevision string) bool {
 			defaultRevision := l.defaultWatcher.GetDefault()
@@ -183,6 +194,16 @@ func NewLeaderElection(namespace, name, electionID, revision string, client kube
 	}
 }
 
+// leader returns the identity of the last observed leader, or an empty string if unknown.
+func (l *LeaderElection) leader() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.le == nil {
+		return ""
+	}
+	return l.le.GetLeader()
+}
+
 func (l *LeaderElection) isLeader() bool {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
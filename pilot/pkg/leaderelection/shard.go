@@ -0,0 +1,195 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/pkg/log"
+)
+
+// ShardController is the prefix of the locks used to elect the owner of each proxy shard.
+const ShardController = "istio-shard-leader"
+
+// shardStagger is the delay between the campaigns of a replica for consecutive shards. It only needs to give the
+// preferred replica of a shard a head start, and is kept well below the lock TTL so that shards without a
+// preferred replica are not left unowned for long.
+const shardStagger = 2 * time.Second
+
+// The keys recorded on the lock of a shard, depending on whether its holder prefers it.
+const (
+	shardPreferredKey = "preferred"
+	shardFallbackKey  = "fallback"
+)
+
+// ShardElection elects the owner of each of the proxy shards of a revision. Every replica runs an election per
+// shard, but campaigns for its preferred shard, derived from its name, right away, and for the other shards only
+// after a short delay that grows with their distance from the preferred one. Shards left without an owner are
+// thus taken over by the other replicas within a few seconds, and handed back as soon as one of the replicas
+// preferring them is up: these replicas steal the lock of their preferred shard from any replica not preferring
+// it. As long as the replicas prefer distinct shards, each of them ends up owning its preferred shard only.
+type ShardElection struct {
+	elections []*LeaderElection
+	preferred int
+	// stagger is the delay before campaigning for the next shard.
+	stagger time.Duration
+
+	mu       sync.RWMutex
+	owned    map[int]bool
+	handlers []func(shard int, owned bool)
+}
+
+// NewShardElection creates the elections for the given number of shards.
+func NewShardElection(namespace, name, revision string, shards int, client kube.Client) *ShardElection {
+	if name == "" {
+		hn, _ := os.Hostname()
+		name = fmt.Sprintf("unknown-%s", hn)
+	}
+	return newShardElection(namespace, name, revision, shards, client, time.Second*30, shardStagger)
+}
+
+func newShardElection(namespace, name, revision string, shards int, client kubernetes.Interface,
+	ttl, stagger time.Duration) *ShardElection {
+	se := &ShardElection{
+		owned:     map[int]bool{},
+		preferred: PreferredShard(name, shards),
+		stagger:   stagger,
+	}
+	for i := 0; i < shards; i++ {
+		shard := i
+		// Shards are owned per revision, so the elections are never prioritized: a revision must not take over
+		// the shards of another one.
+		le := &LeaderElection{
+			namespace:  namespace,
+			name:       name,
+			client:     client,
+			electionID: ShardLockName(revision, i),
+			revision:   revision,
+			key:        shardFallbackKey,
+			ttl:        ttl,
+			cycle:      atomic.NewInt32(0),
+		}
+		if i == se.preferred {
+			// The replica losing the shard only notices on its next renewal, so both replicas may serve the shard
+			// for up to a retry period. This only duplicates work.
+			le.key = shardPreferredKey
+			le.evicts = func(currentKey string) bool {
+				return currentKey != shardPreferredKey
+			}
+		}
+		le.AddRunFunction(func(stop <-chan struct{}) {
+			se.setOwned(shard, true)
+			<-stop
+			se.setOwned(shard, false)
+		})
+		se.elections = append(se.elections, le)
+	}
+	return se
+}
+
+// ShardLockName returns the name of the lock of a shard.
+func ShardLockName(revision string, shard int) string {
+	if revision == "" {
+		revision = "default"
+	}
+	return fmt.Sprintf("%s-%s-%d", ShardController, revision, shard)
+}
+
+// PreferredShard returns the shard a replica campaigns for first.
+func PreferredShard(name string, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Run runs the elections until the stop channel is closed.
+func (se *ShardElection) Run(stop <-chan struct{}) {
+	n := len(se.elections)
+	for i := 0; i < n; i++ {
+		shard := (se.preferred + i) % n
+		delay := time.Duration(i) * se.stagger
+		go func() {
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-stop:
+					return
+				}
+			}
+			se.elections[shard].Run(stop)
+		}()
+	}
+	<-stop
+}
+
+// Owns checks if this replica currently owns the shard.
+func (se *ShardElection) Owns(shard int) bool {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.owned[shard]
+}
+
+// Owned returns the shards this replica currently owns.
+func (se *ShardElection) Owned() []int {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	out := make([]int, 0, len(se.owned))
+	for i := range se.elections {
+		if se.owned[i] {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Owner returns the name of the replica owning the shard, as last observed on its lock, or an empty string if
+// unknown. Unlike Owns, this may be stale by up to the retry period of the election.
+func (se *ShardElection) Owner(shard int) string {
+	if shard < 0 || shard >= len(se.elections) {
+		return ""
+	}
+	return se.elections[shard].leader()
+}
+
+// AddHandler registers a handler called whenever this replica gains or loses a shard. Handlers must be added
+// before Run is called.
+func (se *ShardElection) AddHandler(h func(shard int, owned bool)) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.handlers = append(se.handlers, h)
+}
+
+func (se *ShardElection) setOwned(shard int, owned bool) {
+	se.mu.Lock()
+	if owned {
+		se.owned[shard] = true
+	} else {
+		delete(se.owned, shard)
+	}
+	handlers := se.handlers
+	se.mu.Unlock()
+	log.Infof("shard %d ownership: %v", shard, owned)
+	for _, h := range handlers {
+		h(shard, owned)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// replicaForShard returns a replica name that prefers the given shard.
+func replicaForShard(t *testing.T, shard, shards int) string {
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("istiod-%d", i)
+		if PreferredShard(name, shards) == shard {
+			return name
+		}
+	}
+	t.Fatalf("no replica name prefers shard %d", shard)
+	return ""
+}
+
+func TestShardElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	stagger := time.Second
+
+	first := newShardElection("ns", replicaForShard(t, 0, 2), "", 2, client, stagger, stagger)
+	second := newShardElection("ns", replicaForShard(t, 1, 2), "", 2, client, stagger, stagger)
+	changes := make(chan int, 10)
+	second.AddHandler(func(shard int, owned bool) {
+		if owned {
+			changes <- shard
+		}
+	})
+	stop1 := make(chan struct{})
+	stop2 := make(chan struct{})
+	defer close(stop2)
+	go first.Run(stop1)
+	go second.Run(stop2)
+
+	// Each replica gets its preferred shard.
+	expectOwned := func(se *ShardElection, want []int) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := se.Owned(); !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("expected shards %v, got %v", want, got)
+			}
+			return nil
+		}, retry.Converge(3), retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*10))
+	}
+	expectOwned(first, []int{0})
+	expectOwned(second, []int{1})
+	if !first.Owns(0) || first.Owns(1) {
+		t.Fatalf("unexpected ownership %v", first.Owned())
+	}
+	// Each replica knows the owner of the other shard.
+	retry.UntilSuccessOrFail(t, func() error {
+		if got, want := second.Owner(0), replicaForShard(t, 0, 2); got != want {
+			return fmt.Errorf("expected shard 0 to be owned by %s, got %q", want, got)
+		}
+		return nil
+	}, retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*10))
+	if got := first.Owner(2); got != "" {
+		t.Fatalf("expected no owner for an unknown shard, got %q", got)
+	}
+
+	// Once the first replica is gone, its shard is taken over.
+	close(stop1)
+	expectOwned(second, []int{0, 1})
+	if got := []int{<-changes, <-changes}; !reflect.DeepEqual(got, []int{1, 0}) {
+		t.Fatalf("expected handler to be notified of shards 1 then 0, got %v", got)
+	}
+}
+
+func TestShardElectionOrphanedShard(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// A single replica for 3 shards: the shards it does not prefer are taken over well before the lock TTL.
+	se := newShardElection("ns", replicaForShard(t, 0, 3), "", 3, client, time.Minute, time.Millisecond*100)
+	stop := make(chan struct{})
+	defer close(stop)
+	go se.Run(stop)
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := se.Owned(); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+			return fmt.Errorf("expected all shards, got %v", got)
+		}
+		return nil
+	}, retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*10))
+}
+
+func TestShardElectionSpread(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	shards := 3
+	replicas := make([]*ShardElection, 0, shards)
+	for i := 0; i < shards; i++ {
+		replicas = append(replicas, newShardElection("ns", replicaForShard(t, i, shards), "", shards, client,
+			time.Second, time.Millisecond*100))
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	expectOwned := func(se *ShardElection, want []int) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := se.Owned(); !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("expected shards %v, got %v", want, got)
+			}
+			return nil
+		}, retry.Converge(3), retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*10))
+	}
+
+	// The first replica takes over all the shards while it is alone.
+	go replicas[0].Run(stop)
+	expectOwned(replicas[0], []int{0, 1, 2})
+
+	// The other replicas get their preferred shard back, so the shards end up spread across all of them.
+	for _, se := range replicas[1:] {
+		go se.Run(stop)
+	}
+	for i, se := range replicas {
+		expectOwned(se, []int{i})
+	}
+}
+
+func TestShardLockName(t *testing.T) {
+	if got := ShardLockName("", 1); got != "istio-shard-leader-default-1" {
+		t.Fatalf("unexpected lock name %v", got)
+	}
+	if got := ShardLockName("canary", 0); got != "istio-shard-leader-canary-0" {
+		t.Fatalf("unexpected lock name %v", got)
+	}
+}
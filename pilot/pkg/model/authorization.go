@@ -16,6 +16,7 @@ package model
 
 import (
 	authpb "istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/collections"
 	istiolog "istio.io/pkg/log"
@@ -64,6 +65,42 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 	return policy, nil
 }
 
+// UpdateAuthorizationPolicies returns the AuthorizationPolicies for the given environment, reusing the
+// policies of old for all namespaces except the given ones.
+func UpdateAuthorizationPolicies(env *Environment, old *AuthorizationPolicies, namespaces sets.Set) (*AuthorizationPolicies, error) {
+	if old == nil || old.RootNamespace != env.Mesh().GetRootNamespace() {
+		return GetAuthorizationPolicies(env)
+	}
+	policy := &AuthorizationPolicies{
+		NamespaceToPolicies: make(map[string][]AuthorizationPolicy, len(old.NamespaceToPolicies)),
+		RootNamespace:       old.RootNamespace,
+	}
+	for ns, policies := range old.NamespaceToPolicies {
+		if !namespaces.Contains(ns) {
+			policy.NamespaceToPolicies[ns] = policies
+		}
+	}
+
+	for ns := range namespaces {
+		policies, err := env.List(collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind(), ns)
+		if err != nil {
+			return nil, err
+		}
+		sortConfigByCreationTime(policies)
+		for _, config := range policies {
+			authzConfig := AuthorizationPolicy{
+				Name:        config.Name,
+				Namespace:   config.Namespace,
+				Annotations: config.Annotations,
+				Spec:        config.Spec.(*authpb.AuthorizationPolicy),
+			}
+			policy.NamespaceToPolicies[config.Namespace] = append(policy.NamespaceToPolicies[config.Namespace], authzConfig)
+		}
+	}
+
+	return policy, nil
+}
+
 type AuthorizationPoliciesResult struct {
 	Custom []AuthorizationPolicy
 	Deny   []AuthorizationPolicy
@@ -24,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -35,7 +36,9 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/trustbundle"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/constants"
@@ -91,6 +94,14 @@ type Environment struct {
 	clusterLocalServices ClusterLocalProvider
 
 	GatewayAPIController GatewayController
+
+	// ServesNamespace, if set, tells whether this istiod serves the proxies of a namespace. The sidecar scopes of
+	// the other namespaces are only computed if one of their proxies connects anyway.
+	ServesNamespace func(namespace string) bool
+
+	// extensionProviders holds the current []*ExtensionProvider. They are replaced as a whole, so reading them
+	// takes no lock.
+	extensionProviders atomic.Value
 }
 
 func (e *Environment) Mesh() *meshconfig.MeshConfig {
@@ -303,6 +314,9 @@ type Proxy struct {
 	// XdsNode is the xDS node identifier
 	XdsNode *core.Node
 
+	// DeltaXds is true if the proxy is connected over delta xDS.
+	DeltaXds bool
+
 	CatchAllVirtualHost *route.VirtualHost
 
 	AutoregisteredWorkloadEntryName string
@@ -336,6 +350,9 @@ type WatchedResource struct {
 
 	// LastSent tracks the time of the generated push, to determine the time it takes the client to ack.
 	LastSent time.Time
+
+	// LastTraces holds the push traces of the last sent response, to record when it is acknowledged.
+	LastTraces []pushtrace.SpanContext
 }
 
 var istioVersionRegexp = regexp.MustCompile(`^([1-9]+)\.([0-9]+)(\.([0-9]+))?`)
@@ -613,6 +630,18 @@ type NodeMetadata struct {
 	// redirected tcp listeners. This does not change the virtualOutbound listener.
 	OutboundListenerExactBalance StringBool `json:"OUTBOUND_LISTENER_EXACT_BALANCE,omitempty"`
 
+	// OnDemandXds, if set, requests that virtual hosts and clusters are discovered on demand through
+	// VHDS and ODCDS rather than sent upfront. This is only supported for sidecars using delta xDS.
+	OnDemandXds StringBool `json:"ENABLE_ON_DEMAND_XDS,omitempty"`
+
+	// XdsRedirect is set by the istio-agent, which follows the redirections of istiod to the replica serving
+	// the proxy. Istiod serves the other clients even if it does not own their shard.
+	XdsRedirect StringBool `json:"XDS_REDIRECT,omitempty"`
+
+	// InternalListeners is set by the istio-agent when the proxy loaded the internal listener bootstrap extension,
+	// which Envoy requires to accept internal listeners and the endpoints addressing them.
+	InternalListeners StringBool `json:"INTERNAL_LISTENERS,omitempty"`
+
 	// Contains a copy of the raw metadata. This is needed to lookup arbitrary values.
 	// If a value is known ahead of time it should be added to the struct rather than reading from here,
 	Raw map[string]interface{} `json:"-"`
@@ -1110,6 +1139,13 @@ func (node *Proxy) IsProxylessGrpc() bool {
 	return node.Metadata != nil && node.Metadata.Generator == "grpc"
 }
 
+// OnDemandXds returns true if virtual hosts and clusters should be discovered on demand by the proxy.
+// On demand discovery relies on delta xDS to subscribe to individual virtual hosts and clusters.
+func (node *Proxy) OnDemandXds() bool {
+	return features.EnableOnDemandXds && node.Type == SidecarProxy && node.DeltaXds &&
+		node.Metadata != nil && bool(node.Metadata.OnDemandXds)
+}
+
 type GatewayController interface {
 	ConfigStoreCache
 	// Recompute updates the internal state of the gateway controller for a given input. This should be
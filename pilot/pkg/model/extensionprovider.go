@@ -0,0 +1,466 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config/extproc"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/validation"
+)
+
+// maxRateLimitProviders bounds the number of rate limit filters added to each HTTP connection manager.
+const maxRateLimitProviders = 10
+
+// ExtensionProvider is an extension provider of a type MeshConfig does not support yet. They use the layout of
+// MeshConfig.extensionProviders, and share its names, so that they can move there once MeshConfig supports their
+// types. They are set with the extensionProviders key of the mesh ConfigMap, which is watched like the mesh config,
+// or with PILOT_EXTENSION_PROVIDERS when the key is not set.
+type ExtensionProvider struct {
+	Name string `json:"name"`
+
+	EnvoyRateLimit *EnvoyRateLimitProvider `json:"envoyRateLimit,omitempty"`
+	EnvoyExtProc   *EnvoyExtProcProvider   `json:"envoyExtProc,omitempty"`
+	OpenTelemetry  *OpenTelemetryProvider  `json:"opentelemetry,omitempty"`
+}
+
+// EnvoyRateLimitProvider is a rate limit service implementing the Envoy rate limit gRPC API.
+type EnvoyRateLimitProvider struct {
+	// Service is the rate limit service, in the format of [<Namespace>/]<Hostname>.
+	Service string `json:"service"`
+	// Port is the port of the rate limit service.
+	Port uint32 `json:"port"`
+	// Domain is the rate limit domain sent to the service.
+	Domain string `json:"domain"`
+	// Timeout is the timeout of the calls to the service. Defaults to 20ms.
+	Timeout string `json:"timeout,omitempty"`
+	// FailOpen allows the requests when the service can not be reached.
+	FailOpen bool `json:"failOpen,omitempty"`
+}
+
+// EnvoyExtProcProvider is an external processor implementing the Envoy ext_proc gRPC API. It can mutate the
+// headers and bodies of the requests and responses of the workloads it selects.
+type EnvoyExtProcProvider struct {
+	// Service is the external processor, in the format of [<Namespace>/]<Hostname>.
+	Service string `json:"service"`
+	// Port is the port of the external processor.
+	Port uint32 `json:"port"`
+	// Timeout is the timeout of each message exchanged with the processor. Defaults to 200ms.
+	Timeout string `json:"timeout,omitempty"`
+	// FailOpen continues the processing of the requests when the processor can not be reached.
+	FailOpen bool `json:"failOpen,omitempty"`
+	// ProcessingMode selects the parts of the requests and responses sent to the processor.
+	ProcessingMode *extproc.ProcessingMode `json:"processingMode,omitempty"`
+	// Workloads select the workloads the processor is attached to, on their inbound and gateway listeners. The
+	// processor is not attached to any workload when empty.
+	Workloads []*ExtProcWorkloads `json:"workloads"`
+}
+
+// ExtProcWorkloads selects the workloads an external processor is attached to.
+type ExtProcWorkloads struct {
+	// Namespace is the namespace of the workloads. The workloads of all the namespaces are selected when empty.
+	Namespace string `json:"namespace,omitempty"`
+	// MatchLabels are the labels of the workloads. All the workloads of the namespace are selected when empty.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+const (
+	// OpenTelemetryProtocolGRPC exports with OTLP/gRPC.
+	OpenTelemetryProtocolGRPC = "grpc"
+	// OpenTelemetryProtocolHTTP exports with OTLP/HTTP.
+	OpenTelemetryProtocolHTTP = "http"
+)
+
+// OpenTelemetryProvider is an OpenTelemetry collector receiving the spans of the proxies with OTLP. It is
+// selected as a tracing provider by Telemetry resources.
+type OpenTelemetryProvider struct {
+	// Service is the collector, in the format of [<Namespace>/]<Hostname>.
+	Service string `json:"service"`
+	// Port is the OTLP port of the collector.
+	Port uint32 `json:"port"`
+	// Protocol is the OTLP transport, grpc or http. Defaults to grpc. The http protocol requires 1.21+ proxies.
+	Protocol string `json:"protocol,omitempty"`
+	// Path is the OTLP/HTTP traces endpoint. Defaults to /v1/traces.
+	Path string `json:"path,omitempty"`
+	// Timeout is the timeout of the export calls. Defaults to 5s.
+	Timeout string `json:"timeout,omitempty"`
+	// ResourceAttributes describe the proxies. service.name defaults to <canonical service>.<namespace>. Envoy
+	// only supports the service.name resource attribute, so the others are added as tags to every span.
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+	// MaxTagLength is the maximum length of the request path tags.
+	MaxTagLength uint32 `json:"maxTagLength,omitempty"`
+	// Metrics enables the export of the Istio metrics to the collector. It is only supported with the grpc
+	// protocol.
+	Metrics *OpenTelemetryMetrics `json:"metrics,omitempty"`
+}
+
+// OpenTelemetryMetrics configures the export of the Istio metrics of the workloads selecting the provider in
+// Telemetry.metrics.providers. The metrics are generated by the stats filter, like the Prometheus ones. istiod
+// sends the collector selected for the workload to its agent, which reads the istio_* metrics of the proxy and
+// exports them to the collector.
+type OpenTelemetryMetrics struct {
+	// PushInterval is the interval between two exports of the metrics to the collector. Defaults to 15s.
+	PushInterval string `json:"pushInterval,omitempty"`
+	// TLS configures the connections of the agents to the collector. Defaults to the ISTIO_MUTUAL mode.
+	TLS *OpenTelemetryMetricsTLS `json:"tls,omitempty"`
+}
+
+const (
+	// OpenTelemetryTLSModeIstioMutual exports the metrics with mutual TLS: the agents present the certificate of
+	// their workload, and verify the collector with the mesh CA.
+	OpenTelemetryTLSModeIstioMutual = "ISTIO_MUTUAL"
+	// OpenTelemetryTLSModeSimple verifies the collector with the CA certificates of the provider, or the system ones.
+	OpenTelemetryTLSModeSimple = "SIMPLE"
+	// OpenTelemetryTLSModeDisable exports the metrics in plaintext.
+	OpenTelemetryTLSModeDisable = "DISABLE"
+)
+
+// OpenTelemetryMetricsTLS configures the TLS connections of the agents to the collector, like the TLS settings of
+// a DestinationRule.
+type OpenTelemetryMetricsTLS struct {
+	// Mode is ISTIO_MUTUAL, SIMPLE or DISABLE.
+	Mode string `json:"mode,omitempty"`
+	// CaCertificates is the path, in the proxy container, of the CA certificates verifying the collector in the
+	// SIMPLE mode. Defaults to the system CA certificates.
+	CaCertificates string `json:"caCertificates,omitempty"`
+	// Sni is the server name verified in the certificate of the collector in the SIMPLE mode. Defaults to the
+	// hostname of the collector.
+	Sni string `json:"sni,omitempty"`
+}
+
+// ParseExtensionProviders parses and validates a YAML list of extension providers. As they share the names of the
+// extension providers of the mesh config, the providers with the name of one of them are rejected.
+func ParseExtensionProviders(value string, mesh *meshconfig.MeshConfig) ([]*ExtensionProvider, error) {
+	var providers []*ExtensionProvider
+	if err := yaml.UnmarshalStrict([]byte(value), &providers); err != nil {
+		return nil, fmt.Errorf("invalid extension providers: %v", err)
+	}
+	if err := validateExtensionProviders(providers, mesh); err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+func validateExtensionProviders(providers []*ExtensionProvider, mesh *meshconfig.MeshConfig) (errs error) {
+	inMesh := map[string]struct{}{}
+	for _, p := range mesh.GetExtensionProviders() {
+		inMesh[p.GetName()] = struct{}{}
+	}
+	defined := map[string]struct{}{}
+	rateLimits := 0
+	for _, p := range providers {
+		var currentErrs error
+		if p.Name == "" {
+			currentErrs = multierror.Append(currentErrs, fmt.Errorf("empty extension provider name"))
+		} else if _, f := defined[p.Name]; f {
+			currentErrs = multierror.Append(currentErrs, fmt.Errorf("duplicate extension provider name %s", p.Name))
+		} else if _, f := inMesh[p.Name]; f {
+			currentErrs = multierror.Append(currentErrs, fmt.Errorf("extension provider name %s is used by the mesh config", p.Name))
+		}
+		defined[p.Name] = struct{}{}
+
+		switch {
+		case p.types() > 1:
+			currentErrs = multierror.Append(currentErrs, fmt.Errorf("only one provider type can be set"))
+		case p.EnvoyRateLimit != nil:
+			rateLimits++
+			if err := p.EnvoyRateLimit.validate(); err != nil {
+				currentErrs = multierror.Append(currentErrs, err)
+			}
+		case p.EnvoyExtProc != nil:
+			if err := p.EnvoyExtProc.validate(); err != nil {
+				currentErrs = multierror.Append(currentErrs, err)
+			}
+		case p.OpenTelemetry != nil:
+			if err := p.OpenTelemetry.validate(); err != nil {
+				currentErrs = multierror.Append(currentErrs, err)
+			}
+		default:
+			currentErrs = multierror.Append(currentErrs, fmt.Errorf("unsupported provider"))
+		}
+		if currentErrs != nil {
+			errs = multierror.Append(errs, multierror.Prefix(currentErrs, fmt.Sprintf("invalid extension provider %s:", p.Name)))
+		}
+	}
+	if rateLimits > maxRateLimitProviders {
+		errs = multierror.Append(errs, fmt.Errorf("at most %d envoyRateLimit providers are supported", maxRateLimitProviders))
+	}
+	return
+}
+
+// GetOpenTelemetry returns the opentelemetry provider, or nil if the provider is of another type or nil.
+func (p *ExtensionProvider) GetOpenTelemetry() *OpenTelemetryProvider {
+	if p == nil {
+		return nil
+	}
+	return p.OpenTelemetry
+}
+
+// types returns the number of provider types set.
+func (p *ExtensionProvider) types() int {
+	n := 0
+	if p.EnvoyRateLimit != nil {
+		n++
+	}
+	if p.EnvoyExtProc != nil {
+		n++
+	}
+	if p.OpenTelemetry != nil {
+		n++
+	}
+	return n
+}
+
+func (p *EnvoyRateLimitProvider) validate() (errs error) {
+	errs = validateProviderService(p.Service, p.Port, p.Timeout)
+	if p.Domain == "" {
+		errs = multierror.Append(errs, fmt.Errorf("domain must not be empty"))
+	}
+	return
+}
+
+// TimeoutDuration returns the timeout of the calls to the rate limit service.
+func (p *EnvoyRateLimitProvider) TimeoutDuration() time.Duration {
+	return durationOrDefault(p.Timeout, 20*time.Millisecond)
+}
+
+func (p *EnvoyExtProcProvider) validate() (errs error) {
+	errs = validateProviderService(p.Service, p.Port, p.Timeout)
+	if err := p.ProcessingMode.Validate(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	for _, w := range p.Workloads {
+		if w == nil {
+			errs = multierror.Append(errs, fmt.Errorf("empty workloads"))
+			continue
+		}
+		if w.Namespace != "" && !labels.IsDNS1123Label(w.Namespace) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid workloads namespace %q", w.Namespace))
+		}
+		if err := labels.Instance(w.MatchLabels).Validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return
+}
+
+// Selects checks if the external processor is attached to the proxy.
+func (p *EnvoyExtProcProvider) Selects(proxy *Proxy) bool {
+	for _, w := range p.Workloads {
+		if w.Namespace != "" && w.Namespace != proxy.ConfigNamespace {
+			continue
+		}
+		if labels.Instance(w.MatchLabels).SubsetOf(proxy.Metadata.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutDuration returns the timeout of each message exchanged with the external processor.
+func (p *EnvoyExtProcProvider) TimeoutDuration() time.Duration {
+	return durationOrDefault(p.Timeout, 200*time.Millisecond)
+}
+
+func (p *OpenTelemetryProvider) validate() (errs error) {
+	errs = validateProviderService(p.Service, p.Port, p.Timeout)
+	switch p.Protocol {
+	case "", OpenTelemetryProtocolGRPC:
+		if p.Path != "" {
+			errs = multierror.Append(errs, fmt.Errorf("path is only supported with the http protocol"))
+		}
+	case OpenTelemetryProtocolHTTP:
+		if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			errs = multierror.Append(errs, fmt.Errorf("path should begin with `/` but found %q", p.Path))
+		}
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("unsupported protocol %q, expected grpc or http", p.Protocol))
+	}
+	if p.Metrics != nil {
+		if p.Protocol == OpenTelemetryProtocolHTTP {
+			errs = multierror.Append(errs, fmt.Errorf("metrics are only supported with the grpc protocol"))
+		}
+		if p.Metrics.PushInterval != "" {
+			if d, err := time.ParseDuration(p.Metrics.PushInterval); err != nil || d < time.Second {
+				errs = multierror.Append(errs, fmt.Errorf("invalid push interval %q, expected at least 1s", p.Metrics.PushInterval))
+			}
+		}
+		if err := p.Metrics.TLS.validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return
+}
+
+func (t *OpenTelemetryMetricsTLS) validate() (errs error) {
+	switch t.GetMode() {
+	case OpenTelemetryTLSModeIstioMutual, OpenTelemetryTLSModeDisable:
+		if t.GetCaCertificates() != "" || t.GetSni() != "" {
+			errs = multierror.Append(errs, fmt.Errorf("caCertificates and sni are only supported with the %s TLS mode",
+				OpenTelemetryTLSModeSimple))
+		}
+	case OpenTelemetryTLSModeSimple:
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("unsupported TLS mode %q, expected one of %s, %s or %s", t.GetMode(),
+			OpenTelemetryTLSModeIstioMutual, OpenTelemetryTLSModeSimple, OpenTelemetryTLSModeDisable))
+	}
+	return
+}
+
+// GetMode returns the TLS mode, ISTIO_MUTUAL if t is nil or the mode is not set.
+func (t *OpenTelemetryMetricsTLS) GetMode() string {
+	if t == nil || t.Mode == "" {
+		return OpenTelemetryTLSModeIstioMutual
+	}
+	return t.Mode
+}
+
+// GetCaCertificates returns the path of the CA certificates, or "" if t is nil.
+func (t *OpenTelemetryMetricsTLS) GetCaCertificates() string {
+	if t == nil {
+		return ""
+	}
+	return t.CaCertificates
+}
+
+// GetSni returns the server name, or "" if t is nil.
+func (t *OpenTelemetryMetricsTLS) GetSni() string {
+	if t == nil {
+		return ""
+	}
+	return t.Sni
+}
+
+// TimeoutDuration returns the timeout of the export calls to the collector.
+func (p *OpenTelemetryProvider) TimeoutDuration() time.Duration {
+	return durationOrDefault(p.Timeout, 5*time.Second)
+}
+
+// HTTPPath returns the OTLP/HTTP traces endpoint of the collector.
+func (p *OpenTelemetryProvider) HTTPPath() string {
+	if p.Path == "" {
+		return "/v1/traces"
+	}
+	return p.Path
+}
+
+// GetMetrics returns the metrics export settings, or nil if the provider does not export metrics or is nil.
+func (p *OpenTelemetryProvider) GetMetrics() *OpenTelemetryMetrics {
+	if p == nil {
+		return nil
+	}
+	return p.Metrics
+}
+
+// Address returns the host and port of the collector.
+func (p *OpenTelemetryProvider) Address() string {
+	host := p.Service
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[i+1:]
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(p.Port)))
+}
+
+// PushIntervalDuration returns the interval between two pushes of the metrics.
+func (m *OpenTelemetryMetrics) PushIntervalDuration() time.Duration {
+	return durationOrDefault(m.PushInterval, 15*time.Second)
+}
+
+func validateProviderService(service string, port uint32, timeout string) (errs error) {
+	if err := validation.ValidateExtensionProviderService(service); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if err := validation.ValidatePort(int(port)); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid service port: %v", err))
+	}
+	if timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid timeout %q", timeout))
+		}
+	}
+	return
+}
+
+func durationOrDefault(value string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	return def
+}
+
+// ExtensionProviders returns the current extension providers. Config generation must use the ones of the push
+// context instead, which are consistent for the whole push.
+func (e *Environment) ExtensionProviders() []*ExtensionProvider {
+	providers, _ := e.extensionProviders.Load().([]*ExtensionProvider)
+	return providers
+}
+
+// SetExtensionProviders replaces the extension providers, returning whether they changed. istiod sets them once
+// when it starts, from PILOT_EXTENSION_PROVIDERS, then each time the extensionProviders key of the mesh ConfigMap
+// changes. They are only used by the push contexts created afterwards.
+func (e *Environment) SetExtensionProviders(providers []*ExtensionProvider) bool {
+	if reflect.DeepEqual(providers, e.ExtensionProviders()) {
+		return false
+	}
+	e.extensionProviders.Store(providers)
+	return true
+}
+
+// ExtensionProvider returns the extension provider with the given name, or nil if there is none.
+func (ps *PushContext) ExtensionProvider(name string) *ExtensionProvider {
+	return findExtensionProvider(ps.ExtensionProviders, name)
+}
+
+// RateLimitProviders returns the envoyRateLimit extension providers, in order.
+func (ps *PushContext) RateLimitProviders() []*ExtensionProvider {
+	var out []*ExtensionProvider
+	for _, p := range ps.ExtensionProviders {
+		if p.EnvoyRateLimit != nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// RateLimitStage returns the stage of the rate limit filter of the given envoyRateLimit provider. Stage 0 is
+// used by the local rate limit filter, so each provider has its own stage and only applies the rate limit
+// actions set for it.
+func (ps *PushContext) RateLimitStage(provider string) (uint32, bool) {
+	for i, p := range ps.RateLimitProviders() {
+		if p.Name == provider {
+			return uint32(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+func findExtensionProvider(providers []*ExtensionProvider, name string) *ExtensionProvider {
+	for _, p := range providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestParseExtensionProviders(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name: "rate limit",
+			value: `
+- name: ratelimit
+  envoyRateLimit:
+    service: ratelimit.default.svc.cluster.local
+    port: 8081
+    domain: mesh
+    timeout: 100ms
+    failOpen: true
+`,
+		},
+		{
+			name: "ext proc",
+			value: `
+- name: transform
+  envoyExtProc:
+    service: default/processor.default.svc.cluster.local
+    port: 9000
+    timeout: 1s
+    processingMode:
+      requestHeaders: SEND
+      requestBody: BUFFERED
+    workloads:
+    - namespace: default
+      matchLabels:
+        app: uploads
+`,
+		},
+		{
+			name:  "ext proc invalid workloads namespace",
+			value: `[{name: transform, envoyExtProc: {service: processor, port: 9000, workloads: [{namespace: bad_namespace}]}}]`,
+			err:   "invalid workloads namespace",
+		},
+		{
+			name:  "name of a mesh config provider",
+			value: `[{name: ext-authz, envoyExtProc: {service: processor, port: 9000, workloads: [{}]}}]`,
+			err:   "extension provider name ext-authz is used by the mesh config",
+		},
+		{
+			name:  "invalid processing mode",
+			value: `[{name: transform, envoyExtProc: {service: processor, port: 9000, processingMode: {requestBody: SEND}}}]`,
+			err:   "invalid requestBody mode",
+		},
+		{
+			name: "opentelemetry",
+			value: `
+- name: otel
+  opentelemetry:
+    service: opentelemetry-collector.istio-system.svc.cluster.local
+    port: 4318
+    protocol: http
+    path: /otlp/v1/traces
+    resourceAttributes:
+      deployment.environment: prod
+`,
+		},
+		{
+			name:  "opentelemetry invalid protocol",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, protocol: thrift}}]`,
+			err:   "unsupported protocol",
+		},
+		{
+			name:  "opentelemetry grpc path",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, path: /v1/traces}}]`,
+			err:   "path is only supported with the http protocol",
+		},
+		{
+			name:  "opentelemetry http metrics",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4318, protocol: http, metrics: {}}}]`,
+			err:   "metrics are only supported with the grpc protocol",
+		},
+		{
+			name:  "opentelemetry push interval",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, metrics: {pushInterval: 100ms}}}]`,
+			err:   "invalid push interval",
+		},
+		{
+			name:  "opentelemetry simple tls",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, metrics: {tls: {mode: SIMPLE, caCertificates: /etc/otel/ca.pem}}}}]`,
+		},
+		{
+			name:  "opentelemetry unknown tls mode",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, metrics: {tls: {mode: MUTUAL}}}}]`,
+			err:   "unsupported TLS mode",
+		},
+		{
+			name:  "opentelemetry mesh tls with ca certificates",
+			value: `[{name: otel, opentelemetry: {service: collector, port: 4317, metrics: {tls: {caCertificates: /etc/otel/ca.pem}}}}]`,
+			err:   "caCertificates and sni are only supported with the SIMPLE TLS mode",
+		},
+		{
+			name: "two provider types",
+			value: `
+- name: both
+  envoyRateLimit: {service: ratelimit, port: 8081, domain: mesh}
+  envoyExtProc: {service: processor, port: 9000}
+`,
+			err: "only one provider type can be set",
+		},
+		{
+			name:  "unknown field",
+			value: `[{name: ratelimit, envoyRateLimiter: {}}]`,
+			err:   "invalid extension providers",
+		},
+		{
+			name:  "no provider",
+			value: `[{name: ratelimit}]`,
+			err:   "unsupported provider",
+		},
+		{
+			name: "duplicate name",
+			value: `
+- {name: ratelimit, envoyRateLimit: {service: ratelimit.default.svc.cluster.local, port: 8081, domain: mesh}}
+- {name: ratelimit, envoyRateLimit: {service: ratelimit.default.svc.cluster.local, port: 8081, domain: mesh}}
+`,
+			err: "duplicate extension provider name",
+		},
+		{
+			name:  "missing domain",
+			value: `[{name: ratelimit, envoyRateLimit: {service: ratelimit.default.svc.cluster.local, port: 8081}}]`,
+			err:   "domain must not be empty",
+		},
+		{
+			name:  "invalid timeout",
+			value: `[{name: ratelimit, envoyRateLimit: {service: ratelimit, port: 8081, domain: mesh, timeout: 1}}]`,
+			err:   "invalid timeout",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mesh := &meshconfig.MeshConfig{
+				ExtensionProviders: []*meshconfig.MeshConfig_ExtensionProvider{{Name: "ext-authz"}},
+			}
+			_, err := ParseExtensionProviders(tt.value, mesh)
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected valid providers, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// pushContextWithExtensionProviders returns a push context with the extension providers parsed from the given
+// YAML list.
+func pushContextWithExtensionProviders(t *testing.T, value string) *PushContext {
+	t.Helper()
+	providers, err := ParseExtensionProviders(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := NewPushContext()
+	ps.ExtensionProviders = providers
+	return ps
+}
+
+func TestSetExtensionProviders(t *testing.T) {
+	env := &Environment{}
+	providers, err := ParseExtensionProviders(`[{name: first, envoyRateLimit: {service: first.default.svc.cluster.local, port: 8081, domain: mesh}}]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.SetExtensionProviders(providers) {
+		t.Fatalf("expected the providers to change")
+	}
+	same, _ := ParseExtensionProviders(`[{name: first, envoyRateLimit: {service: first.default.svc.cluster.local, port: 8081, domain: mesh}}]`, nil)
+	if env.SetExtensionProviders(same) {
+		t.Fatalf("expected the same providers not to change")
+	}
+	if got := env.ExtensionProviders(); len(got) != 1 || got[0].Name != "first" {
+		t.Fatalf("expected the first provider, got %v", got)
+	}
+}
+
+func TestPushContextExtensionProviders(t *testing.T) {
+	env := &Environment{}
+	env.IstioConfigStore = &istioConfigStore{ConfigStore: NewFakeStore()}
+	env.ServiceDiscovery = &localServiceDiscovery{}
+	m := mesh.DefaultMeshConfig()
+	env.Watcher = mesh.NewFixedWatcher(&m)
+	env.Init()
+	first, _ := ParseExtensionProviders(`[{name: first, envoyRateLimit: {service: first.default.svc.cluster.local, port: 8081, domain: mesh}}]`, nil)
+	second, _ := ParseExtensionProviders(`[{name: second, envoyRateLimit: {service: second.default.svc.cluster.local, port: 8081, domain: mesh}}]`, nil)
+	env.SetExtensionProviders(first)
+	old := NewPushContext()
+	if err := old.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the providers does not affect the existing push contexts, nor the ones updated incrementally
+	// from them, which keep their telemetry.
+	env.SetExtensionProviders(second)
+	if old.ExtensionProvider("first") == nil || old.ExtensionProvider("second") != nil {
+		t.Fatalf("expected the push context to keep its providers, got %v", old.ExtensionProviders)
+	}
+	incremental := NewPushContext()
+	if err := incremental.InitContext(env, old, &PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "default"}: {}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if incremental.ExtensionProvider("first") == nil {
+		t.Fatalf("expected the incremental push context to keep the providers, got %v", incremental.ExtensionProviders)
+	}
+
+	// The full push triggered by the change uses the new providers.
+	full := NewPushContext()
+	if err := full.InitContext(env, incremental, &PushRequest{Full: true}); err != nil {
+		t.Fatal(err)
+	}
+	if full.ExtensionProvider("second") == nil || full.ExtensionProvider("first") != nil {
+		t.Fatalf("expected the new providers, got %v", full.ExtensionProviders)
+	}
+}
+
+func TestRateLimitStage(t *testing.T) {
+	ps := pushContextWithExtensionProviders(t, `
+- {name: first, envoyRateLimit: {service: first.default.svc.cluster.local, port: 8081, domain: mesh}}
+- {name: second, envoyRateLimit: {service: second.default.svc.cluster.local, port: 8081, domain: mesh}}
+`)
+
+	if stage, f := ps.RateLimitStage("second"); !f || stage != 2 {
+		t.Fatalf("expected stage 2, got %d, %v", stage, f)
+	}
+	if _, f := ps.RateLimitStage("missing"); f {
+		t.Fatalf("expected no stage for an unknown provider")
+	}
+}
+
+func TestOpenTelemetryMetrics(t *testing.T) {
+	ps := pushContextWithExtensionProviders(t, `
+- {name: metrics, opentelemetry: {service: istio-system/otel.istio-system.svc.cluster.local, port: 4317, metrics: {pushInterval: 30s}}}
+`)
+	p := ps.ExtensionProvider("metrics")
+	if got := p.OpenTelemetry.Address(); got != "otel.istio-system.svc.cluster.local:4317" {
+		t.Fatalf("expected the collector address, got %s", got)
+	}
+	if got := p.OpenTelemetry.Metrics.PushIntervalDuration(); got != 30*time.Second {
+		t.Fatalf("expected a 30s push interval, got %v", got)
+	}
+}
+
+func TestExtProcSelects(t *testing.T) {
+	p := &EnvoyExtProcProvider{Workloads: []*ExtProcWorkloads{
+		{Namespace: "default", MatchLabels: map[string]string{"app": "uploads"}},
+		{Namespace: "transform"},
+	}}
+	cases := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		want      bool
+	}{
+		{"matching labels", "default", map[string]string{"app": "uploads", "version": "v1"}, true},
+		{"other labels", "default", map[string]string{"app": "downloads"}, false},
+		{"other namespace", "other", map[string]string{"app": "uploads"}, false},
+		{"whole namespace", "transform", nil, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &Proxy{ConfigNamespace: tt.namespace, Metadata: &NodeMetadata{Labels: tt.labels}}
+			if got := p.Selects(proxy); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if (&EnvoyExtProcProvider{}).Selects(&Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{}}) {
+		t.Fatalf("expected a processor without workloads not to be attached")
+	}
+}
@@ -67,6 +67,10 @@ type MergedGateway struct {
 	// is limited to HTTP3 only
 	MergedQUICTransportServers map[ServerPort]*MergedServers
 
+	// MergedUDPServers map from physical port to servers using the UDP protocol. They are only
+	// populated when UDP listeners are enabled.
+	MergedUDPServers map[ServerPort]*MergedServers
+
 	// HTTP3AdvertisingRoutes represents the set of HTTP routes which advertise HTTP/3.
 	// This mapping is used to generate alt-svc header that is needed for HTTP/3 server discovery.
 	HTTP3AdvertisingRoutes map[string]struct{}
@@ -135,6 +139,7 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 	gatewayPorts := make(map[uint32]bool)
 	mergedServers := make(map[ServerPort]*MergedServers)
 	mergedQUICServers := make(map[ServerPort]*MergedServers)
+	mergedUDPServers := make(map[ServerPort]*MergedServers)
 	serverPorts := make([]ServerPort, 0)
 	plainTextServers := make(map[uint32]ServerPort)
 	serversByRouteName := make(map[string][]*networking.Server)
@@ -183,7 +188,12 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 					verifiedCertificateReferences.Insert(rn)
 				}
 			}
-			for _, resolvedPort := range resolvePorts(s.Port.Number, gwAndInstance.instances, gwAndInstance.legacyGatewaySelector) {
+			resolvedPorts := resolvePorts(s.Port.Number, gwAndInstance.instances, gwAndInstance.legacyGatewaySelector)
+			if strings.HasPrefix(s.Bind, EnvoyInternalAddressPrefix) {
+				// Internal listeners are not exposed by the gateway Service, so their port is used as-is.
+				resolvedPorts = []uint32{s.Port.Number}
+			}
+			for _, resolvedPort := range resolvedPorts {
 				routeName := gatewayRDSRouteName(s, resolvedPort, gatewayConfig)
 				if s.Tls != nil {
 					// Envoy will reject config that has multiple filter chain matches with the same matching rules.
@@ -204,6 +214,16 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 				}
 				serverPort := ServerPort{resolvedPort, s.Port.Protocol, s.Bind}
 				serverProtocol := protocol.Parse(serverPort.Protocol)
+				if features.EnableUDPListeners && serverProtocol == protocol.UDP {
+					// UDP servers are served by their own listener, so they never conflict with the TCP servers
+					// of the same port.
+					if mergedUDPServers[serverPort] == nil {
+						mergedUDPServers[serverPort] = &MergedServers{Servers: []*networking.Server{}}
+						serverPorts = append(serverPorts, serverPort)
+					}
+					mergedUDPServers[serverPort].Servers = append(mergedUDPServers[serverPort].Servers, s)
+					continue
+				}
 				if gatewayPorts[resolvedPort] {
 					// We have two servers on the same port. Should we merge?
 					// 1. Yes if both servers are plain text and HTTP
@@ -324,6 +344,7 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 	return &MergedGateway{
 		MergedServers:                   mergedServers,
 		MergedQUICTransportServers:      mergedQUICServers,
+		MergedUDPServers:                mergedUDPServers,
 		ServerPorts:                     serverPorts,
 		GatewayNameForServer:            gatewayNameForServer,
 		TLSServerInfo:                   tlsServerInfo,
@@ -30,6 +30,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/cluster"
@@ -141,7 +142,13 @@ type sidecarIndex struct {
 	// Unlike computedSidecarsByNamespace, this is *always* the output of DefaultSidecarScopeForNamespace.
 	// These are lazy-loaded. Access protected by defaultSidecarMu
 	gatewayDefaultSidecarsByNamespace map[string]*SidecarScope
-	defaultSidecarMu                  *sync.Mutex
+	// deferredConfigsByNamespace contains, in the order of sidecarsByNamespace, the sidecars of the namespaces
+	// not served by this istiod, which are only converted if one of their proxies connects.
+	deferredConfigsByNamespace map[string][]config.Config
+	// deferredSidecarsByNamespace contains the converted deferredConfigsByNamespace.
+	// These are lazy-loaded. Access protected by defaultSidecarMu
+	deferredSidecarsByNamespace map[string][]*SidecarScope
+	defaultSidecarMu            *sync.Mutex
 }
 
 func newSidecarIndex() sidecarIndex {
@@ -149,10 +156,36 @@ func newSidecarIndex() sidecarIndex {
 		sidecarsByNamespace:               map[string][]*SidecarScope{},
 		computedSidecarsByNamespace:       map[string]*SidecarScope{},
 		gatewayDefaultSidecarsByNamespace: map[string]*SidecarScope{},
+		deferredConfigsByNamespace:        map[string][]config.Config{},
+		deferredSidecarsByNamespace:       map[string][]*SidecarScope{},
 		defaultSidecarMu:                  &sync.Mutex{},
 	}
 }
 
+// deferredSidecars returns the sidecars of a namespace not served by this istiod, converting them on first use.
+func (ps *PushContext) deferredSidecars(namespace string) ([]*SidecarScope, bool) {
+	configs, ok := ps.sidecarIndex.deferredConfigsByNamespace[namespace]
+	if !ok {
+		return nil, false
+	}
+	ps.sidecarIndex.defaultSidecarMu.Lock()
+	defer ps.sidecarIndex.defaultSidecarMu.Unlock()
+	if sidecars, f := ps.sidecarIndex.deferredSidecarsByNamespace[namespace]; f {
+		return sidecars, true
+	}
+	sidecars := make([]*SidecarScope, 0, len(configs))
+	for i := range configs {
+		sidecars = append(sidecars, ConvertToSidecarScope(ps, &configs[i], namespace))
+	}
+	ps.sidecarIndex.deferredSidecarsByNamespace[namespace] = sidecars
+	return sidecars, true
+}
+
+// servesNamespace checks if the sidecar scopes of a namespace are computed upfront.
+func servesNamespace(env *Environment, namespace string) bool {
+	return env.ServesNamespace == nil || namespace == env.Mesh().RootNamespace || env.ServesNamespace(namespace)
+}
+
 // gatewayIndex is the index of gateways by various fields.
 type gatewayIndex struct {
 	// namespace contains gateways by namespace.
@@ -228,6 +261,9 @@ type PushContext struct {
 	// Mesh configuration for the mesh.
 	Mesh *meshconfig.MeshConfig `json:"-"`
 
+	// ExtensionProviders are the extension providers of the types MeshConfig does not support yet.
+	ExtensionProviders []*ExtensionProvider `json:"-"`
+
 	// PushVersion describes the push version this push context was computed for
 	PushVersion string
 
@@ -360,6 +396,10 @@ type PushRequest struct {
 	// There should only be multiple reasons if the push request is the result of two distinct triggers, rather than
 	// classifying a single trigger as having multiple reasons.
 	Reason []TriggerReason
+
+	// Traces holds the push traces of the events that triggered this push, if push tracing is enabled.
+	// There is one for each event merged into the request.
+	Traces []pushtrace.SpanContext
 }
 
 type TriggerReason string
@@ -409,6 +449,8 @@ func (pr *PushRequest) Merge(other *PushRequest) *PushRequest {
 	// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 	pr.Reason = append(pr.Reason, other.Reason...)
 
+	pr.Traces = pushtrace.Merge(pr.Traces, other.Traces)
+
 	// If either is full we need a full push
 	pr.Full = pr.Full || other.Full
 
@@ -456,6 +498,8 @@ func (pr *PushRequest) CopyMerge(other *PushRequest) *PushRequest {
 
 		// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 		Reason: reason,
+
+		Traces: pushtrace.Merge(pr.Traces, other.Traces),
 	}
 
 	// Do not merge when any one is empty
@@ -591,6 +635,13 @@ var (
 		"Duplicate subsets across destination rules for same host",
 	)
 
+	// ProxyStatusPlaintextPeerAuthz tracks proxyless gRPC servers serving plaintext, as they do with PERMISSIVE
+	// mTLS, while their authorization policies match the peer identity, which is only known over mTLS.
+	ProxyStatusPlaintextPeerAuthz = monitoring.NewGauge(
+		"pilot_grpc_plaintext_peer_authz",
+		"Proxyless gRPC servers serving plaintext with authorization rules on principals or namespaces, which never match.",
+	)
+
 	// totalVirtualServices tracks the total number of virtual service
 	totalVirtualServices = monitoring.NewGauge(
 		"pilot_virt_services",
@@ -617,6 +668,7 @@ var (
 		ProxyStatusClusterNoInstances,
 		DuplicatedDomains,
 		DuplicatedSubsets,
+		ProxyStatusPlaintextPeerAuthz,
 	}
 )
 
@@ -868,7 +920,11 @@ func (ps *PushContext) getSidecarScope(proxy *Proxy, workloadLabels labels.Colle
 	// config namespace If none found, construct a sidecarConfig on the fly
 	// that allows the sidecar to talk to any namespace (the default
 	// behavior in the absence of sidecars).
-	if sidecars, ok := ps.sidecarIndex.sidecarsByNamespace[proxy.ConfigNamespace]; ok {
+	sidecars, ok := ps.sidecarIndex.sidecarsByNamespace[proxy.ConfigNamespace]
+	if !ok {
+		sidecars, ok = ps.deferredSidecars(proxy.ConfigNamespace)
+	}
+	if ok {
 		// TODO: logic to merge multiple sidecar resources
 		// Currently we assume that there will be only one sidecar config for a namespace.
 		if proxy.Type == Router {
@@ -1077,10 +1133,14 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 
 	// create new or incremental update
 	if pushReq == nil || oldPushContext == nil || !oldPushContext.InitDone.Load() || len(pushReq.ConfigsUpdated) == 0 {
+		ps.ExtensionProviders = env.ExtensionProviders()
 		if err := ps.createNewContext(env); err != nil {
 			return err
 		}
 	} else {
+		// The telemetry may be kept from the old push context, so keep the providers it was computed with: changes
+		// of the providers trigger a full push of their own.
+		ps.ExtensionProviders = oldPushContext.ExtensionProviders
 		if err := ps.updateContext(env, oldPushContext, pushReq); err != nil {
 			return err
 		}
@@ -1207,16 +1267,36 @@ func (ps *PushContext) updateContext(
 	}
 
 	if virtualServicesChanged {
-		if err := ps.initVirtualServices(env); err != nil {
-			return err
+		updated := false
+		if features.EnableIncrementalPushContext && !gatewayAPIChanged {
+			var err error
+			if updated, err = ps.updateVirtualServices(env, oldPushContext,
+				changedNamespaces(pushReq.ConfigsUpdated, gvk.VirtualService)); err != nil {
+				return err
+			}
+		}
+		if !updated {
+			if err := ps.initVirtualServices(env); err != nil {
+				return err
+			}
 		}
 	} else {
 		ps.virtualServiceIndex = oldPushContext.virtualServiceIndex
 	}
 
 	if destinationRulesChanged {
-		if err := ps.initDestinationRules(env); err != nil {
-			return err
+		updated := false
+		if features.EnableIncrementalPushContext {
+			var err error
+			if updated, err = ps.updateDestinationRules(env, oldPushContext,
+				changedNamespaces(pushReq.ConfigsUpdated, gvk.DestinationRule)); err != nil {
+				return err
+			}
+		}
+		if !updated {
+			if err := ps.initDestinationRules(env); err != nil {
+				return err
+			}
 		}
 	} else {
 		ps.destinationRuleIndex = oldPushContext.destinationRuleIndex
@@ -1231,7 +1311,14 @@ func (ps *PushContext) updateContext(
 	}
 
 	if authzChanged {
-		if err := ps.initAuthorizationPolicies(env); err != nil {
+		if features.EnableIncrementalPushContext {
+			var err error
+			if ps.AuthzPolicies, err = UpdateAuthorizationPolicies(env, oldPushContext.AuthzPolicies,
+				changedNamespaces(pushReq.ConfigsUpdated, gvk.AuthorizationPolicy)); err != nil {
+				authzLog.Errorf("failed to update authorization policies: %v", err)
+				return err
+			}
+		} else if err := ps.initAuthorizationPolicies(env); err != nil {
 			authzLog.Errorf("failed to initialize authorization policies: %v", err)
 			return err
 		}
@@ -1281,12 +1368,30 @@ func (ps *PushContext) updateContext(
 
 	// Must be initialized in the end
 	// Sidecars need to be updated if services, virtual services, destination rules, or the sidecar configs change
-	if servicesChanged || virtualServicesChanged || destinationRulesChanged || sidecarsChanged {
+	if servicesChanged || virtualServicesChanged || destinationRulesChanged {
 		if err := ps.initSidecarScopes(env); err != nil {
 			return err
 		}
+	} else if sidecarsChanged {
+		updated := false
+		if features.EnableIncrementalPushContext {
+			var err error
+			if updated, err = ps.updateSidecarScopes(env, oldPushContext,
+				changedNamespaces(pushReq.ConfigsUpdated, gvk.Sidecar)); err != nil {
+				return err
+			}
+		}
+		if !updated {
+			if err := ps.initSidecarScopes(env); err != nil {
+				return err
+			}
+		}
 	} else {
 		ps.sidecarIndex.sidecarsByNamespace = oldPushContext.sidecarIndex.sidecarsByNamespace
+		ps.sidecarIndex.deferredConfigsByNamespace = oldPushContext.sidecarIndex.deferredConfigsByNamespace
+		// The root namespace sidecar has not changed either, carry it over so that the sidecar scopes
+		// computed on demand for the proxies without a sidecar keep inheriting it.
+		ps.sidecarIndex.rootConfig = oldPushContext.sidecarIndex.rootConfig
 	}
 
 	return nil
@@ -1424,69 +1529,74 @@ func (ps *PushContext) initVirtualServices(env *Environment) error {
 	vservices, ps.virtualServiceIndex.delegates = mergeVirtualServicesIfNeeded(vservices, ps.exportToDefaults.virtualService)
 
 	for _, virtualService := range vservices {
-		ns := virtualService.Namespace
-		rule := virtualService.Spec.(*networking.VirtualService)
-		gwNames := getGatewayNames(rule)
-		if len(rule.ExportTo) == 0 {
-			// No exportTo in virtualService. Use the global default
-			// We only honor ., *
-			if ps.exportToDefaults.virtualService[visibility.Private] {
-				if _, f := ps.virtualServiceIndex.privateByNamespaceAndGateway[ns]; !f {
-					ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] = map[string][]config.Config{}
-				}
-				// add to local namespace only
-				private := ps.virtualServiceIndex.privateByNamespaceAndGateway
-				for _, gw := range gwNames {
-					private[ns][gw] = append(private[ns][gw], virtualService)
-				}
-			} else if ps.exportToDefaults.virtualService[visibility.Public] {
-				for _, gw := range gwNames {
-					ps.virtualServiceIndex.publicByGateway[gw] = append(ps.virtualServiceIndex.publicByGateway[gw], virtualService)
-				}
+		ps.indexVirtualService(virtualService)
+	}
+
+	return nil
+}
+
+// indexVirtualService adds a virtual service to the virtual service index, according to its exportTo.
+func (ps *PushContext) indexVirtualService(virtualService config.Config) {
+	ns := virtualService.Namespace
+	rule := virtualService.Spec.(*networking.VirtualService)
+	gwNames := getGatewayNames(rule)
+	if len(rule.ExportTo) == 0 {
+		// No exportTo in virtualService. Use the global default
+		// We only honor ., *
+		if ps.exportToDefaults.virtualService[visibility.Private] {
+			if _, f := ps.virtualServiceIndex.privateByNamespaceAndGateway[ns]; !f {
+				ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] = map[string][]config.Config{}
 			}
-		} else {
-			exportToMap := make(map[visibility.Instance]bool)
-			for _, e := range rule.ExportTo {
-				exportToMap[visibility.Instance(e)] = true
+			// add to local namespace only
+			private := ps.virtualServiceIndex.privateByNamespaceAndGateway
+			for _, gw := range gwNames {
+				private[ns][gw] = append(private[ns][gw], virtualService)
 			}
-			// if vs has exportTo ~ - i.e. not visible to anyone, ignore all exportTos
-			// if vs has exportTo *, make public and ignore all other exportTos
-			// if vs has exportTo ., replace with current namespace
-			if exportToMap[visibility.Public] {
-				for _, gw := range gwNames {
-					ps.virtualServiceIndex.publicByGateway[gw] = append(ps.virtualServiceIndex.publicByGateway[gw], virtualService)
-				}
-				continue
-			} else if exportToMap[visibility.None] {
-				// not possible
-				continue
-			} else {
-				// . or other namespaces
-				for exportTo := range exportToMap {
-					if exportTo == visibility.Private || string(exportTo) == ns {
-						if _, f := ps.virtualServiceIndex.privateByNamespaceAndGateway[ns]; !f {
-							ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] = map[string][]config.Config{}
-						}
-						// add to local namespace only
-						for _, gw := range gwNames {
-							ps.virtualServiceIndex.privateByNamespaceAndGateway[ns][gw] = append(ps.virtualServiceIndex.privateByNamespaceAndGateway[ns][gw], virtualService)
-						}
-					} else {
-						if _, f := ps.virtualServiceIndex.exportedToNamespaceByGateway[string(exportTo)]; !f {
-							ps.virtualServiceIndex.exportedToNamespaceByGateway[string(exportTo)] = map[string][]config.Config{}
-						}
-						exported := ps.virtualServiceIndex.exportedToNamespaceByGateway
-						// add to local namespace only
-						for _, gw := range gwNames {
-							exported[string(exportTo)][gw] = append(exported[string(exportTo)][gw], virtualService)
-						}
+		} else if ps.exportToDefaults.virtualService[visibility.Public] {
+			for _, gw := range gwNames {
+				ps.virtualServiceIndex.publicByGateway[gw] = append(ps.virtualServiceIndex.publicByGateway[gw], virtualService)
+			}
+		}
+	} else {
+		exportToMap := make(map[visibility.Instance]bool)
+		for _, e := range rule.ExportTo {
+			exportToMap[visibility.Instance(e)] = true
+		}
+		// if vs has exportTo ~ - i.e. not visible to anyone, ignore all exportTos
+		// if vs has exportTo *, make public and ignore all other exportTos
+		// if vs has exportTo ., replace with current namespace
+		if exportToMap[visibility.Public] {
+			for _, gw := range gwNames {
+				ps.virtualServiceIndex.publicByGateway[gw] = append(ps.virtualServiceIndex.publicByGateway[gw], virtualService)
+			}
+			return
+		} else if exportToMap[visibility.None] {
+			// not possible
+			return
+		} else {
+			// . or other namespaces
+			for exportTo := range exportToMap {
+				if exportTo == visibility.Private || string(exportTo) == ns {
+					if _, f := ps.virtualServiceIndex.privateByNamespaceAndGateway[ns]; !f {
+						ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] = map[string][]config.Config{}
+					}
+					// add to local namespace only
+					for _, gw := range gwNames {
+						ps.virtualServiceIndex.privateByNamespaceAndGateway[ns][gw] = append(ps.virtualServiceIndex.privateByNamespaceAndGateway[ns][gw], virtualService)
+					}
+				} else {
+					if _, f := ps.virtualServiceIndex.exportedToNamespaceByGateway[string(exportTo)]; !f {
+						ps.virtualServiceIndex.exportedToNamespaceByGateway[string(exportTo)] = map[string][]config.Config{}
+					}
+					exported := ps.virtualServiceIndex.exportedToNamespaceByGateway
+					// add to local namespace only
+					for _, gw := range gwNames {
+						exported[string(exportTo)][gw] = append(exported[string(exportTo)][gw], virtualService)
 					}
 				}
 			}
 		}
 	}
-
-	return nil
 }
 
 var meshGateways = []string{constants.IstioMeshGateway}
@@ -1574,7 +1684,13 @@ func (ps *PushContext) initSidecarScopes(env *Environment) error {
 	// Currently we expect that it has no workloadSelectors
 	var rootNSConfig *config.Config
 	ps.sidecarIndex.sidecarsByNamespace = make(map[string][]*SidecarScope, sidecarNum)
+	ps.sidecarIndex.deferredConfigsByNamespace = map[string][]config.Config{}
 	for i, sidecarConfig := range sidecarConfigs {
+		if !servesNamespace(env, sidecarConfig.Namespace) {
+			ps.sidecarIndex.deferredConfigsByNamespace[sidecarConfig.Namespace] = append(
+				ps.sidecarIndex.deferredConfigsByNamespace[sidecarConfig.Namespace], sidecarConfig)
+			continue
+		}
 		ps.sidecarIndex.sidecarsByNamespace[sidecarConfig.Namespace] = append(ps.sidecarIndex.sidecarsByNamespace[sidecarConfig.Namespace],
 			ConvertToSidecarScope(ps, &sidecarConfig, sidecarConfig.Namespace))
 		if rootNSConfig == nil && sidecarConfig.Namespace == ps.Mesh.RootNamespace &&
@@ -1724,7 +1840,7 @@ func (ps *PushContext) initAuthorizationPolicies(env *Environment) error {
 }
 
 func (ps *PushContext) initTelemetry(env *Environment) (err error) {
-	if ps.Telemetry, err = getTelemetries(env); err != nil {
+	if ps.Telemetry, err = getTelemetries(env, ps.ExtensionProviders); err != nil {
 		telemetryLog.Errorf("failed to initialize telemetry: %v", err)
 		return
 	}
@@ -0,0 +1,240 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// This file contains the incremental counterparts of the init* functions of PushContext. Each of them
+// rebuilds only the part of an index owned by the namespaces that changed, and reuses the rest from
+// the previous PushContext. When an update cannot be applied incrementally (for example, because it
+// has effects across namespaces), they return false and the caller falls back to a full rebuild.
+
+// changedNamespaces returns the namespaces of the updated configs of the given kind.
+func changedNamespaces(configs map[ConfigKey]struct{}, kind config.GroupVersionKind) sets.Set {
+	namespaces := sets.NewSet()
+	for conf := range configs {
+		if conf.Kind == kind {
+			namespaces.Insert(conf.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// updateVirtualServices updates the virtual service index for the given namespaces.
+func (ps *PushContext) updateVirtualServices(env *Environment, oldPushContext *PushContext, namespaces sets.Set) (bool, error) {
+	// Delegate virtual services are merged into their root, which may live in any namespace.
+	if namespaces.Empty() || len(oldPushContext.virtualServiceIndex.delegates) > 0 {
+		return false, nil
+	}
+
+	vservices := make([]config.Config, 0)
+	for ns := range namespaces {
+		virtualServices, err := env.List(gvk.VirtualService, ns)
+		if err != nil {
+			return false, err
+		}
+		for _, vs := range virtualServices {
+			rule := vs.Spec.(*networking.VirtualService)
+			if isRootVs(rule) {
+				return false, nil
+			}
+			// Delegates without a root are not indexed
+			if len(rule.Hosts) == 0 {
+				continue
+			}
+			vservices = append(vservices, vs.DeepCopy())
+		}
+	}
+	for _, r := range vservices {
+		resolveVirtualServiceShortnames(r.Spec.(*networking.VirtualService), r.Meta)
+	}
+
+	unchanged := func(configs []config.Config) []config.Config {
+		out := make([]config.Config, 0, len(configs))
+		for _, c := range configs {
+			if !namespaces.Contains(c.Namespace) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	old := oldPushContext.virtualServiceIndex
+	for gw, configs := range old.publicByGateway {
+		if out := unchanged(configs); len(out) > 0 {
+			ps.virtualServiceIndex.publicByGateway[gw] = out
+		}
+	}
+	for ns, byGateway := range old.exportedToNamespaceByGateway {
+		exported := map[string][]config.Config{}
+		for gw, configs := range byGateway {
+			if out := unchanged(configs); len(out) > 0 {
+				exported[gw] = out
+			}
+		}
+		if len(exported) > 0 {
+			ps.virtualServiceIndex.exportedToNamespaceByGateway[ns] = exported
+		}
+	}
+	// Private virtual services are only ever indexed under their own namespace, so unchanged
+	// namespaces can be shared as is.
+	for ns, byGateway := range old.privateByNamespaceAndGateway {
+		if !namespaces.Contains(ns) {
+			ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] = byGateway
+		}
+	}
+
+	for _, virtualService := range vservices {
+		ps.indexVirtualService(virtualService)
+	}
+
+	// Restore the creation time order that a full rebuild would produce.
+	for _, configs := range ps.virtualServiceIndex.publicByGateway {
+		sortConfigByCreationTime(configs)
+	}
+	for _, byGateway := range ps.virtualServiceIndex.exportedToNamespaceByGateway {
+		for _, configs := range byGateway {
+			sortConfigByCreationTime(configs)
+		}
+	}
+	for ns := range namespaces {
+		for _, configs := range ps.virtualServiceIndex.privateByNamespaceAndGateway[ns] {
+			sortConfigByCreationTime(configs)
+		}
+	}
+
+	totalVirtualServices.Record(float64(ps.virtualServiceCount()))
+	return true, nil
+}
+
+// virtualServiceCount returns the number of distinct virtual services in the index.
+func (ps *PushContext) virtualServiceCount() int {
+	seen := map[ConfigKey]struct{}{}
+	add := func(configs []config.Config) {
+		for _, c := range configs {
+			seen[ConfigKey{Kind: gvk.VirtualService, Name: c.Name, Namespace: c.Namespace}] = struct{}{}
+		}
+	}
+	for _, configs := range ps.virtualServiceIndex.publicByGateway {
+		add(configs)
+	}
+	for _, byGateway := range ps.virtualServiceIndex.exportedToNamespaceByGateway {
+		for _, configs := range byGateway {
+			add(configs)
+		}
+	}
+	for _, byGateway := range ps.virtualServiceIndex.privateByNamespaceAndGateway {
+		for _, configs := range byGateway {
+			add(configs)
+		}
+	}
+	return len(seen)
+}
+
+// updateDestinationRules updates the destination rule index for the given namespaces.
+func (ps *PushContext) updateDestinationRules(env *Environment, oldPushContext *PushContext, namespaces sets.Set) (bool, error) {
+	// With inheritance, rules in the root namespace apply to all namespaces.
+	if namespaces.Empty() || features.EnableDestinationRuleInheritance || namespaces.Contains(ps.Mesh.RootNamespace) {
+		return false, nil
+	}
+
+	destRules := make([]config.Config, 0)
+	for ns := range namespaces {
+		configs, err := env.List(gvk.DestinationRule, ns)
+		if err != nil {
+			return false, err
+		}
+		for _, c := range configs {
+			destRules = append(destRules, c.DeepCopy())
+		}
+	}
+	ps.SetDestinationRules(destRules)
+
+	old := oldPushContext.destinationRuleIndex
+	for ns, rules := range old.namespaceLocal {
+		if !namespaces.Contains(ns) {
+			ps.destinationRuleIndex.namespaceLocal[ns] = rules
+		}
+	}
+	for ns, rules := range old.exportedByNamespace {
+		if !namespaces.Contains(ns) {
+			ps.destinationRuleIndex.exportedByNamespace[ns] = rules
+		}
+	}
+	ps.destinationRuleIndex.rootNamespaceLocal = old.rootNamespaceLocal
+	ps.destinationRuleIndex.inheritedByNamespace = old.inheritedByNamespace
+	return true, nil
+}
+
+// updateSidecarScopes recomputes the sidecar scopes of the given namespaces. This is only valid if nothing
+// but Sidecars changed, as the scopes of the other namespaces are reused as is.
+func (ps *PushContext) updateSidecarScopes(env *Environment, oldPushContext *PushContext, namespaces sets.Set) (bool, error) {
+	// The root namespace Sidecar is the default for all other namespaces.
+	if namespaces.Empty() || namespaces.Contains(ps.Mesh.RootNamespace) {
+		return false, nil
+	}
+
+	old := oldPushContext.sidecarIndex
+	ps.sidecarIndex.sidecarsByNamespace = make(map[string][]*SidecarScope, len(old.sidecarsByNamespace))
+	for ns, scopes := range old.sidecarsByNamespace {
+		if !namespaces.Contains(ns) {
+			ps.sidecarIndex.sidecarsByNamespace[ns] = scopes
+		}
+	}
+	ps.sidecarIndex.deferredConfigsByNamespace = make(map[string][]config.Config, len(old.deferredConfigsByNamespace))
+	for ns, configs := range old.deferredConfigsByNamespace {
+		if !namespaces.Contains(ns) {
+			ps.sidecarIndex.deferredConfigsByNamespace[ns] = configs
+		}
+	}
+
+	for ns := range namespaces {
+		sidecarConfigs, err := env.List(gvk.Sidecar, ns)
+		if err != nil {
+			return false, err
+		}
+		sortConfigByCreationTime(sidecarConfigs)
+
+		// sidecars with selector take preference
+		sorted := make([]config.Config, 0, len(sidecarConfigs))
+		withoutSelector := make([]config.Config, 0)
+		for _, sidecarConfig := range sidecarConfigs {
+			if sidecarConfig.Spec.(*networking.Sidecar).WorkloadSelector != nil {
+				sorted = append(sorted, sidecarConfig)
+			} else {
+				withoutSelector = append(withoutSelector, sidecarConfig)
+			}
+		}
+		sorted = append(sorted, withoutSelector...)
+		if !servesNamespace(env, ns) {
+			if len(sorted) != 0 {
+				ps.sidecarIndex.deferredConfigsByNamespace[ns] = sorted
+			}
+			continue
+		}
+
+		for i := range sorted {
+			ps.sidecarIndex.sidecarsByNamespace[ns] = append(ps.sidecarIndex.sidecarsByNamespace[ns],
+				ConvertToSidecarScope(ps, &sorted[i], ns))
+		}
+	}
+	ps.sidecarIndex.rootConfig = old.rootConfig
+	return true, nil
+}
@@ -882,6 +882,16 @@ func TestInitPushContext(t *testing.T) {
 			ExportTo: []string{".", "ns1"},
 		},
 	})
+	_, _ = configStore.Create(config.Config{
+		Meta: config.Meta{
+			Name:             "default",
+			Namespace:        "istio-system",
+			GroupVersionKind: gvk.Sidecar,
+		},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}},
+		},
+	})
 	store := istioConfigStore{ConfigStore: configStore}
 
 	env.IstioConfigStore = &store
@@ -948,6 +958,172 @@ func TestInitPushContext(t *testing.T) {
 	}
 }
 
+func TestIncrementalPushContext(t *testing.T) {
+	features.EnableIncrementalPushContext = true
+	defer func() { features.EnableIncrementalPushContext = false }()
+
+	t0 := time.Now()
+	cfg := func(kind config.GroupVersionKind, name, ns string, age int, spec config.Spec) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind:  kind,
+				Name:              name,
+				Namespace:         ns,
+				CreationTimestamp: t0.Add(time.Duration(age) * time.Second),
+			},
+			Spec: spec,
+		}
+	}
+	vs := func(name, ns string, age int, host string, exportTo ...string) config.Config {
+		return cfg(gvk.VirtualService, name, ns, age, &networking.VirtualService{Hosts: []string{host}, ExportTo: exportTo})
+	}
+	dr := func(name, ns string, age int, host string, exportTo ...string) config.Config {
+		return cfg(gvk.DestinationRule, name, ns, age, &networking.DestinationRule{Host: host, ExportTo: exportTo})
+	}
+	sidecar := func(name, ns string, age int, selector map[string]string) config.Config {
+		sc := &networking.Sidecar{Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}}}
+		if selector != nil {
+			sc.WorkloadSelector = &networking.WorkloadSelector{Labels: selector}
+		}
+		return cfg(gvk.Sidecar, name, ns, age, sc)
+	}
+	authz := func(name, ns string, age int) config.Config {
+		return cfg(gvk.AuthorizationPolicy, name, ns, age, &securityBeta.AuthorizationPolicy{})
+	}
+
+	base := []config.Config{
+		vs("vs1", "ns1", 1, "a.com"),
+		vs("vs2", "ns1", 2, "b.com", "ns2"),
+		vs("vs3", "ns2", 3, "a.com"),
+		vs("vs4", "ns2", 4, "c.com", "."),
+		dr("dr1", "ns1", 1, "a.com"),
+		dr("dr2", "ns2", 2, "b.com", "."),
+		dr("dr3", "istio-system", 3, "*.com"),
+		sidecar("sc1", "ns1", 1, nil),
+		sidecar("sc2", "ns2", 2, map[string]string{"app": "foo"}),
+		sidecar("sc3", "istio-system", 3, nil),
+		authz("authz1", "ns1", 1),
+		authz("authz2", "ns2", 2),
+	}
+	newEnv := func(configs []config.Config) *Environment {
+		configStore := NewFakeStore()
+		for _, c := range configs {
+			_, _ = configStore.Create(c)
+		}
+		env := &Environment{}
+		env.IstioConfigStore = &istioConfigStore{ConfigStore: configStore}
+		env.ServiceDiscovery = &localServiceDiscovery{
+			services: []*Service{
+				{Hostname: "a.com", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns1"}},
+				{Hostname: "b.com", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns2"}},
+			},
+		}
+		m := mesh.DefaultMeshConfig()
+		env.Watcher = mesh.NewFixedWatcher(&m)
+		env.Init()
+		return env
+	}
+	without := func(configs []config.Config, names ...string) []config.Config {
+		out := make([]config.Config, 0, len(configs))
+		for _, c := range configs {
+			remove := false
+			for _, n := range names {
+				if c.Name == n {
+					remove = true
+				}
+			}
+			if !remove {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	updated := func(configs ...config.Config) map[ConfigKey]struct{} {
+		res := map[ConfigKey]struct{}{}
+		for _, c := range configs {
+			res[ConfigKey{Kind: c.GroupVersionKind, Name: c.Name, Namespace: c.Namespace}] = struct{}{}
+		}
+		return res
+	}
+
+	cases := []struct {
+		name    string
+		configs []config.Config
+		updated map[ConfigKey]struct{}
+	}{
+		{
+			name:    "virtual service updated",
+			configs: append(without(base, "vs1"), vs("vs1", "ns1", 1, "d.com", "*")),
+			updated: updated(vs("vs1", "ns1", 1, "")),
+		},
+		{
+			name:    "virtual services added and deleted",
+			configs: append(without(base, "vs2"), vs("vs5", "ns1", 0, "a.com"), vs("vs6", "ns2", 5, "a.com", "ns1")),
+			updated: updated(vs("vs2", "ns1", 0, ""), vs("vs5", "ns1", 0, ""), vs("vs6", "ns2", 0, "")),
+		},
+		{
+			name:    "destination rules",
+			configs: append(without(base, "dr1"), dr("dr4", "ns2", 0, "a.com", "*")),
+			updated: updated(dr("dr1", "ns1", 0, ""), dr("dr4", "ns2", 0, "")),
+		},
+		{
+			name:    "root namespace destination rule",
+			configs: without(base, "dr3"),
+			updated: updated(dr("dr3", "istio-system", 0, "")),
+		},
+		{
+			name:    "sidecars",
+			configs: append(without(base, "sc1"), sidecar("sc4", "ns2", 0, nil)),
+			updated: updated(sidecar("sc1", "ns1", 0, nil), sidecar("sc4", "ns2", 0, nil)),
+		},
+		{
+			name:    "root namespace sidecar",
+			configs: without(base, "sc3"),
+			updated: updated(sidecar("sc3", "istio-system", 0, nil)),
+		},
+		{
+			name:    "authorization policies",
+			configs: append(without(base, "authz1"), authz("authz3", "ns2", 0)),
+			updated: updated(authz("authz1", "ns1", 0), authz("authz3", "ns2", 0)),
+		},
+		{
+			name: "mixed",
+			configs: append(without(base, "vs3", "dr2", "sc2", "authz2"),
+				vs("vs3", "ns2", 3, "b.com", "ns1"), dr("dr2", "ns2", 2, "b.com")),
+			updated: updated(vs("vs3", "ns2", 0, ""), dr("dr2", "ns2", 0, ""), sidecar("sc2", "ns2", 0, nil), authz("authz2", "ns2", 0)),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			old := NewPushContext()
+			if err := old.InitContext(newEnv(base), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			env := newEnv(tt.configs)
+			incremental := NewPushContext()
+			if err := incremental.InitContext(env, old, &PushRequest{ConfigsUpdated: tt.updated}); err != nil {
+				t.Fatal(err)
+			}
+			full := NewPushContext()
+			if err := full.InitContext(env, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(full, incremental,
+				cmp.AllowUnexported(PushContext{}, exportToDefaults{}, serviceIndex{}, virtualServiceIndex{},
+					destinationRuleIndex{}, gatewayIndex{}, processedDestRules{}, IstioEgressListenerWrapper{}, SidecarScope{},
+					AuthenticationPolicies{}, NetworkManager{}, sidecarIndex{}, Telemetries{}, ProxyConfigs{}),
+				cmpopts.IgnoreTypes(sync.RWMutex{}, localServiceDiscovery{}, FakeStore{}, atomic.Bool{}, sync.Mutex{}),
+				cmpopts.IgnoreInterfaces(struct{ mesh.Holder }{}),
+			)
+			if diff != "" {
+				t.Fatalf("incremental push context differs from a full rebuild: %v", diff)
+			}
+		})
+	}
+}
+
 func TestSidecarScope(t *testing.T) {
 	ps := NewPushContext()
 	env := &Environment{Watcher: mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"})}
@@ -1042,6 +1218,46 @@ func TestSidecarScope(t *testing.T) {
 	}
 }
 
+func TestSidecarScopeDeferred(t *testing.T) {
+	ps := NewPushContext()
+	env := &Environment{
+		Watcher:         mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"}),
+		ServesNamespace: func(string) bool { return false },
+	}
+	ps.Mesh = env.Mesh()
+	configStore := NewFakeStore()
+	for _, ns := range []string{"default", constants.IstioSystemNamespace} {
+		_, _ = configStore.Create(config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: collections.IstioNetworkingV1Alpha3Sidecars.Resource().GroupVersionKind(),
+				Name:             "foo",
+				Namespace:        ns,
+			},
+			Spec: &networking.Sidecar{
+				Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}},
+			},
+		})
+	}
+	env.IstioConfigStore = &istioConfigStore{ConfigStore: configStore}
+	if err := ps.initSidecarScopes(env); err != nil {
+		t.Fatalf("init sidecar scope failed: %v", err)
+	}
+
+	if _, f := ps.sidecarIndex.sidecarsByNamespace["default"]; f {
+		t.Fatalf("expected the sidecars of namespaces not served not to be converted upfront")
+	}
+	if ps.sidecarIndex.rootConfig == nil {
+		t.Fatalf("expected the root namespace sidecar to be kept")
+	}
+	scope := ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: "default"}, nil)
+	if got := scopeToSidecar(scope); got != "default/foo" {
+		t.Fatalf("expected the deferred sidecar to apply, got %s", got)
+	}
+	if again := ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: "default"}, nil); again != scope {
+		t.Fatalf("expected the deferred sidecar to be converted once")
+	}
+}
+
 func TestBestEffortInferServiceMTLSMode(t *testing.T) {
 	const partialNS string = "partial"
 	const wholeNS string = "whole"
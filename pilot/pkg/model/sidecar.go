@@ -556,11 +556,62 @@ func (sc *SidecarScope) DestinationRule(svc host.Name) *config.Config {
 	return sc.destinationRules[svc]
 }
 
+// DestinationRuleByName returns the destinationrule with the given name and namespace, if it applies to any
+// service visible to the sidecar.
+func (sc *SidecarScope) DestinationRuleByName(name, namespace string) *config.Config {
+	if sc == nil {
+		return nil
+	}
+	for _, dr := range sc.destinationRules {
+		if dr.Name == name && dr.Namespace == namespace {
+			return dr
+		}
+	}
+	return nil
+}
+
+// VirtualServiceByName returns the virtualservice with the given name and namespace, if it is imported by
+// any egress listener of the sidecar.
+func (sc *SidecarScope) VirtualServiceByName(name, namespace string) *config.Config {
+	if sc == nil {
+		return nil
+	}
+	for _, ilw := range sc.EgressListeners {
+		for i, vs := range ilw.virtualServices {
+			if vs.Name == name && vs.Namespace == namespace {
+				return &ilw.virtualServices[i]
+			}
+		}
+	}
+	return nil
+}
+
 // Services returns the list of services that are visible to a sidecar.
 func (sc *SidecarScope) Services() []*Service {
 	return sc.services
 }
 
+// ServicesForHostname returns the services visible to the sidecar whose hostname is matched by the
+// given, possibly wildcarded, hostname.
+func (sc *SidecarScope) ServicesForHostname(hostname host.Name) []*Service {
+	if sc == nil {
+		return nil
+	}
+	if svc, f := sc.servicesByHostname[hostname]; f {
+		return []*Service{svc}
+	}
+	if !hostname.IsWildCarded() {
+		return nil
+	}
+	out := make([]*Service, 0)
+	for _, svc := range sc.services {
+		if svc.Hostname.SubsetOf(hostname) {
+			out = append(out, svc)
+		}
+	}
+	return out
+}
+
 // Return filtered services through the hosts field in the egress portion of the Sidecar config.
 // Note that the returned service could be trimmed.
 func (ilw *IstioEgressListenerWrapper) selectServices(services []*Service, configNamespace string, hosts map[string][]host.Name) []*Service {
@@ -36,8 +36,10 @@ import (
 	tpb "istio.io/api/telemetry/v1alpha1"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/accesslogging"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/tracesampling"
 	"istio.io/istio/pkg/util/protomarshal"
 	istiolog "istio.io/pkg/log"
 )
@@ -62,6 +64,15 @@ type Telemetries struct {
 	// Computed meshConfig
 	meshConfig *meshconfig.MeshConfig
 
+	// The extension providers of the push context.
+	extensionProviders []*ExtensionProvider
+
+	// Maps from Telemetry to the trace sampling rules set with the tracesampling.Annotation.
+	tracingSampling map[NamespacedName][]tracesampling.Rule
+
+	// Maps from Telemetry to the access logging options set with the accesslogging.Annotation.
+	accessLogging map[NamespacedName]*accesslogging.Config
+
 	// computedMetricsFilters contains the set of cached HCM/listener filters for the metrics portion.
 	// These filters are extremely costly, as we insert them into every listener on every proxy, and to
 	// generate them we need to merge many telemetry specs and perform 2 Any marshals.
@@ -94,12 +105,15 @@ type metricsKey struct {
 }
 
 // getTelemetries returns the Telemetry configurations for the given environment.
-func getTelemetries(env *Environment) (*Telemetries, error) {
+func getTelemetries(env *Environment, extensionProviders []*ExtensionProvider) (*Telemetries, error) {
 	telemetries := &Telemetries{
 		NamespaceToTelemetries: map[string][]Telemetry{},
 		RootNamespace:          env.Mesh().GetRootNamespace(),
 		meshConfig:             env.Mesh(),
+		extensionProviders:     extensionProviders,
 		computedMetricsFilters: map[metricsKey]interface{}{},
+		tracingSampling:        map[NamespacedName][]tracesampling.Rule{},
+		accessLogging:          map[NamespacedName]*accesslogging.Config{},
 	}
 
 	fromEnv, err := env.List(collections.IstioTelemetryV1Alpha1Telemetries.Resource().GroupVersionKind(), NamespaceAll)
@@ -113,6 +127,24 @@ func getTelemetries(env *Environment) (*Telemetries, error) {
 			Namespace: config.Namespace,
 			Spec:      config.Spec.(*tpb.Telemetry),
 		}
+		rules, err := tracesampling.FromAnnotations(config.Annotations)
+		if err == nil {
+			err = tracesampling.Validate(rules)
+		}
+		if err != nil {
+			telemetryLog.Warnf("ignoring trace sampling rules of telemetry %s/%s: %v", config.Namespace, config.Name, err)
+		} else if len(rules) > 0 {
+			telemetries.tracingSampling[NamespacedName{Name: config.Name, Namespace: config.Namespace}] = rules
+		}
+		logging, err := accesslogging.FromAnnotations(config.Annotations)
+		if err == nil {
+			err = logging.Validate()
+		}
+		if err != nil {
+			telemetryLog.Warnf("ignoring access logging options of telemetry %s/%s: %v", config.Namespace, config.Name, err)
+		} else if logging != nil {
+			telemetries.accessLogging[NamespacedName{Name: config.Name, Namespace: config.Namespace}] = logging
+		}
 		telemetries.NamespaceToTelemetries[config.Namespace] = append(telemetries.NamespaceToTelemetries[config.Namespace], telemetry)
 	}
 
@@ -130,6 +162,9 @@ type telemetryFilterConfig struct {
 	Metrics       bool
 	AccessLogging bool
 	LogsFilter    *tpb.AccessLogging_Filter
+
+	// ExtensionProvider is set instead of Provider for the provider types MeshConfig does not support yet.
+	ExtensionProvider *ExtensionProvider
 }
 
 func (t telemetryFilterConfig) MetricsForClass(c networking.ListenerClass) []metricsOverride {
@@ -164,19 +199,29 @@ type computedTelemetries struct {
 	Metrics []*tpb.Metrics
 	Logging []*tpb.AccessLogging
 	Tracing []*tpb.Tracing
+	// TracingSampling are the trace sampling rules, from the most to the least specific Telemetry.
+	TracingSampling []tracesampling.Rule
+	// LoggingOptions are the merged access logging options.
+	LoggingOptions *accesslogging.Config
 }
 
 type TracingConfig struct {
-	Provider                     *meshconfig.MeshConfig_ExtensionProvider
+	Provider *meshconfig.MeshConfig_ExtensionProvider
+	// ExtensionProvider is set instead of Provider for the provider types MeshConfig does not support yet.
+	ExtensionProvider            *ExtensionProvider
 	Disabled                     bool
 	RandomSamplingPercentage     float64
 	CustomTags                   map[string]*tpb.Tracing_CustomTag
 	UseRequestIDForTraceSampling bool
+	// SamplingRules override RandomSamplingPercentage for the requests they match. The first matching rule applies.
+	SamplingRules []tracesampling.Rule
 }
 
 type LoggingConfig struct {
 	Providers []*meshconfig.MeshConfig_ExtensionProvider
 	Filter    *tpb.AccessLogging_Filter
+	// Options are the sampling, format and listener class overrides of the access logs.
+	Options *accesslogging.Config
 }
 
 // AccessLogging returns the logging configuration for a given proxy. If nil is returned, access logs
@@ -187,7 +232,7 @@ func (t *Telemetries) AccessLogging(proxy *Proxy) *LoggingConfig {
 	if len(ct.Logging) == 0 && len(t.meshConfig.GetDefaultProviders().GetAccessLogging()) == 0 {
 		return nil
 	}
-	cfg := LoggingConfig{}
+	cfg := LoggingConfig{Options: ct.LoggingOptions}
 	providers, f := mergeLogs(ct.Logging, t.meshConfig)
 	cfg.Filter = f
 	for _, p := range providers.SortedList() {
@@ -226,8 +271,14 @@ func (t *Telemetries) Tracing(proxy *Proxy) *TracingConfig {
 	cfg := TracingConfig{
 		Provider:                     t.fetchProvider(supportedProvider),
 		UseRequestIDForTraceSampling: true,
+		SamplingRules:                ct.TracingSampling,
 	}
 	if cfg.Provider == nil {
+		if p := findExtensionProvider(t.extensionProviders, supportedProvider); p != nil && p.OpenTelemetry != nil {
+			cfg.ExtensionProvider = p
+		}
+	}
+	if cfg.Provider == nil && cfg.ExtensionProvider == nil {
 		cfg.Disabled = true
 		return &cfg
 	}
@@ -271,6 +322,28 @@ func (t *Telemetries) Tracing(proxy *Proxy) *TracingConfig {
 	return &cfg
 }
 
+// MetricsExport returns the opentelemetry provider the metrics of a given proxy are exported to, or nil if the
+// Telemetries of the proxy do not select one. The proxy stats are exported at once, so if several providers are
+// selected, the first one by name is used.
+func (t *Telemetries) MetricsExport(proxy *Proxy) *ExtensionProvider {
+	if t == nil {
+		return nil
+	}
+	ct := t.applicableTelemetries(proxy)
+	tmm := mergeMetrics(ct.Metrics, t.meshConfig)
+	names := make([]string, 0, len(tmm))
+	for k := range tmm {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if p := findExtensionProvider(t.extensionProviders, k); p.GetOpenTelemetry().GetMetrics() != nil {
+			return p
+		}
+	}
+	return nil
+}
+
 // HTTPFilters computes the HttpFilter for a given proxy/class
 func (t *Telemetries) HTTPFilters(proxy *Proxy, class networking.ListenerClass) []*hcm.HttpFilter {
 	if res := t.telemetryFilters(proxy, class, networking.ListenerProtocolHTTP); res != nil {
@@ -299,6 +372,8 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 	ms := []*tpb.Metrics{}
 	ls := []*tpb.AccessLogging{}
 	ts := []*tpb.Tracing{}
+	samplingRules := [][]tracesampling.Rule{}
+	var loggingOptions *accesslogging.Config
 	key := telemetryKey{}
 	if t.RootNamespace != "" {
 		telemetry := t.namespaceWideTelemetryConfig(t.RootNamespace)
@@ -307,6 +382,8 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, telemetry.Spec.GetMetrics()...)
 			ls = append(ls, telemetry.Spec.GetAccessLogging()...)
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			samplingRules = append(samplingRules, t.tracingSampling[key.Root])
+			loggingOptions = accesslogging.Merge(loggingOptions, t.accessLogging[key.Root])
 		}
 	}
 
@@ -317,6 +394,8 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, telemetry.Spec.GetMetrics()...)
 			ls = append(ls, telemetry.Spec.GetAccessLogging()...)
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			samplingRules = append(samplingRules, t.tracingSampling[key.Namespace])
+			loggingOptions = accesslogging.Merge(loggingOptions, t.accessLogging[key.Namespace])
 		}
 	}
 
@@ -331,15 +410,29 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, spec.GetMetrics()...)
 			ls = append(ls, spec.GetAccessLogging()...)
 			ts = append(ts, spec.GetTracing()...)
+			samplingRules = append(samplingRules, t.tracingSampling[key.Workload])
+			loggingOptions = accesslogging.Merge(loggingOptions, t.accessLogging[key.Workload])
 			break
 		}
 	}
 
+	// The rules of the most specific Telemetry are evaluated first.
+	var rules []tracesampling.Rule
+	for i := len(samplingRules) - 1; i >= 0; i-- {
+		rules = append(rules, samplingRules[i]...)
+	}
+	if len(rules) > tracesampling.MaxRules {
+		// Each rule copies the routes it applies to, so their number is bounded for the workload as well.
+		rules = rules[:tracesampling.MaxRules]
+	}
+
 	return computedTelemetries{
-		telemetryKey: key,
-		Metrics:      ms,
-		Logging:      ls,
-		Tracing:      ts,
+		telemetryKey:    key,
+		Metrics:         ms,
+		Logging:         ls,
+		Tracing:         ts,
+		TracingSampling: rules,
+		LoggingOptions:  loggingOptions,
 	}
 }
 
@@ -379,21 +472,26 @@ func (t *Telemetries) telemetryFilters(proxy *Proxy, class networking.ListenerCl
 		allKeys.Insert(k)
 	}
 	for _, k := range allKeys.SortedList() {
-		p := t.fetchProvider(k)
-		if p == nil {
-			continue
-		}
 		_, logging := tml[k]
 		_, metrics := tmm[k]
 		cfg := telemetryFilterConfig{
-			Provider:      p,
+			Provider:      t.fetchProvider(k),
 			metricsConfig: tmm[k],
 			AccessLogging: logging,
 			Metrics:       metrics,
 			LogsFilter:    logsFilter,
 		}
+		if cfg.Provider == nil {
+			// Only the metrics of the opentelemetry providers are supported in filters.
+			ep := findExtensionProvider(t.extensionProviders, k)
+			if ep.GetOpenTelemetry().GetMetrics() == nil || !metrics {
+				continue
+			}
+			cfg.ExtensionProvider = ep
+		}
 		m = append(m, cfg)
 	}
+	m = mergeStatsProviders(m)
 
 	var res interface{}
 	// Finally, compute the actual filters based on the protoc
@@ -623,6 +721,97 @@ func mergeMetrics(metrics []*tpb.Metrics, mesh *meshconfig.MeshConfig) map[strin
 	return processed
 }
 
+// mergeStatsProviders merges the Prometheus and OpenTelemetry metrics providers into the first of them, as the
+// metrics of both are generated by a single stats filter. The filter generates the metrics and tags any of them
+// needs: a metric is only dropped, and a tag only removed, when all the providers do it. When the providers set a
+// tag to different values, the first one in name order applies.
+func mergeStatsProviders(cfgs []telemetryFilterConfig) []telemetryFilterConfig {
+	out := make([]telemetryFilterConfig, 0, len(cfgs))
+	stats := -1
+	for _, cfg := range cfgs {
+		if !cfg.Metrics || (cfg.Provider.GetPrometheus() == nil && cfg.ExtensionProvider.GetOpenTelemetry() == nil) {
+			out = append(out, cfg)
+			continue
+		}
+		if stats < 0 {
+			stats = len(out)
+			out = append(out, cfg)
+			continue
+		}
+		out[stats].ClientMetrics = mergeMetricsOverrides(out[stats].ClientMetrics, cfg.ClientMetrics)
+		out[stats].ServerMetrics = mergeMetricsOverrides(out[stats].ServerMetrics, cfg.ServerMetrics)
+	}
+	return out
+}
+
+// mergeMetricsOverrides merges the metric overrides of two providers sharing the stats filter. A metric without
+// overrides is generated with its default tags for one of them.
+func mergeMetricsOverrides(a, b []metricsOverride) []metricsOverride {
+	bm := make(map[string]metricsOverride, len(b))
+	for _, o := range b {
+		bm[o.Name] = o
+	}
+	merged := map[string]metricsOverride{}
+	for _, o := range a {
+		other, f := bm[o.Name]
+		merged[o.Name] = metricsOverride{
+			Name:     o.Name,
+			Disabled: f && o.Disabled && other.Disabled,
+			Tags:     mergeTagOverrides(o.Tags, other.Tags),
+		}
+	}
+	for _, o := range b {
+		if _, f := merged[o.Name]; !f {
+			merged[o.Name] = metricsOverride{Name: o.Name, Tags: mergeTagOverrides(nil, o.Tags)}
+		}
+	}
+	out := make([]metricsOverride, 0, len(merged))
+	for _, o := range merged {
+		if o.Disabled || len(o.Tags) > 0 {
+			out = append(out, o)
+		}
+	}
+	// Keep order deterministic
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// mergeTagOverrides merges the tag overrides of a metric for two providers, the values set by a winning over the
+// ones set by b.
+func mergeTagOverrides(a, b []tagOverride) []tagOverride {
+	bm := make(map[string]tagOverride, len(b))
+	for _, t := range b {
+		bm[t.Name] = t
+	}
+	merged := map[string]tagOverride{}
+	for _, t := range a {
+		other, f := bm[t.Name]
+		switch {
+		case !t.Remove:
+			merged[t.Name] = t
+		case f:
+			// Removed only if both remove it, otherwise set by b.
+			merged[t.Name] = other
+		}
+	}
+	for _, t := range b {
+		if _, f := merged[t.Name]; !f && !t.Remove {
+			merged[t.Name] = t
+		}
+	}
+	out := make([]tagOverride, 0, len(merged))
+	for _, t := range merged {
+		out = append(out, t)
+	}
+	// Keep order deterministic
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
 func getProviderNames(providers []*tpb.ProviderRef) []string {
 	res := make([]string, 0, len(providers))
 	for _, p := range providers {
@@ -671,8 +860,8 @@ func statsRootIDForClass(class networking.ListenerClass) string {
 func buildHTTPTelemetryFilter(class networking.ListenerClass, filterConfigs []telemetryFilterConfig) []*hcm.HttpFilter {
 	res := []*hcm.HttpFilter{}
 	for _, cfg := range filterConfigs {
-		switch cfg.Provider.GetProvider().(type) {
-		case *meshconfig.MeshConfig_ExtensionProvider_Prometheus:
+		switch {
+		case cfg.Provider.GetPrometheus() != nil, cfg.ExtensionProvider.GetOpenTelemetry() != nil:
 			if !cfg.Metrics {
 				// No logging for prometheus
 				continue
@@ -695,7 +884,7 @@ func buildHTTPTelemetryFilter(class networking.ListenerClass, filterConfigs []te
 				ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: networking.MessageToAny(wasmConfig)},
 			}
 			res = append(res, f)
-		case *meshconfig.MeshConfig_ExtensionProvider_Stackdriver:
+		case cfg.Provider.GetStackdriver() != nil:
 			cfg := generateSDConfig(class, cfg)
 			vmConfig := ConstructVMConfig("", "envoy.wasm.null.stackdriver")
 			vmConfig.VmConfig.VmId = stackdriverVMID(class)
@@ -714,7 +903,7 @@ func buildHTTPTelemetryFilter(class networking.ListenerClass, filterConfigs []te
 			}
 			res = append(res, f)
 		default:
-			// Only prometheus and SD supported currently
+			// Only prometheus, SD and OpenTelemetry supported currently
 			continue
 		}
 	}
@@ -724,8 +913,8 @@ func buildHTTPTelemetryFilter(class networking.ListenerClass, filterConfigs []te
 func buildTCPTelemetryFilter(class networking.ListenerClass, telemetryConfigs []telemetryFilterConfig) []*listener.Filter {
 	res := []*listener.Filter{}
 	for _, telemetryCfg := range telemetryConfigs {
-		switch telemetryCfg.Provider.GetProvider().(type) {
-		case *meshconfig.MeshConfig_ExtensionProvider_Prometheus:
+		switch {
+		case telemetryCfg.Provider.GetPrometheus() != nil, telemetryCfg.ExtensionProvider.GetOpenTelemetry() != nil:
 			cfg := generateStatsConfig(class, telemetryCfg)
 			vmConfig := ConstructVMConfig("/etc/istio/extensions/stats-filter.compiled.wasm", "envoy.wasm.stats")
 			root := statsRootIDForClass(class)
@@ -744,7 +933,7 @@ func buildTCPTelemetryFilter(class networking.ListenerClass, telemetryConfigs []
 				ConfigType: &listener.Filter_TypedConfig{TypedConfig: networking.MessageToAny(wasmConfig)},
 			}
 			res = append(res, f)
-		case *meshconfig.MeshConfig_ExtensionProvider_Stackdriver:
+		case telemetryCfg.Provider.GetStackdriver() != nil:
 			cfg := generateSDConfig(class, telemetryCfg)
 			vmConfig := ConstructVMConfig("", "envoy.wasm.null.stackdriver")
 			vmConfig.VmConfig.VmId = stackdriverVMID(class)
@@ -763,7 +952,7 @@ func buildTCPTelemetryFilter(class networking.ListenerClass, telemetryConfigs []
 			}
 			res = append(res, f)
 		default:
-			// Only prometheus and SD supported currently
+			// Only prometheus, SD and OpenTelemetry supported currently
 			continue
 		}
 	}
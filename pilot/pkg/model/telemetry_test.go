@@ -16,6 +16,7 @@ package model
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
@@ -30,13 +31,27 @@ import (
 	tpb "istio.io/api/telemetry/v1alpha1"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/accesslogging"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/tracesampling"
 )
 
 func createTestTelemetries(configs []config.Config, t *testing.T) *Telemetries {
 	t.Helper()
+	return createTestTelemetriesWithExtensionProviders(configs, "", t)
+}
+
+// createTestTelemetriesWithExtensionProviders creates the telemetries with the extension providers parsed from
+// the given YAML list.
+func createTestTelemetriesWithExtensionProviders(configs []config.Config, extensionProviders string, t *testing.T) *Telemetries {
+	t.Helper()
+
+	providers, err := ParseExtensionProviders(extensionProviders, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	store := &telemetryStore{}
 	for _, cfg := range configs {
@@ -47,7 +62,7 @@ func createTestTelemetries(configs []config.Config, t *testing.T) *Telemetries {
 		IstioConfigStore: MakeIstioStore(store),
 		Watcher:          mesh.NewFixedWatcher(&m),
 	}
-	telemetries, err := getTelemetries(environment)
+	telemetries, err := getTelemetries(environment, providers)
 	if err != nil {
 		t.Fatalf("getTelemetries failed: %v", err)
 	}
@@ -328,6 +343,39 @@ const (
 	reportingDisabled = !reportingEnabled
 )
 
+func TestAccessLoggingOptions(t *testing.T) {
+	sidecar := &Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+	withOptions := func(cfg config.Config, options string) config.Config {
+		cfg.Annotations = map[string]string{accesslogging.Annotation: options}
+		return cfg
+	}
+	logging := &tpb.Telemetry{
+		AccessLogging: []*tpb.AccessLogging{{Providers: []*tpb.ProviderRef{{Name: "envoy"}}}},
+	}
+	telemetry := createTestTelemetries([]config.Config{
+		withOptions(newTelemetry("istio-system", logging), "{sampling: {percentage: 10}, listenerClasses: {gateway: {format: ecs}}}"),
+		withOptions(newTelemetry("default", &tpb.Telemetry{}), "{listenerClasses: {gateway: {sampling: {percentage: 100}}}}"),
+		withOptions(newTelemetry("other", &tpb.Telemetry{}), "{format: xml}"),
+	}, t)
+
+	got := telemetry.AccessLogging(sidecar)
+	if got == nil {
+		t.Fatalf("expected an access logging configuration")
+	}
+	gateway := got.Options.ForClass(accesslogging.Gateway)
+	if gateway.Format != accesslogging.FormatECS || *gateway.Sampling.Percentage != 100 {
+		t.Fatalf("expected the namespace options to override the root namespace ones, got %+v", gateway)
+	}
+	if inbound := got.Options.ForClass(accesslogging.SidecarInbound); *inbound.Sampling.Percentage != 10 {
+		t.Fatalf("expected the root namespace sampling, got %+v", inbound)
+	}
+
+	other := &Proxy{ConfigNamespace: "other", Metadata: &NodeMetadata{}}
+	if got := telemetry.AccessLogging(other); got == nil || got.Options.Format != "" {
+		t.Fatalf("expected the invalid options to be ignored, got %v", got)
+	}
+}
+
 func TestTracing(t *testing.T) {
 	sidecar := &Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
 	envoy := &tpb.Telemetry{
@@ -542,6 +590,109 @@ func TestTracing(t *testing.T) {
 	}
 }
 
+func TestTracingSamplingRules(t *testing.T) {
+	sidecar := &Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+	withRules := func(cfg config.Config, rules string) config.Config {
+		cfg.Annotations = map[string]string{tracesampling.Annotation: rules}
+		return cfg
+	}
+	tracing := &tpb.Telemetry{
+		Tracing: []*tpb.Tracing{{Providers: []*tpb.ProviderRef{{Name: "envoy"}}}},
+	}
+	telemetry := createTestTelemetries([]config.Config{
+		withRules(newTelemetry("istio-system", tracing), "[{match: {route: health}, randomSamplingPercentage: 0}]"),
+		withRules(newTelemetry("default", &tpb.Telemetry{}), "[{match: {route: orders}, randomSamplingPercentage: 100}]"),
+		withRules(newTelemetry("other", &tpb.Telemetry{}), "[{randomSamplingPercentage: 200}]"),
+	}, t)
+
+	got := telemetry.Tracing(sidecar)
+	if got == nil {
+		t.Fatalf("expected a tracing configuration")
+	}
+	var routes []string
+	for _, r := range got.SamplingRules {
+		routes = append(routes, r.Match.Route)
+	}
+	if diff := cmp.Diff(routes, []string{"orders", "health"}); diff != "" {
+		t.Fatalf("expected the namespace rules first, got diff %v", diff)
+	}
+
+	other := &Proxy{ConfigNamespace: "other", Metadata: &NodeMetadata{}}
+	if got := telemetry.Tracing(other); got == nil || len(got.SamplingRules) != 1 {
+		t.Fatalf("expected the invalid rules to be ignored, got %v", got)
+	}
+
+	many := "[" + strings.Repeat("{match: {route: root}, randomSamplingPercentage: 1}, ", tracesampling.MaxRules-1) +
+		"{match: {route: root}, randomSamplingPercentage: 1}]"
+	telemetry = createTestTelemetries([]config.Config{
+		withRules(newTelemetry("istio-system", tracing), many),
+		withRules(newTelemetry("default", &tpb.Telemetry{}), "[{match: {route: orders}, randomSamplingPercentage: 100}]"),
+	}, t)
+	got = telemetry.Tracing(sidecar)
+	if len(got.SamplingRules) != tracesampling.MaxRules || got.SamplingRules[0].Match.Route != "orders" {
+		t.Fatalf("expected the first %d rules, most specific first, got %v", tracesampling.MaxRules, got.SamplingRules)
+	}
+}
+
+func TestMetricsExport(t *testing.T) {
+	providers := `
+- {name: otel-tracing, opentelemetry: {service: tracing.istio-system.svc.cluster.local, port: 4317}}
+- {name: otel, opentelemetry: {service: otel.istio-system.svc.cluster.local, port: 4317, metrics: {}}}
+`
+	metrics := func(providers ...string) *tpb.Telemetry {
+		m := &tpb.Metrics{}
+		for _, p := range providers {
+			m.Providers = append(m.Providers, &tpb.ProviderRef{Name: p})
+		}
+		return &tpb.Telemetry{Metrics: []*tpb.Metrics{m}}
+	}
+	sidecar := &Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+
+	cases := []struct {
+		name string
+		cfgs []config.Config
+		want string
+	}{
+		{
+			name: "no telemetry",
+		},
+		{
+			name: "prometheus",
+			cfgs: []config.Config{newTelemetry("istio-system", metrics("prometheus"))},
+		},
+		{
+			name: "provider without metrics",
+			cfgs: []config.Config{newTelemetry("istio-system", metrics("otel-tracing"))},
+		},
+		{
+			name: "selected",
+			cfgs: []config.Config{newTelemetry("istio-system", metrics("prometheus", "otel"))},
+			want: "otel",
+		},
+		{
+			name: "overridden by namespace",
+			cfgs: []config.Config{
+				newTelemetry("istio-system", metrics("otel")),
+				newTelemetry("default", metrics("prometheus")),
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createTestTelemetriesWithExtensionProviders(tt.cfgs, providers, t).MetricsExport(sidecar)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected no provider, got %v", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Fatalf("expected provider %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestTelemetryFilters(t *testing.T) {
 	overrides := []*tpb.MetricsOverrides{{
 		Match: &tpb.MetricSelector{
@@ -559,6 +710,7 @@ func TestTelemetryFilters(t *testing.T) {
 			},
 		},
 	}}
+	providers := `[{name: otel, opentelemetry: {service: otel-collector.istio-system.svc.cluster.local, port: 4317, metrics: {}}}]`
 	sidecar := &Proxy{ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
 	emptyPrometheus := &tpb.Telemetry{
 		Metrics: []*tpb.Metrics{
@@ -598,6 +750,50 @@ func TestTelemetryFilters(t *testing.T) {
 			},
 		},
 	}
+	overridesOpenTelemetry := &tpb.Telemetry{
+		Metrics: []*tpb.Metrics{
+			{
+				Providers: []*tpb.ProviderRef{{Name: "otel"}},
+				Overrides: overrides,
+			},
+		},
+	}
+	prometheusAndOpenTelemetry := &tpb.Telemetry{
+		Metrics: []*tpb.Metrics{
+			{
+				Providers: []*tpb.ProviderRef{{Name: "otel"}, {Name: "prometheus"}},
+			},
+		},
+	}
+	mergedOverrides := &tpb.Telemetry{
+		Metrics: []*tpb.Metrics{
+			{
+				Providers: []*tpb.ProviderRef{{Name: "otel"}},
+				Overrides: []*tpb.MetricsOverrides{{
+					Match: &tpb.MetricSelector{
+						MetricMatch: &tpb.MetricSelector_Metric{
+							Metric: tpb.MetricSelector_REQUEST_COUNT,
+						},
+					},
+					Disabled: &types.BoolValue{Value: true},
+				}},
+			},
+			{
+				Providers: []*tpb.ProviderRef{{Name: "otel"}, {Name: "prometheus"}},
+				Overrides: []*tpb.MetricsOverrides{
+					overrides[0],
+					{
+						Match: &tpb.MetricSelector{
+							MetricMatch: &tpb.MetricSelector_Metric{
+								Metric: tpb.MetricSelector_REQUEST_DURATION,
+							},
+						},
+						Disabled: &types.BoolValue{Value: true},
+					},
+				},
+			},
+		},
+	}
 	overridesEmptyProvider := &tpb.Telemetry{
 		Metrics: []*tpb.Metrics{
 			{
@@ -743,6 +939,51 @@ func TestTelemetryFilters(t *testing.T) {
 				"istio.stats": `{"metrics":[{"dimensions":{"add":"bar"},"name":"requests_total","tags_to_remove":["remove"]}]}`,
 			},
 		},
+		{
+			"opentelemetry overrides",
+			[]config.Config{newTelemetry("istio-system", overridesOpenTelemetry)},
+			sidecar,
+			networking.ListenerClassSidecarOutbound,
+			networking.ListenerProtocolHTTP,
+			nil,
+			map[string]string{
+				"istio.stats": `{"metrics":[{"dimensions":{"add":"bar"},"name":"requests_total","tags_to_remove":["remove"]}]}`,
+			},
+		},
+		{
+			"opentelemetry tcp",
+			[]config.Config{newTelemetry("istio-system", overridesOpenTelemetry)},
+			sidecar,
+			networking.ListenerClassSidecarOutbound,
+			networking.ListenerProtocolTCP,
+			nil,
+			map[string]string{
+				"istio.stats": `{"metrics":[{"dimensions":{"add":"bar"},"name":"requests_total","tags_to_remove":["remove"]}]}`,
+			},
+		},
+		{
+			"prometheus and opentelemetry share the stats filter",
+			[]config.Config{newTelemetry("istio-system", prometheusAndOpenTelemetry)},
+			sidecar,
+			networking.ListenerClassSidecarOutbound,
+			networking.ListenerProtocolHTTP,
+			nil,
+			map[string]string{
+				"istio.stats": "{}",
+			},
+		},
+		{
+			"prometheus and opentelemetry overrides are merged",
+			[]config.Config{newTelemetry("istio-system", mergedOverrides)},
+			sidecar,
+			networking.ListenerClassSidecarOutbound,
+			networking.ListenerProtocolHTTP,
+			nil,
+			map[string]string{
+				"istio.stats": `{"metrics":[{"dimensions":{"add":"bar"},"name":"requests_total","tags_to_remove":["remove"]},` +
+					`{"name":"request_duration_milliseconds","drop":true}]}`,
+			},
+		},
 		{
 			"namespace overrides default provider",
 			[]config.Config{
@@ -799,7 +1040,7 @@ func TestTelemetryFilters(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			telemetry := createTestTelemetries(tt.cfgs, t)
+			telemetry := createTestTelemetriesWithExtensionProviders(tt.cfgs, providers, t)
 			telemetry.meshConfig.DefaultProviders = tt.defaultProviders
 			got := telemetry.telemetryFilters(tt.proxy, tt.class, tt.protocol)
 			res := map[string]string{}
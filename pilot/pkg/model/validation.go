@@ -26,10 +26,13 @@ import (
 
 // UnixAddressPrefix is the prefix used to indicate an address is for a Unix Domain socket. It is used in
 // ServiceEntry.Endpoint.Address message.
+// EnvoyInternalAddressPrefix is the prefix used to indicate an address is an Envoy internal listener of the
+// same proxy, addressed by name. It is used in Gateway and Sidecar binds, and ServiceEntry.Endpoint.Address.
 const (
-	UnixAddressPrefix      = "unix://"
-	PodIPAddressPrefix     = "0.0.0.0"
-	LocalhostAddressPrefix = "127.0.0.1"
+	UnixAddressPrefix          = "unix://"
+	EnvoyInternalAddressPrefix = "envoy://"
+	PodIPAddressPrefix         = "0.0.0.0"
+	LocalhostAddressPrefix     = "127.0.0.1"
 )
 
 // Validate ensures that the service object is well-defined
@@ -0,0 +1,176 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pkg/config"
+)
+
+// xdsCacheSnapshotFormat is bumped whenever the on-disk layout changes in an incompatible way.
+const xdsCacheSnapshotFormat = 1
+
+// XdsCacheSnapshotter is implemented by XdsCache implementations that can be persisted and
+// restored, allowing a restarted istiod to serve previously generated resources instead of
+// regenerating everything at once.
+type XdsCacheSnapshotter interface {
+	// WriteSnapshot serializes all cache entries, tagged with the given version, to w.
+	WriteSnapshot(w io.Writer, version string) error
+	// RestoreSnapshot loads entries from r into the cache. The snapshot is discarded entirely
+	// if its version does not match the one returned by currentVersion. Entries already in the
+	// cache are never overwritten, and nothing is restored if the cache was invalidated while
+	// reading. It returns the number of restored entries.
+	RestoreSnapshot(r io.Reader, currentVersion func() string) (int, error)
+}
+
+type xdsCacheSnapshot struct {
+	Format  int                     `json:"format"`
+	Version string                  `json:"version"`
+	Entries []xdsCacheSnapshotEntry `json:"entries"`
+}
+
+type xdsCacheSnapshotEntry struct {
+	Key      string                    `json:"key"`
+	Resource []byte                    `json:"resource"`
+	Configs  []ConfigKey               `json:"configs,omitempty"`
+	Types    []config.GroupVersionKind `json:"types,omitempty"`
+}
+
+// snapshotEntry is a restored cache entry. It only carries the key and indexes; restored entries
+// are always considered cacheable since they were cacheable when written.
+type snapshotEntry struct {
+	key     string
+	configs []ConfigKey
+	types   []config.GroupVersionKind
+}
+
+var _ XdsCacheEntry = snapshotEntry{}
+
+func (s snapshotEntry) Key() string {
+	return s.key
+}
+
+func (s snapshotEntry) DependentTypes() []config.GroupVersionKind {
+	return s.types
+}
+
+func (s snapshotEntry) DependentConfigs() []ConfigKey {
+	return s.configs
+}
+
+func (s snapshotEntry) Cacheable() bool {
+	return true
+}
+
+var _ XdsCacheSnapshotter = &lruCache{}
+
+func (l *lruCache) WriteSnapshot(w io.Writer, version string) error {
+	l.mu.RLock()
+	// Invert the indexes so each entry records what it depends on.
+	configs := map[string][]ConfigKey{}
+	for ckey, keys := range l.configIndex {
+		for k := range keys {
+			configs[k] = append(configs[k], ckey)
+		}
+	}
+	types := map[string][]config.GroupVersionKind{}
+	for gvk, keys := range l.typesIndex {
+		for k := range keys {
+			types[k] = append(types[k], gvk)
+		}
+	}
+	snap := xdsCacheSnapshot{Format: xdsCacheSnapshotFormat, Version: version}
+	for _, ik := range l.store.Keys() {
+		v, ok := l.store.Peek(ik)
+		if !ok || v.(cacheValue).value == nil {
+			continue
+		}
+		k := ik.(string)
+		b, err := proto.Marshal(v.(cacheValue).value)
+		if err != nil {
+			l.mu.RUnlock()
+			return fmt.Errorf("failed to marshal cache entry %v: %v", k, err)
+		}
+		snap.Entries = append(snap.Entries, xdsCacheSnapshotEntry{
+			Key:      k,
+			Resource: b,
+			Configs:  configs[k],
+			Types:    types[k],
+		})
+	}
+	l.mu.RUnlock()
+
+	// Keep output deterministic, which makes snapshots diffable.
+	sort.Slice(snap.Entries, func(i, j int) bool {
+		return snap.Entries[i].Key < snap.Entries[j].Key
+	})
+	return json.NewEncoder(w).Encode(snap)
+}
+
+func (l *lruCache) RestoreSnapshot(r io.Reader, currentVersion func() string) (int, error) {
+	snap := xdsCacheSnapshot{}
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("failed to decode cache snapshot: %v", err)
+	}
+	if snap.Format != xdsCacheSnapshotFormat {
+		return 0, fmt.Errorf("unsupported cache snapshot format %d", snap.Format)
+	}
+
+	entries := make([]snapshotEntry, 0, len(snap.Entries))
+	values := make([]*discovery.Resource, 0, len(snap.Entries))
+	for _, e := range snap.Entries {
+		res := &discovery.Resource{}
+		if err := proto.Unmarshal(e.Resource, res); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal cache entry %v: %v", e.Key, err)
+		}
+		entries = append(entries, snapshotEntry{key: e.Key, configs: e.Configs, types: e.Types})
+		values = append(values, res)
+	}
+
+	l.mu.RLock()
+	invalidations := l.invalidations
+	l.mu.RUnlock()
+
+	// The version is computed after recording the invalidation count; if the cache is invalidated in
+	// between, we drop the snapshot rather than risk restoring stale entries.
+	if v := currentVersion(); v != snap.Version {
+		return 0, fmt.Errorf("cache snapshot version %q does not match current version %q", snap.Version, v)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.invalidations != invalidations {
+		return 0, fmt.Errorf("cache was invalidated while restoring snapshot")
+	}
+	restored := 0
+	for i, entry := range entries {
+		if l.store.Contains(entry.key) {
+			continue
+		}
+		l.store.Add(entry.key, cacheValue{value: values[i], token: l.token})
+		indexConfig(l.configIndex, entry.key, entry)
+		indexType(l.typesIndex, entry.key, entry)
+		restored++
+	}
+	size(l.store.Len())
+	return restored, nil
+}
@@ -33,6 +33,12 @@ type ConfigGenerator interface {
 	// once and shared across multiple invocations of this function.
 	BuildListeners(node *model.Proxy, push *model.PushContext) []*listener.Listener
 
+	// BuildDeltaListeners returns both a list of listeners that need to be pushed for a given proxy and a list of
+	// listeners that should be removed from it, along with whether they were actually computed as a delta.
+	// This is Delta LDS output.
+	BuildDeltaListeners(node *model.Proxy, push *model.PushContext, req *model.PushRequest,
+		watched *model.WatchedResource) ([]*listener.Listener, []string, bool)
+
 	// BuildClusters returns the list of clusters for the given proxy. This is the CDS output
 	BuildClusters(node *model.Proxy, req *model.PushRequest) ([]*discovery.Resource, model.XdsLogDetails)
 
@@ -44,6 +50,14 @@ type ConfigGenerator interface {
 	// BuildHTTPRoutes returns the list of HTTP routes for the given proxy. This is the RDS output
 	BuildHTTPRoutes(node *model.Proxy, req *model.PushRequest, routeNames []string) ([]*discovery.Resource, model.XdsLogDetails)
 
+	// BuildDeltaHTTPRoutes returns the list of HTTP routes that changed for the given proxy, along with whether
+	// they were actually computed as a delta. This is Delta RDS output.
+	BuildDeltaHTTPRoutes(node *model.Proxy, req *model.PushRequest,
+		routeNames []string) ([]*discovery.Resource, []string, model.XdsLogDetails, bool)
+
+	// BuildOnDemandVirtualHosts returns the virtual hosts requested by the given proxy. This is the VHDS output.
+	BuildOnDemandVirtualHosts(node *model.Proxy, req *model.PushRequest, names []string) []*discovery.Resource
+
 	// BuildNameTable returns list of hostnames and the associated IPs
 	BuildNameTable(node *model.Proxy, push *model.PushContext) *dnsProto.NameTable
 
@@ -15,6 +15,7 @@
 package v1alpha3
 
 import (
+	"math"
 	"strings"
 	"sync"
 
@@ -28,6 +29,7 @@ import (
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	formatters "github.com/envoyproxy/go-control-plane/envoy/extensions/formatter/req_without_query/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	pbtypes "github.com/gogo/protobuf/types"
 	otlpcommon "go.opentelemetry.io/proto/otlp/common/v1"
@@ -35,7 +37,9 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/accesslogging"
 	"istio.io/istio/pkg/config/xds"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/pkg/log"
@@ -74,6 +78,10 @@ const (
 	devStdout = "/dev/stdout"
 
 	celFilter = "envoy.access_loggers.extension_filters.cel"
+
+	// accessLogSamplingRuntimeKey is the runtime key of the access log sampling, which is not set on the proxies
+	// so that the percentage configured with the Telemetry API applies.
+	accessLogSamplingRuntimeKey = "istio.access_log.sampling"
 )
 
 var (
@@ -109,6 +117,64 @@ var (
 		},
 	}
 
+	// envoyJSONLogFormatECS is the JSON format of the access logs following the Elastic Common Schema.
+	envoyJSONLogFormatECS = &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"@timestamp":                structpb.NewStringValue("%START_TIME%"),
+			"http.request.method":       structpb.NewStringValue("%REQ(:METHOD)%"),
+			"http.request.id":           structpb.NewStringValue("%REQ(X-REQUEST-ID)%"),
+			"http.request.bytes":        structpb.NewStringValue("%BYTES_RECEIVED%"),
+			"http.response.status_code": structpb.NewStringValue("%RESPONSE_CODE%"),
+			"http.response.bytes":       structpb.NewStringValue("%BYTES_SENT%"),
+			"url.path":                  structpb.NewStringValue("%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%"),
+			"url.domain":                structpb.NewStringValue("%REQ(:AUTHORITY)%"),
+			"user_agent.original":       structpb.NewStringValue("%REQ(USER-AGENT)%"),
+			"network.protocol":          structpb.NewStringValue("%PROTOCOL%"),
+			"client.address":            structpb.NewStringValue("%DOWNSTREAM_REMOTE_ADDRESS%"),
+			"server.address":            structpb.NewStringValue("%DOWNSTREAM_LOCAL_ADDRESS%"),
+			"destination.address":       structpb.NewStringValue("%UPSTREAM_HOST%"),
+			"tls.client.server_name":    structpb.NewStringValue("%REQUESTED_SERVER_NAME%"),
+			"labels.duration_ms":        structpb.NewStringValue("%DURATION%"),
+			"labels.response_flags":     structpb.NewStringValue("%RESPONSE_FLAGS%"),
+			"labels.route_name":         structpb.NewStringValue("%ROUTE_NAME%"),
+			"labels.upstream_cluster":   structpb.NewStringValue("%UPSTREAM_CLUSTER%"),
+		},
+	}
+
+	// envoyJSONLogFormatOpenTelemetry is the JSON format of the access logs following the OpenTelemetry log
+	// data model, with attributes named after the HTTP semantic conventions.
+	envoyJSONLogFormatOpenTelemetry = &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"timestamp": structpb.NewStringValue("%START_TIME%"),
+			"attributes": structpb.NewStructValue(&structpb.Struct{
+				Fields: map[string]*structpb.Value{
+					"http.request.method":       structpb.NewStringValue("%REQ(:METHOD)%"),
+					"http.request.body.size":    structpb.NewStringValue("%BYTES_RECEIVED%"),
+					"http.response.status_code": structpb.NewStringValue("%RESPONSE_CODE%"),
+					"http.response.body.size":   structpb.NewStringValue("%BYTES_SENT%"),
+					"url.path":                  structpb.NewStringValue("%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%"),
+					"server.address":            structpb.NewStringValue("%REQ(:AUTHORITY)%"),
+					"user_agent.original":       structpb.NewStringValue("%REQ(USER-AGENT)%"),
+					"client.address":            structpb.NewStringValue("%DOWNSTREAM_REMOTE_ADDRESS%"),
+					"network.protocol.name":     structpb.NewStringValue("%PROTOCOL%"),
+					"istio.request_id":          structpb.NewStringValue("%REQ(X-REQUEST-ID)%"),
+					"istio.duration_ms":         structpb.NewStringValue("%DURATION%"),
+					"istio.response_flags":      structpb.NewStringValue("%RESPONSE_FLAGS%"),
+					"istio.route_name":          structpb.NewStringValue("%ROUTE_NAME%"),
+					"istio.upstream_cluster":    structpb.NewStringValue("%UPSTREAM_CLUSTER%"),
+					"istio.upstream_host":       structpb.NewStringValue("%UPSTREAM_HOST%"),
+				},
+			}),
+		},
+	}
+
+	// envoyJSONLogFormatPresets maps the names of the JSON format presets to their format.
+	envoyJSONLogFormatPresets = map[string]*structpb.Struct{
+		accesslogging.FormatIstio:         EnvoyJSONLogFormatIstio,
+		accesslogging.FormatECS:           envoyJSONLogFormatECS,
+		accesslogging.FormatOpenTelemetry: envoyJSONLogFormatOpenTelemetry,
+	}
+
 	// State logged by the metadata exchange filter about the upstream and downstream service instances
 	// We need to propagate these as part of access log service stream
 	// Logging them by default on the console may be an issue as the base64 encoded string is bound to be a big one.
@@ -149,7 +215,8 @@ func newAccessLogBuilder() *AccessLogBuilder {
 	}
 }
 
-func (b *AccessLogBuilder) setTCPAccessLog(push *model.PushContext, proxy *model.Proxy, tcp *tcp.TcpProxy) {
+func (b *AccessLogBuilder) setTCPAccessLog(push *model.PushContext, proxy *model.Proxy, tcp *tcp.TcpProxy,
+	class istionetworking.ListenerClass) {
 	mesh := push.Mesh
 	cfg := push.Telemetry.AccessLogging(proxy)
 
@@ -166,14 +233,18 @@ func (b *AccessLogBuilder) setTCPAccessLog(push *model.PushContext, proxy *model
 		return
 	}
 
-	if al := buildAccessLogFromTelemetry(push, cfg, false); len(al) != 0 {
+	if al := buildAccessLogFromTelemetry(push, cfg, class, false, false); len(al) != 0 {
 		tcp.AccessLog = append(tcp.AccessLog, al...)
 	}
 }
 
-func buildAccessLogFromTelemetry(push *model.PushContext, spec *model.LoggingConfig, forListener bool) []*accesslog.AccessLog {
+// buildAccessLogFromTelemetry builds the access logs of the Telemetry. The sampling with ratePerSecond only
+// applies to the HTTP access logs.
+func buildAccessLogFromTelemetry(push *model.PushContext, spec *model.LoggingConfig, class istionetworking.ListenerClass,
+	forListener, http bool) []*accesslog.AccessLog {
 	als := make([]*accesslog.AccessLog, 0)
-	telFilter := buildAccessLogFilterFromTelemetry(spec)
+	options := spec.Options.ForClass(accessLoggingClass(class))
+	telFilter := buildAccessLogFilterFromTelemetry(spec, options.Filter)
 	filters := []*accesslog.AccessLogFilter{}
 	if forListener {
 		filters = append(filters, addAccessLogFilter())
@@ -181,12 +252,20 @@ func buildAccessLogFromTelemetry(push *model.PushContext, spec *model.LoggingCon
 	if telFilter != nil {
 		filters = append(filters, telFilter)
 	}
+	if options.Sampling != nil {
+		switch {
+		case options.Sampling.Percentage != nil:
+			filters = append(filters, buildAccessLogSamplingFilter(*options.Sampling.Percentage))
+		case http:
+			filters = append(filters, buildAccessLogSampledOutLogFilter())
+		}
+	}
 
 	for _, p := range spec.Providers {
 		var al *accesslog.AccessLog
 		switch prov := p.Provider.(type) {
 		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLog:
-			al = buildEnvoyFileAccessLogHelper(prov.EnvoyFileAccessLog)
+			al = buildEnvoyFileAccessLogHelper(prov.EnvoyFileAccessLog, options.Format)
 		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyHttpAls:
 			al = buildHTTPGrpcAccessLogHelper(push, prov.EnvoyHttpAls)
 		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyTcpAls:
@@ -204,13 +283,35 @@ func buildAccessLogFromTelemetry(push *model.PushContext, spec *model.LoggingCon
 	return als
 }
 
-func buildAccessLogFilterFromTelemetry(spec *model.LoggingConfig) *accesslog.AccessLogFilter {
-	if spec == nil || spec.Filter == nil {
+// accessLoggingClass returns the class of the access logging options of the listeners of the given class.
+func accessLoggingClass(class istionetworking.ListenerClass) accesslogging.ListenerClass {
+	switch class {
+	case istionetworking.ListenerClassGateway:
+		return accesslogging.Gateway
+	case istionetworking.ListenerClassSidecarInbound:
+		return accesslogging.SidecarInbound
+	case istionetworking.ListenerClassSidecarOutbound:
+		return accesslogging.SidecarOutbound
+	default:
+		return ""
+	}
+}
+
+// buildAccessLogFilterFromTelemetry builds the CEL filter of the access logs. The filter of the listener class,
+// if any, overrides the one of the Telemetry.
+func buildAccessLogFilterFromTelemetry(spec *model.LoggingConfig, classFilter *accesslogging.Filter) *accesslog.AccessLogFilter {
+	var expression string
+	switch {
+	case classFilter != nil:
+		expression = classFilter.Expression
+	case spec != nil && spec.Filter != nil:
+		expression = spec.Filter.Expression
+	default:
 		return nil
 	}
 
 	fl := &cel.ExpressionFilter{
-		Expression: spec.Filter.Expression,
+		Expression: expression,
 	}
 
 	return &accesslog.AccessLogFilter{
@@ -239,12 +340,13 @@ func (b *AccessLogBuilder) setHTTPAccessLog(opts buildListenerOpts, connectionMa
 		return
 	}
 
-	if al := buildAccessLogFromTelemetry(opts.push, cfg, false); len(al) != 0 {
+	if al := buildAccessLogFromTelemetry(opts.push, cfg, opts.class, false, true); len(al) != 0 {
 		connectionManager.AccessLog = append(connectionManager.AccessLog, al...)
 	}
 }
 
-func (b *AccessLogBuilder) setListenerAccessLog(push *model.PushContext, proxy *model.Proxy, listener *listener.Listener) {
+func (b *AccessLogBuilder) setListenerAccessLog(push *model.PushContext, proxy *model.Proxy, listener *listener.Listener,
+	class istionetworking.ListenerClass) {
 	mesh := push.Mesh
 	if mesh.DisableEnvoyListenerLog {
 		return
@@ -264,7 +366,7 @@ func (b *AccessLogBuilder) setListenerAccessLog(push *model.PushContext, proxy *
 		return
 	}
 
-	if al := buildAccessLogFromTelemetry(push, cfg, true); len(al) != 0 {
+	if al := buildAccessLogFromTelemetry(push, cfg, class, true, false); len(al) != 0 {
 		listener.AccessLog = append(listener.AccessLog, al...)
 	}
 }
@@ -307,7 +409,8 @@ func buildTCPGrpcAccessLogHelper(push *model.PushContext, prov *meshconfig.MeshC
 	}
 }
 
-func buildEnvoyFileAccessLogHelper(prov *meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLogProvider) *accesslog.AccessLog {
+func buildEnvoyFileAccessLogHelper(prov *meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLogProvider,
+	preset string) *accesslog.AccessLog {
 	p := prov.Path
 	if p == "" {
 		p = devStdout
@@ -317,7 +420,16 @@ func buildEnvoyFileAccessLogHelper(prov *meshconfig.MeshConfig_ExtensionProvider
 		Path: p,
 	}
 	needsFormatter := false
-	if prov.LogFormat != nil {
+	if jsonLogStruct, f := envoyJSONLogFormatPresets[preset]; f {
+		// The format preset of the Telemetry overrides the format of the provider.
+		fl.AccessLogFormat = &fileaccesslog.FileAccessLog_LogFormat{
+			LogFormat: &core.SubstitutionFormatString{
+				Format: &core.SubstitutionFormatString_JsonFormat{
+					JsonFormat: jsonLogStruct,
+				},
+			},
+		}
+	} else if prov.LogFormat != nil {
 		switch logFormat := prov.LogFormat.LogFormat.(type) {
 		case *meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLogProvider_LogFormat_Text:
 			fl.AccessLogFormat, needsFormatter = buildFileAccessTextLogFormat(logFormat.Text)
@@ -582,6 +694,22 @@ func (b *AccessLogBuilder) buildFileAccessLog(mesh *meshconfig.MeshConfig) *acce
 	return al
 }
 
+// buildAccessLogSamplingFilter builds a filter logging the given percentage of the entries. The decision is based
+// on the request ID when there is one, so that all the proxies log the same requests.
+func buildAccessLogSamplingFilter(percentage float64) *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_RuntimeFilter{
+			RuntimeFilter: &accesslog.RuntimeFilter{
+				RuntimeKey: accessLogSamplingRuntimeKey,
+				PercentSampled: &xdstype.FractionalPercent{
+					Numerator:   uint32(math.Round(percentage * 10000)),
+					Denominator: xdstype.FractionalPercent_MILLION,
+				},
+			},
+		},
+	}
+}
+
 func addAccessLogFilter() *accesslog.AccessLogFilter {
 	return &accesslog.AccessLogFilter{
 		FilterSpecifier: &accesslog.AccessLogFilter_ResponseFlagFilter{
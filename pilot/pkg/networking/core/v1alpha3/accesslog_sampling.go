@@ -0,0 +1,195 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"math"
+	"time"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/accesslogging"
+)
+
+// The HTTP access logs sampled with a ratePerSecond are selected by a local rate limit filter that never rejects
+// the requests, but marks the ones above the rate with the accessLogSampledOutHeader. The header is turned into
+// dynamic metadata, which the access logs filter on, and removed, so that it never reaches the applications. The
+// header sent by the downstream is removed first, so that it cannot skip the access logs.
+//
+// The rate limit filter is served with ECDS: the listeners sharing an extension config share its token bucket, so
+// there is a single bucket per proxy and class of listeners.
+const (
+	// accessLogRateSamplingFilterName is the name of the rate limit filter of the access log sampling, and the
+	// prefix of its extension configs.
+	accessLogRateSamplingFilterName = "istio.access_log.rate_sampling"
+	// accessLogSampledOutFilterName is the name of the filters moving the accessLogSampledOutHeader to the
+	// dynamic metadata.
+	accessLogSampledOutFilterName = "istio.access_log.sampled_out"
+	accessLogSampledOutHeader     = "x-istio-access-log-sampled-out"
+	accessLogMetadataNamespace    = "istio.access_log"
+	accessLogSampledOutKey        = "sampled_out"
+)
+
+// accessLogRateSamplingConfigName returns the name of the extension config of the access log rate sampling of the
+// class of listeners.
+func accessLogRateSamplingConfigName(class accesslogging.ListenerClass) string {
+	if class == "" {
+		return accessLogRateSamplingFilterName
+	}
+	return accessLogRateSamplingFilterName + "." + string(class)
+}
+
+// accessLogRatePerSecond returns the ratePerSecond of the access log sampling of the class of listeners of the
+// proxy, if set.
+func accessLogRatePerSecond(push *model.PushContext, proxy *model.Proxy, class accesslogging.ListenerClass) (float64, bool) {
+	cfg := push.Telemetry.AccessLogging(proxy)
+	if cfg == nil {
+		return 0, false
+	}
+	sampling := cfg.Options.ForClass(class).Sampling
+	if sampling == nil || sampling.RatePerSecond == nil {
+		return 0, false
+	}
+	return *sampling.RatePerSecond, true
+}
+
+// buildAccessLogRateSamplingFilters builds the HTTP filters marking the requests above the ratePerSecond of the
+// access log sampling of the class of listeners, or returns nil if they are not sampled with a rate.
+func buildAccessLogRateSamplingFilters(push *model.PushContext, proxy *model.Proxy, class istionetworking.ListenerClass) []*hcm.HttpFilter {
+	logClass := accessLoggingClass(class)
+	if _, f := accessLogRatePerSecond(push, proxy, logClass); !f {
+		return nil
+	}
+	return []*hcm.HttpFilter{
+		buildAccessLogSampledOutFilter(accessLogSampledOutFilterName+".clear", "false"),
+		{
+			Name: accessLogRateSamplingConfigName(logClass),
+			ConfigType: &hcm.HttpFilter_ConfigDiscovery{
+				ConfigDiscovery: &core.ExtensionConfigSource{
+					ConfigSource: &core.ConfigSource{
+						ConfigSourceSpecifier: &core.ConfigSource_Ads{Ads: &core.AggregatedConfigSource{}},
+						ResourceApiVersion:    core.ApiVersion_V3,
+					},
+					TypeUrls: []string{"type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit"},
+				},
+			},
+		},
+		buildAccessLogSampledOutFilter(accessLogSampledOutFilterName, "true"),
+	}
+}
+
+// buildAccessLogSampledOutFilter builds a filter removing the accessLogSampledOutHeader, and setting the
+// sampled out dynamic metadata to the value if it is present.
+func buildAccessLogSampledOutFilter(name, value string) *hcm.HttpFilter {
+	return &hcm.HttpFilter{
+		Name: name,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: util.MessageToAny(&headertometadata.Config{
+				RequestRules: []*headertometadata.Config_Rule{{
+					Header: accessLogSampledOutHeader,
+					OnHeaderPresent: &headertometadata.Config_KeyValuePair{
+						MetadataNamespace: accessLogMetadataNamespace,
+						Key:               accessLogSampledOutKey,
+						Value:             value,
+					},
+					Remove: true,
+				}},
+			}),
+		},
+	}
+}
+
+// buildAccessLogRateSamplingConfigs builds the requested extension configs of the access log rate sampling of
+// the proxy.
+func buildAccessLogRateSamplingConfigs(push *model.PushContext, proxy *model.Proxy, names []string) []*core.TypedExtensionConfig {
+	requested := sets.NewSet(names...)
+	var out []*core.TypedExtensionConfig
+	for _, class := range []accesslogging.ListenerClass{"", accesslogging.Gateway, accesslogging.SidecarInbound, accesslogging.SidecarOutbound} {
+		name := accessLogRateSamplingConfigName(class)
+		if !requested.Contains(name) {
+			continue
+		}
+		rate, f := accessLogRatePerSecond(push, proxy, class)
+		if !f {
+			continue
+		}
+		out = append(out, &core.TypedExtensionConfig{
+			Name:        name,
+			TypedConfig: util.MessageToAny(buildAccessLogRateSamplingLimit(rate)),
+		})
+	}
+	return out
+}
+
+// buildAccessLogRateSamplingLimit builds the rate limit marking the requests above the rate. It is never
+// enforced: its token bucket only selects the requests that are logged.
+func buildAccessLogRateSamplingLimit(rate float64) *localratelimit.LocalRateLimit {
+	bucket := &xdstype.TokenBucket{
+		MaxTokens:     1,
+		TokensPerFill: wrapperspb.UInt32(1),
+		FillInterval:  durationpb.New(time.Duration(float64(time.Second) / rate)),
+	}
+	if rate > 1 {
+		bucket.MaxTokens = uint32(math.Round(rate))
+		bucket.TokensPerFill = wrapperspb.UInt32(bucket.MaxTokens)
+		bucket.FillInterval = durationpb.New(time.Second)
+	}
+	return &localratelimit.LocalRateLimit{
+		StatPrefix:  accessLogRateSamplingFilterName,
+		TokenBucket: bucket,
+		FilterEnabled: &core.RuntimeFractionalPercent{
+			DefaultValue: &xdstype.FractionalPercent{Numerator: 100, Denominator: xdstype.FractionalPercent_HUNDRED},
+		},
+		RequestHeadersToAddWhenNotEnforced: []*core.HeaderValueOption{{
+			Header: &core.HeaderValue{Key: accessLogSampledOutHeader, Value: "true"},
+			Append: wrapperspb.Bool(false),
+		}},
+	}
+}
+
+// buildAccessLogSampledOutLogFilter builds an access log filter skipping the requests marked as sampled out.
+func buildAccessLogSampledOutLogFilter() *accesslog.AccessLogFilter {
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_MetadataFilter{
+			MetadataFilter: &accesslog.MetadataFilter{
+				Matcher: &matcher.MetadataMatcher{
+					Filter: accessLogMetadataNamespace,
+					Path: []*matcher.MetadataMatcher_PathSegment{{
+						Segment: &matcher.MetadataMatcher_PathSegment_Key{Key: accessLogSampledOutKey},
+					}},
+					Value: &matcher.ValueMatcher{
+						MatchPattern: &matcher.ValueMatcher_StringMatch{
+							StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: "true"}},
+						},
+					},
+					Invert: true,
+				},
+				MatchIfKeyNotFound: wrapperspb.Bool(true),
+			},
+		},
+	}
+}
@@ -16,6 +16,7 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -23,22 +24,28 @@ import (
 	cel "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/filters/cel/v3"
 	grpcaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
 	otelaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/open_telemetry/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/conversion"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/gogo/protobuf/types"
 	"github.com/google/go-cmp/cmp"
 	otlpcommon "go.opentelemetry.io/proto/otlp/common/v1"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	tpb "istio.io/api/telemetry/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/accesslogging"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/util/protomarshal"
@@ -765,13 +772,183 @@ func TestBuildAccessLogFromTelemetry(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got := buildAccessLogFromTelemetry(tc.ctx, tc.spec, tc.forListener)
+			got := buildAccessLogFromTelemetry(tc.ctx, tc.spec, istionetworking.ListenerClassUndefined, tc.forListener, false)
 
 			assert.Equal(t, tc.expected, got)
 		})
 	}
 }
 
+func TestBuildAccessLogFromTelemetryOptions(t *testing.T) {
+	options, err := accesslogging.Parse(`
+sampling: {percentage: 10}
+listenerClasses:
+  gateway: {sampling: {percentage: 100}}
+  sidecarInbound: {format: ecs, filter: {expression: "response.code >= 500"}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := &model.LoggingConfig{
+		Providers: []*meshconfig.MeshConfig_ExtensionProvider{
+			{
+				Name: "stdout",
+				Provider: &meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLog{
+					EnvoyFileAccessLog: &meshconfig.MeshConfig_ExtensionProvider_EnvoyFileAccessLogProvider{
+						Path: devStdout,
+					},
+				},
+			},
+		},
+		Filter:  &tpb.AccessLogging_Filter{Expression: httpCodeExpress},
+		Options: options,
+	}
+	stdout := &fileaccesslog.FileAccessLog{
+		Path: devStdout,
+		AccessLogFormat: &fileaccesslog.FileAccessLog_LogFormat{
+			LogFormat: &core.SubstitutionFormatString{
+				Format: &core.SubstitutionFormatString_TextFormatSource{
+					TextFormatSource: &core.DataSource{
+						Specifier: &core.DataSource_InlineString{
+							InlineString: EnvoyTextLogFormat,
+						},
+					},
+				},
+			},
+		},
+	}
+	ecs := &fileaccesslog.FileAccessLog{
+		Path: devStdout,
+		AccessLogFormat: &fileaccesslog.FileAccessLog_LogFormat{
+			LogFormat: &core.SubstitutionFormatString{
+				Format: &core.SubstitutionFormatString_JsonFormat{
+					JsonFormat: envoyJSONLogFormatECS,
+				},
+			},
+		},
+	}
+	celFilterFor := func(expression string) *accesslog.AccessLogFilter {
+		return buildAccessLogFilterFromTelemetry(nil, &accesslogging.Filter{Expression: expression})
+	}
+
+	for _, tc := range []struct {
+		name     string
+		class    istionetworking.ListenerClass
+		expected []*accesslog.AccessLog
+	}{
+		{
+			name:  "gateway",
+			class: istionetworking.ListenerClassGateway,
+			expected: []*accesslog.AccessLog{
+				{
+					Name:       wellknown.FileAccessLog,
+					Filter:     buildAccessLogFilter(celFilterFor(httpCodeExpress), buildAccessLogSamplingFilter(100)),
+					ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: util.MessageToAny(stdout)},
+				},
+			},
+		},
+		{
+			name:  "sidecar inbound",
+			class: istionetworking.ListenerClassSidecarInbound,
+			expected: []*accesslog.AccessLog{
+				{
+					Name:       wellknown.FileAccessLog,
+					Filter:     buildAccessLogFilter(celFilterFor("response.code >= 500"), buildAccessLogSamplingFilter(10)),
+					ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: util.MessageToAny(ecs)},
+				},
+			},
+		},
+		{
+			name:  "sidecar outbound",
+			class: istionetworking.ListenerClassSidecarOutbound,
+			expected: []*accesslog.AccessLog{
+				{
+					Name:       wellknown.FileAccessLog,
+					Filter:     buildAccessLogFilter(celFilterFor(httpCodeExpress), buildAccessLogSamplingFilter(10)),
+					ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: util.MessageToAny(stdout)},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := buildAccessLogFromTelemetry(nil, spec, tc.class, false, false)
+
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	sampling := buildAccessLogSamplingFilter(0.1).GetRuntimeFilter().GetPercentSampled()
+	if sampling.GetNumerator() != 1000 || sampling.GetDenominator() != xdstype.FractionalPercent_MILLION {
+		t.Fatalf("expected 0.1%% to be 1000 per million, got %v", sampling)
+	}
+}
+
+func TestAccessLogRateSampling(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: default
+  namespace: istio-system
+  annotations:
+    telemetry.istio.io/accessLogging: "{sampling: {ratePerSecond: 5}, listenerClasses: {sidecarOutbound: {sampling: {ratePerSecond: 0.5}}}}"
+spec:
+  accessLogging:
+  - providers:
+    - name: envoy
+`})
+	proxy := cg.SetupProxy(nil)
+	listeners := cg.Listeners(proxy)
+	inbound := accessLogRateSamplingConfigName(accesslogging.SidecarInbound)
+	// The passthrough filter chains of the virtualInbound listener have no listener class.
+	sampling := map[string]bool{inbound: true, accessLogRateSamplingConfigName(""): true}
+
+	http, tcp := 0, 0
+	for _, fc := range xdstest.ExtractListener("virtualInbound", listeners).GetFilterChains() {
+		if h := xdstest.ExtractHTTPConnectionManager(nil, fc); h != nil {
+			http++
+			filters := h.GetHttpFilters()
+			if filters[0].GetName() != accessLogSampledOutFilterName+".clear" || !sampling[filters[1].GetName()] ||
+				filters[2].GetName() != accessLogSampledOutFilterName {
+				t.Fatalf("expected the access log rate sampling filters first, got %v", filters[:3])
+			}
+			for _, al := range h.GetAccessLog() {
+				if al.GetFilter().GetMetadataFilter() == nil {
+					t.Fatalf("expected the HTTP access logs to skip the sampled out requests, got %v", al.GetFilter())
+				}
+			}
+			continue
+		}
+		if tp := xdstest.ExtractTCPProxy(nil, fc); tp != nil {
+			tcp++
+			for _, al := range tp.GetAccessLog() {
+				if al.GetFilter() != nil {
+					t.Fatalf("expected the TCP access logs not to be sampled, got %v", al.GetFilter())
+				}
+			}
+		}
+	}
+	if http == 0 || tcp == 0 {
+		t.Fatalf("expected HTTP and TCP filter chains, got %d and %d", http, tcp)
+	}
+
+	outbound := accessLogRateSamplingConfigName(accesslogging.SidecarOutbound)
+	configs := cg.ConfigGen.BuildExtensionConfiguration(proxy, cg.PushContext(), []string{inbound, outbound, "unknown"})
+	if len(configs) != 2 {
+		t.Fatalf("expected the inbound and outbound rate sampling configs, got %v", configs)
+	}
+	for i, want := range []*xdstype.TokenBucket{
+		{MaxTokens: 5, TokensPerFill: wrapperspb.UInt32(5), FillInterval: durationpb.New(time.Second)},
+		{MaxTokens: 1, TokensPerFill: wrapperspb.UInt32(1), FillInterval: durationpb.New(2 * time.Second)},
+	} {
+		limit := &localratelimit.LocalRateLimit{}
+		if err := configs[i].GetTypedConfig().UnmarshalTo(limit); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, limit.GetTokenBucket(), want)
+	}
+}
+
 func TestAccessLogPatch(t *testing.T) {
 	// Regression test for https://github.com/istio/istio/issues/35778
 	cg := NewConfigGenTest(t, TestOptions{
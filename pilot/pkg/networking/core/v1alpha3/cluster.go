@@ -17,6 +17,7 @@ package v1alpha3
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -55,7 +56,7 @@ var defaultTransportSocketMatch = &cluster.Cluster_TransportSocketMatch{
 
 // deltaConfigTypes are used to detect changes and trigger delta calculations. When config updates has ONLY entries
 // in this map, then delta calculation is triggered.
-var deltaConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind)
+var deltaConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind, gvk.DestinationRule.Kind)
 
 // getDefaultCircuitBreakerThresholds returns a copy of the default circuit breaker thresholds for the given traffic direction.
 func getDefaultCircuitBreakerThresholds() *cluster.CircuitBreakers_Thresholds {
@@ -88,65 +89,192 @@ func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, req *mod
 	return configgen.buildClusters(proxy, req, services)
 }
 
-// BuildDeltaClusters generates the deltas (add and delete) for a given proxy. Currently, only service changes are reflected with deltas.
-// Otherwise, we fall back onto generating everything.
+// BuildDeltaClusters generates the deltas (add and delete) for a given proxy. Currently, only service and destination rule
+// changes are reflected with deltas. Otherwise, we fall back onto generating everything.
 func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, updates *model.PushRequest,
 	watched *model.WatchedResource) ([]*discovery.Resource, []string, model.XdsLogDetails, bool) {
+	if proxy.OnDemandXds() {
+		cl, lg := configgen.buildOnDemandClusters(proxy, updates, watched)
+		return cl, nil, lg, false
+	}
 	// if we can't use delta, fall back to generate all
 	if !shouldUseDelta(updates) {
 		cl, lg := configgen.BuildClusters(proxy, updates)
 		return cl, nil, lg, false
 	}
 
-	deletedClusters := make([]string, 0)
-	services := make([]*model.Service, 0)
 	// holds clusters per service, keyed by hostname.
 	serviceClusters := make(map[string]sets.Set)
-	// holds service ports, keyed by hostname.
-	// inner map holds port and its cluster name.
-	servicePorts := make(map[string]map[int]string)
-
 	for _, cluster := range watched.ResourceNames {
 		// WatchedResources.ResourceNames will contain the names of the clusters it is subscribed to. We can
-		// check with the name of our service (cluster names are in the format outbound|<port>||<hostname>.
-		_, _, svcHost, port := model.ParseSubsetKey(cluster)
+		// check with the name of our service (cluster names are in the format outbound|<port>|<subset>|<hostname>.
+		_, _, svcHost, _ := model.ParseSubsetKey(cluster)
 		if serviceClusters[string(svcHost)] == nil {
 			serviceClusters[string(svcHost)] = sets.NewSet()
 		}
 		serviceClusters[string(svcHost)].Insert(cluster)
-		if servicePorts[string(svcHost)] == nil {
-			servicePorts[string(svcHost)] = make(map[int]string)
-		}
-		servicePorts[string(svcHost)][port] = cluster
 	}
 
 	// In delta, we only care about the services that have changed.
+	services := make([]*model.Service, 0)
+	updatedServices := sets.NewSet()
+	removedServices := sets.NewSet()
+	addServices := func(svcs ...*model.Service) {
+		for _, svc := range svcs {
+			if !updatedServices.Contains(svc.Hostname.String()) {
+				updatedServices.Insert(svc.Hostname.String())
+				services = append(services, svc)
+			}
+		}
+	}
 	for key := range updates.ConfigsUpdated {
-		// get the service that has changed.
-		service := updates.Push.ServiceForHostname(proxy, host.Name(key.Name))
-		// if this service removed, we can conclude that it is a removed cluster.
-		if service == nil {
-			for cluster := range serviceClusters[key.Name] {
-				deletedClusters = append(deletedClusters, cluster)
+		switch key.Kind {
+		case gvk.ServiceEntry:
+			// get the service that has changed.
+			service := updates.Push.ServiceForHostname(proxy, host.Name(key.Name))
+			if service == nil {
+				// if this service removed, we can conclude that all of its clusters are removed.
+				removedServices.Insert(key.Name)
+			} else {
+				addServices(service)
 			}
-		} else {
-			services = append(services, service)
-			// If servicePorts has this service, that means it is old service.
-			if servicePorts[service.Hostname.String()] != nil {
-				oldPorts := servicePorts[service.Hostname.String()]
-				for port, cluster := range oldPorts {
-					// if this service port is removed, we can conclude that it is a removed cluster.
-					if _, exists := service.Ports.GetByPort(port); !exists {
-						deletedClusters = append(deletedClusters, cluster)
-					}
-				}
+		case gvk.DestinationRule:
+			svcs, ok := servicesForDestinationRule(proxy, key)
+			if !ok {
+				cl, lg := configgen.BuildClusters(proxy, updates)
+				return cl, nil, lg, false
 			}
+			addServices(svcs...)
 		}
 	}
 	clusters, log := configgen.buildClusters(proxy, updates, services)
+
+	// Rebuilding a service builds all of its clusters, so anything else we previously sent for it, such as
+	// removed ports or subsets, is gone.
+	built := sets.NewSet(extractNames(clusters)...)
+	deletedClusters := make([]string, 0)
+	for hostname := range removedServices.Union(updatedServices) {
+		for cluster := range serviceClusters[hostname] {
+			if !built.Contains(cluster) {
+				deletedClusters = append(deletedClusters, cluster)
+			}
+		}
+	}
+	sort.Strings(deletedClusters)
 	return clusters, deletedClusters, log, true
 }
 
+// servicesForDestinationRule returns the services whose clusters may be affected by the change of a destination rule.
+// This is both the services matching the rule now and the ones it matched before, in case the host changed or the rule
+// was removed. If the rule cannot be found, false is returned and the caller should fall back to a full push.
+func servicesForDestinationRule(proxy *model.Proxy, key model.ConfigKey) ([]*model.Service, bool) {
+	// With inheritance, a mesh or namespace wide rule applies to all services.
+	if features.EnableDestinationRuleInheritance {
+		return nil, false
+	}
+	found := false
+	services := make([]*model.Service, 0)
+	current := proxy.SidecarScope
+	for _, sc := range []*model.SidecarScope{current, proxy.PrevSidecarScope} {
+		dr := sc.DestinationRuleByName(key.Name, key.Namespace)
+		if dr == nil {
+			continue
+		}
+		found = true
+		// The clusters are built for the current scope, so the host of the rule is resolved there even if the
+		// rule comes from the previous scope: a service the proxy no longer sees has no cluster to rebuild.
+		services = append(services, current.ServicesForHostname(host.Name(dr.Spec.(*networking.DestinationRule).Host))...)
+	}
+	return services, found
+}
+
+func extractNames(resources model.Resources) []string {
+	names := make([]string, 0, len(resources))
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// buildOnDemandClusters generates the clusters of a proxy that discovers them on demand (ODCDS). Only the HTTP
+// connection managers of the outbound listeners request their clusters on demand: the clusters of the services the
+// proxy asked for are built, along with the clusters of the non-HTTP ports and of the destinations of the TCP and
+// TLS routes, which the other outbound listeners point at, and the ones that do not depend on services.
+func (configgen *ConfigGeneratorImpl) buildOnDemandClusters(proxy *model.Proxy, req *model.PushRequest,
+	watched *model.WatchedResource) ([]*discovery.Resource, model.XdsLogDetails) {
+	requested := sets.NewSet()
+	for _, cluster := range watched.ResourceNames {
+		_, _, svcHost, _ := model.ParseSubsetKey(cluster)
+		requested.Insert(string(svcHost))
+	}
+	requested = requested.Union(nonHTTPRouteDestinations(proxy))
+	requested.Delete("")
+
+	services := make([]*model.Service, 0, len(requested))
+	// nonHTTP are the services that were not requested, of which only the clusters of the non-HTTP ports are kept.
+	nonHTTP := map[host.Name]*model.Service{}
+	for _, svcHost := range requested.SortedList() {
+		if svc := req.Push.ServiceForHostname(proxy, host.Name(svcHost)); svc != nil {
+			services = append(services, svc)
+		}
+	}
+	for _, svc := range proxy.SidecarScope.Services() {
+		if requested.Contains(string(svc.Hostname)) || nonHTTP[svc.Hostname] != nil || !hasNonHTTPPort(svc) {
+			continue
+		}
+		nonHTTP[svc.Hostname] = svc
+		services = append(services, svc)
+	}
+
+	resources, logDetails := configgen.buildClusters(proxy, req, services)
+	if len(nonHTTP) == 0 {
+		return resources, logDetails
+	}
+	out := resources[:0]
+	for _, r := range resources {
+		if _, _, svcHost, port := model.ParseSubsetKey(r.Name); nonHTTP[svcHost] != nil {
+			if p, f := nonHTTP[svcHost].Ports.GetByPort(port); f && p.Protocol.IsHTTP() {
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out, logDetails
+}
+
+// hasNonHTTPPort checks if the outbound listeners of a port of the service forward the connections to its clusters
+// without an HTTP connection manager.
+func hasNonHTTPPort(svc *model.Service) bool {
+	for _, p := range svc.Ports {
+		if !p.Protocol.IsHTTP() {
+			return true
+		}
+	}
+	return false
+}
+
+// nonHTTPRouteDestinations returns the hostnames of the destinations of the TCP and TLS routes of the virtual
+// services visible to the proxy.
+func nonHTTPRouteDestinations(proxy *model.Proxy) sets.Set {
+	out := sets.NewSet()
+	for _, el := range proxy.SidecarScope.EgressListeners {
+		for _, cfg := range el.VirtualServices() {
+			vs := cfg.Spec.(*networking.VirtualService)
+			for _, r := range vs.Tcp {
+				for _, d := range r.Route {
+					out.Insert(d.GetDestination().GetHost())
+				}
+			}
+			for _, r := range vs.Tls {
+				for _, d := range r.Route {
+					out.Insert(d.GetDestination().GetHost())
+				}
+			}
+		}
+	}
+	return out
+}
+
 // buildClusters builds clusters for the proxy with the services passed.
 func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *model.PushRequest,
 	services []*model.Service) ([]*discovery.Resource, model.XdsLogDetails) {
@@ -165,6 +293,7 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 		resources = append(resources, ob...)
 		// Add a blackhole and passthrough cluster for catching traffic to unresolved routes
 		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster(), cb.buildDefaultPassthroughCluster())
+		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildUDPWeightedClusters(proxy)...)
 		clusters = append(clusters, outboundPatcher.insertedClusters()...)
 
 		// Setup inbound clusters
@@ -180,6 +309,7 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 		resources = append(resources, ob...)
 		// Gateways do not require the default passthrough cluster as they do not have original dst listeners.
 		clusters = patcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster())
+		clusters = patcher.conditionallyAppend(clusters, nil, cb.buildUDPWeightedClusters(proxy)...)
 		if proxy.Type == model.Router && proxy.MergedGateway != nil && proxy.MergedGateway.ContainsAutoPassthroughGateways {
 			clusters = append(clusters, configgen.buildOutboundSniDnatClusters(proxy, req, patcher)...)
 		}
@@ -197,6 +327,20 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 	return resources, model.XdsLogDetails{AdditionalInfo: fmt.Sprintf("cached:%v/%v", cacheStats.hits, cacheStats.hits+cacheStats.miss)}
 }
 
+// buildUDPCluster checks if a cluster is needed for a UDP port. Ports sharing their number with a port of another
+// protocol, such as DNS ports, share its cluster as well.
+func buildUDPCluster(service *model.Service, port *model.Port) bool {
+	if !features.EnableUDPListeners {
+		return false
+	}
+	for _, p := range service.Ports {
+		if p.Port == port.Port && p.Protocol != protocol.UDP {
+			return false
+		}
+	}
+	return true
+}
+
 func shouldUseDelta(updates *model.PushRequest) bool {
 	return updates != nil && deltaAwareConfigTypes(updates.ConfigsUpdated) && len(updates.ConfigsUpdated) > 0
 }
@@ -256,7 +400,7 @@ func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder,
 	hit, miss := 0, 0
 	for _, service := range services {
 		for _, port := range service.Ports {
-			if port.Protocol == protocol.UDP {
+			if port.Protocol == protocol.UDP && !buildUDPCluster(service, port) {
 				continue
 			}
 			clusterKey := buildClusterKey(service, port, cb, proxy, efKeys)
@@ -611,6 +755,8 @@ type buildClusterOpts struct {
 	// Indicates the service registry of the cluster being built.
 	serviceRegistry provider.ID
 	cache           model.XdsCache
+	// The annotations of the destination rule, tuning the load balancer beyond its traffic policy.
+	destinationRuleAnnotations map[string]string
 }
 
 type upgradeTuple struct {
@@ -651,8 +797,9 @@ func setKeepAliveSettings(c *cluster.Cluster, keepalive *networking.ConnectionPo
 	}
 }
 
+// ApplyOutlierDetection sets the outlier detection of the cluster from the DestinationRule settings.
 // FIXME: there isn't a way to distinguish between unset values and zero values
-func applyOutlierDetection(c *cluster.Cluster, outlier *networking.OutlierDetection) {
+func ApplyOutlierDetection(c *cluster.Cluster, outlier *networking.OutlierDetection) {
 	if outlier == nil {
 		return
 	}
@@ -725,7 +872,7 @@ func defaultLBAlgorithm() cluster.Cluster_LbPolicy {
 }
 
 func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings, port *model.Port,
-	locality *core.Locality, proxyLabels map[string]string, meshConfig *meshconfig.MeshConfig) {
+	locality *core.Locality, proxyLabels map[string]string, meshConfig *meshconfig.MeshConfig, activeRequestBias float64) {
 	localityLbSetting := loadbalancer.GetLocalityLbSetting(meshConfig.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
 	if localityLbSetting != nil {
 		if c.CommonLbConfig == nil {
@@ -753,6 +900,7 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 	}
 
 	if lb == nil {
+		loadbalancer.ApplyLbConfig(c, nil, activeRequestBias)
 		return
 	}
 
@@ -772,6 +920,7 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 	}
 
 	ApplyRingHashLoadBalancer(c, lb)
+	loadbalancer.ApplyLbConfig(c, lb, activeRequestBias)
 }
 
 // ApplyRingHashLoadBalancer will set the LbPolicy and create an LbConfig for RING_HASH if  used in LoadBalancerSettings
@@ -48,6 +48,7 @@ import (
 	istio_cluster "istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/loadbalancing"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/security"
@@ -250,6 +251,9 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *MutableCluster, clusterMode C
 		direction:        model.TrafficDirectionOutbound,
 		cache:            cb.cache,
 	}
+	if destRule != nil {
+		opts.destinationRuleAnnotations = destRule.Annotations
+	}
 
 	if clusterMode == DefaultClusterMode {
 		opts.serviceAccounts = serviceAccounts
@@ -781,8 +785,9 @@ func (cb *ClusterBuilder) applyTrafficPolicy(opts buildClusterOpts) {
 	cb.applyConnectionPool(opts.mesh, opts.mutable, connectionPool)
 	if opts.direction != model.TrafficDirectionInbound {
 		cb.applyH2Upgrade(opts, connectionPool)
-		applyOutlierDetection(opts.mutable.cluster, outlierDetection)
-		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, opts.mesh)
+		ApplyOutlierDetection(opts.mutable.cluster, outlierDetection)
+		activeRequestBias := loadbalancing.ActiveRequestBias(opts.destinationRuleAnnotations, features.LeastRequestActiveRequestBias)
+		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, opts.mesh, activeRequestBias)
 		if opts.clusterMode != SniDnatClusterMode {
 			autoMTLSEnabled := opts.mesh.GetEnableAutoMtls().Value
 			tls, mtlsCtxType := cb.buildAutoMtlsSettings(tls, opts.serviceAccounts, opts.istioMtlsSni,
@@ -1729,7 +1729,7 @@ func TestApplyLoadBalancer(t *testing.T) {
 				defer func() { features.EnableRedisFilter = defaultValue }()
 			}
 
-			applyLoadBalancer(c, test.lbSettings, test.port, proxy.Locality, nil, &meshconfig.MeshConfig{})
+			applyLoadBalancer(c, test.lbSettings, test.port, proxy.Locality, nil, &meshconfig.MeshConfig{}, features.LeastRequestActiveRequestBias)
 
 			if c.LbPolicy != test.expectedLbPolicy {
 				t.Errorf("cluster LbPolicy %s != expected %s", c.LbPolicy, test.expectedLbPolicy)
@@ -1770,6 +1770,59 @@ func TestBuildStaticClusterWithNoEndPoint(t *testing.T) {
 	g.Expect(xdstest.MapKeys(xdstest.ExtractClusters(clusters))).To(Equal([]string{"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster"}))
 }
 
+func TestActiveRequestBiasAnnotation(t *testing.T) {
+	service := &model.Service{
+		Hostname:   host.Name("bias.test"),
+		Ports:      []*model.Port{{Name: "default", Port: 8080, Protocol: protocol.HTTP}},
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{Namespace: TestServiceNamespace},
+	}
+	for _, tt := range []struct {
+		name        string
+		annotations string
+		want        *core.RuntimeDouble
+	}{
+		{"default", "", nil},
+		{"annotation", "annotations: {networking.istio.io/activeRequestBias: \"0\"}", &core.RuntimeDouble{
+			DefaultValue: 0,
+			RuntimeKey:   "upstream.least_request.active_request_bias",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewConfigGenTest(t, TestOptions{
+				Services: []*model.Service{service},
+				ConfigString: fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: bias
+  namespace: %s
+  %s
+spec:
+  host: bias.test
+  trafficPolicy:
+    loadBalancer:
+      simple: LEAST_REQUEST
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+`, TestServiceNamespace, tt.annotations),
+			})
+			clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(nil)))
+			for _, name := range []string{"outbound|8080||bias.test", "outbound|8080|v1|bias.test"} {
+				c := clusters[name]
+				if c == nil {
+					t.Fatalf("cluster %s not found", name)
+				}
+				if diff := cmp.Diff(c.GetLeastRequestLbConfig().GetActiveRequestBias(), tt.want, protocmp.Transform()); diff != "" {
+					t.Errorf("unexpected active request bias for %s: %v", name, diff)
+				}
+			}
+		})
+	}
+}
+
 func TestEnvoyFilterPatching(t *testing.T) {
 	service := &model.Service{
 		Hostname: host.Name("static.test"),
@@ -2457,10 +2510,36 @@ func TestBuildDeltaClusters(t *testing.T) {
 		},
 	}
 
-	// TODO: Add more test cases.
+	testDestinationRule := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             "test-dr",
+			Namespace:        TestServiceNamespace,
+		},
+		Spec: &networking.DestinationRule{
+			Host:    "test.com",
+			Subsets: []*networking.Subset{{Name: "v1", Labels: map[string]string{"version": "v1"}}},
+		},
+	}
+
+	testDestinationRuleNewHost := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             "test-dr",
+			Namespace:        TestServiceNamespace,
+		},
+		Spec: &networking.DestinationRule{
+			Host:    "testnew.com",
+			Subsets: []*networking.Subset{{Name: "v1", Labels: map[string]string{"version": "v1"}}},
+		},
+	}
+
 	testCases := []struct {
-		name                 string
-		services             []*model.Service
+		name     string
+		services []*model.Service
+		configs  []config.Config
+		// prevConfigs are the configs of the previous push, if any.
+		prevConfigs          []config.Config
 		configUpdated        map[model.ConfigKey]struct{}
 		watchedResourceNames []string
 		usedDelta            bool
@@ -2495,7 +2574,47 @@ func TestBuildDeltaClusters(t *testing.T) {
 			expectedClusters:     []string{"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster", "outbound|8080||test.com"},
 		},
 		{
-			name:                 "config update that is not delta aware",
+			name:                 "destination rule is updated",
+			services:             []*model.Service{testService1, testService2},
+			configs:              []config.Config{testDestinationRule},
+			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "test-dr", Namespace: TestServiceNamespace}: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v2|test.com", "outbound|8080||testnew.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v2|test.com"},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080|v1|test.com", "outbound|8080||test.com",
+			},
+		},
+		{
+			name:                 "destination rule host is changed",
+			services:             []*model.Service{testService1, testService2},
+			configs:              []config.Config{testDestinationRuleNewHost},
+			prevConfigs:          []config.Config{testDestinationRule},
+			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "test-dr", Namespace: TestServiceNamespace}: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v1|test.com", "outbound|8080||testnew.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v1|test.com"},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080|v1|testnew.com", "outbound|8080||test.com", "outbound|8080||testnew.com",
+			},
+		},
+		{
+			name:                 "destination rule is removed",
+			services:             []*model.Service{testService1, testService2},
+			prevConfigs:          []config.Config{testDestinationRule},
+			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "test-dr", Namespace: TestServiceNamespace}: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v1|test.com", "outbound|8080||testnew.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v1|test.com"},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080||test.com",
+			},
+		},
+		{
+			name:                 "unknown destination rule",
 			services:             []*model.Service{testService1, testService2},
 			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "test.com", Namespace: TestServiceNamespace}: {}},
 			watchedResourceNames: []string{"outbound|7070||test.com"},
@@ -2506,14 +2625,35 @@ func TestBuildDeltaClusters(t *testing.T) {
 				"outbound|8080||test.com", "outbound|8080||testnew.com",
 			},
 		},
+		{
+			name:                 "config update that is not delta aware",
+			services:             []*model.Service{testService1, testService2},
+			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "test.com", Namespace: TestServiceNamespace}: {}},
+			watchedResourceNames: []string{"outbound|7070||test.com"},
+			usedDelta:            false,
+			removedClusters:      nil,
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080||test.com", "outbound|8080||testnew.com",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			var proxy *model.Proxy
+			if tc.prevConfigs != nil {
+				// Setting the proxy up again below moves the scope of the previous push to PrevSidecarScope.
+				proxy = NewConfigGenTest(t, TestOptions{
+					Services: tc.services,
+					Configs:  tc.prevConfigs,
+				}).SetupProxy(nil)
+			}
 			cg := NewConfigGenTest(t, TestOptions{
 				Services: tc.services,
+				Configs:  tc.configs,
 			})
-			clusters, removed, delta := cg.DeltaClusters(cg.SetupProxy(nil), tc.configUpdated,
+			clusters, removed, delta := cg.DeltaClusters(cg.SetupProxy(proxy), tc.configUpdated,
 				&model.WatchedResource{ResourceNames: tc.watchedResourceNames})
 			if delta != tc.usedDelta {
 				t.Errorf("un expected delta, want %v got %v", tc.usedDelta, delta)
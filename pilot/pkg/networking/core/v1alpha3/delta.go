@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"strconv"
+	"strings"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+var (
+	// deltaRouteConfigTypes are the config types for which routes can be computed as a delta.
+	deltaRouteConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind, gvk.VirtualService.Kind, gvk.DestinationRule.Kind)
+	// deltaListenerConfigTypes are the config types for which listeners can be computed as a delta.
+	deltaListenerConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind)
+)
+
+// BuildDeltaHTTPRoutes generates the routes that changed for a given proxy. Outbound sidecar routes are built per port,
+// so when only services and their routing rules changed, we only rebuild the routes for the ports of the affected
+// services. Otherwise, we fall back onto generating everything.
+func (configgen *ConfigGeneratorImpl) BuildDeltaHTTPRoutes(node *model.Proxy, req *model.PushRequest,
+	routeNames []string) ([]*discovery.Resource, []string, model.XdsLogDetails, bool) {
+	ports, ok := deltaPorts(node, req, deltaRouteConfigTypes)
+	if !ok {
+		routes, lg := configgen.BuildHTTPRoutes(node, req, routeNames)
+		return routes, nil, lg, false
+	}
+	affected := make([]string, 0)
+	for _, routeName := range routeNames {
+		if routeAffected(routeName, ports) {
+			affected = append(affected, routeName)
+		}
+	}
+	if len(affected) == 0 {
+		return nil, nil, model.DefaultXdsLogDetails, true
+	}
+	routes, lg := configgen.BuildHTTPRoutes(node, req, affected)
+	return routes, nil, lg, true
+}
+
+// BuildDeltaListeners generates the listeners that changed for a given proxy, and the ones that should be removed.
+// Listeners are not built independently of each other, so all of them are generated, but only the outbound listeners
+// for the ports of the services that changed are returned. Otherwise, everything is returned.
+func (configgen *ConfigGeneratorImpl) BuildDeltaListeners(node *model.Proxy, push *model.PushContext,
+	req *model.PushRequest, watched *model.WatchedResource) ([]*listener.Listener, []string, bool) {
+	listeners := configgen.BuildListeners(node, push)
+	ports, ok := deltaPorts(node, req, deltaListenerConfigTypes)
+	if !ok {
+		return listeners, nil, false
+	}
+	// Inbound listeners are built from the services of the proxy itself.
+	for _, si := range node.ServiceInstances {
+		if _, f := req.ConfigsUpdated[model.ConfigKey{
+			Kind: gvk.ServiceEntry, Name: si.Service.Hostname.String(), Namespace: si.Service.Attributes.Namespace,
+		}]; f {
+			return listeners, nil, false
+		}
+	}
+
+	updated := make([]*listener.Listener, 0)
+	built := sets.NewSet()
+	for _, l := range listeners {
+		built.Insert(l.Name)
+		if _, f := ports[int(l.GetAddress().GetSocketAddress().GetPortValue())]; f {
+			updated = append(updated, l)
+		}
+	}
+	removed := make([]string, 0)
+	for _, name := range watched.ResourceNames {
+		if !built.Contains(name) {
+			removed = append(removed, name)
+		}
+	}
+	return updated, removed, true
+}
+
+// deltaPorts returns the ports whose outbound listeners and routes may be affected by the updated configs. If the
+// update cannot be computed as a delta for the proxy, false is returned.
+func deltaPorts(node *model.Proxy, req *model.PushRequest, configTypes sets.Set) (map[int]struct{}, bool) {
+	if node.Type != model.SidecarProxy || req == nil || len(req.ConfigsUpdated) == 0 {
+		return nil, false
+	}
+	ports := map[int]struct{}{}
+	addServices := func(svcs []*model.Service) {
+		for _, svc := range svcs {
+			for _, port := range svc.Ports {
+				ports[port.Port] = struct{}{}
+			}
+		}
+	}
+	// addHosts adds the ports of the services behind the hosts of a virtual service. Virtual services that do not
+	// match a service are not handled.
+	addHosts := func(sc *model.SidecarScope, vs *networking.VirtualService) bool {
+		for _, h := range vs.Hosts {
+			svcs := sc.ServicesForHostname(host.Name(h))
+			if len(svcs) == 0 {
+				return false
+			}
+			addServices(svcs)
+		}
+		return true
+	}
+	scopes := []*model.SidecarScope{node.SidecarScope, node.PrevSidecarScope}
+
+	for key := range req.ConfigsUpdated {
+		if !configTypes.Contains(key.Kind.Kind) {
+			return nil, false
+		}
+		switch key.Kind {
+		case gvk.ServiceEntry:
+			for _, sc := range scopes {
+				if sc == nil {
+					continue
+				}
+				addServices(sc.ServicesForHostname(host.Name(key.Name)))
+				// Routes to the service take their default port from it.
+				for _, ilw := range sc.EgressListeners {
+					for _, cfg := range ilw.VirtualServices() {
+						vs := cfg.Spec.(*networking.VirtualService)
+						if routesToHost(vs, key.Name) && !addHosts(sc, vs) {
+							return nil, false
+						}
+					}
+				}
+			}
+		case gvk.VirtualService:
+			found := false
+			for _, sc := range scopes {
+				if cfg := sc.VirtualServiceByName(key.Name, key.Namespace); cfg != nil {
+					found = true
+					if !addHosts(sc, cfg.Spec.(*networking.VirtualService)) {
+						return nil, false
+					}
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case gvk.DestinationRule:
+			svcs, ok := servicesForDestinationRule(node, key)
+			if !ok {
+				return nil, false
+			}
+			addServices(svcs)
+		}
+	}
+
+	// Egress listeners with an explicit port serve the services they import on that port.
+	if len(ports) > 0 {
+		for _, sc := range scopes {
+			if sc == nil {
+				continue
+			}
+			for _, ilw := range sc.EgressListeners {
+				if ilw.IstioListener != nil && ilw.IstioListener.Port != nil {
+					ports[int(ilw.IstioListener.Port.Number)] = struct{}{}
+				}
+			}
+		}
+	}
+	return ports, true
+}
+
+// routesToHost checks if any route of the virtual service sends traffic to the given host.
+func routesToHost(vs *networking.VirtualService, hostname string) bool {
+	for _, h := range vs.Http {
+		if h.Mirror != nil && h.Mirror.Host == hostname {
+			return true
+		}
+		for _, r := range h.Route {
+			if r.Destination.GetHost() == hostname {
+				return true
+			}
+		}
+	}
+	for _, t := range vs.Tcp {
+		for _, r := range t.Route {
+			if r.Destination.GetHost() == hostname {
+				return true
+			}
+		}
+	}
+	for _, t := range vs.Tls {
+		for _, r := range t.Route {
+			if r.Destination.GetHost() == hostname {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeAffected checks if the sidecar route with the given name serves any of the ports.
+// Routes are named after their port, optionally prefixed with the hostname when protocol sniffing
+// is used. Other routes, such as http_proxy and unix domain sockets, may serve any port.
+func routeAffected(routeName string, ports map[int]struct{}) bool {
+	port := routeName
+	if i := strings.LastIndex(routeName, ":"); i >= 0 {
+		port = routeName[i+1:]
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return true
+	}
+	_, f := ports[p]
+	return f
+}
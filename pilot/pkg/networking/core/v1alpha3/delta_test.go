@@ -0,0 +1,181 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func deltaTestServices() []*model.Service {
+	svc := func(hostname string, port int, proto protocol.Instance) *model.Service {
+		return &model.Service{
+			Hostname:   host.Name(hostname),
+			Ports:      []*model.Port{{Name: "default", Port: port, Protocol: proto}},
+			Resolution: model.ClientSideLB,
+			Attributes: model.ServiceAttributes{Namespace: TestServiceNamespace},
+		}
+	}
+	return []*model.Service{
+		svc("a.com", 8080, protocol.HTTP),
+		svc("b.com", 9090, protocol.HTTP),
+		svc("c.com", 3306, protocol.TCP),
+	}
+}
+
+func TestBuildDeltaHTTPRoutes(t *testing.T) {
+	vs := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.VirtualService,
+			Name:             "vs",
+			Namespace:        TestServiceNamespace,
+		},
+		Spec: &networking.VirtualService{
+			Hosts: []string{"b.com"},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "a.com"}}},
+			}},
+		},
+	}
+	routeNames := []string{"8080", "9090", "a.com:8080", "http_proxy"}
+	cases := []struct {
+		name          string
+		configUpdated map[model.ConfigKey]struct{}
+		usedDelta     bool
+		expected      []string
+	}{
+		{
+			name:          "service updated",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "b.com", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     true,
+			expected:      []string{"9090", "http_proxy"},
+		},
+		{
+			name:          "service routed to by a virtual service",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a.com", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     true,
+			expected:      []string{"8080", "9090", "a.com:8080", "http_proxy"},
+		},
+		{
+			name:          "virtual service updated",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     true,
+			expected:      []string{"9090", "http_proxy"},
+		},
+		{
+			name:          "unrelated service",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "c.com", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     true,
+			expected:      []string{"http_proxy"},
+		},
+		{
+			name:          "config update that is not delta aware",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     false,
+			expected:      []string{"8080", "9090", "a.com:8080", "http_proxy"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewConfigGenTest(t, TestOptions{
+				Services: deltaTestServices(),
+				Configs:  []config.Config{vs},
+			})
+			routes, _, _, usedDelta := cg.ConfigGen.BuildDeltaHTTPRoutes(cg.SetupProxy(nil),
+				&model.PushRequest{Push: cg.PushContext(), ConfigsUpdated: tt.configUpdated}, routeNames)
+			if usedDelta != tt.usedDelta {
+				t.Fatalf("expected delta %v, got %v", tt.usedDelta, usedDelta)
+			}
+			got := make([]string, 0, len(routes))
+			for _, r := range routes {
+				got = append(got, r.Name)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected routes %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildDeltaListeners(t *testing.T) {
+	cases := []struct {
+		name          string
+		configUpdated map[model.ConfigKey]struct{}
+		watched       []string
+		usedDelta     bool
+		expected      []string
+		removed       []string
+	}{
+		{
+			name:          "service updated",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "b.com", Namespace: TestServiceNamespace}: {}},
+			watched:       []string{"0.0.0.0_8080", "0.0.0.0_9090"},
+			usedDelta:     true,
+			expected:      []string{"0.0.0.0_9090"},
+			removed:       []string{},
+		},
+		{
+			name:          "service removed",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "d.com", Namespace: TestServiceNamespace}: {}},
+			watched:       []string{"0.0.0.0_8080", "0.0.0.0_7070"},
+			usedDelta:     true,
+			expected:      []string{},
+			removed:       []string{"0.0.0.0_7070"},
+		},
+		{
+			name:          "config update that is not delta aware",
+			configUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: TestServiceNamespace}: {}},
+			usedDelta:     false,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewConfigGenTest(t, TestOptions{Services: deltaTestServices()})
+			proxy := cg.SetupProxy(nil)
+			all := cg.ConfigGen.BuildListeners(proxy, cg.PushContext())
+			listeners, removed, usedDelta := cg.ConfigGen.BuildDeltaListeners(proxy, cg.PushContext(),
+				&model.PushRequest{Push: cg.PushContext(), ConfigsUpdated: tt.configUpdated},
+				&model.WatchedResource{ResourceNames: tt.watched})
+			if usedDelta != tt.usedDelta {
+				t.Fatalf("expected delta %v, got %v", tt.usedDelta, usedDelta)
+			}
+			if !usedDelta {
+				if len(listeners) != len(all) {
+					t.Fatalf("expected all %d listeners, got %d", len(all), len(listeners))
+				}
+				return
+			}
+			got := make([]string, 0, len(listeners))
+			for _, l := range listeners {
+				got = append(got, l.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected listeners %v, got %v", tt.expected, got)
+			}
+			if !reflect.DeepEqual(removed, tt.removed) {
+				t.Fatalf("expected removed listeners %v, got %v", tt.removed, removed)
+			}
+		})
+	}
+}
@@ -30,5 +30,6 @@ func (configgen *ConfigGeneratorImpl) BuildExtensionConfiguration(
 	extensions := envoyfilter.InsertedExtensionConfigurations(envoyFilterPatches, extensionConfigNames)
 	wasmPlugins := push.WasmPlugins(proxy)
 	extensions = append(extensions, extension.InsertedExtensionConfigurations(wasmPlugins, extensionConfigNames)...)
+	extensions = append(extensions, buildAccessLogRateSamplingConfigs(push, proxy, extensionConfigNames)...)
 	return extensions
 }
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extproc "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/extensionproviders"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/pkg/log"
+)
+
+// buildExtProcFilters builds an ext_proc filter for each envoyExtProc provider selecting the proxy, in the order of
+// the providers. Routes can disable them, or change their processing mode, with the extproc.RouteAnnotation of
+// their VirtualService.
+func buildExtProcFilters(push *model.PushContext, proxy *model.Proxy) []*hcm.HttpFilter {
+	var filters []*hcm.HttpFilter
+	for _, p := range push.ExtensionProviders {
+		if p.EnvoyExtProc == nil || !p.EnvoyExtProc.Selects(proxy) {
+			continue
+		}
+		name := p.Name
+		_, cluster, err := extensionproviders.LookupCluster(push, p.EnvoyExtProc.Service, int(p.EnvoyExtProc.Port))
+		if err != nil {
+			log.Errorf("failed to build ext_proc filter for provider %s: %v", name, err)
+			continue
+		}
+		filters = append(filters, &hcm.HttpFilter{
+			Name: xdsfilters.ExtProcFilterName + "." + name,
+			ConfigType: &hcm.HttpFilter_TypedConfig{
+				TypedConfig: util.MessageToAny(&extproc.ExternalProcessor{
+					GrpcService: &core.GrpcService{
+						TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &core.GrpcService_EnvoyGrpc{
+								ClusterName: cluster,
+								// `|` is invalid in the gRPC authority header.
+								Authority: strings.ReplaceAll(cluster, "|", "_."),
+							},
+						},
+					},
+					FailureModeAllow: p.EnvoyExtProc.FailOpen,
+					ProcessingMode:   xdsfilters.BuildExtProcProcessingMode(p.EnvoyExtProc.ProcessingMode),
+					MessageTimeout:   durationpb.New(p.EnvoyExtProc.TimeoutDuration()),
+					StatPrefix:       name,
+				}),
+			},
+		})
+	}
+	return filters
+}
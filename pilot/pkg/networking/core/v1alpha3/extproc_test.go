@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3_test
+
+import (
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	extproc "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+const extProcConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: processor
+spec:
+  hosts: [processor.example.com]
+  ports:
+  - number: 9000
+    name: grpc
+    protocol: GRPC
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: backend
+spec:
+  hosts: [backend.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts: ["*"]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: backend
+  annotations:
+    networking.istio.io/extProcRoutes: |
+      - provider: transform
+        routes: [health]
+        disabled: true
+      - provider: transform
+        routes: [upload]
+        processingMode:
+          requestBody: STREAMED
+spec:
+  hosts: [backend.example.com]
+  gateways: [gateway, mesh]
+  http:
+  - name: health
+    match:
+    - uri:
+        exact: /health
+    route:
+    - destination:
+        host: backend.example.com
+  - name: upload
+    route:
+    - destination:
+        host: backend.example.com
+`
+
+func TestExtProc(t *testing.T) {
+	providers := xdstest.ExtensionProviders(t, `
+- name: transform
+  envoyExtProc:
+    service: processor.example.com
+    port: 9000
+    processingMode:
+      requestBody: BUFFERED
+    workloads:
+    - {}
+- name: uploads
+  envoyExtProc:
+    service: processor.example.com
+    port: 9000
+    workloads:
+    - namespace: default
+      matchLabels:
+        app: uploads
+`)
+
+	cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{ConfigString: extProcConfig, ExtensionProviders: providers})
+
+	listeners := cg.Listeners(cg.SetupProxy(nil))
+	for _, f := range httpFilters(t, listeners, "0.0.0.0_80") {
+		if f.GetName() == "envoy.filters.http.ext_proc.transform" {
+			t.Fatalf("expected no ext_proc filter on the outbound listeners")
+		}
+	}
+	inbound := xdstest.ExtractListener("virtualInbound", listeners)
+	if !hasHTTPFilter(inbound, "envoy.filters.http.ext_proc.transform") || hasHTTPFilter(inbound, "envoy.filters.http.ext_proc.uploads") {
+		t.Fatalf("expected only the ext_proc filter of the processor selecting all the workloads")
+	}
+
+	uploads := cg.SetupProxy(&model.Proxy{Metadata: &model.NodeMetadata{Labels: map[string]string{"app": "uploads"}}})
+	inbound = xdstest.ExtractListener("virtualInbound", cg.Listeners(uploads))
+	if !hasHTTPFilter(inbound, "envoy.filters.http.ext_proc.transform") || !hasHTTPFilter(inbound, "envoy.filters.http.ext_proc.uploads") {
+		t.Fatalf("expected the ext_proc filters of both processors")
+	}
+
+	proxy := cg.SetupProxy(&model.Proxy{
+		Type: model.Router,
+		Metadata: &model.NodeMetadata{
+			Labels: map[string]string{"istio": "ingressgateway"},
+		},
+	})
+	var filter *extproc.ExternalProcessor
+	for _, f := range httpFilters(t, cg.Listeners(proxy), "0.0.0.0_80") {
+		if f.GetName() == "envoy.filters.http.ext_proc.transform" {
+			filter = &extproc.ExternalProcessor{}
+			if err := f.GetTypedConfig().UnmarshalTo(filter); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if filter == nil {
+		t.Fatalf("expected the ext_proc filter of the transform provider")
+	}
+	if got := filter.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != "outbound|9000||processor.example.com" {
+		t.Fatalf("expected the processor cluster, got %s", got)
+	}
+	if got := filter.GetProcessingMode().GetRequestBodyMode(); got != extproc.ProcessingMode_BUFFERED {
+		t.Fatalf("expected buffered request bodies, got %v", got)
+	}
+
+	routes := map[string]*extproc.ExtProcPerRoute{}
+	for _, rc := range cg.Routes(proxy) {
+		for _, vh := range rc.GetVirtualHosts() {
+			for _, r := range vh.GetRoutes() {
+				if c := r.GetTypedPerFilterConfig()["envoy.filters.http.ext_proc.transform"]; c != nil {
+					perRoute := &extproc.ExtProcPerRoute{}
+					if err := c.UnmarshalTo(perRoute); err != nil {
+						t.Fatal(err)
+					}
+					routes[r.GetName()] = perRoute
+				}
+			}
+		}
+	}
+	if !routes["health"].GetDisabled() {
+		t.Fatalf("expected the processor to be disabled on the health route, got %v", routes["health"])
+	}
+	if got := routes["upload"].GetOverrides().GetProcessingMode().GetRequestBodyMode(); got != extproc.ProcessingMode_STREAMED {
+		t.Fatalf("expected streamed request bodies on the upload route, got %v", got)
+	}
+}
+
+func TestExtProcNotAttached(t *testing.T) {
+	cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{ConfigString: extProcConfig})
+	for _, f := range httpFilters(t, cg.Listeners(cg.SetupProxy(nil)), "0.0.0.0_80") {
+		if f.GetName() == "envoy.filters.http.ext_proc.transform" {
+			t.Fatalf("expected no ext_proc filter on a workload without processors")
+		}
+	}
+}
+
+// hasHTTPFilter checks if one of the HTTP connection managers of the listener has the filter.
+func hasHTTPFilter(l *listener.Listener, name string) bool {
+	for _, fc := range l.GetFilterChains() {
+		for _, f := range xdstest.ExtractHTTPConnectionManager(nil, fc).GetHttpFilters() {
+			if f.GetName() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// httpFilters returns the HTTP filters of the HTTP connection managers of the listener.
+func httpFilters(t *testing.T, listeners []*listener.Listener, name string) []*hcm.HttpFilter {
+	t.Helper()
+	l := xdstest.ExtractListener(name, listeners)
+	if l == nil {
+		t.Fatalf("expected the %s listener", name)
+	}
+	var filters []*hcm.HttpFilter
+	for _, fc := range l.GetFilterChains() {
+		filters = append(filters, xdstest.ExtractHTTPConnectionManager(t, fc).GetHttpFilters()...)
+	}
+	if len(filters) == 0 {
+		t.Fatalf("expected HTTP filters on the %s listener", name)
+	}
+	return filters
+}
@@ -70,10 +70,16 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 	mutableopts := make(map[string]mutableListenerOpts)
 	proxyConfig := builder.node.Metadata.ProxyConfigOrDefault(builder.push.Mesh.DefaultConfig)
 	for _, port := range mergedGateway.ServerPorts {
+		internal := strings.HasPrefix(port.Bind, model.EnvoyInternalAddressPrefix)
+		if internal && !util.IsInternalListenerEnabled(builder.node) {
+			log.Debugf("buildGatewayListeners: skipping internal listener %s for node %s, internal listeners are not enabled",
+				port.Bind, builder.node.ID)
+			continue
+		}
 		// Skip ports we cannot bind to. Note that MergeGateways will already translate Service port to
 		// targetPort, which handles the common case of exposing ports like 80 and 443 but listening on
-		// higher numbered ports.
-		if builder.node.Metadata.UnprivilegedPod != "" && port.Number < 1024 {
+		// higher numbered ports. Internal listeners are not bound to their port.
+		if !internal && builder.node.Metadata.UnprivilegedPod != "" && port.Number < 1024 {
 			log.Warnf("buildGatewayListeners: skipping privileged gateway port %d for node %s as it is an unprivileged pod",
 				port.Number, builder.node.ID)
 			continue
@@ -97,6 +103,10 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 				log.Debugf("buildGatewayListeners: no gateway-server for transport %s at port %d", transport.String(), port)
 				continue
 			}
+			if internal && transport != istionetworking.TransportProtocolTCP {
+				// Internal listeners only carry streams
+				continue
+			}
 
 			// on a given port, we can either have plain text HTTP servers or
 			// HTTPS/TLS servers with SNI. We cannot have a mix of http and https server on same port.
@@ -169,6 +179,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 		}
 		listeners = append(listeners, ml.mutable.Listener)
 	}
+	listeners = append(listeners, configgen.buildUDPListeners(builder.node, builder.push)...)
 	// We'll try to return any listeners we successfully marshaled; if we have none, we'll emit the error we built up
 	err := errs.ErrorOrNil()
 	if err != nil {
@@ -176,7 +187,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 		log.Info(err.Error())
 	}
 
-	if len(mutableopts) == 0 {
+	if len(mutableopts) == 0 && len(listeners) == 0 {
 		log.Warnf("gateway has zero listeners for node %v", builder.node.ID)
 		return builder
 	}
@@ -271,10 +282,14 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTP3FilterChains(
 }
 
 func getListenerName(bind string, port int, transport istionetworking.TransportProtocol) string {
+	if strings.HasPrefix(bind, model.EnvoyInternalAddressPrefix) {
+		// Internal listeners are addressed by their name, which must be the name of the listener.
+		return strings.TrimPrefix(bind, model.EnvoyInternalAddressPrefix)
+	}
 	switch transport {
 	case istionetworking.TransportProtocolTCP:
 		return bind + "_" + strconv.Itoa(port)
-	case istionetworking.TransportProtocolQUIC:
+	case istionetworking.TransportProtocolQUIC, istionetworking.TransportProtocolUDP:
 		return "udp_" + bind + "_" + strconv.Itoa(port)
 	}
 	return "unknown"
@@ -394,7 +409,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 			if routes, exists = gatewayRoutes[gatewayName][vskey]; !exists {
 				hashByDestination := istio_route.GetConsistentHashForVirtualService(push, node, virtualService, nameToServiceMap)
 				routes, err = istio_route.BuildHTTPRoutesForVirtualService(node, virtualService, nameToServiceMap,
-					hashByDestination, port, map[string]bool{gatewayName: true}, isH3DiscoveryNeeded, push.Mesh)
+					hashByDestination, port, map[string]bool{gatewayName: true}, isH3DiscoveryNeeded, push)
 				if err != nil {
 					log.Debugf("%s omitting routes for virtual service %v/%v due to error: %v", node.ID, virtualService.Namespace, virtualService.Name, err)
 					continue
@@ -467,7 +482,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 	routeCfg := &route.RouteConfiguration{
 		// Retain the routeName as its used by EnvoyFilter patching logic
 		Name:             routeName,
-		VirtualHosts:     virtualHosts,
+		VirtualHosts:     istio_route.ApplyTraceSampling(virtualHosts, traceSamplingRules(node, push, false), false),
 		ValidateClusters: proto.BoolFalse,
 	}
 
@@ -876,7 +891,7 @@ func builtAutoPassthroughFilterChains(push *model.PushContext, proxy *model.Prox
 				sniHosts:       []string{clusterName},
 				match:          &listener.FilterChainMatch{ApplicationProtocols: allIstioMtlsALPNs},
 				tlsContext:     nil, // NO TLS context because this is passthrough
-				networkFilters: buildOutboundNetworkFiltersWithSingleDestination(push, proxy, statPrefix, clusterName, "", port, destinationRule),
+				networkFilters: buildOutboundNetworkFiltersWithSingleDestination(push, proxy, statPrefix, clusterName, "", port, destinationRule, nil),
 			})
 
 			// Do the same, but for each subset
@@ -891,7 +906,7 @@ func builtAutoPassthroughFilterChains(push *model.PushContext, proxy *model.Prox
 					sniHosts:       []string{subsetClusterName},
 					match:          &listener.FilterChainMatch{ApplicationProtocols: allIstioMtlsALPNs},
 					tlsContext:     nil, // NO TLS context because this is passthrough
-					networkFilters: buildOutboundNetworkFiltersWithSingleDestination(push, proxy, subsetStatPrefix, subsetClusterName, subset.Name, port, destinationRule),
+					networkFilters: buildOutboundNetworkFiltersWithSingleDestination(push, proxy, subsetStatPrefix, subsetClusterName, subset.Name, port, destinationRule, nil),
 				})
 			}
 		}
@@ -35,7 +35,9 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/tracesampling"
 	"istio.io/istio/pkg/proto"
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -96,10 +98,11 @@ func (configgen *ConfigGeneratorImpl) BuildHTTPRoutes(
 	return routeConfigurations, model.XdsLogDetails{AdditionalInfo: fmt.Sprintf("cached:%v/%v", hit, hit+miss)}
 }
 
-// buildSidecarInboundHTTPRouteConfig builds the route config with a single wildcard virtual host on the inbound path
+// buildSidecarInboundHTTPRouteConfig builds the route config with a single wildcard virtual host on the inbound path.
+// mtls is set for the filter chains terminating Istio mTLS.
 // TODO: trace decorators, inbound timeouts
-func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(
-	node *model.Proxy, push *model.PushContext, instance *model.ServiceInstance, clusterName string) *route.RouteConfiguration {
+func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(node *model.Proxy, push *model.PushContext,
+	instance *model.ServiceInstance, clusterName string, mtls bool) *route.RouteConfiguration {
 	traceOperation := util.TraceOperation(string(instance.Service.Hostname), instance.ServicePort.Port)
 	defaultRoute := istio_route.BuildDefaultHTTPInboundRoute(clusterName, traceOperation)
 
@@ -111,7 +114,7 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(
 
 	r := &route.RouteConfiguration{
 		Name:             clusterName,
-		VirtualHosts:     []*route.VirtualHost{inboundVHost},
+		VirtualHosts:     istio_route.ApplyTraceSampling([]*route.VirtualHost{inboundVHost}, traceSamplingRules(node, push, true), mtls),
 		ValidateClusters: proto.BoolFalse,
 	}
 	efw := push.EnvoyFilters(node)
@@ -119,17 +122,9 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(
 	return r
 }
 
-// buildSidecarOutboundHTTPRouteConfig builds an outbound HTTP Route for sidecar.
-// Based on port, will determine all virtual hosts that listen on the port.
-func (configgen *ConfigGeneratorImpl) buildSidecarOutboundHTTPRouteConfig(
-	node *model.Proxy,
-	req *model.PushRequest,
-	routeName string,
-	vHostCache map[int][]*route.VirtualHost,
-	efw *model.EnvoyFilterWrapper,
-	efKeys []string,
-) (*discovery.Resource, bool) {
-	var virtualHosts []*route.VirtualHost
+// parseSidecarRouteName returns the listener port of an outbound sidecar route, and whether the route
+// is for a single host because of protocol sniffing. Routes that are not known are reported as not ok.
+func parseSidecarRouteName(routeName string) (int, bool, bool) {
 	listenerPort := 0
 	useSniffing := false
 	var err error
@@ -152,9 +147,31 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundHTTPRouteConfig(
 			// user wants to ship a custom RDS. But at this point, the match semantics are murky. We have no
 			// object to match upon. This needs more thought. For now, we will continue to return nil for
 			// unknown routes
-			return nil, false
+			return 0, false, false
 		}
 	}
+	return listenerPort, useSniffing, true
+}
+
+// buildSidecarOutboundHTTPRouteConfig builds an outbound HTTP Route for sidecar.
+// Based on port, will determine all virtual hosts that listen on the port.
+func (configgen *ConfigGeneratorImpl) buildSidecarOutboundHTTPRouteConfig(
+	node *model.Proxy,
+	req *model.PushRequest,
+	routeName string,
+	vHostCache map[int][]*route.VirtualHost,
+	efw *model.EnvoyFilterWrapper,
+	efKeys []string,
+) (*discovery.Resource, bool) {
+	var virtualHosts []*route.VirtualHost
+	listenerPort, useSniffing, ok := parseSidecarRouteName(routeName)
+	if !ok {
+		return nil, false
+	}
+
+	if node.OnDemandXds() {
+		return buildOnDemandRouteConfig(node, routeName, efw), false
+	}
 
 	var routeCache *istio_route.Cache
 	var resource *discovery.Resource
@@ -193,6 +210,8 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundHTTPRouteConfig(
 		virtualHosts = append(virtualHosts, node.CatchAllVirtualHost)
 	}
 
+	virtualHosts = istio_route.ApplyTraceSampling(virtualHosts, traceSamplingRules(node, req.Push, false), false)
+
 	out := &route.RouteConfiguration{
 		Name:             routeName,
 		VirtualHosts:     virtualHosts,
@@ -300,6 +319,7 @@ func BuildSidecarOutboundVirtualHosts(node *model.Proxy, push *model.PushContext
 			VirtualServices:         virtualServices,
 			DelegateVirtualServices: push.DelegateVirtualServicesConfigKey(virtualServices),
 			EnvoyFilterKeys:         efKeys,
+			TraceSampling:           tracesampling.Key(traceSamplingRules(node, push, false)),
 		}
 	}
 
@@ -629,3 +649,23 @@ func getUniqueAndSharedDNSDomain(fqdnHostname, proxyDomain string) (partsUnique
 	}
 	return
 }
+
+// traceSamplingRules returns the trace sampling rules of the Telemetry resources applying to the proxy. The rules
+// of the outbound routes are resolved for the identity of the proxy.
+func traceSamplingRules(node *model.Proxy, push *model.PushContext, inbound bool) []tracesampling.Rule {
+	if push.Telemetry == nil {
+		return nil
+	}
+	tracing := push.Telemetry.Tracing(node)
+	if tracing == nil || tracing.Disabled {
+		return nil
+	}
+	if inbound {
+		return tracing.SamplingRules
+	}
+	principal := ""
+	if node.VerifiedIdentity != nil {
+		principal = strings.TrimPrefix(node.VerifiedIdentity.String(), spiffe.URIPrefix)
+	}
+	return tracesampling.ForWorkload(tracing.SamplingRules, principal)
+}
@@ -0,0 +1,215 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3_test
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/simulation"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+// The gateway terminates TLS, then hands the request to the authz internal listener, which routes it to the backend.
+var internalListenerConfig = createGateway("gateway", "", `
+port:
+  number: 443
+  name: https
+  protocol: HTTPS
+hosts:
+- "*"
+tls:
+  mode: SIMPLE
+  credentialName: cred
+`, `
+port:
+  number: 8080
+  name: http-authz
+  protocol: HTTP
+bind: envoy://authz
+hosts:
+- "*"
+`) + `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: authz
+spec:
+  hosts: [authz.internal]
+  ports:
+  - number: 8080
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: envoy://authz
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: backend
+spec:
+  hosts: [backend.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: example
+spec:
+  hosts: [example.com]
+  gateways: [gateway]
+  http:
+  - match:
+    - port: 443
+    route:
+    - destination:
+        host: authz.internal
+  - match:
+    - port: 8080
+    route:
+    - destination:
+        host: backend.example.com
+`
+
+func enableInternalListeners(t *testing.T) {
+	old := features.EnableInternalListeners
+	features.EnableInternalListeners = true
+	t.Cleanup(func() { features.EnableInternalListeners = old })
+}
+
+func gatewayProxy() *model.Proxy {
+	return &model.Proxy{
+		Metadata: &model.NodeMetadata{
+			Labels:            map[string]string{"istio": "ingressgateway"},
+			Namespace:         "istio-system",
+			InternalListeners: true,
+		},
+		Type: model.Router,
+	}
+}
+
+func TestInternalListenerGateway(t *testing.T) {
+	enableInternalListeners(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: internalListenerConfig})
+	proxy := s.SetupProxy(gatewayProxy())
+	sim := simulation.NewSimulation(t, s, proxy)
+	sim.RunExpectations([]simulation.Expect{
+		{
+			Name: "tls is terminated and sent to the internal listener",
+			Call: simulation.Call{
+				Port:       443,
+				HostHeader: "example.com",
+				Protocol:   simulation.HTTP,
+				TLS:        simulation.TLS,
+				CallMode:   simulation.CallModeGateway,
+			},
+			Result: simulation.Result{
+				ListenerMatched: "0.0.0.0_443",
+				ClusterMatched:  "outbound|8080||authz.internal",
+			},
+		},
+		{
+			Name: "internal listener routes to the backend",
+			Call: simulation.Call{
+				Address:    "authz",
+				Port:       8080,
+				HostHeader: "example.com",
+				Protocol:   simulation.HTTP,
+				CallMode:   simulation.CallModeInternal,
+			},
+			Result: simulation.Result{
+				ListenerMatched: "authz",
+				ClusterMatched:  "outbound|80||backend.example.com",
+			},
+		},
+		{
+			Name:   "internal listener is not bound to its port",
+			Call:   simulation.Call{Port: 8080, Protocol: simulation.HTTP, CallMode: simulation.CallModeGateway},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		},
+	})
+	xdstest.ValidateListeners(t, sim.Listeners)
+	xdstest.ValidateClusters(t, sim.Clusters)
+
+	l := xdstest.ExtractListener("authz", sim.Listeners)
+	if l.GetInternalListener() == nil {
+		t.Fatalf("expected authz to be an internal listener, got %v", l)
+	}
+	for _, cla := range s.Endpoints(proxy) {
+		if cla.GetClusterName() != "outbound|8080||authz.internal" {
+			continue
+		}
+		eps := cla.GetEndpoints()
+		if len(eps) != 1 || len(eps[0].GetLbEndpoints()) != 1 {
+			t.Fatalf("expected a single endpoint, got %v", eps)
+		}
+		addr := eps[0].GetLbEndpoints()[0].GetEndpoint().GetAddress()
+		if got := addr.GetEnvoyInternalAddress().GetServerListenerName(); got != "authz" {
+			t.Fatalf("expected the endpoint to address the authz internal listener, got %v", addr)
+		}
+		return
+	}
+	t.Fatalf("expected endpoints for the authz.internal cluster")
+}
+
+func TestInternalListenerDisabled(t *testing.T) {
+	runGatewayTest(t, simulationTest{
+		config: internalListenerConfig,
+		calls: []simulation.Expect{{
+			Name: "internal listener",
+			Call: simulation.Call{
+				Address:    "authz",
+				Port:       8080,
+				HostHeader: "example.com",
+				Protocol:   simulation.HTTP,
+				CallMode:   simulation.CallModeInternal,
+			},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		}},
+	})
+}
+
+func TestInternalListenerExtensionNotLoaded(t *testing.T) {
+	enableInternalListeners(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: internalListenerConfig})
+	// The agent of the proxy does not advertise the internal listener bootstrap extension.
+	p := gatewayProxy()
+	p.Metadata.InternalListeners = false
+	proxy := s.SetupProxy(p)
+	sim := simulation.NewSimulation(t, s, proxy)
+	sim.RunExpectations([]simulation.Expect{{
+		Name: "internal listener",
+		Call: simulation.Call{
+			Address:    "authz",
+			Port:       8080,
+			HostHeader: "example.com",
+			Protocol:   simulation.HTTP,
+			CallMode:   simulation.CallModeInternal,
+		},
+		Result: simulation.Result{Error: simulation.ErrNoListener},
+	}})
+	for _, cla := range s.Endpoints(proxy) {
+		if cla.GetClusterName() == "outbound|8080||authz.internal" && len(cla.GetEndpoints()) != 0 {
+			t.Fatalf("expected no endpoints addressing internal listeners, got %v", cla.GetEndpoints())
+		}
+	}
+}
@@ -391,10 +391,10 @@ func (configgen *ConfigGeneratorImpl) buildSidecarInboundListeners(
 }
 
 func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPListenerOptsForPortOrUDS(node *model.Proxy,
-	pluginParams *plugin.InputParams, clusterName string) *httpListenerOpts {
+	pluginParams *plugin.InputParams, clusterName string, mtls bool) *httpListenerOpts {
 	httpOpts := &httpListenerOpts{
 		routeConfig: configgen.buildSidecarInboundHTTPRouteConfig(pluginParams.Node,
-			pluginParams.Push, pluginParams.ServiceInstance, clusterName),
+			pluginParams.Push, pluginParams.ServiceInstance, clusterName, mtls),
 		rds:              "", // no RDS for inbound traffic
 		useRemoteAddress: false,
 		connectionManager: &hcm.HttpConnectionManager{
@@ -597,6 +597,15 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundListeners(node *model.
 				// If the bind is a Unix domain socket, set bindtoPort to true as it makes no
 				// sense to have ORIG_DST listener for unix domain socket listeners.
 				bindToPort = true
+			} else if strings.HasPrefix(egressListener.IstioListener.Bind, model.EnvoyInternalAddressPrefix) {
+				// Internal listeners are only reachable from the other listeners of the proxy, never through
+				// iptables.
+				if !util.IsInternalListenerEnabled(node) {
+					log.Debugf("buildSidecarOutboundListeners: skipping internal listener %s for node %s, internal listeners are not enabled",
+						egressListener.IstioListener.Bind, node.ID)
+					continue
+				}
+				bindToPort = true
 			}
 		}
 
@@ -1441,8 +1450,11 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 
 	routerFilterCtx, reqIDExtensionCtx := configureTracing(listenerOpts, connectionManager)
 
-	filters := make([]*hcm.HttpFilter, len(httpFilters))
-	copy(filters, httpFilters)
+	// The access log rate sampling runs first, so that it also counts the requests rejected by the other filters.
+	samplingFilters := buildAccessLogRateSamplingFilters(listenerOpts.push, listenerOpts.proxy, listenerOpts.class)
+	filters := make([]*hcm.HttpFilter, 0, len(samplingFilters)+len(httpFilters))
+	filters = append(filters, samplingFilters...)
+	filters = append(filters, httpFilters...)
 
 	if features.MetadataExchange {
 		filters = append(filters, xdsfilters.HTTPMx)
@@ -1463,7 +1475,19 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 
 	// TypedPerFilterConfig in route needs these filters.
 	filters = append(filters, xdsfilters.Fault, xdsfilters.Cors)
+	if features.EnableRateLimit {
+		filters = append(filters, buildRateLimitFilters(listenerOpts.push)...)
+	}
+	// Like CUSTOM authorization policies, the external processors of a workload process the requests it receives,
+	// not the ones it sends.
+	if listenerOpts.class != istionetworking.ListenerClassSidecarOutbound {
+		filters = append(filters, buildExtProcFilters(listenerOpts.push, listenerOpts.proxy)...)
+	}
 	filters = append(filters, listenerOpts.push.Telemetry.HTTPFilters(listenerOpts.proxy, listenerOpts.class)...)
+	// The on demand filter must run right before the router, once the route is known to be missing.
+	if httpOpts.rds != "" && listenerOpts.class == istionetworking.ListenerClassSidecarOutbound && listenerOpts.proxy.OnDemandXds() {
+		filters = append(filters, xdsfilters.OnDemand)
+	}
 	filters = append(filters, xdsfilters.BuildRouterFilter(routerFilterCtx))
 
 	connectionManager.HttpFilters = filters
@@ -1606,6 +1630,14 @@ func buildListener(opts buildListenerOpts, trafficDirection core.TrafficDirectio
 			BindToPort:              bindToPort,
 			ConnectionBalanceConfig: connectionBalance,
 		}
+		if strings.HasPrefix(opts.bind, model.EnvoyInternalAddressPrefix) {
+			// Internal listeners are not bound to a port; they only accept connections from the clusters of
+			// the same proxy with endpoints addressing them.
+			res.BindToPort = nil
+			res.ListenerSpecifier = &listener.Listener_InternalListener{
+				InternalListener: &listener.Listener_InternalListenerConfig{},
+			}
+		}
 
 		if opts.proxy.Type != model.Router {
 			res.ListenerFiltersTimeout = gogo.DurationToProtoDuration(opts.push.Mesh.ProtocolDetectionTimeout)
@@ -1635,7 +1667,7 @@ func buildListener(opts buildListenerOpts, trafficDirection core.TrafficDirectio
 		}
 	}
 
-	accessLogBuilder.setListenerAccessLog(opts.push, opts.proxy, res)
+	accessLogBuilder.setListenerAccessLog(opts.push, opts.proxy, res, opts.class)
 
 	return res
 }
@@ -335,6 +335,7 @@ func (lb *ListenerBuilder) buildSidecarInboundListeners(configgen *ConfigGenerat
 
 func (lb *ListenerBuilder) buildSidecarOutboundListeners(configgen *ConfigGeneratorImpl) *ListenerBuilder {
 	lb.outboundListeners = configgen.buildSidecarOutboundListeners(lb.node, lb.push)
+	lb.outboundListeners = append(lb.outboundListeners, configgen.buildUDPListeners(lb.node, lb.push)...)
 	return lb
 }
 
@@ -373,7 +374,7 @@ func (lb *ListenerBuilder) buildVirtualOutboundListener(configgen *ConfigGenerat
 		FilterChains:     filterChains,
 		TrafficDirection: core.TrafficDirection_OUTBOUND,
 	}
-	accessLogBuilder.setListenerAccessLog(lb.push, lb.node, ipTablesListener)
+	accessLogBuilder.setListenerAccessLog(lb.push, lb.node, ipTablesListener, istionetworking.ListenerClassSidecarOutbound)
 	lb.virtualOutboundListener = ipTablesListener
 	return lb
 }
@@ -413,7 +414,7 @@ func (lb *ListenerBuilder) buildVirtualInboundListener(configgen *ConfigGenerato
 		FilterChains:            filterChains,
 		ConnectionBalanceConfig: connectionBalance,
 	}
-	accessLogBuilder.setListenerAccessLog(lb.push, lb.node, lb.virtualInboundListener)
+	accessLogBuilder.setListenerAccessLog(lb.push, lb.node, lb.virtualInboundListener, istionetworking.ListenerClassSidecarInbound)
 	lb.aggregateVirtualInboundListener(passthroughInspector)
 
 	return lb
@@ -676,16 +677,18 @@ func (configgen *ConfigGeneratorImpl) buildInboundFilterchains(in *plugin.InputP
 			fcOpt.tlsContext = opt.fc.TLSContext
 		}
 		fcOpt.filterChain = opt.fc
+		// The client certificate header can only be trusted on the chains terminating Istio mTLS.
+		mtls := opt.matchOpts.MTLS && opt.fc.TLSContext != nil
 		switch opt.fc.ListenerProtocol {
 		case istionetworking.ListenerProtocolHTTP:
-			fcOpt.httpOpts = configgen.buildSidecarInboundHTTPListenerOptsForPortOrUDS(in.Node, in, clusterName)
+			fcOpt.httpOpts = configgen.buildSidecarInboundHTTPListenerOptsForPortOrUDS(in.Node, in, clusterName, mtls)
 			fcOpt.filterChain.TCP = append(
 				buildMetadataExchangeNetworkFilters(istionetworking.ListenerClassSidecarInbound),
 				fcOpt.filterChain.TCP...)
 		case istionetworking.ListenerProtocolTCP:
 			fcOpt.networkFilters = buildInboundNetworkFilters(in.Push, in.Node, in.ServiceInstance, clusterName)
 		case istionetworking.ListenerProtocolAuto:
-			fcOpt.httpOpts = configgen.buildSidecarInboundHTTPListenerOptsForPortOrUDS(in.Node, in, clusterName)
+			fcOpt.httpOpts = configgen.buildSidecarInboundHTTPListenerOptsForPortOrUDS(in.Node, in, clusterName, mtls)
 			fcOpt.networkFilters = buildInboundNetworkFilters(in.Push, in.Node, in.ServiceInstance, clusterName)
 		}
 		fcOpt.filterChainName = model.VirtualInboundListenerName
@@ -720,7 +723,7 @@ func buildOutboundCatchAllNetworkFiltersOnly(push *model.PushContext, node *mode
 		ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: egressCluster},
 	}
 	filterStack := buildMetricsNetworkFilters(push, node, istionetworking.ListenerClassSidecarOutbound)
-	accessLogBuilder.setTCPAccessLog(push, node, tcpProxy)
+	accessLogBuilder.setTCPAccessLog(push, node, tcpProxy, istionetworking.ListenerClassSidecarOutbound)
 	filterStack = append(filterStack, &listener.Filter{
 		Name:       wellknown.TCPProxy,
 		ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(tcpProxy)},
@@ -19,6 +19,7 @@ import (
 	"math"
 	"sort"
 
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
@@ -26,8 +27,13 @@ import (
 	"istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/util/gogo"
 )
 
+// activeRequestBiasRuntimeKey is the runtime key that can override the active request bias of LEAST_REQUEST clusters.
+const activeRequestBiasRuntimeKey = "upstream.least_request.active_request_bias"
+
 func GetLocalityLbSetting(
 	mesh *v1alpha3.LocalityLoadBalancerSetting,
 	destrule *v1alpha3.LocalityLoadBalancerSetting,
@@ -286,3 +292,118 @@ func applyPriorityFailoverPerLocality(
 
 	return out
 }
+
+// DegradedRegions returns the regions where the share of healthy endpoints is below minHealthyPercent. An endpoint
+// is healthy if the registry reports it as such and the proxies did not report it as ejected by their outlier
+// detection. The endpoints of all the localities of a region are counted together, whatever cluster they belong to,
+// so that a degraded remote cluster moves the traffic away from its whole region.
+func DegradedRegions(
+	wrappedLocalityLbEndpoints []*WrappedLocalityLbEndpoints,
+	ejected func(*model.IstioEndpoint) bool,
+	minHealthyPercent int) sets.Set {
+	total := map[string]int{}
+	healthy := map[string]int{}
+	for _, wrapped := range wrappedLocalityLbEndpoints {
+		region := wrapped.LocalityLbEndpoints.GetLocality().GetRegion()
+		for _, ep := range wrapped.IstioEndpoints {
+			total[region]++
+			if ep.HealthStatus == model.Healthy && (ejected == nil || !ejected(ep)) {
+				healthy[region]++
+			}
+		}
+	}
+	degraded := sets.NewSet()
+	for region, n := range total {
+		if healthy[region]*100 < minHealthyPercent*n {
+			degraded.Insert(region)
+		}
+	}
+	return degraded
+}
+
+// ApplyHealthAwareFailover lowers the priority of the localities of the degraded regions below the priority of all
+// the healthy ones, keeping their relative order, so that traffic fails over to the healthy regions before each
+// proxy detects the failures by itself. Nothing changes if every region is degraded.
+func ApplyHealthAwareFailover(loadAssignment *endpoint.ClusterLoadAssignment, degraded sets.Set) {
+	if loadAssignment == nil || len(degraded) == 0 {
+		return
+	}
+	hasHealthy := false
+	lowestPriority := 0
+	for _, localityEndpoint := range loadAssignment.Endpoints {
+		if int(localityEndpoint.Priority) > lowestPriority {
+			lowestPriority = int(localityEndpoint.Priority)
+		}
+		if !degraded.Contains(localityEndpoint.Locality.GetRegion()) {
+			hasHealthy = true
+		}
+	}
+	if !hasHealthy {
+		return
+	}
+
+	// key is priority, value is the index of the LocalityLbEndpoints in ClusterLoadAssignment
+	priorityMap := map[int][]int{}
+	for i, localityEndpoint := range loadAssignment.Endpoints {
+		priority := int(localityEndpoint.Priority)
+		if degraded.Contains(localityEndpoint.Locality.GetRegion()) {
+			priority += lowestPriority + 1
+		}
+		priorityMap[priority] = append(priorityMap[priority], i)
+	}
+
+	// since Priorities should range from 0 (highest) to N (lowest) without skipping.
+	// adjust the priorities in order
+	priorities := []int{}
+	for priority := range priorityMap {
+		priorities = append(priorities, priority)
+	}
+	sort.Ints(priorities)
+	for i, priority := range priorities {
+		for _, index := range priorityMap[priority] {
+			loadAssignment.Endpoints[index].Priority = uint32(i)
+		}
+	}
+}
+
+// ApplyLbConfig configures the algorithm specific settings of the cluster load balancer, once its policy is set:
+// the warm-up window of new endpoints, for ROUND_ROBIN and LEAST_REQUEST, and the weight given to the active
+// requests of the endpoints for LEAST_REQUEST.
+func ApplyLbConfig(c *cluster.Cluster, lb *v1alpha3.LoadBalancerSettings, activeRequestBias float64) {
+	var slowStart *cluster.Cluster_SlowStartConfig
+	if warmup := lb.GetWarmupDurationSecs(); warmup != nil {
+		slowStart = &cluster.Cluster_SlowStartConfig{
+			SlowStartWindow: gogo.DurationToProtoDuration(warmup),
+		}
+	}
+
+	switch c.LbPolicy {
+	case cluster.Cluster_ROUND_ROBIN:
+		if slowStart == nil {
+			return
+		}
+		c.LbConfig = &cluster.Cluster_RoundRobinLbConfig_{
+			RoundRobinLbConfig: &cluster.Cluster_RoundRobinLbConfig{
+				SlowStartConfig: slowStart,
+			},
+		}
+	case cluster.Cluster_LEAST_REQUEST:
+		var bias *core.RuntimeDouble
+		// 1.0 is the Envoy default, negative values are rejected.
+		if activeRequestBias >= 0 && activeRequestBias != 1.0 {
+			bias = &core.RuntimeDouble{
+				DefaultValue: activeRequestBias,
+				RuntimeKey:   activeRequestBiasRuntimeKey,
+			}
+		}
+		if slowStart == nil && bias == nil {
+			return
+		}
+		c.LbConfig = &cluster.Cluster_LeastRequestLbConfig_{
+			LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+				ActiveRequestBias: bias,
+				SlowStartConfig:   slowStart,
+			},
+		}
+	}
+}
@@ -15,20 +15,25 @@
 package loadbalancer
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/gogo/protobuf/types"
 	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
 	memregistry "istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/mesh"
@@ -497,6 +502,179 @@ func TestApplyLocalitySetting(t *testing.T) {
 	})
 }
 
+func TestApplyLbConfig(t *testing.T) {
+	warmup := &networking.LoadBalancerSettings{WarmupDurationSecs: &types.Duration{Seconds: 30}}
+	slowStart := &cluster.Cluster_SlowStartConfig{SlowStartWindow: durationpb.New(30 * time.Second)}
+	cases := []struct {
+		name     string
+		policy   cluster.Cluster_LbPolicy
+		lb       *networking.LoadBalancerSettings
+		bias     float64
+		expected *cluster.Cluster
+	}{
+		{
+			name:     "default least request",
+			policy:   cluster.Cluster_LEAST_REQUEST,
+			bias:     1.0,
+			expected: &cluster.Cluster{LbPolicy: cluster.Cluster_LEAST_REQUEST},
+		},
+		{
+			name:   "least request with bias",
+			policy: cluster.Cluster_LEAST_REQUEST,
+			bias:   0.5,
+			expected: &cluster.Cluster{
+				LbPolicy: cluster.Cluster_LEAST_REQUEST,
+				LbConfig: &cluster.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+					ActiveRequestBias: &core.RuntimeDouble{DefaultValue: 0.5, RuntimeKey: activeRequestBiasRuntimeKey},
+				}},
+			},
+		},
+		{
+			name:     "negative bias ignored",
+			policy:   cluster.Cluster_LEAST_REQUEST,
+			bias:     -1,
+			expected: &cluster.Cluster{LbPolicy: cluster.Cluster_LEAST_REQUEST},
+		},
+		{
+			name:   "least request with warmup",
+			policy: cluster.Cluster_LEAST_REQUEST,
+			lb:     warmup,
+			bias:   1.0,
+			expected: &cluster.Cluster{
+				LbPolicy: cluster.Cluster_LEAST_REQUEST,
+				LbConfig: &cluster.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+					SlowStartConfig: slowStart,
+				}},
+			},
+		},
+		{
+			name:   "round robin with warmup",
+			policy: cluster.Cluster_ROUND_ROBIN,
+			lb:     warmup,
+			bias:   0.5,
+			expected: &cluster.Cluster{
+				LbPolicy: cluster.Cluster_ROUND_ROBIN,
+				LbConfig: &cluster.Cluster_RoundRobinLbConfig_{RoundRobinLbConfig: &cluster.Cluster_RoundRobinLbConfig{
+					SlowStartConfig: slowStart,
+				}},
+			},
+		},
+		{
+			name:     "warmup not supported by random",
+			policy:   cluster.Cluster_RANDOM,
+			lb:       warmup,
+			bias:     0.5,
+			expected: &cluster.Cluster{LbPolicy: cluster.Cluster_RANDOM},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &cluster.Cluster{LbPolicy: tt.policy}
+			ApplyLbConfig(c, tt.lb, tt.bias)
+			if !proto.Equal(c, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, c)
+			}
+		})
+	}
+}
+
+func TestApplyHealthAwareFailover(t *testing.T) {
+	localityEndpoints := func(locality string, priority uint32, health ...model.HealthStatus) *WrappedLocalityLbEndpoints {
+		out := &WrappedLocalityLbEndpoints{
+			LocalityLbEndpoints: &endpoint.LocalityLbEndpoints{
+				Locality: util.ConvertLocality(locality),
+				Priority: priority,
+			},
+		}
+		for i, h := range health {
+			out.IstioEndpoints = append(out.IstioEndpoints, &model.IstioEndpoint{
+				Address:      fmt.Sprintf("%s-%d", locality, i),
+				HealthStatus: h,
+			})
+		}
+		return out
+	}
+	ejected := func(ep *model.IstioEndpoint) bool {
+		return ep.Address == "region1/zone3-0"
+	}
+	cases := []struct {
+		name     string
+		wrapped  []*WrappedLocalityLbEndpoints
+		expected []uint32
+	}{
+		{
+			name: "all healthy",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.Healthy, model.Healthy),
+				localityEndpoints("region1/zone2", 1, model.Healthy),
+				localityEndpoints("region2/zone1", 2, model.Healthy),
+			},
+			expected: []uint32{0, 1, 2},
+		},
+		{
+			name: "local region degraded",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.UnHealthy, model.UnHealthy, model.Healthy),
+				localityEndpoints("region1/zone2", 1, model.UnHealthy),
+				localityEndpoints("region2/zone1", 2, model.Healthy),
+			},
+			expected: []uint32{1, 2, 0},
+		},
+		{
+			name: "degraded zone of a healthy region",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.UnHealthy, model.UnHealthy),
+				localityEndpoints("region1/zone2", 1, model.Healthy, model.Healthy, model.Healthy),
+				localityEndpoints("region2/zone1", 2, model.Healthy),
+			},
+			expected: []uint32{0, 1, 2},
+		},
+		{
+			name: "local region degraded by ejections",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.UnHealthy, model.Healthy),
+				localityEndpoints("region1/zone3", 1, model.Healthy),
+				localityEndpoints("region2/zone1", 2, model.Healthy),
+			},
+			expected: []uint32{1, 2, 0},
+		},
+		{
+			name: "degraded regions keep their order",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.Healthy, model.Healthy),
+				localityEndpoints("region2/zone1", 1, model.UnHealthy),
+				localityEndpoints("region2/zone2", 2, model.UnHealthy, model.UnHealthy),
+				localityEndpoints("region3/zone1", 3, model.Healthy),
+			},
+			expected: []uint32{0, 2, 3, 1},
+		},
+		{
+			name: "all degraded",
+			wrapped: []*WrappedLocalityLbEndpoints{
+				localityEndpoints("region1/zone1", 0, model.UnHealthy),
+				localityEndpoints("region2/zone1", 1, model.UnHealthy),
+			},
+			expected: []uint32{0, 1},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cla := &endpoint.ClusterLoadAssignment{}
+			for _, w := range tt.wrapped {
+				cla.Endpoints = append(cla.Endpoints, w.LocalityLbEndpoints)
+			}
+			ApplyHealthAwareFailover(cla, DegradedRegions(tt.wrapped, ejected, 50))
+			var got []uint32
+			for _, llb := range cla.Endpoints {
+				got = append(got, llb.Priority)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected priorities %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestGetLocalityLbSetting(t *testing.T) {
 	// dummy config for test
 	failover := []*networking.LocalityLoadBalancerSetting_Failover{nil}
@@ -17,14 +17,20 @@ package v1alpha3
 import (
 	"time"
 
+	kafka "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/kafka_broker/v3"
 	mysql "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/mysql_proxy/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	dubbo "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/dubbo_proxy/v3"
 	mongo "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/mongo_proxy/v3"
 	redis "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/redis_proxy/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
-	hashpolicy "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	thrift "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/thrift_proxy/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/types/known/durationpb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
@@ -32,15 +38,26 @@ import (
 	istionetworking "istio.io/istio/pilot/pkg/networking"
 	istioroute "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
 	"istio.io/istio/pilot/pkg/networking/util"
+	authzmatcher "istio.io/istio/pilot/pkg/security/authz/matcher"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/redisroute"
+	"istio.io/istio/pkg/config/rpcroute"
+	"istio.io/pkg/log"
 )
 
 // redisOpTimeout is the default operation timeout for the Redis proxy filter.
 var redisOpTimeout = 5 * time.Second
 
+const (
+	// KafkaBrokerFilterName is the name of the Envoy Kafka broker filter.
+	KafkaBrokerFilterName = "envoy.filters.network.kafka_broker"
+	// DubboProxyFilterName is the name of the Envoy Dubbo proxy filter.
+	DubboProxyFilterName = "envoy.filters.network.dubbo_proxy"
+)
+
 func buildMetadataExchangeNetworkFilters(class istionetworking.ListenerClass) []*listener.Filter {
 	filterstack := make([]*listener.Filter, 0)
 	// We add metadata exchange on inbound only; outbound is handled in cluster filter
@@ -71,19 +88,20 @@ func buildInboundNetworkFilters(push *model.PushContext, proxy *model.Proxy, ins
 	if err == nil {
 		tcpProxy.IdleTimeout = durationpb.New(idleTimeout)
 	}
-	tcpFilter := setAccessLogAndBuildTCPFilter(push, proxy, tcpProxy)
+	tcpFilter := setAccessLogAndBuildTCPFilter(push, proxy, tcpProxy, istionetworking.ListenerClassSidecarInbound)
 
 	var filters []*listener.Filter
 	filters = append(filters, buildMetadataExchangeNetworkFilters(istionetworking.ListenerClassSidecarInbound)...)
 	filters = append(filters, buildMetricsNetworkFilters(push, proxy, istionetworking.ListenerClassSidecarInbound)...)
-	filters = append(filters, buildNetworkFiltersStack(instance.ServicePort, tcpFilter, statPrefix, clusterName)...)
+	filters = append(filters, buildNetworkFiltersStack(instance.ServicePort, tcpFilter, statPrefix, clusterName, nil, nil)...)
 	return filters
 }
 
 // setAccessLogAndBuildTCPFilter sets the AccessLog configuration in the given
 // TcpProxy instance and builds a TCP filter out of it.
-func setAccessLogAndBuildTCPFilter(push *model.PushContext, node *model.Proxy, config *tcp.TcpProxy) *listener.Filter {
-	accessLogBuilder.setTCPAccessLog(push, node, config)
+func setAccessLogAndBuildTCPFilter(push *model.PushContext, node *model.Proxy, config *tcp.TcpProxy,
+	class istionetworking.ListenerClass) *listener.Filter {
+	accessLogBuilder.setTCPAccessLog(push, node, config, class)
 
 	tcpFilter := &listener.Filter{
 		Name:       wellknown.TCPProxy,
@@ -93,9 +111,11 @@ func setAccessLogAndBuildTCPFilter(push *model.PushContext, node *model.Proxy, c
 }
 
 // buildOutboundNetworkFiltersWithSingleDestination takes a single cluster name
-// and builds a stack of network filters.
+// and builds a stack of network filters. The method routes, if any, apply to
+// the Dubbo and Thrift ports.
 func buildOutboundNetworkFiltersWithSingleDestination(push *model.PushContext, node *model.Proxy,
-	statPrefix, clusterName, subsetName string, port *model.Port, destinationRule *networking.DestinationRule) []*listener.Filter {
+	statPrefix, clusterName, subsetName string, port *model.Port, destinationRule *networking.DestinationRule,
+	methodRoutes []rpcMethodRoute) []*listener.Filter {
 	tcpProxy := &tcp.TcpProxy{
 		StatPrefix:       statPrefix,
 		ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: clusterName},
@@ -106,19 +126,20 @@ func buildOutboundNetworkFiltersWithSingleDestination(push *model.PushContext, n
 		tcpProxy.IdleTimeout = durationpb.New(idleTimeout)
 	}
 	maybeSetHashPolicy(destinationRule, tcpProxy, subsetName)
-	tcpFilter := setAccessLogAndBuildTCPFilter(push, node, tcpProxy)
+	tcpFilter := setAccessLogAndBuildTCPFilter(push, node, tcpProxy, model.OutboundListenerClass(node.Type))
 
 	var filters []*listener.Filter
 	filters = append(filters, buildMetadataExchangeNetworkFilters(model.OutboundListenerClass(node.Type))...)
 	filters = append(filters, buildMetricsNetworkFilters(push, node, model.OutboundListenerClass(node.Type))...)
-	filters = append(filters, buildNetworkFiltersStack(port, tcpFilter, statPrefix, clusterName)...)
+	filters = append(filters, buildNetworkFiltersStack(port, tcpFilter, statPrefix, clusterName, nil, methodRoutes)...)
 	return filters
 }
 
 // buildOutboundNetworkFiltersWithWeightedClusters takes a set of weighted
 // destination routes and builds a stack of network filters.
 func buildOutboundNetworkFiltersWithWeightedClusters(node *model.Proxy, routes []*networking.RouteDestination,
-	push *model.PushContext, port *model.Port, configMeta config.Meta, destinationRule *networking.DestinationRule) []*listener.Filter {
+	push *model.PushContext, port *model.Port, configMeta config.Meta, destinationRule *networking.DestinationRule,
+	methodRoutes []rpcMethodRoute) []*listener.Filter {
 	statPrefix := configMeta.Name + "." + configMeta.Namespace
 	clusterSpecifier := &tcp.TcpProxy_WeightedClusters{
 		WeightedClusters: &tcp.TcpProxy_WeightedCluster{},
@@ -148,14 +169,13 @@ func buildOutboundNetworkFiltersWithWeightedClusters(node *model.Proxy, routes [
 	// For weighted clusters set hash policy if any of the upstream destinations have sourceIP.
 	maybeSetHashPolicy(destinationRule, tcpProxy, "")
 
-	// TODO: Need to handle multiple cluster names for Redis
 	clusterName := clusterSpecifier.WeightedClusters.Clusters[0].Name
-	tcpFilter := setAccessLogAndBuildTCPFilter(push, node, tcpProxy)
+	tcpFilter := setAccessLogAndBuildTCPFilter(push, node, tcpProxy, model.OutboundListenerClass(node.Type))
 
 	var filters []*listener.Filter
 	filters = append(filters, buildMetadataExchangeNetworkFilters(model.OutboundListenerClass(node.Type))...)
 	filters = append(filters, buildMetricsNetworkFilters(push, node, model.OutboundListenerClass(node.Type))...)
-	filters = append(filters, buildNetworkFiltersStack(port, tcpFilter, statPrefix, clusterName)...)
+	filters = append(filters, buildNetworkFiltersStack(port, tcpFilter, statPrefix, clusterName, clusterSpecifier.WeightedClusters, methodRoutes)...)
 	return filters
 }
 
@@ -179,16 +199,20 @@ func maybeSetHashPolicy(destinationRule *networking.DestinationRule, tcpProxy *t
 		}
 		// If destinationrule has consistent hash source ip set, use it for tcp proxy.
 		if useSourceIP {
-			tcpProxy.HashPolicy = []*hashpolicy.HashPolicy{{PolicySpecifier: &hashpolicy.HashPolicy_SourceIp_{
-				SourceIp: &hashpolicy.HashPolicy_SourceIp{},
+			tcpProxy.HashPolicy = []*xdstype.HashPolicy{{PolicySpecifier: &xdstype.HashPolicy_SourceIp_{
+				SourceIp: &xdstype.HashPolicy_SourceIp{},
 			}}}
 		}
 	}
 }
 
 // buildNetworkFiltersStack builds a slice of network filters based on
-// the protocol in use and the given TCP filter instance.
-func buildNetworkFiltersStack(port *model.Port, tcpFilter *listener.Filter, statPrefix string, clusterName string) []*listener.Filter {
+// the protocol in use and the given TCP filter instance. The weighted clusters,
+// if any, are the destinations of the TCP filter; protocols whose filter
+// terminates the connection route to them instead of the single cluster. The
+// Dubbo and Thrift filters route the calls matching the method routes first.
+func buildNetworkFiltersStack(port *model.Port, tcpFilter *listener.Filter, statPrefix string, clusterName string,
+	weightedClusters *tcp.TcpProxy_WeightedCluster, methodRoutes []rpcMethodRoute) []*listener.Filter {
 	filterstack := make([]*listener.Filter, 0)
 	switch port.Protocol {
 	case protocol.Mongo:
@@ -209,6 +233,25 @@ func buildNetworkFiltersStack(port *model.Port, tcpFilter *listener.Filter, stat
 			filterstack = append(filterstack, buildMySQLFilter(statPrefix))
 		}
 		filterstack = append(filterstack, tcpFilter)
+	case protocol.Kafka:
+		if features.EnableKafkaFilter {
+			filterstack = append(filterstack, buildKafkaFilter(statPrefix))
+		}
+		filterstack = append(filterstack, tcpFilter)
+	case protocol.Dubbo:
+		if features.EnableDubboFilter {
+			// dubbo filter has route config, it is a terminating filter, no need append tcp filter.
+			filterstack = append(filterstack, buildDubboFilter(statPrefix, clusterName, weightedClusters, methodRoutes))
+		} else {
+			filterstack = append(filterstack, tcpFilter)
+		}
+	case protocol.Thrift:
+		if features.EnableThriftFilter {
+			// thrift filter has route config, it is a terminating filter, no need append tcp filter.
+			filterstack = append(filterstack, buildThriftFilter(statPrefix, clusterName, weightedClusters, methodRoutes))
+		} else {
+			filterstack = append(filterstack, tcpFilter)
+		}
 	default:
 		filterstack = append(filterstack, tcpFilter)
 	}
@@ -223,10 +266,14 @@ func buildOutboundNetworkFilters(node *model.Proxy,
 	routes []*networking.RouteDestination, push *model.PushContext,
 	port *model.Port, configMeta config.Meta) []*listener.Filter {
 	service := push.ServiceForHostname(node, host.Name(routes[0].Destination.Host))
+	if port.Protocol == protocol.Redis && features.EnableRedisFilter {
+		return buildOutboundRedisFilters(node, routes, push, port, configMeta, service)
+	}
 	var destinationRule *networking.DestinationRule
 	if service != nil {
 		destinationRule = CastDestinationRule(node.SidecarScope.DestinationRule(service.Hostname))
 	}
+	methodRoutes := buildRPCMethodRoutes(node, routes, push, port, configMeta, service)
 	if len(routes) == 1 {
 		clusterName := istioroute.GetDestinationCluster(routes[0].Destination, service, port.Port)
 		statPrefix := clusterName
@@ -236,9 +283,37 @@ func buildOutboundNetworkFilters(node *model.Proxy,
 				routes[0].Destination.Subset, port, &service.Attributes)
 		}
 
-		return buildOutboundNetworkFiltersWithSingleDestination(push, node, statPrefix, clusterName, routes[0].Destination.Subset, port, destinationRule)
+		return buildOutboundNetworkFiltersWithSingleDestination(push, node, statPrefix, clusterName, routes[0].Destination.Subset, port,
+			destinationRule, methodRoutes)
 	}
-	return buildOutboundNetworkFiltersWithWeightedClusters(node, routes, push, port, configMeta, destinationRule)
+	return buildOutboundNetworkFiltersWithWeightedClusters(node, routes, push, port, configMeta, destinationRule, methodRoutes)
+}
+
+// rpcMethodRoute routes the calls of a Dubbo or Thrift service method to a cluster.
+type rpcMethodRoute struct {
+	rpcroute.MethodRoute
+	cluster string
+}
+
+// buildRPCMethodRoutes returns the method routes listed in the rpcroute.MethodRoutesAnnotation of the
+// VirtualService, sending the matching calls of a Dubbo or Thrift port to a subset of the first destination.
+func buildRPCMethodRoutes(node *model.Proxy, routes []*networking.RouteDestination, push *model.PushContext,
+	port *model.Port, configMeta config.Meta, service *model.Service) []rpcMethodRoute {
+	if !(port.Protocol == protocol.Dubbo && features.EnableDubboFilter) && !(port.Protocol == protocol.Thrift && features.EnableThriftFilter) {
+		return nil
+	}
+	// The annotation is validated with the VirtualService, so the malformed entries are only skipped here.
+	methodRoutes, err := rpcroute.MethodRoutesFromAnnotations(configMeta.Annotations)
+	if err != nil {
+		log.Warnf("ignoring malformed rpc method routes of %s/%s: %v", configMeta.Namespace, configMeta.Name, err)
+	}
+	primary := routes[0].Destination
+	out := make([]rpcMethodRoute, 0, len(methodRoutes))
+	for _, mr := range methodRoutes {
+		destination := &networking.Destination{Host: primary.Host, Subset: mr.Subset, Port: primary.Port}
+		out = append(out, rpcMethodRoute{MethodRoute: mr, cluster: istioroute.GetDestinationCluster(destination, service, port.Port)})
+	}
+	return out
 }
 
 // buildMongoFilter builds an outbound Envoy MongoProxy filter.
@@ -264,7 +339,7 @@ func buildMongoFilter(statPrefix string) *listener.Filter {
 func buildOutboundAutoPassthroughFilterStack(push *model.PushContext, node *model.Proxy, port *model.Port) []*listener.Filter {
 	// First build tcp with access logs
 	// then add sni_cluster to the front
-	tcpProxy := buildOutboundNetworkFiltersWithSingleDestination(push, node, util.BlackHoleCluster, util.BlackHoleCluster, "", port, nil)
+	tcpProxy := buildOutboundNetworkFiltersWithSingleDestination(push, node, util.BlackHoleCluster, util.BlackHoleCluster, "", port, nil, nil)
 	filterstack := make([]*listener.Filter, 0)
 	filterstack = append(filterstack, &listener.Filter{
 		Name: util.SniClusterFilter,
@@ -274,10 +349,73 @@ func buildOutboundAutoPassthroughFilterStack(push *model.PushContext, node *mode
 	return filterstack
 }
 
-// buildRedisFilter builds an outbound Envoy RedisProxy filter.
-// Currently, if multiple clusters are defined, one of them will be picked for
-// configuring the Redis proxy.
+// buildOutboundRedisFilters builds the network filters of a Redis port from the destinations of a TCP route.
+// The Redis proxy cannot split the requests by weight: the first destination is the catch-all route, and the
+// others are ignored. The key prefixes listed in the redisroute.KeyPrefixRoutesAnnotation of the VirtualService
+// are routed to a subset of the first destination, and every route mirrors the requests to the destinations
+// listed in the redisroute.MirrorsAnnotation.
+func buildOutboundRedisFilters(node *model.Proxy, routes []*networking.RouteDestination, push *model.PushContext,
+	port *model.Port, configMeta config.Meta, service *model.Service) []*listener.Filter {
+	primary := routes[0].Destination
+	clusterName := istioroute.GetDestinationCluster(primary, service, port.Port)
+	statPrefix := clusterName
+	if len(routes) > 1 {
+		statPrefix = configMeta.Name + "." + configMeta.Namespace
+	} else if len(push.Mesh.OutboundClusterStatName) != 0 && service != nil {
+		// If stat name is configured, build the stat prefix from configured pattern.
+		statPrefix = util.BuildStatPrefix(push.Mesh.OutboundClusterStatName, primary.Host, primary.Subset, port, &service.Attributes)
+	}
+
+	// The annotations are validated with the VirtualService, so the malformed entries are only skipped here.
+	mirrorConfigs, err := redisroute.MirrorsFromAnnotations(configMeta.Annotations)
+	if err != nil {
+		log.Warnf("ignoring malformed redis mirrors of %s/%s: %v", configMeta.Namespace, configMeta.Name, err)
+	}
+	var mirrors []*redis.RedisProxy_PrefixRoutes_Route_RequestMirrorPolicy
+	for _, m := range mirrorConfigs {
+		mirrorService := push.ServiceForHostname(node, host.Name(m.Host))
+		destination := &networking.Destination{Host: m.Host, Subset: m.Subset}
+		mirrors = append(mirrors, &redis.RedisProxy_PrefixRoutes_Route_RequestMirrorPolicy{
+			Cluster: istioroute.GetDestinationCluster(destination, mirrorService, port.Port),
+			RuntimeFraction: &core.RuntimeFractionalPercent{
+				DefaultValue: &xdstype.FractionalPercent{
+					Numerator:   m.Percent,
+					Denominator: xdstype.FractionalPercent_HUNDRED,
+				},
+			},
+		})
+	}
+
+	keyPrefixRoutes, err := redisroute.KeyPrefixRoutesFromAnnotations(configMeta.Annotations)
+	if err != nil {
+		log.Warnf("ignoring malformed redis key prefix routes of %s/%s: %v", configMeta.Namespace, configMeta.Name, err)
+	}
+	catchAll := &redis.RedisProxy_PrefixRoutes_Route{Cluster: clusterName, RequestMirrorPolicy: mirrors}
+	var prefixRoutes []*redis.RedisProxy_PrefixRoutes_Route
+	for _, pr := range keyPrefixRoutes {
+		destination := &networking.Destination{Host: primary.Host, Subset: pr.Subset, Port: primary.Port}
+		prefixRoutes = append(prefixRoutes, &redis.RedisProxy_PrefixRoutes_Route{
+			Prefix:              pr.Prefix,
+			Cluster:             istioroute.GetDestinationCluster(destination, service, port.Port),
+			RequestMirrorPolicy: mirrors,
+		})
+	}
+
+	var filters []*listener.Filter
+	filters = append(filters, buildMetadataExchangeNetworkFilters(model.OutboundListenerClass(node.Type))...)
+	filters = append(filters, buildMetricsNetworkFilters(push, node, model.OutboundListenerClass(node.Type))...)
+	filters = append(filters, buildRedisFilterWithRoutes(statPrefix, catchAll, prefixRoutes))
+	return filters
+}
+
+// buildRedisFilter builds an Envoy RedisProxy filter sending every request to the given cluster.
 func buildRedisFilter(statPrefix, clusterName string) *listener.Filter {
+	return buildRedisFilterWithRoutes(statPrefix, &redis.RedisProxy_PrefixRoutes_Route{Cluster: clusterName}, nil)
+}
+
+// buildRedisFilterWithRoutes builds an Envoy RedisProxy filter with the given key prefix routes.
+func buildRedisFilterWithRoutes(statPrefix string, catchAll *redis.RedisProxy_PrefixRoutes_Route,
+	routes []*redis.RedisProxy_PrefixRoutes_Route) *listener.Filter {
 	redisProxy := &redis.RedisProxy{
 		LatencyInMicros: true,       // redis latency stats are captured in micro seconds which is typically the case.
 		StatPrefix:      statPrefix, // redis stats are prefixed with redis.<statPrefix> by Envoy
@@ -285,9 +423,8 @@ func buildRedisFilter(statPrefix, clusterName string) *listener.Filter {
 			OpTimeout: durationpb.New(redisOpTimeout), // TODO: Make this user configurable
 		},
 		PrefixRoutes: &redis.RedisProxy_PrefixRoutes{
-			CatchAllRoute: &redis.RedisProxy_PrefixRoutes_Route{
-				Cluster: clusterName,
-			},
+			Routes:        routes,
+			CatchAllRoute: catchAll,
 		},
 	}
 
@@ -312,3 +449,136 @@ func buildMySQLFilter(statPrefix string) *listener.Filter {
 
 	return out
 }
+
+// buildKafkaFilter builds an Envoy KafkaBroker filter.
+func buildKafkaFilter(statPrefix string) *listener.Filter {
+	kafkaBroker := &kafka.KafkaBroker{
+		StatPrefix: statPrefix, // Kafka stats are prefixed with kafka.<statPrefix> by Envoy.
+	}
+
+	out := &listener.Filter{
+		Name:       KafkaBrokerFilterName,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(kafkaBroker)},
+	}
+
+	return out
+}
+
+// buildDubboFilter builds an Envoy DubboProxy filter routing the calls of every
+// service and method to the given cluster, or split across the weighted clusters
+// when the TCP route has several destinations. The calls matching a method route
+// are sent to its cluster instead: the routes are grouped by interface, each
+// falling back to the destinations of the TCP route.
+func buildDubboFilter(statPrefix, clusterName string, weightedClusters *tcp.TcpProxy_WeightedCluster,
+	methodRoutes []rpcMethodRoute) *listener.Filter {
+	action := &dubbo.RouteAction{ClusterSpecifier: &dubbo.RouteAction_Cluster{Cluster: clusterName}}
+	if len(weightedClusters.GetClusters()) > 1 {
+		weighted := &route.WeightedCluster{}
+		for _, c := range weightedClusters.Clusters {
+			weighted.Clusters = append(weighted.Clusters, &route.WeightedCluster_ClusterWeight{
+				Name:   c.Name,
+				Weight: &wrappers.UInt32Value{Value: c.Weight},
+			})
+		}
+		action.ClusterSpecifier = &dubbo.RouteAction_WeightedClusters{WeightedClusters: weighted}
+	}
+
+	catchAll := &dubbo.RouteConfiguration{Name: statPrefix, Interface: rpcroute.Wildcard}
+	routeConfigs := make([]*dubbo.RouteConfiguration, 0, 1)
+	byInterface := map[string]*dubbo.RouteConfiguration{rpcroute.Wildcard: catchAll}
+	for _, mr := range methodRoutes {
+		rc := byInterface[mr.Service]
+		if rc == nil {
+			rc = &dubbo.RouteConfiguration{Name: statPrefix + "|" + mr.Service, Interface: mr.Service}
+			byInterface[mr.Service] = rc
+			routeConfigs = append(routeConfigs, rc)
+		}
+		rc.Routes = append(rc.Routes, &dubbo.Route{
+			Match: &dubbo.RouteMatch{Method: &dubbo.MethodMatch{Name: authzmatcher.StringMatcher(mr.Method)}},
+			Route: &dubbo.RouteAction{ClusterSpecifier: &dubbo.RouteAction_Cluster{Cluster: mr.cluster}},
+		})
+	}
+	// The route configuration of every interface comes last.
+	routeConfigs = append(routeConfigs, catchAll)
+	for _, rc := range routeConfigs {
+		rc.Routes = append(rc.Routes, &dubbo.Route{
+			Match: &dubbo.RouteMatch{
+				Method: &dubbo.MethodMatch{Name: authzmatcher.StringMatcher(rpcroute.Wildcard)},
+			},
+			Route: action,
+		})
+	}
+
+	dubboProxy := &dubbo.DubboProxy{
+		StatPrefix:  statPrefix, // dubbo stats are prefixed with dubbo.<statPrefix> by Envoy
+		RouteConfig: routeConfigs,
+	}
+
+	out := &listener.Filter{
+		Name:       DubboProxyFilterName,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(dubboProxy)},
+	}
+
+	return out
+}
+
+// buildThriftFilter builds an Envoy ThriftProxy filter routing the calls of every
+// method to the given cluster, or split across the weighted clusters when the TCP
+// route has several destinations. The transport and protocol are detected by Envoy.
+// The calls matching a method route are sent to its cluster instead, the service
+// of the route matching the service of the multiplexed protocol.
+func buildThriftFilter(statPrefix, clusterName string, weightedClusters *tcp.TcpProxy_WeightedCluster,
+	methodRoutes []rpcMethodRoute) *listener.Filter {
+	action := &thrift.RouteAction{ClusterSpecifier: &thrift.RouteAction_Cluster{Cluster: clusterName}}
+	if len(weightedClusters.GetClusters()) > 1 {
+		weighted := &thrift.WeightedCluster{}
+		for _, c := range weightedClusters.Clusters {
+			weighted.Clusters = append(weighted.Clusters, &thrift.WeightedCluster_ClusterWeight{
+				Name:   c.Name,
+				Weight: &wrappers.UInt32Value{Value: c.Weight},
+			})
+		}
+		action.ClusterSpecifier = &thrift.RouteAction_WeightedClusters{WeightedClusters: weighted}
+	}
+
+	routes := make([]*thrift.Route, 0, len(methodRoutes)+1)
+	for _, mr := range methodRoutes {
+		routes = append(routes, &thrift.Route{
+			Match: thriftMethodMatch(mr.MethodRoute),
+			Route: &thrift.RouteAction{ClusterSpecifier: &thrift.RouteAction_Cluster{Cluster: mr.cluster}},
+		})
+	}
+	routes = append(routes, &thrift.Route{
+		// An empty method name matches every method.
+		Match: &thrift.RouteMatch{MatchSpecifier: &thrift.RouteMatch_MethodName{MethodName: ""}},
+		Route: action,
+	})
+
+	thriftProxy := &thrift.ThriftProxy{
+		StatPrefix: statPrefix, // thrift stats are prefixed with thrift.<statPrefix> by Envoy
+		RouteConfig: &thrift.RouteConfiguration{
+			Name:   statPrefix,
+			Routes: routes,
+		},
+	}
+
+	out := &listener.Filter{
+		Name:       wellknown.ThriftProxy,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(thriftProxy)},
+	}
+
+	return out
+}
+
+// thriftMethodMatch matches the calls of a method route. The multiplexed protocol prefixes the method names with
+// their service and a colon.
+func thriftMethodMatch(mr rpcroute.MethodRoute) *thrift.RouteMatch {
+	switch {
+	case mr.Method == rpcroute.Wildcard:
+		return &thrift.RouteMatch{MatchSpecifier: &thrift.RouteMatch_ServiceName{ServiceName: mr.Service}}
+	case mr.Service == rpcroute.Wildcard:
+		return &thrift.RouteMatch{MatchSpecifier: &thrift.RouteMatch_MethodName{MethodName: mr.Method}}
+	default:
+		return &thrift.RouteMatch{MatchSpecifier: &thrift.RouteMatch_MethodName{MethodName: mr.Service + ":" + mr.Method}}
+	}
+}
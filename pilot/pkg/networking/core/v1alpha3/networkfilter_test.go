@@ -15,20 +15,26 @@
 package v1alpha3
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	dubbo "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/dubbo_proxy/v3"
 	redis "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/redis_proxy/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	thrift "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/thrift_proxy/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/redisroute"
+	"istio.io/istio/pkg/config/rpcroute"
 	"istio.io/istio/pkg/config/schema/collections"
 )
 
@@ -56,6 +62,121 @@ func TestBuildRedisFilter(t *testing.T) {
 	}
 }
 
+func TestBuildDubboFilter(t *testing.T) {
+	dubboFilter := buildDubboFilter("dubbo", "dubbo-cluster", nil, nil)
+	if dubboFilter.Name != DubboProxyFilterName {
+		t.Fatalf("dubbo filter name is %s not %s", dubboFilter.Name, DubboProxyFilterName)
+	}
+	dubboProxy := &dubbo.DubboProxy{}
+	if err := dubboFilter.GetTypedConfig().UnmarshalTo(dubboProxy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if dubboProxy.StatPrefix != "dubbo" {
+		t.Errorf("dubbo proxy statPrefix is %s", dubboProxy.StatPrefix)
+	}
+	if len(dubboProxy.RouteConfig) != 1 || dubboProxy.RouteConfig[0].Interface != "*" {
+		t.Fatalf("expected a single route config for every interface, got %v", dubboProxy.RouteConfig)
+	}
+	routes := dubboProxy.RouteConfig[0].Routes
+	if len(routes) != 1 || routes[0].GetRoute().GetCluster() != "dubbo-cluster" {
+		t.Errorf("expected a single route to dubbo-cluster, got %v", routes)
+	}
+	if routes[0].GetMatch().GetMethod().GetName().GetSafeRegex().GetRegex() != ".+" {
+		t.Errorf("expected the route to match every method, got %v", routes[0].GetMatch())
+	}
+}
+
+func TestBuildThriftFilter(t *testing.T) {
+	thriftFilter := buildThriftFilter("thrift", "thrift-cluster", nil, nil)
+	if thriftFilter.Name != wellknown.ThriftProxy {
+		t.Fatalf("thrift filter name is %s not %s", thriftFilter.Name, wellknown.ThriftProxy)
+	}
+	thriftProxy := &thrift.ThriftProxy{}
+	if err := thriftFilter.GetTypedConfig().UnmarshalTo(thriftProxy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if thriftProxy.StatPrefix != "thrift" {
+		t.Errorf("thrift proxy statPrefix is %s", thriftProxy.StatPrefix)
+	}
+	routes := thriftProxy.GetRouteConfig().GetRoutes()
+	if len(routes) != 1 || routes[0].GetRoute().GetCluster() != "thrift-cluster" {
+		t.Fatalf("expected a single route to thrift-cluster, got %v", routes)
+	}
+	if _, ok := routes[0].GetMatch().GetMatchSpecifier().(*thrift.RouteMatch_MethodName); !ok || routes[0].GetMatch().GetMethodName() != "" {
+		t.Errorf("expected the route to match every method, got %v", routes[0].GetMatch())
+	}
+}
+
+func TestBuildWeightedDubboAndThriftFilters(t *testing.T) {
+	weighted := &tcp.TcpProxy_WeightedCluster{Clusters: []*tcp.TcpProxy_WeightedCluster_ClusterWeight{
+		{Name: "outbound|9090|v1|svc.default.svc.cluster.local", Weight: 80},
+		{Name: "outbound|9090|v2|svc.default.svc.cluster.local", Weight: 20},
+	}}
+	want := map[string]uint32{
+		"outbound|9090|v1|svc.default.svc.cluster.local": 80,
+		"outbound|9090|v2|svc.default.svc.cluster.local": 20,
+	}
+
+	dubboProxy := &dubbo.DubboProxy{}
+	if err := buildDubboFilter("dubbo", weighted.Clusters[0].Name, weighted, nil).GetTypedConfig().UnmarshalTo(dubboProxy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	got := map[string]uint32{}
+	for _, c := range dubboProxy.RouteConfig[0].Routes[0].GetRoute().GetWeightedClusters().GetClusters() {
+		got[c.Name] = c.Weight.GetValue()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected dubbo weighted clusters %v, got %v", want, got)
+	}
+
+	thriftProxy := &thrift.ThriftProxy{}
+	if err := buildThriftFilter("thrift", weighted.Clusters[0].Name, weighted, nil).GetTypedConfig().UnmarshalTo(thriftProxy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	got = map[string]uint32{}
+	for _, c := range thriftProxy.RouteConfig.Routes[0].GetRoute().GetWeightedClusters().GetClusters() {
+		got[c.Name] = c.Weight.GetValue()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected thrift weighted clusters %v, got %v", want, got)
+	}
+}
+
+func TestBuildNetworkFiltersStack(t *testing.T) {
+	tcpFilter := &listener.Filter{Name: wellknown.TCPProxy}
+	cases := []struct {
+		name     string
+		protocol protocol.Instance
+		enabled  bool
+		want     []string
+	}{
+		{"kafka", protocol.Kafka, true, []string{KafkaBrokerFilterName, wellknown.TCPProxy}},
+		{"kafka disabled", protocol.Kafka, false, []string{wellknown.TCPProxy}},
+		{"dubbo", protocol.Dubbo, true, []string{DubboProxyFilterName}},
+		{"dubbo disabled", protocol.Dubbo, false, []string{wellknown.TCPProxy}},
+		{"thrift", protocol.Thrift, true, []string{wellknown.ThriftProxy}},
+		{"thrift disabled", protocol.Thrift, false, []string{wellknown.TCPProxy}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			kafka, dubbo, thrift := features.EnableKafkaFilter, features.EnableDubboFilter, features.EnableThriftFilter
+			features.EnableKafkaFilter, features.EnableDubboFilter, features.EnableThriftFilter = tt.enabled, tt.enabled, tt.enabled
+			t.Cleanup(func() {
+				features.EnableKafkaFilter, features.EnableDubboFilter, features.EnableThriftFilter = kafka, dubbo, thrift
+			})
+
+			port := &model.Port{Name: tt.name, Port: 9090, Protocol: tt.protocol}
+			got := []string{}
+			for _, f := range buildNetworkFiltersStack(port, tcpFilter, "stats", "cluster", nil, nil) {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected filters %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestInboundNetworkFilterStatPrefix(t *testing.T) {
 	cases := []struct {
 		name               string
@@ -287,6 +408,198 @@ func TestOutboundNetworkFilterStatPrefix(t *testing.T) {
 	}
 }
 
+func TestOutboundRedisFilters(t *testing.T) {
+	old := features.EnableRedisFilter
+	features.EnableRedisFilter = true
+	t.Cleanup(func() { features.EnableRedisFilter = old })
+
+	services := []*model.Service{
+		buildServiceWithPort("redis.com", 6379, protocol.Redis, tnow),
+		buildServiceWithPort("shadow.com", 6379, protocol.Redis, tnow),
+	}
+	env := buildListenerEnv(services)
+	env.PushContext.InitContext(env, nil, nil)
+	proxy := getProxy()
+	proxy.IstioVersion = model.ParseIstioVersion(proxy.Metadata.IstioVersion)
+	proxy.SidecarScope = model.DefaultSidecarScopeForNamespace(env.PushContext, "not-default")
+
+	routes := []*networking.RouteDestination{
+		{Destination: &networking.Destination{Host: "redis.com", Subset: "old"}, Weight: 90},
+		{Destination: &networking.Destination{Host: "shadow.com"}, Weight: 10},
+	}
+	meta := config.Meta{
+		Name:      "redis",
+		Namespace: "ns",
+		Annotations: map[string]string{
+			redisroute.KeyPrefixRoutesAnnotation: "user:=new, session:=new,malformed",
+			redisroute.MirrorsAnnotation:         "shadow.com=10",
+		},
+	}
+	filters := buildOutboundNetworkFilters(proxy, routes, env.PushContext, &model.Port{Port: 6379, Protocol: protocol.Redis}, meta)
+	last := filters[len(filters)-1]
+	if last.Name != wellknown.RedisProxy {
+		t.Fatalf("expected the redis proxy to terminate the filter chain, got %v", last.Name)
+	}
+	redisProxy := &redis.RedisProxy{}
+	if err := last.GetTypedConfig().UnmarshalTo(redisProxy); err != nil {
+		t.Fatal(err)
+	}
+	if redisProxy.StatPrefix != "redis.ns" {
+		t.Errorf("unexpected stat prefix %v", redisProxy.StatPrefix)
+	}
+	mirrors := func(r *redis.RedisProxy_PrefixRoutes_Route) []string {
+		out := []string{}
+		for _, m := range r.RequestMirrorPolicy {
+			if m.RuntimeFraction.GetDefaultValue().GetNumerator() != 10 {
+				t.Errorf("unexpected mirror fraction %v", m.RuntimeFraction)
+			}
+			out = append(out, m.Cluster)
+		}
+		return out
+	}
+	catchAll := redisProxy.PrefixRoutes.CatchAllRoute
+	if catchAll.Cluster != "outbound|6379|old|redis.com" {
+		t.Errorf("unexpected catch all cluster %v", catchAll.Cluster)
+	}
+	wantMirrors := []string{"outbound|6379||shadow.com"}
+	if got := mirrors(catchAll); !reflect.DeepEqual(got, wantMirrors) {
+		t.Errorf("expected mirrors %v, got %v", wantMirrors, got)
+	}
+	got := map[string]string{}
+	for _, r := range redisProxy.PrefixRoutes.Routes {
+		got[r.Prefix] = r.Cluster
+		if m := mirrors(r); !reflect.DeepEqual(m, wantMirrors) {
+			t.Errorf("expected mirrors %v for prefix %v, got %v", wantMirrors, r.Prefix, m)
+		}
+	}
+	want := map[string]string{"user:": "outbound|6379|new|redis.com", "session:": "outbound|6379|new|redis.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected prefix routes %v, got %v", want, got)
+	}
+}
+
+func TestOutboundRedisFiltersWithoutMirrors(t *testing.T) {
+	old := features.EnableRedisFilter
+	features.EnableRedisFilter = true
+	t.Cleanup(func() { features.EnableRedisFilter = old })
+
+	services := []*model.Service{
+		buildServiceWithPort("redis.com", 6379, protocol.Redis, tnow),
+		buildServiceWithPort("shadow.com", 6379, protocol.Redis, tnow),
+	}
+	env := buildListenerEnv(services)
+	env.PushContext.InitContext(env, nil, nil)
+	proxy := getProxy()
+	proxy.IstioVersion = model.ParseIstioVersion(proxy.Metadata.IstioVersion)
+	proxy.SidecarScope = model.DefaultSidecarScopeForNamespace(env.PushContext, "not-default")
+
+	// The weight of the other destinations is not a mirror percentage.
+	routes := []*networking.RouteDestination{
+		{Destination: &networking.Destination{Host: "redis.com"}, Weight: 90},
+		{Destination: &networking.Destination{Host: "shadow.com"}, Weight: 10},
+	}
+	filters := buildOutboundNetworkFilters(proxy, routes, env.PushContext, &model.Port{Port: 6379, Protocol: protocol.Redis},
+		config.Meta{Name: "redis", Namespace: "ns"})
+	redisProxy := &redis.RedisProxy{}
+	if err := filters[len(filters)-1].GetTypedConfig().UnmarshalTo(redisProxy); err != nil {
+		t.Fatal(err)
+	}
+	catchAll := redisProxy.PrefixRoutes.CatchAllRoute
+	if catchAll.Cluster != "outbound|6379||redis.com" || len(catchAll.RequestMirrorPolicy) != 0 {
+		t.Errorf("expected an unmirrored catch all route to redis.com, got %v", catchAll)
+	}
+}
+
+func TestOutboundRPCMethodRoutes(t *testing.T) {
+	dubboEnabled, thriftEnabled := features.EnableDubboFilter, features.EnableThriftFilter
+	features.EnableDubboFilter, features.EnableThriftFilter = true, true
+	t.Cleanup(func() { features.EnableDubboFilter, features.EnableThriftFilter = dubboEnabled, thriftEnabled })
+
+	services := []*model.Service{
+		buildServiceWithPort("dubbo.com", 20880, protocol.Dubbo, tnow),
+		buildServiceWithPort("thrift.com", 9090, protocol.Thrift, tnow),
+	}
+	env := buildListenerEnv(services)
+	env.PushContext.InitContext(env, nil, nil)
+	proxy := getProxy()
+	proxy.IstioVersion = model.ParseIstioVersion(proxy.Metadata.IstioVersion)
+	proxy.SidecarScope = model.DefaultSidecarScopeForNamespace(env.PushContext, "not-default")
+	meta := config.Meta{
+		Name:      "rpc",
+		Namespace: "ns",
+		Annotations: map[string]string{
+			rpcroute.MethodRoutesAnnotation: "com.example.Users/get=v2, com.example.Orders/*=v2, */ping=v3, malformed",
+		},
+	}
+
+	t.Run("dubbo", func(t *testing.T) {
+		routes := []*networking.RouteDestination{{Destination: &networking.Destination{Host: "dubbo.com"}}}
+		filters := buildOutboundNetworkFilters(proxy, routes, env.PushContext, &model.Port{Port: 20880, Protocol: protocol.Dubbo}, meta)
+		dubboProxy := &dubbo.DubboProxy{}
+		if err := filters[len(filters)-1].GetTypedConfig().UnmarshalTo(dubboProxy); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		interfaces := []string{}
+		for _, rc := range dubboProxy.RouteConfig {
+			interfaces = append(interfaces, rc.Interface)
+			for _, r := range rc.Routes {
+				method := r.GetMatch().GetMethod().GetName()
+				name := method.GetExact()
+				if method.GetSafeRegex() != nil {
+					name = "*"
+				}
+				got[rc.Interface] = append(got[rc.Interface], name+"="+r.GetRoute().GetCluster())
+			}
+		}
+		// The routes of every interface come last, so that the method routes of the interfaces match first.
+		if want := []string{"com.example.Users", "com.example.Orders", "*"}; !reflect.DeepEqual(interfaces, want) {
+			t.Fatalf("expected interfaces %v, got %v", want, interfaces)
+		}
+		want := map[string][]string{
+			"com.example.Users":  {"get=outbound|20880|v2|dubbo.com", "*=outbound|20880||dubbo.com"},
+			"com.example.Orders": {"*=outbound|20880|v2|dubbo.com", "*=outbound|20880||dubbo.com"},
+			"*":                  {"ping=outbound|20880|v3|dubbo.com", "*=outbound|20880||dubbo.com"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected routes %v, got %v", want, got)
+		}
+	})
+
+	t.Run("thrift", func(t *testing.T) {
+		routes := []*networking.RouteDestination{
+			{Destination: &networking.Destination{Host: "thrift.com", Subset: "v1"}, Weight: 50},
+			{Destination: &networking.Destination{Host: "thrift.com", Subset: "v2"}, Weight: 50},
+		}
+		filters := buildOutboundNetworkFilters(proxy, routes, env.PushContext, &model.Port{Port: 9090, Protocol: protocol.Thrift}, meta)
+		thriftProxy := &thrift.ThriftProxy{}
+		if err := filters[len(filters)-1].GetTypedConfig().UnmarshalTo(thriftProxy); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, r := range thriftProxy.RouteConfig.Routes {
+			cluster := r.GetRoute().GetCluster()
+			if wc := r.GetRoute().GetWeightedClusters(); wc != nil {
+				cluster = fmt.Sprintf("%d weighted clusters", len(wc.Clusters))
+			}
+			match := "method " + r.GetMatch().GetMethodName()
+			if _, ok := r.GetMatch().GetMatchSpecifier().(*thrift.RouteMatch_ServiceName); ok {
+				match = "service " + r.GetMatch().GetServiceName()
+			}
+			got = append(got, match+"="+cluster)
+		}
+		want := []string{
+			"method com.example.Users:get=outbound|9090|v2|thrift.com",
+			"service com.example.Orders=outbound|9090|v2|thrift.com",
+			"method ping=outbound|9090|v3|thrift.com",
+			"method =2 weighted clusters",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected routes %v, got %v", want, got)
+		}
+	})
+}
+
 func TestOutboundNetworkFilterWithSourceIPHashing(t *testing.T) {
 	services := []*model.Service{
 		buildService("test.com", "10.10.0.0/24", protocol.TCP, tnow),
@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitconfig "github.com/envoyproxy/go-control-plane/envoy/config/ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/extensionproviders"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/pkg/log"
+)
+
+// buildRateLimitFilters builds the local rate limit filter, and a rate limit filter for each envoyRateLimit
+// provider. The filters only limit the routes configuring rate limits for them.
+func buildRateLimitFilters(push *model.PushContext) []*hcm.HttpFilter {
+	filters := []*hcm.HttpFilter{xdsfilters.LocalRateLimit}
+	for _, p := range push.RateLimitProviders() {
+		stage, _ := push.RateLimitStage(p.Name)
+		_, cluster, err := extensionproviders.LookupCluster(push, p.EnvoyRateLimit.Service, int(p.EnvoyRateLimit.Port))
+		if err != nil {
+			log.Errorf("failed to build rate limit filter for provider %s: %v", p.Name, err)
+			continue
+		}
+		filters = append(filters, &hcm.HttpFilter{
+			Name: wellknown.HTTPRateLimit + "." + p.Name,
+			ConfigType: &hcm.HttpFilter_TypedConfig{
+				TypedConfig: util.MessageToAny(&ratelimit.RateLimit{
+					Domain:          p.EnvoyRateLimit.Domain,
+					Stage:           stage,
+					Timeout:         durationpb.New(p.EnvoyRateLimit.TimeoutDuration()),
+					FailureModeDeny: !p.EnvoyRateLimit.FailOpen,
+					RateLimitService: &ratelimitconfig.RateLimitServiceConfig{
+						GrpcService: &core.GrpcService{
+							TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+								EnvoyGrpc: &core.GrpcService_EnvoyGrpc{
+									ClusterName: cluster,
+									// `|` is invalid in the gRPC authority header.
+									Authority: strings.ReplaceAll(cluster, "|", "_."),
+								},
+							},
+						},
+						TransportApiVersion: core.ApiVersion_V3,
+					},
+				}),
+			},
+		})
+	}
+	return filters
+}
@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extproc "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	any "google.golang.org/protobuf/types/known/anypb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/util"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config"
+	extprocconfig "istio.io/istio/pkg/config/extproc"
+	"istio.io/pkg/log"
+)
+
+// extProcOverrides returns the ext_proc route overrides of the virtual service. Invalid overrides are ignored.
+func extProcOverrides(virtualService config.Config) []extprocconfig.RouteOverride {
+	overrides, err := extprocconfig.RouteOverridesFromAnnotations(virtualService.Annotations)
+	if err == nil {
+		err = extprocconfig.ValidateRouteOverrides(overrides)
+	}
+	if err != nil {
+		log.Warnf("ignoring ext_proc route overrides of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return overrides
+}
+
+// applyExtProc configures the ext_proc filters on the route built from the given HTTP route. The filters are
+// only added to the proxies the providers are attached to, and ignore the configuration otherwise.
+func applyExtProc(out *route.Route, in *networking.HTTPRoute, overrides []extprocconfig.RouteOverride) {
+	for _, o := range overrides {
+		if !o.AppliesTo(in.Name) {
+			continue
+		}
+		perRoute := &extproc.ExtProcPerRoute{}
+		if o.Disabled {
+			perRoute.Override = &extproc.ExtProcPerRoute_Disabled{Disabled: true}
+		} else {
+			perRoute.Override = &extproc.ExtProcPerRoute_Overrides{Overrides: &extproc.ExtProcOverrides{
+				ProcessingMode: xdsfilters.BuildExtProcProcessingMode(o.ProcessingMode),
+			}}
+		}
+		if out.TypedPerFilterConfig == nil {
+			out.TypedPerFilterConfig = make(map[string]*any.Any)
+		}
+		out.TypedPerFilterConfig[xdsfilters.ExtProcFilterName+"."+o.Provider] = util.MessageToAny(perRoute)
+	}
+}
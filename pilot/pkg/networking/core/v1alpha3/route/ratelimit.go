@@ -0,0 +1,159 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	commonratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	any "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/ratelimit"
+	"istio.io/pkg/log"
+)
+
+// localRateLimitStage is the stage of the rate limit actions of the local rate limit filter. The envoyRateLimit
+// providers use the following ones.
+const localRateLimitStage = 0
+
+var fullyEnabled = &core.RuntimeFractionalPercent{
+	DefaultValue: &xdstype.FractionalPercent{
+		Numerator:   100,
+		Denominator: xdstype.FractionalPercent_HUNDRED,
+	},
+}
+
+// rateLimitPolicy returns the rate limit policy of the virtual service, or nil if it has none or rate limiting
+// is disabled. Invalid policies are ignored.
+func rateLimitPolicy(virtualService config.Config) *ratelimit.Policy {
+	if !features.EnableRateLimit {
+		return nil
+	}
+	p, err := ratelimit.FromAnnotations(virtualService.Annotations)
+	if err == nil && p != nil {
+		err = p.Validate()
+	}
+	if err != nil {
+		log.Warnf("ignoring rate limit policy of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return p
+}
+
+// applyRateLimit configures the rate limits of the policy on the route built from the given HTTP route.
+func applyRateLimit(out *route.Route, in *networking.HTTPRoute, p *ratelimit.Policy, virtualServiceName string,
+	push *model.PushContext) {
+	if p == nil || !p.AppliesTo(in.Name) {
+		return
+	}
+	routeName := in.Name
+	if routeName == "" {
+		routeName = virtualServiceName
+	}
+	// Redirects have no route action, so only their local token bucket applies.
+	action := out.GetRoute()
+
+	if p.Local != nil {
+		if out.TypedPerFilterConfig == nil {
+			out.TypedPerFilterConfig = make(map[string]*any.Any)
+		}
+		out.TypedPerFilterConfig[xdsfilters.LocalRateLimitFilterName] = util.MessageToAny(translateLocalRateLimit(p.Local))
+		if action != nil {
+			action.RateLimits = append(action.RateLimits, translateRateLimits(p.Descriptors, localRateLimitStage, routeName)...)
+		}
+	}
+	if p.Global != nil && action != nil {
+		stage, f := push.RateLimitStage(p.Global.Provider)
+		if !f {
+			log.Warnf("ignoring global rate limit of route %s: unknown envoyRateLimit provider %s", routeName, p.Global.Provider)
+			return
+		}
+		action.RateLimits = append(action.RateLimits, translateRateLimits(p.Descriptors, stage, routeName)...)
+	}
+}
+
+func translateLocalRateLimit(in *ratelimit.Local) *localratelimit.LocalRateLimit {
+	out := &localratelimit.LocalRateLimit{
+		StatPrefix:     xdsfilters.LocalRateLimitStatPrefix,
+		TokenBucket:    translateTokenBucket(in.TokenBucket),
+		FilterEnabled:  fullyEnabled,
+		FilterEnforced: fullyEnabled,
+		Stage:          localRateLimitStage,
+	}
+	for _, d := range in.Descriptors {
+		descriptor := &commonratelimit.LocalRateLimitDescriptor{
+			TokenBucket: translateTokenBucket(d.TokenBucket),
+		}
+		for _, e := range d.Entries {
+			descriptor.Entries = append(descriptor.Entries, &commonratelimit.RateLimitDescriptor_Entry{
+				Key:   e.Key,
+				Value: e.Value,
+			})
+		}
+		out.Descriptors = append(out.Descriptors, descriptor)
+	}
+	return out
+}
+
+func translateTokenBucket(in ratelimit.TokenBucket) *xdstype.TokenBucket {
+	return &xdstype.TokenBucket{
+		MaxTokens:     in.MaxTokens,
+		TokensPerFill: &wrappers.UInt32Value{Value: in.Fill()},
+		FillInterval:  durationpb.New(in.FillDuration()),
+	}
+}
+
+func translateRateLimits(descriptors []ratelimit.Descriptor, stage uint32, routeName string) []*route.RateLimit {
+	out := make([]*route.RateLimit, 0, len(descriptors))
+	for _, d := range descriptors {
+		rl := &route.RateLimit{Stage: &wrappers.UInt32Value{Value: stage}}
+		for _, e := range d.Entries {
+			rl.Actions = append(rl.Actions, translateRateLimitAction(e, routeName))
+		}
+		out = append(out, rl)
+	}
+	return out
+}
+
+func translateRateLimitAction(e ratelimit.Entry, routeName string) *route.RateLimit_Action {
+	switch e.Type {
+	case ratelimit.EntryHost:
+		return &route.RateLimit_Action{ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+			RequestHeaders: &route.RateLimit_Action_RequestHeaders{HeaderName: ":authority", DescriptorKey: e.DescriptorKey()},
+		}}
+	case ratelimit.EntryRoute:
+		return &route.RateLimit_Action{ActionSpecifier: &route.RateLimit_Action_GenericKey_{
+			GenericKey: &route.RateLimit_Action_GenericKey{DescriptorKey: e.DescriptorKey(), DescriptorValue: routeName},
+		}}
+	case ratelimit.EntryHeader:
+		return &route.RateLimit_Action{ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+			RequestHeaders: &route.RateLimit_Action_RequestHeaders{HeaderName: e.Header, DescriptorKey: e.DescriptorKey()},
+		}}
+	default:
+		return &route.RateLimit_Action{ActionSpecifier: &route.RateLimit_Action_RemoteAddress_{
+			RemoteAddress: &route.RateLimit_Action_RemoteAddress{},
+		}}
+	}
+}
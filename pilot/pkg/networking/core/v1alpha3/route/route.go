@@ -27,6 +27,7 @@ import (
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/grpc/codes"
 	any "google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
@@ -116,7 +117,7 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 
 	// translate all virtual service configs into virtual hosts
 	for _, virtualService := range virtualServices {
-		wrappers := buildSidecarVirtualHostsForVirtualService(node, virtualService, serviceRegistry, hashByDestination, listenPort, push.Mesh)
+		wrappers := buildSidecarVirtualHostsForVirtualService(node, virtualService, serviceRegistry, hashByDestination, listenPort, push)
 		out = append(out, wrappers...)
 	}
 
@@ -205,11 +206,11 @@ func buildSidecarVirtualHostsForVirtualService(
 	serviceRegistry map[host.Name]*model.Service,
 	hashByDestination map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB,
 	listenPort int,
-	mesh *meshconfig.MeshConfig,
+	push *model.PushContext,
 ) []VirtualHostWrapper {
 	meshGateway := map[string]bool{constants.IstioMeshGateway: true}
 	routes, err := BuildHTTPRoutesForVirtualService(node, virtualService, serviceRegistry, hashByDestination,
-		listenPort, meshGateway, false /* isH3DiscoveryNeeded */, mesh)
+		listenPort, meshGateway, false /* isH3DiscoveryNeeded */, push)
 	if err != nil || len(routes) == 0 {
 		return nil
 	}
@@ -317,7 +318,7 @@ func BuildHTTPRoutesForVirtualService(
 	listenPort int,
 	gatewayNames map[string]bool,
 	isHTTP3AltSvcHeaderNeeded bool,
-	mesh *meshconfig.MeshConfig,
+	push *model.PushContext,
 ) ([]*route.Route, error) {
 	vs, ok := virtualService.Spec.(*networking.VirtualService)
 	if !ok { // should never happen
@@ -325,19 +326,25 @@ func BuildHTTPRoutesForVirtualService(
 	}
 
 	out := make([]*route.Route, 0, len(vs.Http))
+	rateLimit := rateLimitPolicy(virtualService)
+	extProc := extProcOverrides(virtualService)
 
 	catchall := false
 	for _, http := range vs.Http {
 		if len(http.Match) == 0 {
 			if r := translateRoute(node, http, nil, listenPort, virtualService, serviceRegistry,
-				hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
+				hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, push.Mesh); r != nil {
+				applyRateLimit(r, http, rateLimit, virtualService.Name, push)
+				applyExtProc(r, http, extProc)
 				out = append(out, r)
 			}
 			catchall = true
 		} else {
 			for _, match := range http.Match {
 				if r := translateRoute(node, http, match, listenPort, virtualService, serviceRegistry,
-					hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
+					hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, push.Mesh); r != nil {
+					applyRateLimit(r, http, rateLimit, virtualService.Name, push)
+					applyExtProc(r, http, extProc)
 					out = append(out, r)
 					// This is a catch all path. Routes are matched in order, so we will never go beyond this match
 					// As an optimization, we can just top sending any more routes here.
@@ -1194,6 +1201,17 @@ func translateFault(in *networking.HTTPFaultInjection) *xdshttpfault.HTTPFault {
 			out.Abort.ErrorType = &xdshttpfault.FaultAbort_HttpStatus{
 				HttpStatus: uint32(a.HttpStatus),
 			}
+		case *networking.HTTPFaultInjection_Abort_GrpcStatus:
+			// The status is the name of the code, for example UNAVAILABLE.
+			var code codes.Code
+			if err := code.UnmarshalJSON([]byte(strconv.Quote(a.GrpcStatus))); err != nil {
+				log.Warnf("Invalid gRPC status %q in abort fault", a.GrpcStatus)
+				out.Abort = nil
+				break
+			}
+			out.Abort.ErrorType = &xdshttpfault.FaultAbort_GrpcStatus{
+				GrpcStatus: uint32(code),
+			}
 		default:
 			log.Warnf("Non-HTTP type abort faults are not yet supported")
 			out.Abort = nil
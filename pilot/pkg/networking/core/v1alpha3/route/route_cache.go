@@ -49,6 +49,8 @@ type Cache struct {
 	DelegateVirtualServices []model.ConfigKey
 	DestinationRules        []*config.Config
 	EnvoyFilterKeys         []string
+	// TraceSampling identifies the trace sampling rules applied to the routes.
+	TraceSampling string
 }
 
 func (r *Cache) Cacheable() bool {
@@ -116,6 +118,7 @@ func (r *Cache) Key() string {
 		params = append(params, dr.Name+"/"+dr.Namespace)
 	}
 	params = append(params, r.EnvoyFilterKeys...)
+	params = append(params, r.TraceSampling)
 
 	hash := md5.New()
 	for _, param := range params {
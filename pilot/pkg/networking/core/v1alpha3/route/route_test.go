@@ -38,7 +38,9 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/ratelimit"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/tracesampling"
 	"istio.io/istio/pkg/util/gogo"
 )
 
@@ -75,7 +77,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		os.Setenv("ISTIO_DEFAULT_REQUEST_TIMEOUT", "0ms")
 		defer os.Unsetenv("ISTIO_DEFAULT_REQUEST_TIMEOUT")
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -90,7 +92,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, true, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, true, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(routes[0].GetResponseHeadersToAdd()).To(gomega.Equal([]*core.HeaderValueOption{
@@ -112,7 +114,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		features.DefaultRequestTimeout = durationpb.New(1 * time.Second)
 		defer func() { features.DefaultRequestTimeout = dt }()
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServicePlain, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -123,11 +125,67 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g.Expect(routes[0].GetRoute().MaxGrpcTimeout.Seconds).To(gomega.Equal(int64(1)))
 	})
 
+	t.Run("for virtual service with rate limit", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+			ExtensionProviders: xdstest.ExtensionProviders(t,
+				`[{name: ratelimit, envoyRateLimit: {service: ratelimit.default.svc.cluster.local, port: 8081, domain: test}}]`),
+		})
+
+		enabled := features.EnableRateLimit
+		features.EnableRateLimit = true
+		defer func() { features.EnableRateLimit = enabled }()
+
+		vs := virtualServicePlain.DeepCopy()
+		vs.Annotations = map[string]string{ratelimit.Annotation: `
+descriptors:
+- entries:
+  - type: header
+    header: x-user-id
+  - type: route
+local:
+  maxTokens: 10
+  fillInterval: 1s
+global:
+  provider: ratelimit
+`}
+		vs.Spec.(*networking.VirtualService).Http[0].Name = "api"
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), vs, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		g.Expect(routes[0].GetTypedPerFilterConfig()).To(gomega.HaveKey("envoy.filters.http.local_ratelimit"))
+		rateLimits := routes[0].GetRoute().GetRateLimits()
+		g.Expect(len(rateLimits)).To(gomega.Equal(2))
+		// The local descriptors use stage 0, and each envoyRateLimit provider its own stage.
+		g.Expect(rateLimits[0].GetStage().GetValue()).To(gomega.Equal(uint32(0)))
+		g.Expect(rateLimits[1].GetStage().GetValue()).To(gomega.Equal(uint32(1)))
+		for _, rl := range rateLimits {
+			g.Expect(rl.GetActions()[0].GetRequestHeaders().GetHeaderName()).To(gomega.Equal("x-user-id"))
+			g.Expect(rl.GetActions()[1].GetGenericKey().GetDescriptorValue()).To(gomega.Equal("api"))
+		}
+	})
+
+	t.Run("for virtual service with rate limit disabled", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
+
+		vs := virtualServicePlain.DeepCopy()
+		vs.Annotations = map[string]string{ratelimit.Annotation: `{local: {maxTokens: 10, fillInterval: 1s}}`}
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), vs, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(routes[0].GetTypedPerFilterConfig()).To(gomega.BeEmpty())
+		g.Expect(routes[0].GetRoute().GetRateLimits()).To(gomega.BeEmpty())
+	})
+
 	t.Run("for virtual service with timeout", func(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithTimeout, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithTimeout, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -142,7 +200,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithTimeoutDisabled, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithTimeoutDisabled, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -156,7 +214,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithCatchAllRoute,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -170,7 +228,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithCatchAllRouteWeightedDestination,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -182,7 +240,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithCatchAllMultiPrefixRoute,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 
 		g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -194,7 +252,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRegexMatchingOnURI,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -206,7 +264,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithExactMatchingOnHeaderForJWTClaims,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -222,7 +280,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRegexMatchingOnHeader,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -236,7 +294,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRegexMatchingOnWithoutHeader,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -251,7 +309,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithPresentMatchingOnHeader,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -265,7 +323,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithPresentMatchingOnWithoutHeader,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -281,7 +339,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 			g := gomega.NewWithT(t)
 			cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 			routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), *c, serviceRegistry, nil,
-				8080, gatewayNames, false, nil)
+				8080, gatewayNames, false, cg.PushContext())
 			xdstest.ValidateRoutes(t, routes)
 			g.Expect(err).NotTo(gomega.HaveOccurred())
 			g.Expect(len(routes)).To(gomega.Equal(1))
@@ -300,7 +358,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(fooNode, virtualServiceMatchingOnSourceNamespace,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -311,7 +369,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		})
 
 		routes, err = route.BuildHTTPRoutesForVirtualService(barNode, virtualServiceMatchingOnSourceNamespace,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
 		g.Expect(routes[0].GetName()).To(gomega.Equal("bar"))
@@ -353,7 +411,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		proxy := node(cg)
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualServicePlain, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualServicePlain, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -401,7 +459,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		proxy := node(cg)
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualServicePlain, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualServicePlain, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -446,7 +504,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		proxy := node(cg)
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualService, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualService, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -489,7 +547,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		proxy := node(cg)
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualService, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualService, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -535,7 +593,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		proxy := node(cg)
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualService, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualService, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -570,7 +628,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		gatewayNames := map[string]bool{"some-gateway": true}
 		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualServicePlain, serviceRegistry)
 		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualServicePlain, serviceRegistry,
-			hashByDestination, 8080, gatewayNames, false, nil)
+			hashByDestination, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -591,7 +649,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithHeaderOperationsForSingleCluster,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -676,7 +734,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
 		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithHeaderOperationsForWeightedCluster,
-			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+			serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -786,7 +844,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRedirect, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRedirect, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -800,7 +858,7 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
 
-		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRedirectAndSetHeader, serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithRedirectAndSetHeader, serviceRegistry, nil, 8080, gatewayNames, false, cg.PushContext())
 		xdstest.ValidateRoutes(t, routes)
 		g.Expect(err).NotTo(gomega.HaveOccurred())
 		g.Expect(len(routes)).To(gomega.Equal(1))
@@ -1771,3 +1829,61 @@ func TestCombineVHostRoutes(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyTraceSampling(t *testing.T) {
+	rules, err := tracesampling.Parse(`
+- {match: {route: orders, headers: {x-canary: "true"}}, randomSamplingPercentage: 100}
+- {match: {sourcePrincipal: cluster.local/ns/a/sa/client}, randomSamplingPercentage: 50}
+- {match: {route: health}, randomSamplingPercentage: 0}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vhosts := []*envoyroute.VirtualHost{{
+		Name: "foo",
+		Routes: []*envoyroute.Route{
+			{Name: "orders.v2", Match: &envoyroute.RouteMatch{}},
+			{Name: "health", Match: &envoyroute.RouteMatch{}},
+			{Name: "other", Match: &envoyroute.RouteMatch{}},
+		},
+	}}
+
+	t.Run("outbound", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		got := route.ApplyTraceSampling(vhosts, rules, false)
+		routes := got[0].Routes
+		g.Expect(routes).To(gomega.HaveLen(4))
+		g.Expect(routes[0].Name).To(gomega.Equal("orders.v2"))
+		g.Expect(routes[0].Match.Headers).To(gomega.HaveLen(1))
+		g.Expect(routes[0].Tracing.RandomSampling.Numerator).To(gomega.Equal(uint32(1000000)))
+		g.Expect(routes[1]).To(gomega.BeIdenticalTo(vhosts[0].Routes[0]))
+		g.Expect(routes[2].Tracing.RandomSampling.Numerator).To(gomega.Equal(uint32(0)))
+		g.Expect(routes[3]).To(gomega.BeIdenticalTo(vhosts[0].Routes[2]))
+		// The virtual hosts are shared between proxies and must not be modified.
+		g.Expect(vhosts[0].Routes).To(gomega.HaveLen(3))
+		g.Expect(vhosts[0].Routes[1].Tracing).To(gomega.BeNil())
+	})
+
+	t.Run("inbound mtls", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		got := route.ApplyTraceSampling(vhosts, rules, true)
+		routes := got[0].Routes
+		g.Expect(routes).To(gomega.HaveLen(7))
+		// The source principal is matched with the certificate of the peer.
+		g.Expect(routes[1].Match.Headers).To(gomega.HaveLen(1))
+		g.Expect(routes[1].Match.Headers[0].Name).To(gomega.Equal(route.HeaderClientCert))
+		g.Expect(routes[1].Tracing.RandomSampling.Numerator).To(gomega.Equal(uint32(500000)))
+	})
+
+	t.Run("inbound plaintext", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		got := route.ApplyTraceSampling(vhosts, rules, false)
+		// The client certificate header can be forged without mTLS, so the source principal rules are ignored.
+		for _, r := range got[0].Routes {
+			for _, h := range r.Match.Headers {
+				g.Expect(h.Name).NotTo(gomega.Equal(route.HeaderClientCert))
+			}
+		}
+		g.Expect(got[0].Routes).To(gomega.HaveLen(4))
+	})
+}
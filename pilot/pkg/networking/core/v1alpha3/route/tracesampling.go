@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	"math"
+	"regexp"
+	"sort"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	golangproto "google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/tracesampling"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// HeaderClientCert is the header the inbound listeners append the certificate of the mTLS peer to.
+const HeaderClientCert = "x-forwarded-client-cert"
+
+// ApplyTraceSampling returns the virtual hosts with the trace sampling rules applied to their routes. A rule
+// matching all the requests of a route sets the sampling percentage of the route. Otherwise, a copy of the route
+// only matching the requests of the rule is added before it. If mtls is set, the routes are the inbound routes of
+// a filter chain terminating Istio mTLS, and the source principal of the rules is matched against the
+// certificate of the peer. Otherwise, rules with a source principal are ignored: the client certificate header
+// could be forged by plaintext clients, and on the outbound routes tracesampling.ForWorkload has already
+// resolved them.
+// The virtual hosts and routes are not modified, the ones with rules applied are copies.
+func ApplyTraceSampling(virtualHosts []*route.VirtualHost, rules []tracesampling.Rule, mtls bool) []*route.VirtualHost {
+	if len(rules) == 0 {
+		return virtualHosts
+	}
+	out := make([]*route.VirtualHost, 0, len(virtualHosts))
+	for _, vh := range virtualHosts {
+		routes := make([]*route.Route, 0, len(vh.Routes))
+		changed := false
+		for _, r := range vh.Routes {
+			sampled := traceSamplingRoutes(r, rules, mtls)
+			if len(sampled) != 1 || sampled[0] != r {
+				changed = true
+			}
+			routes = append(routes, sampled...)
+		}
+		if changed {
+			vh = golangproto.Clone(vh).(*route.VirtualHost)
+			vh.Routes = routes
+		}
+		out = append(out, vh)
+	}
+	return out
+}
+
+// traceSamplingRoutes returns the routes replacing the given route to apply the trace sampling rules.
+func traceSamplingRoutes(r *route.Route, rules []tracesampling.Rule, mtls bool) []*route.Route {
+	var out []*route.Route
+	for _, rule := range rules {
+		if !rule.Match.AppliesToRoute(r.Name) || (!mtls && rule.Match.SourcePrincipal != "") {
+			continue
+		}
+		sampled := golangproto.Clone(r).(*route.Route)
+		if sampled.Tracing == nil {
+			sampled.Tracing = &route.Tracing{}
+		}
+		sampled.Tracing.RandomSampling = translateSamplingPercentage(*rule.RandomSamplingPercentage)
+		headers := traceSamplingHeaders(rule.Match)
+		if len(headers) == 0 {
+			// The rule matches all the requests of the route, the later rules do not apply.
+			return append(out, sampled)
+		}
+		if sampled.Match == nil {
+			sampled.Match = &route.RouteMatch{}
+		}
+		sampled.Match.Headers = append(sampled.Match.Headers, headers...)
+		out = append(out, sampled)
+	}
+	return append(out, r)
+}
+
+// traceSamplingHeaders returns the header matchers selecting the requests of the match.
+func traceSamplingHeaders(m tracesampling.Match) []*route.HeaderMatcher {
+	var headers []*route.HeaderMatcher
+	for name, value := range m.Headers {
+		headers = append(headers, translateHeaderMatch(name, &networking.StringMatch{
+			MatchType: &networking.StringMatch_Exact{Exact: value},
+		}))
+	}
+	// guarantee ordering of headers
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	if m.SourcePrincipal != "" {
+		// The certificate of the peer is the last element of the header, and its URI is the SPIFFE ID of the peer.
+		headers = append(headers, &route.HeaderMatcher{
+			Name: HeaderClientCert,
+			HeaderMatchSpecifier: &route.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: &matcher.RegexMatcher{
+					EngineType: regexEngine,
+					Regex:      `(.*,)?[^,]*;URI=` + regexp.QuoteMeta(spiffe.URIPrefix+m.SourcePrincipal) + `(;[^,]*)?`,
+				},
+			},
+		})
+	}
+	return headers
+}
+
+// translateSamplingPercentage translates a sampling percentage, in millionths to keep the precision of small
+// percentages.
+func translateSamplingPercentage(p float64) *xdstype.FractionalPercent {
+	return &xdstype.FractionalPercent{
+		Numerator:   uint32(math.Round(p * 10000)),
+		Denominator: xdstype.FractionalPercent_MILLION,
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3_test
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+const traceSamplingConfig = `
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: default
+  namespace: istio-system
+  annotations:
+    telemetry.istio.io/tracingSamplingRules: |
+      - match:
+          sourcePrincipal: cluster.local/ns/default/sa/client
+        randomSamplingPercentage: 100
+spec:
+  tracing:
+  - providers:
+    - name: stackdriver
+`
+
+func TestInboundTraceSamplingSourcePrincipal(t *testing.T) {
+	cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{ConfigString: traceSamplingConfig})
+	l := xdstest.ExtractListener("virtualInbound", cg.Listeners(cg.SetupProxy(nil)))
+	if l == nil {
+		t.Fatalf("expected the virtualInbound listener")
+	}
+	mtls, plaintext := 0, 0
+	for _, fc := range l.GetFilterChains() {
+		h := xdstest.ExtractHTTPConnectionManager(nil, fc)
+		if h == nil {
+			continue
+		}
+		matched := false
+		for _, vh := range h.GetRouteConfig().GetVirtualHosts() {
+			for _, r := range vh.GetRoutes() {
+				for _, header := range r.GetMatch().GetHeaders() {
+					if header.GetName() == route.HeaderClientCert {
+						matched = true
+					}
+				}
+			}
+		}
+		if fc.GetTransportSocket() != nil {
+			mtls++
+			if !matched {
+				t.Errorf("expected the source principal to be matched on the mTLS filter chain %s", fc.GetName())
+			}
+		} else {
+			plaintext++
+			if matched {
+				t.Errorf("expected the source principal to be ignored on the plaintext filter chain %s", fc.GetName())
+			}
+		}
+	}
+	if mtls == 0 || plaintext == 0 {
+		t.Fatalf("expected mTLS and plaintext HTTP filter chains, got %d and %d", mtls, plaintext)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"strconv"
 
 	opb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	udpa "github.com/cncf/xds/go/udpa/type/v1"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	tracingcfg "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
 	hpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
@@ -28,6 +29,7 @@ import (
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -35,6 +37,7 @@ import (
 	"istio.io/istio/pilot/pkg/extensionproviders"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
 	authz_model "istio.io/istio/pilot/pkg/security/authz/model"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/pkg/xds/requestidextension"
@@ -85,6 +88,13 @@ func configureTracingFromSpec(tracing *model.TracingConfig, opts buildListenerOp
 		}
 		hcm.Tracing = tcfg
 		routerFilterCtx = rfCtx
+	} else if tracing.ExtensionProvider != nil {
+		tcfg, err := configureFromExtensionProviderConfig(opts.push, opts.proxy, tracing.ExtensionProvider)
+		if err != nil {
+			log.Warnf("Not able to configure requested tracing provider %q: %v", tracing.ExtensionProvider.Name, err)
+			return nil, nil
+		}
+		hcm.Tracing = tcfg
 	} else {
 		hcm.Tracing = &hpb.HttpConnectionManager_Tracing{}
 		// TODO: transition to configuring providers from proxy config here?
@@ -94,7 +104,11 @@ func configureTracingFromSpec(tracing *model.TracingConfig, opts buildListenerOp
 	// gracefully fallback to MeshConfig configuration. It will act as an implicit
 	// parent configuration during transition period.
 	configureSampling(hcm.Tracing, tracing.RandomSamplingPercentage)
-	configureCustomTags(hcm.Tracing, tracing.CustomTags, proxyCfg, opts.proxy.Metadata)
+	customTags := tracing.CustomTags
+	if otel := tracing.ExtensionProvider.GetOpenTelemetry(); otel != nil {
+		customTags = withResourceAttributeTags(customTags, otel.ResourceAttributes)
+	}
+	configureCustomTags(hcm.Tracing, customTags, proxyCfg, opts.proxy.Metadata)
 
 	// if there is configured max tag length somewhere, fallback to it.
 	if hcm.GetTracing().GetMaxPathTagLength() == nil && proxyCfg.GetTracing().GetMaxPathTagLength() != 0 {
@@ -238,6 +252,99 @@ func configureFromProviderConfig(pushCtx *model.PushContext, meta *model.NodeMet
 	return tracing, rfCtx, err
 }
 
+// configureFromExtensionProviderConfig configures tracing for the provider types MeshConfig does not support yet.
+func configureFromExtensionProviderConfig(pushCtx *model.PushContext, proxy *model.Proxy,
+	provider *model.ExtensionProvider) (*hpb.HttpConnectionManager_Tracing, error) {
+	if provider.OpenTelemetry == nil {
+		return nil, fmt.Errorf("unsupported tracing provider %q", provider.Name)
+	}
+	if !util.IsIstioVersionGE115(proxy.IstioVersion) {
+		return nil, fmt.Errorf("the OpenTelemetry tracer of provider %q requires 1.15+ proxies", provider.Name)
+	}
+	otel := provider.OpenTelemetry
+	if otel.Protocol == model.OpenTelemetryProtocolHTTP && !util.IsIstioVersionGE121(proxy.IstioVersion) {
+		return nil, fmt.Errorf("the OTLP/HTTP exporter of provider %q requires 1.21+ proxies", provider.Name)
+	}
+	return buildHCMTracing(pushCtx, provider.Name, otel.Service, otel.Port, otel.MaxTagLength, func(cluster string) (*anypb.Any, error) {
+		return openTelemetryConfigGen(cluster, otel, proxy.Metadata)
+	})
+}
+
+// The OpenTelemetry tracer is not available in the vendored Envoy protos yet, so it is configured through a
+// TypedStruct. Envoy only implements it from the 1.15 proxies, and its http_service from the 1.21 proxies: older
+// ones would reject the listener.
+const openTelemetryConfigType = "type.googleapis.com/envoy.config.trace.v3.OpenTelemetryConfig"
+
+func openTelemetryConfigGen(cluster string, otel *model.OpenTelemetryProvider, meta *model.NodeMetadata) (*anypb.Any, error) {
+	_, _, hostname, port := model.ParseSubsetKey(cluster)
+	timeout := otel.TimeoutDuration().String()
+	cfg := map[string]interface{}{
+		"service_name": openTelemetryServiceName(otel, meta),
+	}
+	if otel.Protocol == model.OpenTelemetryProtocolHTTP {
+		cfg["http_service"] = map[string]interface{}{
+			"http_uri": map[string]interface{}{
+				"uri":     fmt.Sprintf("http://%s:%d%s", hostname, port, otel.HTTPPath()),
+				"cluster": cluster,
+				"timeout": timeout,
+			},
+		}
+	} else {
+		cfg["grpc_service"] = map[string]interface{}{
+			"envoy_grpc": map[string]interface{}{
+				"cluster_name": cluster,
+				"authority":    string(hostname),
+			},
+			"timeout": timeout,
+		}
+	}
+	value, err := structpb.NewStruct(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return anypb.New(&udpa.TypedStruct{
+		TypeUrl: openTelemetryConfigType,
+		Value:   value,
+	})
+}
+
+// openTelemetryServiceName returns the service.name resource attribute of the proxy.
+func openTelemetryServiceName(otel *model.OpenTelemetryProvider, meta *model.NodeMetadata) string {
+	if name := otel.ResourceAttributes["service.name"]; name != "" {
+		return name
+	}
+	service := meta.Labels["service.istio.io/canonical-name"]
+	if service == "" {
+		service = "unknown"
+	}
+	namespace := meta.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return service + "." + namespace
+}
+
+// withResourceAttributeTags adds the resource attributes other than service.name to the custom tags. The
+// custom tags of the Telemetry resources take precedence.
+func withResourceAttributeTags(customTags map[string]*telemetrypb.Tracing_CustomTag,
+	attributes map[string]string) map[string]*telemetrypb.Tracing_CustomTag {
+	tags := make(map[string]*telemetrypb.Tracing_CustomTag, len(customTags)+len(attributes))
+	for k, v := range attributes {
+		if k == "service.name" {
+			continue
+		}
+		tags[k] = &telemetrypb.Tracing_CustomTag{
+			Type: &telemetrypb.Tracing_CustomTag_Literal{
+				Literal: &telemetrypb.Tracing_Literal{Value: v},
+			},
+		}
+	}
+	for k, v := range customTags {
+		tags[k] = v
+	}
+	return tags
+}
+
 type typedConfigGenFromClusterFn func(clusterName string) (*anypb.Any, error)
 
 func zipkinConfigGen(cluster string) (*anypb.Any, error) {
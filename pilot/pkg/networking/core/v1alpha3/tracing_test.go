@@ -17,14 +17,23 @@ package v1alpha3
 import (
 	"testing"
 
+	udpa "github.com/cncf/xds/go/udpa/type/v1"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	tracingcfg "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
 	hpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tracing "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -418,3 +427,199 @@ func fakeSkywalkingProvider(expectClusterName, expectProviderName string) *traci
 		ConfigType: &tracingcfg.Tracing_Http_TypedConfig{TypedConfig: fakeSkywalkingAny},
 	}
 }
+
+func TestOpenTelemetryConfigGen(t *testing.T) {
+	cluster := "outbound|4317||otel-collector.istio-system.svc.cluster.local"
+	meta := &model.NodeMetadata{
+		Namespace: "ns",
+		Labels:    map[string]string{"service.istio.io/canonical-name": "productpage"},
+	}
+	cases := []struct {
+		name string
+		otel *model.OpenTelemetryProvider
+		want map[string]interface{}
+	}{
+		{
+			name: "grpc",
+			otel: &model.OpenTelemetryProvider{Service: "otel-collector.istio-system.svc.cluster.local", Port: 4317},
+			want: map[string]interface{}{
+				"service_name": "productpage.ns",
+				"grpc_service": map[string]interface{}{
+					"envoy_grpc": map[string]interface{}{
+						"cluster_name": cluster,
+						"authority":    "otel-collector.istio-system.svc.cluster.local",
+					},
+					"timeout": "5s",
+				},
+			},
+		},
+		{
+			name: "http",
+			otel: &model.OpenTelemetryProvider{
+				Service:            "otel-collector.istio-system.svc.cluster.local",
+				Port:               4317,
+				Protocol:           model.OpenTelemetryProtocolHTTP,
+				Timeout:            "1s",
+				ResourceAttributes: map[string]string{"service.name": "reviews"},
+			},
+			want: map[string]interface{}{
+				"service_name": "reviews",
+				"http_service": map[string]interface{}{
+					"http_uri": map[string]interface{}{
+						"uri":     "http://otel-collector.istio-system.svc.cluster.local:4317/v1/traces",
+						"cluster": cluster,
+						"timeout": "1s",
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			any, err := openTelemetryConfigGen(cluster, tc.otel, meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := &udpa.TypedStruct{}
+			if err := any.UnmarshalTo(ts); err != nil {
+				t.Fatal(err)
+			}
+			if ts.TypeUrl != openTelemetryConfigType {
+				t.Fatalf("expected type %s, got %s", openTelemetryConfigType, ts.TypeUrl)
+			}
+			if diff := cmp.Diff(tc.want, ts.Value.AsMap()); diff != "" {
+				t.Fatalf("unexpected config (-want +got):\n%s", diff)
+			}
+
+			// Envoy converts the TypedStruct to an OpenTelemetryConfig, which must keep the whole config.
+			value, err := protojson.Marshal(ts.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			otelConfig := dynamicpb.NewMessage(openTelemetryConfigDescriptor(t))
+			if err := protojson.Unmarshal(value, otelConfig); err != nil {
+				t.Fatalf("config does not match the OpenTelemetryConfig schema: %v", err)
+			}
+			value, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(otelConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTrip := &structpb.Struct{}
+			if err := protojson.Unmarshal(value, roundTrip); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, roundTrip.AsMap()); diff != "" {
+				t.Fatalf("config changed through the OpenTelemetryConfig schema (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// openTelemetryConfigDescriptor describes the fields of the Envoy OpenTelemetryConfig set by istiod, which the
+// vendored Envoy protos do not include yet.
+func openTelemetryConfigDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("envoy/config/trace/v3/opentelemetry.proto"),
+		Package: proto.String("envoy.config.trace.v3"),
+		Dependency: []string{
+			"envoy/config/core/v3/grpc_service.proto",
+			"envoy/config/core/v3/http_uri.proto",
+		},
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				// envoy.config.core.v3.HttpService
+				Name: proto.String("HttpService"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("http_uri", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".envoy.config.core.v3.HttpUri"),
+				},
+			},
+			{
+				Name: proto.String("OpenTelemetryConfig"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("grpc_service", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".envoy.config.core.v3.GrpcService"),
+					field("service_name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("http_service", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".envoy.config.trace.v3.HttpService"),
+				},
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Messages().ByName("OpenTelemetryConfig")
+}
+
+func TestOpenTelemetryProxyVersion(t *testing.T) {
+	clusterLookupFn = func(push *model.PushContext, service string, port int) (hostname string, cluster string, err error) {
+		return "otel-collector", "outbound|4317||otel-collector", nil
+	}
+	defer func() {
+		clusterLookupFn = extensionproviders.LookupCluster
+	}()
+
+	cases := []struct {
+		name     string
+		protocol string
+		version  *model.IstioVersion
+		wantErr  bool
+	}{
+		{"unknown version", "", nil, false},
+		{"1.14", "", &model.IstioVersion{Major: 1, Minor: 14}, true},
+		{"1.15", "", &model.IstioVersion{Major: 1, Minor: 15}, false},
+		{"http unknown version", model.OpenTelemetryProtocolHTTP, nil, false},
+		{"http 1.20", model.OpenTelemetryProtocolHTTP, &model.IstioVersion{Major: 1, Minor: 20}, true},
+		{"http 1.21", model.OpenTelemetryProtocolHTTP, &model.IstioVersion{Major: 1, Minor: 21}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &model.ExtensionProvider{
+				Name:          "otel",
+				OpenTelemetry: &model.OpenTelemetryProvider{Service: "otel-collector", Port: 4317, Protocol: tc.protocol},
+			}
+			proxy := &model.Proxy{IstioVersion: tc.version, Metadata: &model.NodeMetadata{}}
+			tcfg, err := configureFromExtensionProviderConfig(&model.PushContext{}, proxy, provider)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected the OpenTelemetry tracer to be rejected, got %v", tcfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tcfg.GetProvider().GetTypedConfig().GetTypeUrl(); got != "type.googleapis.com/udpa.type.v1.TypedStruct" {
+				t.Fatalf("expected the OpenTelemetry tracer, got %v", got)
+			}
+		})
+	}
+}
+
+func TestWithResourceAttributeTags(t *testing.T) {
+	literal := func(v string) *tpb.Tracing_CustomTag {
+		return &tpb.Tracing_CustomTag{Type: &tpb.Tracing_CustomTag_Literal{Literal: &tpb.Tracing_Literal{Value: v}}}
+	}
+	got := withResourceAttributeTags(
+		map[string]*tpb.Tracing_CustomTag{"deployment.environment": literal("staging")},
+		map[string]string{"service.name": "reviews", "deployment.environment": "prod", "cloud.region": "us-east1"})
+	want := map[string]*tpb.Tracing_CustomTag{
+		"deployment.environment": literal("staging"),
+		"cloud.region":           literal("us-east1"),
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("unexpected tags (-want +got):\n%s", diff)
+	}
+}
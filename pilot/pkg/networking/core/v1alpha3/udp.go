@@ -0,0 +1,343 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	udp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	istioroute "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/proto"
+	"istio.io/istio/pkg/util/gogo"
+	"istio.io/pkg/log"
+)
+
+// UDPProxyFilterName is the name of the Envoy udp_proxy listener filter.
+const UDPProxyFilterName = "envoy.filters.udp_listener.udp_proxy"
+
+// udpProxy describes a udp_proxy listener, forwarding the datagrams received on an address to a single cluster.
+type udpProxy struct {
+	bind       string
+	port       int
+	statPrefix string
+	cluster    string
+	// weighted holds the destinations of a weighted route. They are served by a dedicated cluster, as udp_proxy
+	// only supports a single cluster.
+	weighted []UDPWeightedDestination
+}
+
+// UDPWeightedDestination is a destination cluster of a weighted UDP route, with its weight.
+type UDPWeightedDestination struct {
+	Cluster string
+	Weight  uint32
+}
+
+const udpWeightedClusterPrefix = "udp|"
+
+// udpWeightedClusterName returns the name of the cluster serving the destinations of a weighted UDP route, identified
+// by its VirtualService and its index in the tcp routes.
+func udpWeightedClusterName(port int, vs config.Meta, route int) string {
+	return fmt.Sprintf("%s%d|%s.%s|%d", udpWeightedClusterPrefix, port, vs.Name, vs.Namespace, route)
+}
+
+// IsUDPWeightedCluster checks if a cluster serves the destinations of a weighted UDP route.
+func IsUDPWeightedCluster(clusterName string) bool {
+	return strings.HasPrefix(clusterName, udpWeightedClusterPrefix)
+}
+
+// UDPWeightedDestinations returns the destination clusters of the weighted UDP routes of the proxy, keyed by the
+// cluster serving each route. It walks all the UDP routes of the proxy, so it should be computed once per push and
+// looked up for each cluster.
+func UDPWeightedDestinations(node *model.Proxy, push *model.PushContext) map[string][]UDPWeightedDestination {
+	out := map[string][]UDPWeightedDestination{}
+	for _, p := range udpProxies(node, push) {
+		if len(p.weighted) > 0 {
+			out[p.cluster] = p.weighted
+		}
+	}
+	return out
+}
+
+// newUDPProxy builds the proxy of a listener routing to the given destinations. Without destinations, the traffic
+// is sent to the service itself.
+func newUDPProxy(node *model.Proxy, push *model.PushContext, bind string, port int, service *model.Service,
+	routes []*networking.RouteDestination, vs config.Meta, routeIndex int) *udpProxy {
+	p := &udpProxy{bind: bind, port: port}
+	destinations := make([]*networking.RouteDestination, 0, len(routes))
+	for _, r := range routes {
+		if r.Weight > 0 || len(routes) == 1 {
+			destinations = append(destinations, r)
+		}
+	}
+	switch len(destinations) {
+	case 0:
+		if service == nil {
+			return nil
+		}
+		servicePort := port
+		if len(service.Ports) == 1 {
+			servicePort = service.Ports[0].Port
+		}
+		p.cluster = model.BuildSubsetKey(model.TrafficDirectionOutbound, "", service.Hostname, servicePort)
+		p.statPrefix = p.cluster
+	case 1:
+		dest := destinations[0].Destination
+		p.cluster = istioroute.GetDestinationCluster(dest, push.ServiceForHostname(node, host.Name(dest.Host)), port)
+		p.statPrefix = p.cluster
+	default:
+		p.cluster = udpWeightedClusterName(port, vs, routeIndex)
+		p.statPrefix = vs.Name + "." + vs.Namespace
+		p.weighted = make([]UDPWeightedDestination, 0, len(destinations))
+		for _, d := range destinations {
+			p.weighted = append(p.weighted, UDPWeightedDestination{
+				Cluster: istioroute.GetDestinationCluster(d.Destination, push.ServiceForHostname(node, host.Name(d.Destination.Host)), port),
+				Weight:  uint32(d.Weight),
+			})
+		}
+	}
+	return p
+}
+
+// buildUDPListeners builds the udp_proxy listeners of the proxy.
+func (configgen *ConfigGeneratorImpl) buildUDPListeners(node *model.Proxy, push *model.PushContext) []*listener.Listener {
+	proxies := udpProxies(node, push)
+	out := make([]*listener.Listener, 0, len(proxies))
+	for _, p := range proxies {
+		out = append(out, buildUDPProxyListener(node, p))
+	}
+	return out
+}
+
+// buildUDPWeightedClusters builds the clusters of the weighted UDP routes of the proxy. Their endpoints are served
+// through EDS, merging the endpoints of the destination clusters with weights relative to the route, so that subsets
+// and the endpoints discovered for each destination are honored.
+func (cb *ClusterBuilder) buildUDPWeightedClusters(node *model.Proxy) []*cluster.Cluster {
+	push := cb.req.Push
+	out := make([]*cluster.Cluster, 0)
+	for _, p := range udpProxies(node, push) {
+		if len(p.weighted) == 0 {
+			continue
+		}
+		c := &cluster.Cluster{
+			Name:                 p.cluster,
+			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+			ConnectTimeout:       gogo.DurationToProtoDuration(push.Mesh.ConnectTimeout),
+			LbPolicy:             cluster.Cluster_ROUND_ROBIN,
+		}
+		maybeApplyEdsConfig(c)
+		out = append(out, c)
+	}
+	return out
+}
+
+func udpProxies(node *model.Proxy, push *model.PushContext) []*udpProxy {
+	if !features.EnableUDPListeners {
+		return nil
+	}
+	switch node.Type {
+	case model.SidecarProxy:
+		return sidecarUDPProxies(node, push)
+	case model.Router:
+		return gatewayUDPProxies(node, push)
+	}
+	return nil
+}
+
+// sidecarUDPProxies returns a proxy for each UDP port of the services of the Sidecar egress listeners, or for the
+// port of the egress listeners declaring a UDP port. Traffic capture does not redirect UDP, so the listeners are only
+// built where applications explicitly send their datagrams: on the bind address of the egress listener, or on
+// localhost if the egress listener or the proxy does not capture traffic. Each listener forwards to the first of its
+// services serving the port.
+func sidecarUDPProxies(node *model.Proxy, push *model.PushContext) []*udpProxy {
+	_, actualLocalHostAddress := getActualWildcardAndLocalHost(node)
+	meshGateway := map[string]bool{constants.IstioMeshGateway: true}
+	out := make([]*udpProxy, 0)
+	// The listeners already built, keyed by address. Egress listeners with a port come first in the Sidecar, so
+	// they take precedence over the catch-all egress listener.
+	built := map[string]bool{}
+	for _, egressListener := range node.SidecarScope.EgressListeners {
+		il := egressListener.IstioListener
+		listenerPort := 0
+		if il.GetPort().GetNumber() != 0 {
+			if protocol.Parse(il.GetPort().GetProtocol()) != protocol.UDP {
+				continue
+			}
+			listenerPort = int(il.GetPort().GetNumber())
+		}
+		bind := il.GetBind()
+		if bind == "" && (il.GetCaptureMode() == networking.CaptureMode_NONE || node.GetInterceptionMode() == model.InterceptionNone) {
+			bind = actualLocalHostAddress
+		}
+		if bind == "" {
+			log.Debugf("sidecarUDPProxies: skipping UDP ports of egress listener %v for node %s, they need a bind "+
+				"address as UDP traffic is not captured", il.GetHosts(), node.ID)
+			continue
+		}
+		virtualServices := egressListener.VirtualServices()
+		for _, service := range egressListener.Services() {
+			for _, port := range udpPorts(service, listenerPort) {
+				address := net.JoinHostPort(bind, strconv.Itoa(port))
+				if built[address] {
+					continue
+				}
+				if !node.CanBindToPort(true, uint32(port)) {
+					log.Debugf("sidecarUDPProxies: skipping privileged port %d for node %s as it is an unprivileged proxy", port, node.ID)
+					continue
+				}
+				routes, vs, index := sidecarUDPRoute(node, service.Hostname, port, virtualServices, meshGateway)
+				if p := newUDPProxy(node, push, bind, port, service, routes, vs, index); p != nil {
+					built[address] = true
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// udpPorts returns the UDP ports of a service. If a listener port is set, it is only returned if the service serves
+// UDP on it, or only has a single UDP port that can be exposed on it.
+func udpPorts(service *model.Service, listenerPort int) []int {
+	out := make([]int, 0, 1)
+	for _, servicePort := range service.Ports {
+		if servicePort.Protocol != protocol.UDP {
+			continue
+		}
+		switch {
+		case listenerPort == 0:
+			out = append(out, servicePort.Port)
+		case servicePort.Port == listenerPort || len(service.Ports) == 1:
+			return []int{listenerPort}
+		}
+	}
+	return out
+}
+
+// sidecarUDPRoute returns the destinations of the first tcp route of the virtual services of a host matching the
+// port and the proxy.
+func sidecarUDPRoute(node *model.Proxy, hostname host.Name, port int, virtualServices []config.Config,
+	gateways map[string]bool) ([]*networking.RouteDestination, config.Meta, int) {
+	for _, cfg := range getConfigsForHost(hostname, virtualServices) {
+		vs := cfg.Spec.(*networking.VirtualService)
+		for i, tcp := range vs.Tcp {
+			if len(tcp.Match) == 0 {
+				return tcp.Route, cfg.Meta, i
+			}
+			for _, match := range tcp.Match {
+				if matchTCP(match, labels.Collection{node.Metadata.Labels}, gateways, port, node.Metadata.Namespace) {
+					return tcp.Route, cfg.Meta, i
+				}
+			}
+		}
+	}
+	return nil, config.Meta{}, 0
+}
+
+// gatewayUDPProxies returns a proxy for each UDP server port of the gateway, routing to the destinations of the
+// first tcp route of the virtual services bound to its servers.
+func gatewayUDPProxies(node *model.Proxy, push *model.PushContext) []*udpProxy {
+	mergedGateway := node.MergedGateway
+	if mergedGateway == nil || len(mergedGateway.MergedUDPServers) == 0 {
+		return nil
+	}
+	actualWildcard, _ := getActualWildcardAndLocalHost(node)
+	out := make([]*udpProxy, 0)
+	for _, port := range mergedGateway.ServerPorts {
+		servers := mergedGateway.MergedUDPServers[port]
+		if servers == nil {
+			continue
+		}
+		if node.Metadata.UnprivilegedPod != "" && port.Number < 1024 {
+			log.Warnf("gatewayUDPProxies: skipping privileged gateway port %d for node %s as it is an unprivileged pod",
+				port.Number, node.ID)
+			continue
+		}
+		bind := actualWildcard
+		if len(port.Bind) > 0 {
+			bind = port.Bind
+		}
+		if p := gatewayUDPProxy(node, push, bind, int(port.Number), servers.Servers); p != nil {
+			out = append(out, p)
+		} else {
+			log.Debugf("gatewayUDPProxies: no virtual service routes UDP port %d of node %s", port.Number, node.ID)
+		}
+	}
+	return out
+}
+
+func gatewayUDPProxy(node *model.Proxy, push *model.PushContext, bind string, port int, servers []*networking.Server) *udpProxy {
+	for _, server := range servers {
+		gatewayName := node.MergedGateway.GatewayNameForServer[server]
+		gatewayServerHosts := make(map[host.Name]bool, len(server.Hosts))
+		for _, hostname := range server.Hosts {
+			gatewayServerHosts[host.Name(hostname)] = true
+		}
+		for _, v := range push.VirtualServicesForGateway(node.ConfigNamespace, gatewayName) {
+			if len(pickMatchingGatewayHosts(gatewayServerHosts, v)) == 0 {
+				continue
+			}
+			for i, tcp := range v.Spec.(*networking.VirtualService).Tcp {
+				if l4MultiMatch(tcp.Match, server, gatewayName) {
+					return newUDPProxy(node, push, bind, port, nil, tcp.Route, v.Meta, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// buildUDPProxyListener builds a listener with a udp_proxy filter.
+func buildUDPProxyListener(node *model.Proxy, p *udpProxy) *listener.Listener {
+	udpProxy := &udp.UdpProxyConfig{
+		StatPrefix:     p.statPrefix,
+		RouteSpecifier: &udp.UdpProxyConfig_Cluster{Cluster: p.cluster},
+	}
+	if idleTimeout, err := time.ParseDuration(node.Metadata.IdleTimeout); err == nil {
+		udpProxy.IdleTimeout = durationpb.New(idleTimeout)
+	}
+	l := &listener.Listener{
+		Name:             getListenerName(p.bind, p.port, istionetworking.TransportProtocolUDP),
+		Address:          util.BuildNetworkAddress(p.bind, uint32(p.port), istionetworking.TransportProtocolUDP),
+		TrafficDirection: core.TrafficDirection_OUTBOUND,
+		ListenerFilters: []*listener.ListenerFilter{{
+			Name:       UDPProxyFilterName,
+			ConfigType: &listener.ListenerFilter_TypedConfig{TypedConfig: util.MessageToAny(udpProxy)},
+		}},
+		UdpListenerConfig: &listener.UdpListenerConfig{
+			DownstreamSocketConfig: &core.UdpSocketConfig{},
+		},
+		EnableReusePort: proto.BoolTrue,
+	}
+	return l
+}
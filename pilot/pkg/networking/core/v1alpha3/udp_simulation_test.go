@@ -0,0 +1,357 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3_test
+
+import (
+	"reflect"
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/simulation"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+// dnsService declares the same port for UDP and TCP, which is only valid with UDP listeners enabled.
+const dnsService = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: dns
+spec:
+  hosts: [dns.example.com]
+  addresses: [1.2.3.4]
+  ports:
+  - number: 53
+    name: udp-dns
+    protocol: UDP
+  - number: 53
+    name: tcp-dns
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+    labels:
+      version: v1
+  - address: 10.0.0.4
+    labels:
+      version: v2
+`
+
+const syslogServices = `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: syslog
+spec:
+  hosts: [syslog.example.com]
+  addresses: [2.3.4.5]
+  ports:
+  - number: 514
+    name: udp-syslog
+    protocol: UDP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: syslog-v2
+spec:
+  hosts: [syslog-v2.example.com]
+  addresses: [2.3.4.6]
+  ports:
+  - number: 1514
+    name: udp-syslog
+    protocol: UDP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.3
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: syslog
+spec:
+  hosts: [syslog.example.com]
+  tcp:
+  - route:
+    - destination:
+        host: syslog.example.com
+      weight: 80
+    - destination:
+        host: syslog-v2.example.com
+      weight: 20
+`
+
+const udpServices = dnsService + syslogServices
+
+// udpSidecar binds the UDP egress listeners explicitly, as UDP traffic is not captured.
+const udpSidecar = `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: default
+spec:
+  egress:
+  - port:
+      number: 53
+      name: udp-dns
+      protocol: UDP
+    bind: 127.0.0.53
+    hosts: [./dns.example.com]
+  - port:
+      number: 514
+      name: udp-syslog
+      protocol: UDP
+    bind: 127.0.0.54
+    hosts: [./syslog.example.com]
+  - port:
+      number: 5514
+      name: udp-syslog-captured
+      protocol: UDP
+    hosts: [./syslog.example.com]
+  - hosts: ["*/*"]
+`
+
+func enableUDPListeners(t *testing.T) {
+	old := features.EnableUDPListeners
+	features.EnableUDPListeners = true
+	t.Cleanup(func() { features.EnableUDPListeners = old })
+}
+
+func TestUDPSidecar(t *testing.T) {
+	enableUDPListeners(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: udpServices + udpSidecar})
+	proxy := s.SetupProxy(nil)
+	sim := simulation.NewSimulation(t, s, proxy)
+	sim.RunExpectations([]simulation.Expect{
+		{
+			Name: "udp to bind address",
+			Call: simulation.Call{Address: "127.0.0.53", Port: 53, Protocol: simulation.UDP},
+			Result: simulation.Result{
+				ListenerMatched: "udp_127.0.0.53_53",
+				ClusterMatched:  "outbound|53||dns.example.com",
+			},
+		},
+		{
+			Name:   "udp to service is not captured",
+			Call:   simulation.Call{Address: "1.2.3.4", Port: 53, Protocol: simulation.UDP},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		},
+		{
+			Name:   "tcp to service with the same port",
+			Call:   simulation.Call{Address: "1.2.3.4", Port: 53, Protocol: simulation.TCP},
+			Result: simulation.Result{ClusterMatched: "outbound|53||dns.example.com"},
+		},
+		{
+			Name: "weighted udp route",
+			Call: simulation.Call{Address: "127.0.0.54", Port: 514, Protocol: simulation.UDP},
+			Result: simulation.Result{
+				ListenerMatched: "udp_127.0.0.54_514",
+				ClusterMatched:  "udp|514|syslog.default|0",
+			},
+		},
+		{
+			Name:   "egress listener without bind address",
+			Call:   simulation.Call{Address: "0.0.0.0", Port: 5514, Protocol: simulation.UDP},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		},
+		{
+			Name:   "udp to unknown port",
+			Call:   simulation.Call{Address: "127.0.0.53", Port: 5353, Protocol: simulation.UDP},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		},
+	})
+	xdstest.ValidateListeners(t, sim.Listeners)
+	xdstest.ValidateClusters(t, sim.Clusters)
+
+	clusters := xdstest.ExtractClusters(sim.Clusters)
+	if c := clusters["udp|514|syslog.default|0"]; c.GetType() != cluster.Cluster_EDS {
+		t.Fatalf("expected an EDS cluster for the weighted route, got %v", c)
+	}
+	for _, name := range []string{"outbound|514||syslog.example.com", "outbound|1514||syslog-v2.example.com"} {
+		if clusters[name] == nil {
+			t.Fatalf("expected cluster %v for the UDP port", name)
+		}
+	}
+	expectEndpointWeights(t, s, proxy, "udp|514|syslog.default|0", map[string]uint32{"10.0.0.2": 80000, "10.0.0.3": 20000})
+}
+
+// expectEndpointWeights checks the weights of the endpoints of a cluster, keyed by address.
+func expectEndpointWeights(t *testing.T, s *xds.FakeDiscoveryServer, proxy *model.Proxy, clusterName string,
+	want map[string]uint32) {
+	t.Helper()
+	got := map[string]uint32{}
+	for _, cla := range s.Endpoints(proxy) {
+		if cla.ClusterName != clusterName {
+			continue
+		}
+		for _, llbEndpoints := range cla.Endpoints {
+			for _, ep := range llbEndpoints.LbEndpoints {
+				got[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep.GetLoadBalancingWeight().GetValue()
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected endpoint weights %v for %v, got %v", want, clusterName, got)
+	}
+}
+
+func TestUDPSidecarWithoutCapture(t *testing.T) {
+	enableUDPListeners(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: udpServices})
+	proxy := s.SetupProxy(&model.Proxy{Metadata: &model.NodeMetadata{InterceptionMode: model.InterceptionNone}})
+	sim := simulation.NewSimulation(t, s, proxy)
+	sim.RunExpectations([]simulation.Expect{
+		{
+			Name: "udp service port",
+			Call: simulation.Call{Address: "127.0.0.1", Port: 53, Protocol: simulation.UDP},
+			Result: simulation.Result{
+				ListenerMatched: "udp_127.0.0.1_53",
+				ClusterMatched:  "outbound|53||dns.example.com",
+			},
+		},
+		{
+			Name: "weighted udp route",
+			Call: simulation.Call{Address: "127.0.0.1", Port: 514, Protocol: simulation.UDP},
+			Result: simulation.Result{
+				ListenerMatched: "udp_127.0.0.1_514",
+				ClusterMatched:  "udp|514|syslog.default|0",
+			},
+		},
+		{
+			Name: "destination of the weighted udp route",
+			Call: simulation.Call{Address: "127.0.0.1", Port: 1514, Protocol: simulation.UDP},
+			Result: simulation.Result{
+				ListenerMatched: "udp_127.0.0.1_1514",
+				ClusterMatched:  "outbound|1514||syslog-v2.example.com",
+			},
+		},
+	})
+	xdstest.ValidateListeners(t, sim.Listeners)
+	xdstest.ValidateClusters(t, sim.Clusters)
+}
+
+func TestUDPDisabled(t *testing.T) {
+	runSimulationTest(t, nil, xds.FakeOptions{}, simulationTest{
+		config: syslogServices + udpSidecar,
+		calls: []simulation.Expect{{
+			Name:   "udp to bind address",
+			Call:   simulation.Call{Address: "127.0.0.54", Port: 514, Protocol: simulation.UDP},
+			Result: simulation.Result{Error: simulation.ErrNoListener},
+		}},
+	})
+}
+
+func TestUDPGateway(t *testing.T) {
+	enableUDPListeners(t)
+	runGatewayTest(t, simulationTest{
+		config: createGateway("gateway", "", `
+port:
+  number: 5353
+  name: udp-dns
+  protocol: UDP
+hosts:
+- "*"
+`) + udpServices + `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: dns
+spec:
+  hosts: [dns.example.com]
+  gateways: [gateway]
+  tcp:
+  - match:
+    - port: 5353
+    route:
+    - destination:
+        host: dns.example.com
+        port:
+          number: 53
+`,
+		calls: []simulation.Expect{
+			{
+				Name: "udp",
+				Call: simulation.Call{Port: 5353, Protocol: simulation.UDP, CallMode: simulation.CallModeGateway},
+				Result: simulation.Result{
+					ListenerMatched: "udp_0.0.0.0_5353",
+					ClusterMatched:  "outbound|53||dns.example.com",
+				},
+			},
+			{
+				Name:   "tcp",
+				Call:   simulation.Call{Port: 5353, Protocol: simulation.TCP, CallMode: simulation.CallModeGateway},
+				Result: simulation.Result{Error: simulation.ErrNoListener},
+			},
+		},
+	})
+}
+
+func TestUDPWeightedSubsets(t *testing.T) {
+	enableUDPListeners(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: udpServices + udpSidecar + `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: dns
+spec:
+  host: dns.example.com
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: dns
+spec:
+  hosts: [dns.example.com]
+  tcp:
+  - route:
+    - destination:
+        host: dns.example.com
+        subset: v1
+      weight: 75
+    - destination:
+        host: dns.example.com
+        subset: v2
+      weight: 25
+`})
+	proxy := s.SetupProxy(nil)
+	sim := simulation.NewSimulation(t, s, proxy)
+	sim.RunExpectations([]simulation.Expect{{
+		Name: "weighted udp route with subsets",
+		Call: simulation.Call{Address: "127.0.0.53", Port: 53, Protocol: simulation.UDP},
+		Result: simulation.Result{
+			ListenerMatched: "udp_127.0.0.53_53",
+			ClusterMatched:  "udp|53|dns.default|0",
+		},
+	}})
+	expectEndpointWeights(t, s, proxy, "udp|53|dns.default|0", map[string]uint32{"10.0.0.1": 75000, "10.0.0.4": 25000})
+}
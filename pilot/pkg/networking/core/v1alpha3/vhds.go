@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"sort"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	golangproto "google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/proto"
+)
+
+// buildOnDemandRouteConfig builds an outbound route for a sidecar that discovers its virtual hosts on demand.
+// The route has no virtual hosts, not even the catch all one, so that Envoy requests the virtual host of
+// every host it sees through VHDS.
+func buildOnDemandRouteConfig(node *model.Proxy, routeName string, efw *model.EnvoyFilterWrapper) *discovery.Resource {
+	out := &route.RouteConfiguration{
+		Name:             routeName,
+		VirtualHosts:     []*route.VirtualHost{},
+		ValidateClusters: proto.BoolFalse,
+		Vhds: &route.Vhds{
+			ConfigSource: &core.ConfigSource{
+				ConfigSourceSpecifier: &core.ConfigSource_Ads{
+					Ads: &core.AggregatedConfigSource{},
+				},
+				ResourceApiVersion: core.ApiVersion_V3,
+			},
+		},
+	}
+	out = envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_SIDECAR_OUTBOUND, node, efw, out)
+	return &discovery.Resource{
+		Name:     out.Name,
+		Resource: util.MessageToAny(out),
+	}
+}
+
+// BuildOnDemandVirtualHosts produces the virtual hosts requested by a sidecar through VHDS. Envoy names them
+// <route name>/<host>, after the route and the host header of the request. Each of them is answered with a
+// virtual host named the same way that only serves the requested host, so that virtual hosts of the same
+// route never share a domain. Hosts that do not match any service get the catch all virtual host.
+func (configgen *ConfigGeneratorImpl) BuildOnDemandVirtualHosts(node *model.Proxy, req *model.PushRequest,
+	names []string) []*discovery.Resource {
+	hostsByRoute := map[string][]string{}
+	for _, name := range names {
+		// Unix domain socket routes contain slashes, but host headers never do.
+		i := strings.LastIndex(name, "/")
+		if i <= 0 || i == len(name)-1 {
+			continue
+		}
+		hostsByRoute[name[:i]] = append(hostsByRoute[name[:i]], name[i+1:])
+	}
+	routeNames := make([]string, 0, len(hostsByRoute))
+	for routeName := range hostsByRoute {
+		routeNames = append(routeNames, routeName)
+	}
+	sort.Strings(routeNames)
+
+	efw := req.Push.EnvoyFilters(node)
+	efKeys := efw.Keys()
+	out := make([]*discovery.Resource, 0, len(names))
+	for _, routeName := range routeNames {
+		virtualHosts, ok := configgen.buildSidecarOutboundVirtualHostsForRoute(node, req.Push, routeName, efw, efKeys)
+		if !ok {
+			continue
+		}
+		for _, hostname := range hostsByRoute[routeName] {
+			vh := matchVirtualHost(virtualHosts, hostname)
+			if vh == nil {
+				vh = node.CatchAllVirtualHost
+			}
+			vh = golangproto.Clone(vh).(*route.VirtualHost)
+			vh.Name = routeName + "/" + hostname
+			vh.Domains = []string{hostname}
+			out = append(out, &discovery.Resource{
+				Name:     vh.Name,
+				Resource: util.MessageToAny(vh),
+			})
+		}
+	}
+	return out
+}
+
+// buildSidecarOutboundVirtualHostsForRoute builds the virtual hosts that the outbound route with the given name
+// would have if they were not discovered on demand.
+func (configgen *ConfigGeneratorImpl) buildSidecarOutboundVirtualHostsForRoute(node *model.Proxy, push *model.PushContext,
+	routeName string, efw *model.EnvoyFilterWrapper, efKeys []string) ([]*route.VirtualHost, bool) {
+	listenerPort, useSniffing, ok := parseSidecarRouteName(routeName)
+	if !ok {
+		return nil, false
+	}
+	virtualHosts, _, _ := BuildSidecarOutboundVirtualHosts(node, push, routeName, listenerPort, efKeys, &model.DisabledCache{})
+	if useSniffing {
+		virtualHosts = getVirtualHostsForSniffedServicePort(virtualHosts, routeName)
+	}
+	util.SortVirtualHosts(virtualHosts)
+	if !useSniffing {
+		virtualHosts = append(virtualHosts, node.CatchAllVirtualHost)
+	}
+	out := &route.RouteConfiguration{
+		Name:             routeName,
+		VirtualHosts:     virtualHosts,
+		ValidateClusters: proto.BoolFalse,
+	}
+	out = envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_SIDECAR_OUTBOUND, node, efw, out)
+	return out.VirtualHosts, true
+}
+
+// matchVirtualHost returns the virtual host that Envoy would select for the given host header. Exact domains
+// take precedence over the longest suffix wildcard, then the longest prefix wildcard, and finally "*".
+func matchVirtualHost(virtualHosts []*route.VirtualHost, hostname string) *route.VirtualHost {
+	hostname = strings.ToLower(hostname)
+	var suffixMatch, prefixMatch, defaultMatch *route.VirtualHost
+	suffixLen, prefixLen := 0, 0
+	for _, vh := range virtualHosts {
+		for _, domain := range vh.Domains {
+			domain = strings.ToLower(domain)
+			switch {
+			case domain == hostname:
+				return vh
+			case domain == "*":
+				if defaultMatch == nil {
+					defaultMatch = vh
+				}
+			case strings.HasPrefix(domain, "*"):
+				if len(domain) > suffixLen && len(hostname) >= len(domain) && strings.HasSuffix(hostname, domain[1:]) {
+					suffixMatch, suffixLen = vh, len(domain)
+				}
+			case strings.HasSuffix(domain, "*"):
+				if len(domain) > prefixLen && len(hostname) >= len(domain) && strings.HasPrefix(hostname, domain[:len(domain)-1]) {
+					prefixMatch, prefixLen = vh, len(domain)
+				}
+			}
+		}
+	}
+	switch {
+	case suffixMatch != nil:
+		return suffixMatch
+	case prefixMatch != nil:
+		return prefixMatch
+	default:
+		return defaultMatch
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+func setupOnDemandProxy(t *testing.T) (*ConfigGenTest, *model.Proxy) {
+	defaultValue := features.EnableOnDemandXds
+	features.EnableOnDemandXds = true
+	t.Cleanup(func() { features.EnableOnDemandXds = defaultValue })
+
+	cg := NewConfigGenTest(t, TestOptions{Services: deltaTestServices()})
+	proxy := cg.SetupProxy(&model.Proxy{DeltaXds: true, Metadata: &model.NodeMetadata{OnDemandXds: true}})
+	if !proxy.OnDemandXds() {
+		t.Fatalf("expected proxy to use on demand xds")
+	}
+	return cg, proxy
+}
+
+func TestOnDemandRouteConfig(t *testing.T) {
+	cg, proxy := setupOnDemandProxy(t)
+	routes, _ := cg.ConfigGen.BuildHTTPRoutes(proxy, &model.PushRequest{Push: cg.PushContext()}, []string{"8080"})
+	if len(routes) != 1 {
+		t.Fatalf("expected 1 route, got %d", len(routes))
+	}
+	rc := &route.RouteConfiguration{}
+	if err := routes[0].Resource.UnmarshalTo(rc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.VirtualHosts) != 0 {
+		t.Fatalf("expected no virtual hosts, got %v", rc.VirtualHosts)
+	}
+	if rc.Vhds.GetConfigSource().GetAds() == nil {
+		t.Fatalf("expected virtual hosts to be discovered over ADS, got %v", rc.Vhds)
+	}
+}
+
+func TestBuildOnDemandVirtualHosts(t *testing.T) {
+	cg, proxy := setupOnDemandProxy(t)
+	resources := cg.ConfigGen.BuildOnDemandVirtualHosts(proxy, &model.PushRequest{Push: cg.PushContext()},
+		[]string{"8080/a.com", "8080/a.com:8080", "8080/unknown.com", "9090/b.com", "8080/", "bogus"})
+
+	expected := map[string]string{
+		"8080/a.com":       "outbound|8080||a.com",
+		"8080/a.com:8080":  "outbound|8080||a.com",
+		"8080/unknown.com": util.PassthroughCluster,
+		"9090/b.com":       "outbound|9090||b.com",
+	}
+	got := map[string]string{}
+	for _, r := range resources {
+		vh := &route.VirtualHost{}
+		if err := r.Resource.UnmarshalTo(vh); err != nil {
+			t.Fatal(err)
+		}
+		if vh.Name != r.Name {
+			t.Fatalf("expected virtual host to be named %s, got %s", r.Name, vh.Name)
+		}
+		if len(vh.Domains) != 1 || vh.Domains[0] != r.Name[strings.LastIndex(r.Name, "/")+1:] {
+			t.Fatalf("expected virtual host %s to only serve its host, got %v", r.Name, vh.Domains)
+		}
+		got[r.Name] = vh.Routes[0].GetRoute().GetCluster()
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected virtual hosts %v, got %v", expected, got)
+	}
+}
+
+func TestBuildOnDemandClusters(t *testing.T) {
+	cg, proxy := setupOnDemandProxy(t)
+	resources, _, _, usedDelta := cg.ConfigGen.BuildDeltaClusters(proxy, &model.PushRequest{Push: cg.PushContext(), Full: true},
+		&model.WatchedResource{ResourceNames: []string{"outbound|8080||a.com", "outbound|8080||unknown.com"}})
+	if usedDelta {
+		t.Fatalf("expected on demand clusters to be generated in full")
+	}
+	got := sets.NewSet(extractNames(resources)...)
+	// The clusters of the TCP services are always sent, their listeners do not discover them on demand.
+	for _, name := range []string{"outbound|8080||a.com", "outbound|3306||c.com", util.BlackHoleCluster, util.PassthroughCluster} {
+		if !got.Contains(name) {
+			t.Errorf("expected cluster %s, got %v", name, got.SortedList())
+		}
+	}
+	for _, name := range []string{"outbound|9090||b.com", "outbound|8080||unknown.com"} {
+		if got.Contains(name) {
+			t.Errorf("unexpected cluster %s", name)
+		}
+	}
+}
+
+func TestBuildOnDemandClustersOfTCPListeners(t *testing.T) {
+	defaultValue := features.EnableOnDemandXds
+	features.EnableOnDemandXds = true
+	t.Cleanup(func() { features.EnableOnDemandXds = defaultValue })
+
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: db
+spec:
+  hosts: [db.example.com]
+  addresses: [240.0.0.1]
+  ports:
+  - number: 3306
+    name: tcp
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 1.1.1.1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: mixed
+spec:
+  hosts: [mixed.example.com]
+  addresses: [240.0.0.2]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  - number: 9000
+    name: tcp
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 1.1.1.2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: legacy
+spec:
+  hosts: [legacy.example.com]
+  ports:
+  - number: 8080
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: db
+spec:
+  hosts: [db.example.com]
+  tcp:
+  - route:
+    - destination:
+        host: db.example.com
+      weight: 90
+    - destination:
+        host: legacy.example.com
+        port:
+          number: 8080
+      weight: 10
+`})
+	proxy := cg.SetupProxy(&model.Proxy{DeltaXds: true, Metadata: &model.NodeMetadata{OnDemandXds: true}})
+	resources, _, _, _ := cg.ConfigGen.BuildDeltaClusters(proxy, &model.PushRequest{Push: cg.PushContext(), Full: true},
+		&model.WatchedResource{})
+	got := sets.NewSet(extractNames(resources)...)
+
+	// Every cluster the TCP proxies of the outbound listeners forward to is sent.
+	tcpClusters := sets.NewSet()
+	for _, l := range cg.Listeners(proxy) {
+		if l.GetTrafficDirection() != core.TrafficDirection_OUTBOUND {
+			continue
+		}
+		for _, fc := range l.GetFilterChains() {
+			tcp := xdstest.ExtractTCPProxy(t, fc)
+			if tcp == nil {
+				continue
+			}
+			if c := tcp.GetCluster(); c != "" {
+				tcpClusters.Insert(c)
+			}
+			for _, c := range tcp.GetWeightedClusters().GetClusters() {
+				tcpClusters.Insert(c.GetName())
+			}
+		}
+	}
+	for _, name := range []string{"outbound|3306||db.example.com", "outbound|9000||mixed.example.com", "outbound|8080||legacy.example.com"} {
+		if !tcpClusters.Contains(name) {
+			t.Fatalf("expected a TCP proxy forwarding to %s, got %v", name, tcpClusters.SortedList())
+		}
+	}
+	if missing := tcpClusters.Difference(got); !missing.Empty() {
+		t.Fatalf("expected the clusters of the TCP proxies to be sent, missing %v", missing.SortedList())
+	}
+	// The clusters of the HTTP ports are still discovered on demand.
+	if got.Contains("outbound|80||mixed.example.com") {
+		t.Fatalf("unexpected cluster outbound|80||mixed.example.com")
+	}
+}
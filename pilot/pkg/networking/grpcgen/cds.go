@@ -16,17 +16,20 @@ package grpcgen
 
 import (
 	"fmt"
+	"strings"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	corexds "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/certprovider"
 	"istio.io/istio/pkg/config/host"
 )
 
@@ -176,11 +179,17 @@ func (b *clusterBuilder) applyDestinationRule(defaultCluster *cluster.Cluster) (
 	}
 
 	// resolve policy from context
-	destinationRule := corexds.CastDestinationRule(b.node.SidecarScope.DestinationRule(b.svc.Hostname))
+	cfg := b.node.SidecarScope.DestinationRule(b.svc.Hostname)
+	destinationRule := corexds.CastDestinationRule(cfg)
 	trafficPolicy := corexds.MergeTrafficPolicy(nil, destinationRule.GetTrafficPolicy(), b.port)
+	var annotations map[string]string
+	if cfg != nil {
+		annotations = cfg.Annotations
+	}
+	certProvider := certprovider.FromAnnotations(annotations, defaultCertProvider)
 
 	// setup default cluster
-	b.applyTrafficPolicy(defaultCluster, trafficPolicy)
+	b.applyTrafficPolicy(defaultCluster, trafficPolicy, certProvider)
 
 	// subset clusters
 	if len(destinationRule.GetSubsets()) > 0 {
@@ -192,7 +201,7 @@ func (b *clusterBuilder) applyDestinationRule(defaultCluster *cluster.Cluster) (
 			}
 			c := edsCluster(subsetKey)
 			trafficPolicy := corexds.MergeTrafficPolicy(trafficPolicy, subset.TrafficPolicy, b.port)
-			b.applyTrafficPolicy(c, trafficPolicy)
+			b.applyTrafficPolicy(c, trafficPolicy, certProvider)
 			subsetClusters = append(subsetClusters, c)
 		}
 	}
@@ -201,41 +210,82 @@ func (b *clusterBuilder) applyDestinationRule(defaultCluster *cluster.Cluster) (
 }
 
 // applyTrafficPolicy mutates the give cluster (if not-nil) so that the given merged traffic policy applies.
-func (b *clusterBuilder) applyTrafficPolicy(c *cluster.Cluster, trafficPolicy *networking.TrafficPolicy) {
+// certProvider is the certificate provider of the gRPC bootstrap used by SIMPLE and MUTUAL TLS settings.
+func (b *clusterBuilder) applyTrafficPolicy(c *cluster.Cluster, trafficPolicy *networking.TrafficPolicy, certProvider string) {
 	// cluster can be nil if it wasn't requested
 	if c == nil {
 		return
 	}
-	b.applyTLS(c, trafficPolicy)
+	b.applyTLS(c, trafficPolicy, certProvider)
 	b.applyLoadBalancing(c, trafficPolicy)
+	// gRPC clients that do not support outlier detection ignore it.
+	corexds.ApplyOutlierDetection(c, trafficPolicy.GetOutlierDetection())
 	// TODO status or log when unsupported features are included
 }
 
+// leastRequestMinVersion is the first gRPC release supporting the LEAST_REQUEST policy in xDS clusters; older
+// clients reject clusters using it.
+var leastRequestMinVersion = &model.IstioVersion{Major: 1, Minor: 58, Patch: -1}
+
 func (b *clusterBuilder) applyLoadBalancing(c *cluster.Cluster, policy *networking.TrafficPolicy) {
 	switch policy.GetLoadBalancer().GetSimple() {
 	case networking.LoadBalancerSettings_ROUND_ROBIN, networking.LoadBalancerSettings_UNSPECIFIED:
 	// ok
+	case networking.LoadBalancerSettings_LEAST_REQUEST, networking.LoadBalancerSettings_LEAST_CONN:
+		if grpcVersion(b.node).Compare(leastRequestMinVersion) < 0 {
+			log.Warnf("cannot apply LbPolicy %s to %s, its gRPC version does not support it", policy.LoadBalancer.GetSimple(), b.node.ID)
+			break
+		}
+		c.LbPolicy = cluster.Cluster_LEAST_REQUEST
+		c.LbConfig = &cluster.Cluster_LeastRequestLbConfig_{
+			LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+				ChoiceCount: &wrappers.UInt32Value{Value: 2},
+			},
+		}
 	default:
 		log.Warnf("cannot apply LbPolicy %s to %s", policy.LoadBalancer.GetSimple(), b.node.ID)
 	}
 	corexds.ApplyRingHashLoadBalancer(c, policy.GetLoadBalancer())
 }
 
-func (b *clusterBuilder) applyTLS(c *cluster.Cluster, policy *networking.TrafficPolicy) {
+// grpcVersion returns the version of the gRPC library of the client, as reported in its xDS node. Clients that
+// do not report it, or that are not a gRPC library, are assumed to be up to date.
+func grpcVersion(node *model.Proxy) *model.IstioVersion {
+	// gRPC libraries report a name like "gRPC Go" or "gRPC Java"; other user agents version something else.
+	if !strings.HasPrefix(node.XdsNode.GetUserAgentName(), "gRPC") {
+		return model.MaxIstioVersion
+	}
+	return model.ParseIstioVersion(node.XdsNode.GetUserAgentVersion())
+}
+
+func (b *clusterBuilder) applyTLS(c *cluster.Cluster, policy *networking.TrafficPolicy, certProvider string) {
 	// TODO for now, we leave mTLS *off* by default:
 	// 1. We don't know if the client uses xds.NewClientCredentials; these settings will be ignored if not
 	// 2. We cannot reach servers in PERMISSIVE mode; gRPC doesn't allow us to override the alpn to one of Istio's
 	// 3. Once we support gRPC servers, we have no good way to detect if a server is implemented with xds.NewGrpcServer and will actually support our config
 	// For these reasons, support only explicit tls configuration.
-	switch policy.GetTls().GetMode() {
+	var tlsCtx *tls.UpstreamTlsContext
+	settings := policy.GetTls()
+	switch settings.GetMode() {
 	case networking.ClientTLSSettings_DISABLE:
 		// nothing to do
-	case networking.ClientTLSSettings_SIMPLE:
-		// TODO support this
-	case networking.ClientTLSSettings_MUTUAL:
-		// TODO support this
+	case networking.ClientTLSSettings_SIMPLE, networking.ClientTLSSettings_MUTUAL:
+		// gRPC reads certificates from the certificate providers of its bootstrap only. The provider is selected
+		// with the certprovider annotation of the DestinationRule, and defaults to the one serving the workload
+		// certificates fetched by the agent over SDS, which validates servers issued by the mesh CA only. The
+		// credentialName is left to the sidecars sharing the DestinationRule.
+		if settings.GetCaCertificates() != "" || settings.GetClientCertificate() != "" || settings.GetPrivateKey() != "" {
+			log.Warnf("ignoring certificate files in TLS settings of %s for %s, gRPC only supports the certificate "+
+				"provider set with %s", c.Name, b.node.ID, certprovider.Annotation)
+		}
+		tlsCtx = &tls.UpstreamTlsContext{
+			CommonTlsContext: buildProviderTLSContext(certProvider, settings.GetSubjectAltNames(),
+				settings.GetMode() == networking.ClientTLSSettings_MUTUAL),
+		}
 	case networking.ClientTLSSettings_ISTIO_MUTUAL:
-		tlsCtx := buildUpstreamTLSContext(b.push.ServiceAccounts[b.hostname][b.portNum])
+		tlsCtx = buildUpstreamTLSContext(b.push.ServiceAccounts[b.hostname][b.portNum])
+	}
+	if tlsCtx != nil {
 		c.TransportSocket = &core.TransportSocket{
 			Name:       transportSocketName,
 			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(tlsCtx)},
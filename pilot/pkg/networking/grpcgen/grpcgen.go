@@ -67,22 +67,27 @@ func (g *GrpcConfigGenerator) Generate(proxy *model.Proxy, push *model.PushConte
 	return nil, model.DefaultXdsLogDetails, nil
 }
 
+// defaultCertProvider is the certificate provider serving the workload certificates in the gRPC bootstrap.
+const defaultCertProvider = "default"
+
 // buildCommonTLSContext creates a TLS context that assumes 'default' name, and credentials/tls/certprovider/pemfile
 // (see grpc/xds/internal/client/xds.go securityConfigFromCluster).
 func buildCommonTLSContext(sans []string) *tls.CommonTlsContext {
+	return buildProviderTLSContext(defaultCertProvider, sans, true)
+}
+
+// buildProviderTLSContext creates a TLS context validating the peer with the root certificate of the given
+// certificate provider instance, and presenting its certificate if withIdentity is set.
+func buildProviderTLSContext(provider string, sans []string, withIdentity bool) *tls.CommonTlsContext {
 	var sanMatch []*matcher.StringMatcher
 	if len(sans) > 0 {
 		sanMatch = util.StringToExactMatch(sans)
 	}
-	return &tls.CommonTlsContext{
-		TlsCertificateCertificateProviderInstance: &tls.CommonTlsContext_CertificateProviderInstance{
-			InstanceName:    "default",
-			CertificateName: "default",
-		},
+	ctx := &tls.CommonTlsContext{
 		ValidationContextType: &tls.CommonTlsContext_CombinedValidationContext{
 			CombinedValidationContext: &tls.CommonTlsContext_CombinedCertificateValidationContext{
 				ValidationContextCertificateProviderInstance: &tls.CommonTlsContext_CertificateProviderInstance{
-					InstanceName:    "default",
+					InstanceName:    provider,
 					CertificateName: "ROOTCA",
 				},
 				DefaultValidationContext: &tls.CertificateValidationContext{
@@ -91,4 +96,11 @@ func buildCommonTLSContext(sans []string) *tls.CommonTlsContext {
 			},
 		},
 	}
+	if withIdentity {
+		ctx.TlsCertificateCertificateProviderInstance = &tls.CommonTlsContext_CertificateProviderInstance{
+			InstanceName:    provider,
+			CertificateName: "default",
+		}
+	}
+	return ctx
 }
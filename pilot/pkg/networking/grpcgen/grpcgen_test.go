@@ -25,24 +25,33 @@ import (
 	"testing"
 	"time"
 
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/resolver"
 	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
 
 	// To install the xds resolvers and balancers.
 	grpcxdsresolver "google.golang.org/grpc/xds"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/grpcgen"
 	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/istio-agent/grpcxds"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/proto"
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/pkg/log"
 )
 
@@ -224,6 +233,383 @@ func TestGRPC(t *testing.T) {
 	})
 }
 
+// echoService is a Kubernetes Service selecting the echo servers of newConfigGenTest on the given port.
+func echoService(port int) string {
+	return fmt.Sprintf(`
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app: echo-app
+  name: echo-app
+  namespace: default
+spec:
+  clusterIP: 1.2.3.4
+  selector:
+    app: echo
+  ports:
+  - name: grpc
+    targetPort: grpc
+    port: %d
+`, port)
+}
+
+const echoSubsets = `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo-dr
+  namespace: default
+spec:
+  host: echo-app.default.svc.cluster.local
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+`
+
+func TestGRPCTrafficPolicy(t *testing.T) {
+	tt := newConfigGenTest(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7072),
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo-dr
+  namespace: default
+spec:
+  host: echo-app.default.svc.cluster.local
+  trafficPolicy:
+    loadBalancer:
+      consistentHash:
+        httpHeaderName: x-user
+    outlierDetection:
+      consecutive5xxErrors: 5
+      interval: 10s
+      baseEjectionTime: 30s
+    tls:
+      mode: MUTUAL
+      # the secret of the sidecars sharing the DestinationRule, gRPC clients use their default certificate provider
+      credentialName: echo-client
+---
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: default
+spec:
+  mtls:
+    mode: STRICT
+`,
+	}, echoCfg{version: "v1", tls: true}, echoCfg{version: "v2", tls: true})
+
+	// the client must accept the cluster, use the certificate provider for MUTUAL, and always pick the same
+	// backend for a given hash key
+	retry.UntilSuccessOrFail(tt.T, func() error {
+		cw := tt.dialEcho("xds:///echo-app.default.svc.cluster.local:7072")
+		defer cw.Close()
+		for _, user := range []string{"alice", "bob"} {
+			versions := map[string]int{}
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-user", user)
+			for i := 0; i < 20; i++ {
+				res, err := cw.Echo(ctx, &proto.EchoRequest{Message: "needle"})
+				if err != nil {
+					return err
+				}
+				versions[res.Version]++
+			}
+			if len(versions) != 1 {
+				return fmt.Errorf("expected requests of %s to reach a single backend, got %v", user, versions)
+			}
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(0))
+}
+
+func TestGRPCLeastRequest(t *testing.T) {
+	tt := newConfigGenTest(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7073),
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo-dr
+  namespace: default
+spec:
+  host: echo-app.default.svc.cluster.local
+  trafficPolicy:
+    loadBalancer:
+      simple: LEAST_REQUEST
+`,
+	}, echoCfg{version: "v1"}, echoCfg{version: "v2"})
+
+	// clients too old for least request get round robin instead of a cluster they would reject
+	retry.UntilSuccessOrFail(tt.T, func() error {
+		cw := tt.dialEcho("xds:///echo-app.default.svc.cluster.local:7073")
+		defer cw.Close()
+		versions := map[string]int{}
+		for i := 0; i < 20; i++ {
+			res, err := cw.Echo(context.Background(), &proto.EchoRequest{Message: "needle"})
+			if err != nil {
+				return err
+			}
+			versions[res.Version]++
+		}
+		if len(versions) != 2 {
+			return fmt.Errorf("expected requests to reach both backends, got %v", versions)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(0))
+
+	clusterName := "outbound|7073||echo-app.default.svc.cluster.local"
+	for _, c := range []struct {
+		name, version string
+		want          cluster.Cluster_LbPolicy
+	}{
+		{"gRPC Go", "1.44.0", cluster.Cluster_ROUND_ROBIN},
+		{"gRPC Go", "1.58.0", cluster.Cluster_LEAST_REQUEST},
+		{"gRPC Java", "1.57.2", cluster.Cluster_ROUND_ROBIN},
+		// the version of other user agents is not a gRPC version
+		{"envoy", "1.21.0", cluster.Cluster_LEAST_REQUEST},
+		{"", "", cluster.Cluster_LEAST_REQUEST},
+	} {
+		t.Run(c.name+" "+c.version, func(t *testing.T) {
+			proxy := tt.ds.SetupProxy(&model.Proxy{
+				ConfigNamespace: "default",
+				XdsNode: &core.Node{
+					UserAgentName:        c.name,
+					UserAgentVersionType: &core.Node_UserAgentVersion{UserAgentVersion: c.version},
+				},
+			})
+			resources := (&grpcgen.GrpcConfigGenerator{}).BuildClusters(proxy, tt.ds.PushContext(), []string{clusterName})
+			if len(resources) != 1 {
+				t.Fatalf("expected cluster %s, got %v", clusterName, resources)
+			}
+			got := &cluster.Cluster{}
+			if err := resources[0].Resource.UnmarshalTo(got); err != nil {
+				t.Fatal(err)
+			}
+			if got.LbPolicy != c.want {
+				t.Fatalf("expected lb policy %v, got %v", c.want, got.LbPolicy)
+			}
+		})
+	}
+}
+
+func TestGRPCCertificateProvider(t *testing.T) {
+	ds := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7077),
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo-dr
+  namespace: default
+  annotations:
+    # a provider of the gRPC bootstrap holding the root certificate of a CA outside of the mesh
+    networking.istio.io/grpcCertificateProvider: external-ca
+spec:
+  host: echo-app.default.svc.cluster.local
+  trafficPolicy:
+    tls:
+      mode: SIMPLE
+      subjectAltNames:
+      - echo.example.com
+  subsets:
+  - name: v2
+    labels:
+      version: v2
+    trafficPolicy:
+      tls:
+        mode: MUTUAL
+        # the secret of the sidecars sharing the DestinationRule, not used by gRPC clients
+        credentialName: echo-client
+        subjectAltNames:
+        - echo.example.com
+`,
+	})
+	proxy := ds.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+
+	for _, c := range []struct {
+		cluster      string
+		withIdentity bool
+	}{
+		// SIMPLE validates the server with the root certificate of the provider only
+		{"outbound|7077||echo-app.default.svc.cluster.local", false},
+		// MUTUAL also presents the certificate of the provider
+		{"outbound|7077|v2|echo-app.default.svc.cluster.local", true},
+	} {
+		t.Run(c.cluster, func(t *testing.T) {
+			resources := (&grpcgen.GrpcConfigGenerator{}).BuildClusters(proxy, ds.PushContext(), []string{c.cluster})
+			if len(resources) != 1 {
+				t.Fatalf("expected cluster %s, got %v", c.cluster, resources)
+			}
+			got := &cluster.Cluster{}
+			if err := resources[0].Resource.UnmarshalTo(got); err != nil {
+				t.Fatal(err)
+			}
+			tlsCtx := &tls.UpstreamTlsContext{}
+			if err := got.GetTransportSocket().GetTypedConfig().UnmarshalTo(tlsCtx); err != nil {
+				t.Fatal(err)
+			}
+			validation := tlsCtx.GetCommonTlsContext().GetCombinedValidationContext()
+			if name := validation.GetValidationContextCertificateProviderInstance().GetInstanceName(); name != "external-ca" {
+				t.Fatalf("expected the server to be validated by external-ca, got %q", name)
+			}
+			if sans := validation.GetDefaultValidationContext().GetMatchSubjectAltNames(); len(sans) != 1 ||
+				sans[0].GetExact() != "echo.example.com" {
+				t.Fatalf("expected subject alt name echo.example.com, got %v", sans)
+			}
+			identity := tlsCtx.GetCommonTlsContext().GetTlsCertificateCertificateProviderInstance()
+			if (identity != nil) != c.withIdentity {
+				t.Fatalf("expected client certificate %v, got %v", c.withIdentity, identity)
+			}
+			if identity != nil && identity.GetInstanceName() != "external-ca" {
+				t.Fatalf("expected the client certificate of external-ca, got %q", identity.GetInstanceName())
+			}
+		})
+	}
+}
+
+func TestGRPCHeaderRouting(t *testing.T) {
+	tt := newConfigGenTest(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7074),
+		ConfigString: echoSubsets + `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: echo-vs
+  namespace: default
+spec:
+  hosts:
+  - echo-app.default.svc.cluster.local
+  http:
+  - match:
+    - headers:
+        x-version:
+          exact: v2
+    route:
+    - destination:
+        host: echo-app.default.svc.cluster.local
+        subset: v2
+    retries:
+      attempts: 3
+      retryOn: unavailable,cancelled
+  - route:
+    - destination:
+        host: echo-app.default.svc.cluster.local
+        subset: v1
+    retries:
+      attempts: 3
+      retryOn: unavailable
+`,
+	}, echoCfg{version: "v1"}, echoCfg{version: "v2"})
+
+	retry.UntilSuccessOrFail(tt.T, func() error {
+		cw := tt.dialEcho("xds:///echo-app.default.svc.cluster.local:7074")
+		defer cw.Close()
+		for header, want := range map[string]string{"v2": "v2", "v3": "v1", "": "v1"} {
+			ctx := context.Background()
+			if header != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-version", header)
+			}
+			for i := 0; i < 10; i++ {
+				res, err := cw.Echo(ctx, &proto.EchoRequest{Message: "needle"})
+				if err != nil {
+					return err
+				}
+				if res.Version != want {
+					return fmt.Errorf("expected x-version %q to reach %s, got %s", header, want, res.Version)
+				}
+			}
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(0))
+}
+
+func TestGRPCFaultAbort(t *testing.T) {
+	tt := newConfigGenTest(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7075),
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: echo-abort
+  namespace: default
+spec:
+  hosts:
+  - echo-app.default.svc.cluster.local
+  http:
+  - fault:
+      abort:
+        percentage:
+          value: 100
+        grpcStatus: UNAVAILABLE
+    route:
+    - destination:
+        host: echo-app.default.svc.cluster.local
+`,
+	}, echoCfg{version: "v1"})
+
+	retry.UntilSuccessOrFail(tt.T, func() error {
+		cw := tt.dialEcho("xds:///echo-app.default.svc.cluster.local:7075")
+		defer cw.Close()
+		_, err := cw.Echo(context.Background(), &proto.EchoRequest{Message: "needle"})
+		if code := status.Code(err); code != codes.Unavailable {
+			return fmt.Errorf("expected the request to be aborted with %v, got %v", codes.Unavailable, err)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(0))
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	tt := newConfigGenTest(t, xds.FakeOptions{
+		KubernetesObjectString: echoService(7076),
+		ConfigString: `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: default
+spec:
+  mtls:
+    mode: PERMISSIVE
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: deny-header
+  namespace: default
+spec:
+  action: DENY
+  rules:
+  - when:
+    - key: request.headers[x-deny]
+      values: ["true"]
+`,
+	}, echoCfg{version: "v1"})
+
+	// PERMISSIVE servers fall back to plaintext, as gRPC cannot pick a filter chain by transport protocol, and
+	// enforce the authorization policies of the workload
+	retry.UntilSuccessOrFail(tt.T, func() error {
+		cw := tt.dialEcho("xds:///echo-app.default.svc.cluster.local:7076")
+		defer cw.Close()
+		if _, err := cw.Echo(context.Background(), &proto.EchoRequest{Message: "needle"}); err != nil {
+			return err
+		}
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-deny", "true")
+		_, err := cw.Echo(ctx, &proto.EchoRequest{Message: "needle"})
+		if code := status.Code(err); code != codes.PermissionDenied {
+			return fmt.Errorf("expected the request to be denied with %v, got %v", codes.PermissionDenied, err)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(0))
+}
+
 type testLBClientConn struct {
 	balancer.ClientConn
 }
@@ -22,16 +22,22 @@ import (
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbachttp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/security/authn"
 	"istio.io/istio/pilot/pkg/security/authn/factory"
+	"istio.io/istio/pilot/pkg/security/authz/builder"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
 	"istio.io/istio/pilot/pkg/util/sets"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pkg/config/labels"
@@ -64,6 +70,7 @@ func buildInboundListeners(node *model.Proxy, push *model.PushContext, names []s
 	}
 	var out model.Resources
 	policyApplier := factory.NewPolicyApplier(push, node.Metadata.Namespace, labels.Collection{node.Metadata.Labels})
+	rbacFilters := buildRBAC(node, push)
 	serviceInstancesByPort := map[uint32]*model.ServiceInstance{}
 	for _, si := range node.ServiceInstances {
 		serviceInstancesByPort[si.Endpoint.EndpointPort] = si
@@ -97,7 +104,7 @@ func buildInboundListeners(node *model.Proxy, push *model.PushContext, names []s
 					},
 				},
 			}},
-			FilterChains: buildInboundFilterChains(node, push, si, policyApplier),
+			FilterChains: buildInboundFilterChains(node, push, si, policyApplier, rbacFilters),
 			// the following must not be set or the client will NACK
 			ListenerFilters: nil,
 			UseOriginalDst:  nil,
@@ -110,8 +117,14 @@ func buildInboundListeners(node *model.Proxy, push *model.PushContext, names []s
 	return out
 }
 
-// nolint: unparam
-func buildInboundFilterChains(node *model.Proxy, push *model.PushContext, si *model.ServiceInstance, applier authn.PolicyApplier) []*listener.FilterChain {
+// buildInboundFilterChains builds the filter chain of a proxyless gRPC server port. PERMISSIVE mTLS is not supported:
+// it needs a mTLS and a plaintext filter chain selected by the transport protocol of the connection, but gRPC
+// servers do not inspect it. The only transport_protocol they accept is "raw_buffer", and chains differing by
+// their transport protocol alone are dropped or rejected as overlapping (see
+// https://github.com/grpc/proposal/blob/master/A36-xds-for-servers.md). PERMISSIVE ports are served as plaintext,
+// like DISABLE ones, so the authorization rules on principals or namespaces never match on them.
+func buildInboundFilterChains(node *model.Proxy, push *model.PushContext, si *model.ServiceInstance,
+	applier authn.PolicyApplier, rbacFilters []*hcm.HttpFilter) []*listener.FilterChain {
 	mode := applier.GetMutualTLSModeForPort(si.Endpoint.EndpointPort)
 
 	var tlsContext *tls.DownstreamTlsContext
@@ -130,25 +143,126 @@ func buildInboundFilterChains(node *model.Proxy, push *model.PushContext, si *mo
 		mode = model.MTLSDisable
 	}
 	if mode == model.MTLSPermissive {
-		// TODO gRPC's filter chain match is super limted - only effective transport_protocol match is "raw_buffer"
-		// see https://github.com/grpc/proposal/blob/master/A36-xds-for-servers.md for detail
-		log.Warnf("cannot support PERMISSIVE mode for %s on %s; defaulting to DISABLE", si.Service.Hostname, node.ID)
+		log.Warnf("PERMISSIVE mode is not supported by proxyless gRPC servers, serving %s on %s as plaintext (DISABLE)",
+			si.Service.Hostname, node.ID)
 		mode = model.MTLSDisable
 	}
 
 	var out []*listener.FilterChain
 	switch mode {
 	case model.MTLSDisable:
-		out = append(out, buildInboundFilterChain("plaintext", nil))
+		if matchesPeerIdentity(rbacFilters) {
+			msg := fmt.Sprintf("%s is served as plaintext on port %d, authorization rules on principals or "+
+				"namespaces never match", si.Service.Hostname, si.Endpoint.EndpointPort)
+			log.Warnf("%s: %s", node.ID, msg)
+			push.AddMetric(model.ProxyStatusPlaintextPeerAuthz, node.ID+"/"+strconv.Itoa(int(si.Endpoint.EndpointPort)),
+				node.ID, msg)
+		}
+		out = append(out, buildInboundFilterChain("plaintext", nil, rbacFilters))
 	case model.MTLSStrict:
-		out = append(out, buildInboundFilterChain("mtls", tlsContext))
-		// TODO permissive builts both plaintext and mtls; when tlsContext is present add a match for protocol
+		out = append(out, buildInboundFilterChain("mtls", tlsContext, rbacFilters))
 	}
 
 	return out
 }
 
-func buildInboundFilterChain(nameSuffix string, tlsContext *tls.DownstreamTlsContext) *listener.FilterChain {
+// matchesPeerIdentity checks if the RBAC filters have rules on the authenticated identity of the peer, which the
+// principals and namespaces of the authorization policies are matched against.
+func matchesPeerIdentity(rbacFilters []*hcm.HttpFilter) bool {
+	for _, filter := range rbacFilters {
+		rbac := &rbachttp.RBAC{}
+		if err := filter.GetTypedConfig().UnmarshalTo(rbac); err != nil {
+			continue
+		}
+		for _, policy := range rbac.GetRules().GetPolicies() {
+			for _, principal := range policy.Principals {
+				if principalMatchesPeerIdentity(principal) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func principalMatchesPeerIdentity(principal *rbacpb.Principal) bool {
+	switch id := principal.Identifier.(type) {
+	case *rbacpb.Principal_Authenticated_:
+		return true
+	case *rbacpb.Principal_AndIds:
+		for _, p := range id.AndIds.Ids {
+			if principalMatchesPeerIdentity(p) {
+				return true
+			}
+		}
+	case *rbacpb.Principal_OrIds:
+		for _, p := range id.OrIds.Ids {
+			if principalMatchesPeerIdentity(p) {
+				return true
+			}
+		}
+	case *rbacpb.Principal_NotId:
+		return principalMatchesPeerIdentity(id.NotId)
+	}
+	return false
+}
+
+// buildRBAC builds the RBAC filters enforcing the authorization policies that select the proxy, in the order
+// sidecars evaluate them: CUSTOM, DENY and then ALLOW. gRPC only implements the RBAC filter, so the requests
+// matched by CUSTOM policies, which sidecars send to the external authorizer, are denied instead.
+func buildRBAC(node *model.Proxy, push *model.PushContext) []*hcm.HttpFilter {
+	if push.AuthzPolicies == nil {
+		return nil
+	}
+	tdBundle := trustdomain.NewBundle(push.Mesh.TrustDomain, push.Mesh.TrustDomainAliases)
+	in := &plugin.InputParams{Node: node, Push: push}
+
+	var out []*hcm.HttpFilter
+	for _, custom := range []bool{true, false} {
+		option := builder.Option{
+			IsCustomBuilder: custom,
+			Logger:          &builder.AuthzLogger{},
+		}
+		if b := builder.New(tdBundle, in, option); b != nil {
+			for _, filter := range b.BuildHTTP() {
+				if filter.Name != wellknown.HTTPRoleBasedAccessControl {
+					// the ext_authz filter of CUSTOM policies, which gRPC would reject
+					continue
+				}
+				if custom {
+					filter = enforceShadowRules(filter)
+				}
+				out = append(out, filter)
+			}
+		}
+		option.Logger.Report(in)
+	}
+	return out
+}
+
+// enforceShadowRules turns the shadow rules of a CUSTOM RBAC filter, which only evaluate the requests for the
+// ext_authz filter, into enforced DENY rules.
+func enforceShadowRules(filter *hcm.HttpFilter) *hcm.HttpFilter {
+	rbac := &rbachttp.RBAC{}
+	if err := filter.GetTypedConfig().UnmarshalTo(rbac); err != nil {
+		log.Errorf("failed to unmarshal the RBAC filter of CUSTOM policies: %v", err)
+		return filter
+	}
+	if rbac.Rules != nil {
+		// the deny all config of an invalid provider
+		return filter
+	}
+	rbac.Rules, rbac.ShadowRules, rbac.ShadowRulesStatPrefix = rbac.ShadowRules, nil, ""
+	return &hcm.HttpFilter{
+		Name:       wellknown.HTTPRoleBasedAccessControl,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(rbac)},
+	}
+}
+
+func buildInboundFilterChain(nameSuffix string, tlsContext *tls.DownstreamTlsContext, rbacFilters []*hcm.HttpFilter) *listener.FilterChain {
+	httpFilters := make([]*hcm.HttpFilter, 0, len(rbacFilters)+1)
+	httpFilters = append(httpFilters, rbacFilters...)
+	httpFilters = append(httpFilters, xdsfilters.Router)
 	out := &listener.FilterChain{
 		Name:             "inbound-" + nameSuffix,
 		FilterChainMatch: nil,
@@ -171,7 +285,7 @@ func buildInboundFilterChain(nameSuffix string, tlsContext *tls.DownstreamTlsCon
 							}},
 						},
 					},
-					HttpFilters: []*hcm.HttpFilter{xdsfilters.Router},
+					HttpFilters: httpFilters,
 				}),
 			},
 		}},
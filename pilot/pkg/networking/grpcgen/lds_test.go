@@ -19,9 +19,15 @@ import (
 	"sort"
 	"testing"
 
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	rbachttp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
 
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/istio-agent/grpcxds"
 )
@@ -158,3 +164,47 @@ func TestTryFindFQDN(t *testing.T) {
 		})
 	}
 }
+
+func TestEnforceShadowRules(t *testing.T) {
+	shadow := &rbacpb.RBAC{Action: rbacpb.RBAC_DENY, Policies: map[string]*rbacpb.Policy{"ns-custom-0": {}}}
+	filter := enforceShadowRules(&hcm.HttpFilter{
+		Name: wellknown.HTTPRoleBasedAccessControl,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&rbachttp.RBAC{
+			ShadowRules:           shadow,
+			ShadowRulesStatPrefix: "istio_ext_authz_",
+		})},
+	})
+	got := &rbachttp.RBAC{}
+	if err := filter.GetTypedConfig().UnmarshalTo(got); err != nil {
+		t.Fatal(err)
+	}
+	want := &rbachttp.RBAC{Rules: shadow}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMatchesPeerIdentity(t *testing.T) {
+	rbacFilter := func(principal *rbacpb.Principal) *hcm.HttpFilter {
+		return &hcm.HttpFilter{
+			Name: wellknown.HTTPRoleBasedAccessControl,
+			ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&rbachttp.RBAC{
+				Rules: &rbacpb.RBAC{Policies: map[string]*rbacpb.Policy{"ns-policy-0": {
+					Principals: []*rbacpb.Principal{principal},
+				}}},
+			})},
+		}
+	}
+	header := &rbacpb.Principal{Identifier: &rbacpb.Principal_Header{}}
+	authenticated := &rbacpb.Principal{Identifier: &rbacpb.Principal_Authenticated_{}}
+
+	if matchesPeerIdentity([]*hcm.HttpFilter{rbacFilter(header)}) {
+		t.Fatalf("expected rules on headers not to match the peer identity")
+	}
+	nested := &rbacpb.Principal{Identifier: &rbacpb.Principal_AndIds{AndIds: &rbacpb.Principal_Set{
+		Ids: []*rbacpb.Principal{header, {Identifier: &rbacpb.Principal_NotId{NotId: authenticated}}},
+	}}}
+	if !matchesPeerIdentity([]*hcm.HttpFilter{rbacFilter(header), rbacFilter(nested)}) {
+		t.Fatalf("expected nested rules on principals to match the peer identity")
+	}
+}
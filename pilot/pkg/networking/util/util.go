@@ -177,8 +177,12 @@ func ConvertAddressToCidr(addr string) *core.CidrRange {
 	return cidr
 }
 
-// BuildAddress returns a SocketAddress with the given ip and port or uds.
+// BuildAddress returns a SocketAddress with the given ip and port or uds, or the address of an
+// internal listener.
 func BuildAddress(bind string, port uint32) *core.Address {
+	if strings.HasPrefix(bind, model.EnvoyInternalAddressPrefix) {
+		return BuildInternalAddress(strings.TrimPrefix(bind, model.EnvoyInternalAddressPrefix))
+	}
 	address := BuildNetworkAddress(bind, port, istionetworking.TransportProtocolTCP)
 	if address != nil {
 		return address
@@ -193,6 +197,19 @@ func BuildAddress(bind string, port uint32) *core.Address {
 	}
 }
 
+// BuildInternalAddress returns the address of the Envoy internal listener with the given name.
+func BuildInternalAddress(name string) *core.Address {
+	return &core.Address{
+		Address: &core.Address_EnvoyInternalAddress{
+			EnvoyInternalAddress: &core.EnvoyInternalAddress{
+				AddressNameSpecifier: &core.EnvoyInternalAddress_ServerListenerName{
+					ServerListenerName: name,
+				},
+			},
+		},
+	}
+}
+
 func BuildNetworkAddress(bind string, port uint32, transport istionetworking.TransportProtocol) *core.Address {
 	if port == 0 {
 		return nil
@@ -276,6 +293,31 @@ func IsIstioVersionGE112(version *model.IstioVersion) bool {
 		version.Compare(&model.IstioVersion{Major: 1, Minor: 12, Patch: -1}) >= 0
 }
 
+// IsIstioVersionGE114 checks whether the given Istio version is greater than or equals 1.14.
+func IsIstioVersionGE114(version *model.IstioVersion) bool {
+	return version == nil ||
+		version.Compare(&model.IstioVersion{Major: 1, Minor: 14, Patch: -1}) >= 0
+}
+
+// IsIstioVersionGE115 checks whether the given Istio version is greater than or equals 1.15.
+func IsIstioVersionGE115(version *model.IstioVersion) bool {
+	return version == nil ||
+		version.Compare(&model.IstioVersion{Major: 1, Minor: 15, Patch: -1}) >= 0
+}
+
+// IsIstioVersionGE121 checks whether the given Istio version is greater than or equals 1.21.
+func IsIstioVersionGE121(version *model.IstioVersion) bool {
+	return version == nil ||
+		version.Compare(&model.IstioVersion{Major: 1, Minor: 21, Patch: -1}) >= 0
+}
+
+// IsInternalListenerEnabled checks whether internal listeners, and the endpoints addressing them, can be sent to
+// the proxy. Envoy only accepts them if the proxy loaded the internal listener bootstrap extension, which the agent
+// advertises in the node metadata.
+func IsInternalListenerEnabled(node *model.Proxy) bool {
+	return features.EnableInternalListeners && node.Metadata != nil && bool(node.Metadata.InternalListeners)
+}
+
 func IsProtocolSniffingEnabledForPort(port *model.Port) bool {
 	return features.EnableProtocolSniffingForOutbound && port.Protocol.IsUnsupported()
 }
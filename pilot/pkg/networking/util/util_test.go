@@ -925,6 +925,20 @@ func TestBuildAddress(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "internal listener",
+			addr: "envoy://authz",
+			port: 8080,
+			expected: &core.Address{
+				Address: &core.Address_EnvoyInternalAddress{
+					EnvoyInternalAddress: &core.EnvoyInternalAddress{
+						AddressNameSpecifier: &core.EnvoyInternalAddress_ServerListenerName{
+							ServerListenerName: "authz",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range testCases {
@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pushtrace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlptrace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	istiolog "istio.io/pkg/log"
+)
+
+var log = istiolog.RegisterScope("pushtrace", "push tracing", 0)
+
+const (
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+)
+
+// RunExporter periodically exports the recorded spans to the OTLP/HTTP traces endpoint at the given URL,
+// such as http://otel-collector:4318/v1/traces, until the stop channel is closed.
+func RunExporter(url string, stop <-chan struct{}) {
+	defaultTracer.runExporter(&httpExporter{url: url, client: &http.Client{Timeout: exportTimeout}}, stop)
+}
+
+type exporter interface {
+	export(spans *otlptrace.ResourceSpans) error
+}
+
+func (t *Tracer) runExporter(e exporter, stop <-chan struct{}) {
+	t.setExporting(true)
+	defer t.setExporting(false)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.export(e)
+		case <-stop:
+			t.export(e)
+			return
+		}
+	}
+}
+
+func (t *Tracer) export(e exporter) {
+	spans := t.drain()
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.export(toResourceSpans(spans)); err != nil {
+		log.Warnf("failed to export %d push trace spans: %v", len(spans), err)
+	}
+}
+
+type httpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func (e *httpExporter) export(spans *otlptrace.ResourceSpans) error {
+	body, err := proto.Marshal(&collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*otlptrace.ResourceSpans{spans},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
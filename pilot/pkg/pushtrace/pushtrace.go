@@ -0,0 +1,296 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pushtrace records the causal trace of a config push: from the event that triggered it, through
+// debouncing and the PushContext computation, down to the generation of each xDS response and its ACK.
+// Traces are kept in memory for debugging and can be exported to an OpenTelemetry collector.
+package pushtrace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	otlpcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpresource "go.opentelemetry.io/proto/otlp/resource/v1"
+	otlptrace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+const (
+	// maxTraces is the number of most recent traces kept in memory.
+	maxTraces = 100
+	// maxSpansPerTrace bounds the spans of a single trace, as a push may reach thousands of proxies.
+	maxSpansPerTrace = 1000
+	// maxPendingSpans bounds the spans waiting to be exported, in case the collector is unavailable.
+	maxPendingSpans = 10000
+	// MaxTracesPerPush bounds the traces carried by a PushRequest, as debouncing may merge many events.
+	MaxTracesPerPush = 16
+
+	serviceName = "istiod"
+	scopeName   = "istio.io/istio/pilot/pkg/pushtrace"
+)
+
+// TraceID identifies a push trace.
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a push trace.
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies the root span of a push trace, which the other spans of the trace are children of.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// Span is a recorded step of a push.
+type Span struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Parent     SpanID
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+}
+
+type trace struct {
+	start   time.Time
+	spans   []Span
+	dropped int
+}
+
+// Tracer keeps the most recent push traces in memory.
+type Tracer struct {
+	mu     sync.Mutex
+	traces map[TraceID]*trace
+	// order holds the trace IDs from the oldest to the most recent.
+	order []TraceID
+	// pending holds the spans that were not exported yet. It is only populated while exporting.
+	pending   []Span
+	exporting bool
+}
+
+// NewTracer creates a Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{traces: map[TraceID]*trace{}}
+}
+
+var defaultTracer = NewTracer()
+
+// Start starts a new push trace for an event, and returns the context of its root span to be carried by the
+// PushRequest. Nothing is returned if push tracing is disabled.
+func Start(name string, attrs map[string]string) []SpanContext {
+	if !features.EnablePushTracing {
+		return nil
+	}
+	return []SpanContext{defaultTracer.Start(name, attrs)}
+}
+
+// Merge returns the traces of two merged PushRequests, keeping the oldest ones up to MaxTracesPerPush.
+// The inputs are not modified, but one of them may be returned.
+func Merge(a, b []SpanContext) []SpanContext {
+	if len(b) == 0 || len(a) >= MaxTracesPerPush {
+		return a
+	}
+	if len(a) == 0 && len(b) <= MaxTracesPerPush {
+		return b
+	}
+	n := len(a) + len(b)
+	if n > MaxTracesPerPush {
+		n = MaxTracesPerPush
+	}
+	out := make([]SpanContext, 0, n)
+	out = append(out, a...)
+	return append(out, b[:n-len(a)]...)
+}
+
+// Record records a step of a push in each of the given traces, from start until now. If start is zero,
+// the step is considered to have begun with the trace.
+func Record(traces []SpanContext, name string, start time.Time, attrs map[string]string) {
+	if len(traces) == 0 {
+		return
+	}
+	defaultTracer.Record(traces, name, start, time.Now(), attrs)
+}
+
+// Export returns the traces kept in memory as OpenTelemetry spans. If ids are given, only those traces are returned.
+func Export(ids ...string) *otlptrace.ResourceSpans {
+	return defaultTracer.Export(ids...)
+}
+
+// Start starts a new trace, recording its root span.
+func (t *Tracer) Start(name string, attrs map[string]string) SpanContext {
+	sc := SpanContext{}
+	_, _ = rand.Read(sc.TraceID[:])
+	_, _ = rand.Read(sc.SpanID[:])
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.order) >= maxTraces {
+		delete(t.traces, t.order[0])
+		t.order = t.order[1:]
+	}
+	t.traces[sc.TraceID] = &trace{start: now}
+	t.order = append(t.order, sc.TraceID)
+	t.addLocked(Span{
+		TraceID:    sc.TraceID,
+		SpanID:     sc.SpanID,
+		Name:       name,
+		Start:      now,
+		End:        now,
+		Attributes: attrs,
+	})
+	return sc
+}
+
+// Record records a span in each of the given traces, as a child of their root span.
+func (t *Tracer) Record(traces []SpanContext, name string, start, end time.Time, attrs map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sc := range traces {
+		tr, f := t.traces[sc.TraceID]
+		if !f {
+			// The trace was evicted, there is nothing to attach the span to.
+			continue
+		}
+		s := Span{
+			TraceID:    sc.TraceID,
+			Parent:     sc.SpanID,
+			Name:       name,
+			Start:      start,
+			End:        end,
+			Attributes: attrs,
+		}
+		if s.Start.IsZero() {
+			s.Start = tr.start
+		}
+		_, _ = rand.Read(s.SpanID[:])
+		t.addLocked(s)
+	}
+}
+
+func (t *Tracer) addLocked(s Span) {
+	tr := t.traces[s.TraceID]
+	if len(tr.spans) >= maxSpansPerTrace {
+		tr.dropped++
+		return
+	}
+	tr.spans = append(tr.spans, s)
+	if t.exporting && len(t.pending) < maxPendingSpans {
+		t.pending = append(t.pending, s)
+	}
+}
+
+// Traces returns the spans of the traces kept in memory, keyed by trace ID.
+func (t *Tracer) Traces() map[string][]Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string][]Span, len(t.traces))
+	for id, tr := range t.traces {
+		out[id.String()] = append([]Span(nil), tr.spans...)
+	}
+	return out
+}
+
+// Export returns the traces kept in memory as OpenTelemetry spans. If ids are given, only those traces are returned.
+func (t *Tracer) Export(ids ...string) *otlptrace.ResourceSpans {
+	traces := t.Traces()
+	keys := ids
+	if len(keys) == 0 {
+		for id := range traces {
+			keys = append(keys, id)
+		}
+		sort.Strings(keys)
+	}
+	spans := make([]Span, 0)
+	for _, id := range keys {
+		spans = append(spans, traces[id]...)
+	}
+	return toResourceSpans(spans)
+}
+
+// drain returns the spans recorded since the last call.
+func (t *Tracer) drain() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := t.pending
+	t.pending = nil
+	return spans
+}
+
+func (t *Tracer) setExporting(exporting bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exporting = exporting
+	if !exporting {
+		t.pending = nil
+	}
+}
+
+func toResourceSpans(spans []Span) *otlptrace.ResourceSpans {
+	out := make([]*otlptrace.Span, 0, len(spans))
+	for _, s := range spans {
+		span := &otlptrace.Span{
+			TraceId:           append([]byte(nil), s.TraceID[:]...),
+			SpanId:            append([]byte(nil), s.SpanID[:]...),
+			Name:              s.Name,
+			Kind:              otlptrace.Span_SPAN_KIND_INTERNAL,
+			StartTimeUnixNano: uint64(s.Start.UnixNano()),
+			EndTimeUnixNano:   uint64(s.End.UnixNano()),
+			Attributes:        toAttributes(s.Attributes),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanId = append([]byte(nil), s.Parent[:]...)
+		}
+		out = append(out, span)
+	}
+	return &otlptrace.ResourceSpans{
+		Resource: &otlpresource.Resource{
+			Attributes: toAttributes(map[string]string{"service.name": serviceName}),
+		},
+		InstrumentationLibrarySpans: []*otlptrace.InstrumentationLibrarySpans{{
+			InstrumentationLibrary: &otlpcommon.InstrumentationLibrary{Name: scopeName},
+			Spans:                  out,
+		}},
+	}
+}
+
+func toAttributes(attrs map[string]string) []*otlpcommon.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*otlpcommon.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, &otlpcommon.KeyValue{
+			Key:   k,
+			Value: &otlpcommon.AnyValue{Value: &otlpcommon.AnyValue_StringValue{StringValue: attrs[k]}},
+		})
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pushtrace
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlptrace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+func TestStartDisabled(t *testing.T) {
+	defaultValue := features.EnablePushTracing
+	features.EnablePushTracing = false
+	t.Cleanup(func() { features.EnablePushTracing = defaultValue })
+
+	if traces := Start("config.event", nil); traces != nil {
+		t.Fatalf("expected no trace when disabled, got %v", traces)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	tracer := NewTracer()
+	a := tracer.Start("config.event", map[string]string{"kind": "VirtualService"})
+	b := tracer.Start("service.event", nil)
+	start := time.Now()
+	tracer.Record([]SpanContext{a, b}, "debounce", time.Time{}, start, nil)
+	tracer.Record([]SpanContext{a}, "xds.push", start, start.Add(time.Millisecond), map[string]string{"type": "CDS"})
+
+	traces := tracer.Traces()
+	spans := traces[a.TraceID.String()]
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %v", spans)
+	}
+	if spans[0].Parent != (SpanID{}) || spans[0].SpanID != a.SpanID {
+		t.Fatalf("expected the first span to be the root span, got %v", spans[0])
+	}
+	for _, s := range spans[1:] {
+		if s.Parent != a.SpanID || s.TraceID != a.TraceID {
+			t.Fatalf("expected span %s to be a child of the root span, got %v", s.Name, s)
+		}
+	}
+	if !spans[1].Start.Equal(spans[0].Start) {
+		t.Fatalf("expected the debounce span to start with the trace")
+	}
+	if len(traces[b.TraceID.String()]) != 2 {
+		t.Fatalf("expected 2 spans, got %v", traces[b.TraceID.String()])
+	}
+}
+
+func TestEviction(t *testing.T) {
+	tracer := NewTracer()
+	first := tracer.Start("config.event", nil)
+	for i := 0; i < maxTraces; i++ {
+		tracer.Start("config.event", nil)
+	}
+	traces := tracer.Traces()
+	if len(traces) != maxTraces {
+		t.Fatalf("expected %d traces, got %d", maxTraces, len(traces))
+	}
+	if _, f := traces[first.TraceID.String()]; f {
+		t.Fatalf("expected the oldest trace to be evicted")
+	}
+	// Spans of evicted traces are dropped.
+	tracer.Record([]SpanContext{first}, "debounce", time.Time{}, time.Now(), nil)
+	if _, f := tracer.Traces()[first.TraceID.String()]; f {
+		t.Fatalf("expected the evicted trace to not be recreated")
+	}
+
+	sc := tracer.Start("config.event", nil)
+	for i := 0; i < maxSpansPerTrace; i++ {
+		tracer.Record([]SpanContext{sc}, "xds.push", time.Time{}, time.Now(), nil)
+	}
+	if got := len(tracer.Traces()[sc.TraceID.String()]); got != maxSpansPerTrace {
+		t.Fatalf("expected %d spans, got %d", maxSpansPerTrace, got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tracer := NewTracer()
+	traces := func(n int) []SpanContext {
+		out := make([]SpanContext, 0, n)
+		for i := 0; i < n; i++ {
+			out = append(out, tracer.Start("config.event", nil))
+		}
+		return out
+	}
+	a, b := traces(2), traces(MaxTracesPerPush)
+	if got := Merge(nil, a); len(got) != 2 || got[0] != a[0] {
+		t.Fatalf("expected the non empty traces, got %v", got)
+	}
+	if got := Merge(a, nil); len(got) != 2 || got[0] != a[0] {
+		t.Fatalf("expected the non empty traces, got %v", got)
+	}
+	got := Merge(a, b)
+	if len(got) != MaxTracesPerPush {
+		t.Fatalf("expected %d traces, got %d", MaxTracesPerPush, len(got))
+	}
+	if got[0] != a[0] || got[1] != a[1] || got[2] != b[0] || got[MaxTracesPerPush-1] != b[MaxTracesPerPush-3] {
+		t.Fatalf("expected the oldest traces to be kept, got %v", got)
+	}
+	if len(a) != 2 || cap(a) != 2 {
+		t.Fatalf("expected the inputs not to be modified")
+	}
+	if got := Merge(got, traces(1)); len(got) != MaxTracesPerPush {
+		t.Fatalf("expected %d traces, got %d", MaxTracesPerPush, len(got))
+	}
+}
+
+func TestExport(t *testing.T) {
+	tracer := NewTracer()
+	a := tracer.Start("config.event", map[string]string{"kind": "VirtualService"})
+	tracer.Start("service.event", nil)
+	tracer.Record([]SpanContext{a}, "debounce", time.Time{}, time.Now(), nil)
+
+	if got := len(tracer.Export().InstrumentationLibrarySpans[0].Spans); got != 3 {
+		t.Fatalf("expected 3 spans, got %d", got)
+	}
+	spans := tracer.Export(a.TraceID.String()).InstrumentationLibrarySpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if !bytes.Equal(spans[0].TraceId, a.TraceID[:]) || spans[0].ParentSpanId != nil {
+		t.Fatalf("unexpected root span %v", spans[0])
+	}
+	if !bytes.Equal(spans[1].ParentSpanId, a.SpanID[:]) {
+		t.Fatalf("expected debounce span to be a child of the root span, got %v", spans[1])
+	}
+	if attrs := spans[0].Attributes; len(attrs) != 1 || attrs[0].Key != "kind" ||
+		attrs[0].Value.GetStringValue() != "VirtualService" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	received := make(chan *otlptrace.ResourceSpans, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req := &collectortrace.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Error(err)
+			return
+		}
+		if len(req.ResourceSpans) != 1 {
+			t.Errorf("expected 1 resource spans, got %d", len(req.ResourceSpans))
+			return
+		}
+		received <- req.ResourceSpans[0]
+	}))
+	defer srv.Close()
+
+	tracer := NewTracer()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		tracer.runExporter(&httpExporter{url: srv.URL, client: srv.Client()}, stop)
+		close(done)
+	}()
+	// Wait for the exporter to be running, so that spans are queued for export.
+	for {
+		tracer.mu.Lock()
+		exporting := tracer.exporting
+		tracer.mu.Unlock()
+		if exporting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sc := tracer.Start("config.event", nil)
+	tracer.Record([]SpanContext{sc}, "debounce", time.Now(), time.Now(), nil)
+	close(stop)
+	<-done
+
+	select {
+	case rs := <-received:
+		if got := len(rs.InstrumentationLibrarySpans[0].Spans); got != 2 {
+			t.Fatalf("expected 2 spans to be exported, got %d", got)
+		}
+	default:
+		t.Fatalf("expected spans to be exported")
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"istio.io/api/label"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	labelutil "istio.io/istio/pilot/pkg/serviceregistry/util/label"
@@ -233,10 +234,13 @@ func (s *ServiceEntryStore) convertEndpoint(service *model.Service, servicePort
 	wle *networking.WorkloadEntry, configKey *configKey, clusterID cluster.ID) *model.ServiceInstance {
 	var instancePort uint32
 	addr := wle.GetAddress()
-	// priority level: unixAddress > we.ports > se.port.targetPort > se.port.number
+	// priority level: unixAddress > internalAddress > we.ports > se.port.targetPort > se.port.number
 	if strings.HasPrefix(addr, model.UnixAddressPrefix) {
 		instancePort = 0
 		addr = strings.TrimPrefix(addr, model.UnixAddressPrefix)
+	} else if strings.HasPrefix(addr, model.EnvoyInternalAddressPrefix) {
+		// The prefix is kept, the endpoint is built as the address of the internal listener.
+		instancePort = 0
 	} else if port, ok := wle.Ports[servicePort.Name]; ok && port > 0 {
 		instancePort = port
 	} else if servicePort.TargetPort > 0 {
@@ -325,6 +329,10 @@ func (s *ServiceEntryStore) convertServiceEntryToInstances(cfg config.Config, se
 				})
 			} else {
 				for _, endpoint := range serviceEntry.Endpoints {
+					if !features.EnableInternalListeners && strings.HasPrefix(endpoint.Address, model.EnvoyInternalAddressPrefix) {
+						// There is no internal listener to send traffic to.
+						continue
+					}
 					out = append(out, s.convertEndpoint(service, serviceEntryPort, endpoint, &configKey{}, s.clusterID))
 				}
 			}
@@ -27,6 +27,7 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/workloadinstances"
@@ -271,6 +272,10 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 		Full:           true,
 		ConfigsUpdated: configsUpdated,
 		Reason:         []model.TriggerReason{model.EndpointUpdate},
+		Traces: pushtrace.Start("workloadentry.event", map[string]string{
+			"event":         event.String(),
+			"workloadentry": curr.Namespace + "/" + curr.Name,
+		}),
 	}
 	// trigger a full push
 	s.XdsUpdater.ConfigUpdate(pushReq)
@@ -391,6 +396,10 @@ func (s *ServiceEntryStore) serviceEntryHandler(_, curr config.Config, event mod
 		Full:           true,
 		ConfigsUpdated: configsUpdated,
 		Reason:         []model.TriggerReason{model.ServiceUpdate},
+		Traces: pushtrace.Start("serviceentry.event", map[string]string{
+			"event":        event.String(),
+			"serviceentry": curr.Namespace + "/" + curr.Name,
+		}),
 	}
 	s.XdsUpdater.ConfigUpdate(pushReq)
 }
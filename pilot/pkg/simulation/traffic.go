@@ -48,6 +48,7 @@ const (
 	HTTP  Protocol = "http"
 	HTTP2 Protocol = "http2"
 	TCP   Protocol = "tcp"
+	UDP   Protocol = "udp"
 )
 
 type TLSMode string
@@ -94,6 +95,8 @@ var (
 	CallModeOutbound CallMode = "outbound"
 	// CallModeInbound simulate iptables redirect to 15006
 	CallModeInbound CallMode = "inbound"
+	// CallModeInternal simulate a connection to the internal listener named by the Address
+	CallModeInternal CallMode = "internal"
 )
 
 type Call struct {
@@ -270,6 +273,17 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 	result.ListenerMatched = l.Name
 
+	if input.Protocol == UDP {
+		// UDP listeners have no filter chains, datagrams are forwarded by the udp_proxy listener filter.
+		udpProxy := xdstest.ExtractUDPProxy(sim.t, l)
+		if udpProxy == nil {
+			result.Error = ErrProtocolError
+			return
+		}
+		result.ClusterMatched = udpProxy.GetCluster()
+		return
+	}
+
 	hasTLSInspector := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
 	if !hasTLSInspector {
 		// Without tls inspector, Envoy would not read the ALPN in the TLS handshake
@@ -620,18 +634,30 @@ func matchListener(listeners []*listener.Listener, input Call) *listener.Listene
 	if input.CallMode == CallModeInbound {
 		return xdstest.ExtractListener(model.VirtualInboundListenerName, listeners)
 	}
+	if input.CallMode == CallModeInternal {
+		for _, l := range listeners {
+			if l.GetAddress().GetEnvoyInternalAddress().GetServerListenerName() == input.Address {
+				return l
+			}
+		}
+		return nil
+	}
 	// First find exact match for the IP/Port, then fallback to wildcard IP/Port
 	// There is no wildcard port
 	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), input.Address, input.Port) {
+		if matchAddress(l.GetAddress(), input.Address, input.Port, input.Protocol) {
 			return l
 		}
 	}
 	for _, l := range listeners {
-		if matchAddress(l.GetAddress(), "0.0.0.0", input.Port) {
+		if matchAddress(l.GetAddress(), "0.0.0.0", input.Port, input.Protocol) {
 			return l
 		}
 	}
+	if input.Protocol == UDP {
+		// UDP traffic is not captured, so there is no outbound listener to fall back to
+		return nil
+	}
 
 	// Fallback to the outbound listener
 	// TODO - support inbound
@@ -643,10 +669,13 @@ func matchListener(listeners []*listener.Listener, input Call) *listener.Listene
 	return nil
 }
 
-func matchAddress(a *core.Address, address string, port int) bool {
+func matchAddress(a *core.Address, address string, port int, protocol Protocol) bool {
 	if a.GetSocketAddress().GetAddress() != address {
 		return false
 	}
+	if (a.GetSocketAddress().GetProtocol() == core.SocketAddress_UDP) != (protocol == UDP) {
+		return false
+	}
 	if int(a.GetSocketAddress().GetPortValue()) != port {
 		return false
 	}
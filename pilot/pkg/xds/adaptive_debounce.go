@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ewmaWeight is the weight given to the newest sample when updating moving averages.
+	ewmaWeight = 0.2
+	// churnFactor bounds what is considered a burst: events closer together than churnFactor*min
+	// are assumed to be related, and the quiet period grows to cover the gap between them.
+	churnFactor = 4
+)
+
+// adaptiveDebouncer tunes the debounce quiet period based on observed load. When events arrive in
+// quick succession, pushes take long, or the push queue is backed up, it waits longer to batch more
+// events into a single push; when things are calm it falls back to the minimum quiet period.
+type adaptiveDebouncer struct {
+	mu sync.Mutex
+
+	// min and max bound the quiet period.
+	min time.Duration
+	max time.Duration
+	// pending returns the number of proxies waiting to be pushed.
+	pending func() int
+	// pendingScale is the queue depth at which the quiet period is doubled.
+	pendingScale int
+
+	lastEvent     time.Time
+	eventInterval time.Duration
+	pushDuration  time.Duration
+	lastQuiet     time.Duration
+}
+
+// AdaptiveDebounceStatus is the debug representation of the adaptive debouncer state.
+type AdaptiveDebounceStatus struct {
+	Mode          string `json:"mode"`
+	QuietPeriod   string `json:"quietPeriod"`
+	Min           string `json:"min"`
+	Max           string `json:"max"`
+	EventInterval string `json:"eventInterval,omitempty"`
+	PushDuration  string `json:"pushDuration,omitempty"`
+	Pending       int    `json:"pending"`
+}
+
+func newAdaptiveDebouncer(minQuiet, maxQuiet time.Duration, pendingScale int, pending func() int) *adaptiveDebouncer {
+	if pendingScale <= 0 {
+		pendingScale = 1
+	}
+	return &adaptiveDebouncer{
+		min:          minQuiet,
+		max:          maxQuiet,
+		pending:      pending,
+		pendingScale: pendingScale,
+		lastQuiet:    minQuiet,
+	}
+}
+
+func ewma(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return time.Duration(ewmaWeight*float64(sample) + (1-ewmaWeight)*float64(avg))
+}
+
+// recordEvent records the arrival of a config event.
+func (a *adaptiveDebouncer) recordEvent(t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.lastEvent.IsZero() {
+		a.eventInterval = ewma(a.eventInterval, t.Sub(a.lastEvent))
+	}
+	a.lastEvent = t
+}
+
+// recordPush records how long a debounced push took.
+func (a *adaptiveDebouncer) recordPush(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pushDuration = ewma(a.pushDuration, d)
+}
+
+// quietPeriod computes how long we must observe no events before pushing.
+func (a *adaptiveDebouncer) quietPeriod() time.Duration {
+	pending := 0
+	if a.pending != nil {
+		pending = a.pending()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	quiet := a.min
+	// Churn: wait long enough to cover the typical gap between related events.
+	if a.eventInterval > 0 && a.eventInterval < churnFactor*a.min {
+		quiet = maxDuration(quiet, 2*a.eventInterval)
+	}
+	// There is little value in pushing more often than we are able to complete pushes.
+	quiet = maxDuration(quiet, a.pushDuration/2)
+	// Back off while proxies are still waiting for the previous push.
+	quiet += time.Duration(float64(quiet) * float64(pending) / float64(a.pendingScale))
+	if a.max > 0 && quiet > a.max {
+		quiet = a.max
+	}
+	a.lastQuiet = quiet
+	debounceQuietPeriod.Record(quiet.Seconds())
+	return quiet
+}
+
+func (a *adaptiveDebouncer) status() AdaptiveDebounceStatus {
+	pending := 0
+	if a.pending != nil {
+		pending = a.pending()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AdaptiveDebounceStatus{
+		Mode:          "adaptive",
+		QuietPeriod:   a.lastQuiet.String(),
+		Min:           a.min.String(),
+		Max:           a.max.String(),
+		EventInterval: a.eventInterval.String(),
+		PushDuration:  a.pushDuration.String(),
+		Pending:       pending,
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveDebouncer(t *testing.T) {
+	minQuiet := 100 * time.Millisecond
+	maxQuiet := 2 * time.Second
+	events := func(a *adaptiveDebouncer, n int, interval time.Duration) {
+		t0 := time.Now()
+		for i := 0; i < n; i++ {
+			a.recordEvent(t0.Add(time.Duration(i) * interval))
+		}
+	}
+	cases := []struct {
+		name    string
+		setup   func(a *adaptiveDebouncer)
+		pending int
+		want    time.Duration
+	}{
+		{
+			name:  "calm",
+			setup: func(a *adaptiveDebouncer) {},
+			want:  minQuiet,
+		},
+		{
+			name: "sparse events",
+			setup: func(a *adaptiveDebouncer) {
+				events(a, 5, 10*time.Second)
+			},
+			want: minQuiet,
+		},
+		{
+			name: "churn",
+			setup: func(a *adaptiveDebouncer) {
+				events(a, 10, 150*time.Millisecond)
+			},
+			want: 300 * time.Millisecond,
+		},
+		{
+			name: "slow pushes",
+			setup: func(a *adaptiveDebouncer) {
+				a.recordPush(time.Second)
+			},
+			want: 500 * time.Millisecond,
+		},
+		{
+			name:    "backlog",
+			setup:   func(a *adaptiveDebouncer) {},
+			pending: 100,
+			want:    2 * minQuiet,
+		},
+		{
+			name: "capped at max",
+			setup: func(a *adaptiveDebouncer) {
+				a.recordPush(10 * time.Second)
+			},
+			pending: 1000,
+			want:    maxQuiet,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdaptiveDebouncer(minQuiet, maxQuiet, 100, func() int { return tt.pending })
+			tt.setup(a)
+			if got := a.quietPeriod(); got != tt.want {
+				t.Fatalf("got quiet period %v, want %v", got, tt.want)
+			}
+			if got := a.status().QuietPeriod; got != tt.want.String() {
+				t.Fatalf("got status quiet period %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
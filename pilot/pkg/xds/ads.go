@@ -17,6 +17,7 @@ package xds
 import (
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -97,8 +98,10 @@ type Connection struct {
 	// the proxy, should not be started until this channel is closed.
 	initialized chan struct{}
 
-	// stop can be used to end the connection manually via debug endpoints. Only to be used for testing.
-	stop chan struct{}
+	// stop can be used to end the connection manually via debug endpoints, or when the shard of the proxy
+	// moves to another replica.
+	stop     chan struct{}
+	stopOnce sync.Once
 
 	// reqChan is used to receive discovery requests for this connection.
 	reqChan      chan *discovery.DiscoveryRequest
@@ -111,6 +114,9 @@ type Connection struct {
 	// (last push not ACKed). When we get an ACK from Envoy, if the type is populated here, we will trigger
 	// the push.
 	blockedPushes map[string]*model.PushRequest
+
+	// throttle rate limits the pushes sent over this connection.
+	throttle *pushThrottle
 }
 
 // Event represents a config or registry event that results in a push.
@@ -120,6 +126,9 @@ type Event struct {
 
 	// function to call once a push is finished. This must be called or future changes may be blocked.
 	done func()
+
+	// throttled is set for pushes released by the connection throttle, which must not be throttled again.
+	throttled bool
 }
 
 func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
@@ -133,6 +142,7 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		Connect:       time.Now(),
 		stream:        stream,
 		blockedPushes: map[string]*model.PushRequest{},
+		throttle:      newPushThrottle(pushThrottleLimits),
 	}
 }
 
@@ -164,8 +174,8 @@ func (s *DiscoveryServer) receive(con *Connection, identities []string) {
 		// This should be only set for the first request. The node id may not be set - for example malicious clients.
 		if firstRequest {
 			// probe happens before envoy sends first xDS request
-			if req.TypeUrl == v3.HealthInfoType {
-				log.Warnf("ADS: %q %s send health check probe before normal xDS request", con.PeerAddr, con.ConID)
+			if req.TypeUrl == v3.HealthInfoType || req.TypeUrl == v3.OutlierEjectionsReportType {
+				log.Warnf("ADS: %q %s send %s before normal xDS request", con.PeerAddr, con.ConID, v3.GetShortType(req.TypeUrl))
 				continue
 			}
 			firstRequest = false
@@ -194,7 +204,7 @@ func (s *DiscoveryServer) receive(con *Connection, identities []string) {
 // handles 'push' requests and close - the code will eventually call the 'push' code, and it needs more mutex
 // protection. Original code avoided the mutexes by doing both 'push' and 'process requests' in same thread.
 func (s *DiscoveryServer) processRequest(req *discovery.DiscoveryRequest, con *Connection) error {
-	if !s.shouldProcessRequest(con.proxy, req) {
+	if !s.shouldProcessRequest(con, req) {
 		return nil
 	}
 
@@ -333,6 +343,10 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 			if err != nil {
 				return err
 			}
+		case <-con.throttle.Ready():
+			if err := s.pushThrottled(con); err != nil {
+				return err
+			}
 		case <-con.stop:
 			return nil
 		}
@@ -354,6 +368,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, request)
 		}
+		con.recordAckTrace(request.TypeUrl, request.ResponseNonce, true)
 		con.proxy.Lock()
 		if w, f := con.proxy.WatchedResources[request.TypeUrl]; f {
 			w.NonceNacked = request.ResponseNonce
@@ -408,6 +423,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 	con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = request.ResourceNames
 	con.proxy.Unlock()
+	con.recordAckTrace(request.TypeUrl, request.ResponseNonce, false)
 
 	// Envoy can send two DiscoveryRequests with same version and nonce
 	// when it detects a new resource. We should respond if they change.
@@ -439,7 +455,7 @@ func shouldUnsubscribe(request *discovery.DiscoveryRequest) bool {
 // resource names.
 func isWildcardTypeURL(typeURL string) bool {
 	switch typeURL {
-	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.ExtensionConfigurationType:
+	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.VirtualHostType, v3.ExtensionConfigurationType:
 		// By XDS spec, these are not wildcard
 		return false
 	case v3.ClusterType, v3.ListenerType:
@@ -485,11 +501,19 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 	con.ConID = connectionID(proxy.ID)
 	con.node = node
 	con.proxy = proxy
+	proxy.DeltaXds = con.deltaStream != nil
+	if bool(proxy.Metadata.OnDemandXds) && !proxy.DeltaXds {
+		log.Warnf("ADS: %s requested on demand xDS without delta xDS, ignoring", con.ConID)
+	}
 
 	// Authorize xds clients
 	if err := s.authorize(con, identities); err != nil {
 		return err
 	}
+	// Reject proxies that are served by another replica.
+	if err := s.checkShard(proxy); err != nil {
+		return err
+	}
 
 	// Register the connection. this allows pushes to be triggered for the proxy. Note: the timing of
 	// this and initializeProxy important. While registering for pushes *after* initialization is complete seems like
@@ -514,6 +538,7 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 }
 
 func (s *DiscoveryServer) closeConnection(con *Connection) {
+	con.throttle.Stop()
 	if con.ConID == "" {
 		return
 	}
@@ -525,6 +550,7 @@ func (s *DiscoveryServer) closeConnection(con *Connection) {
 		s.StatusReporter.RegisterDisconnect(con.ConID, AllEventTypesList)
 	}
 	s.WorkloadEntryController.QueueUnregisterWorkload(con.proxy, con.Connect)
+	s.pushOutlierEjections(s.OutlierEjections.update(con.ConID, nil))
 }
 
 func connectionID(node string) string {
@@ -653,19 +679,23 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 }
 
 // shouldProcessRequest returns whether or not to continue with the request.
-func (s *DiscoveryServer) shouldProcessRequest(proxy *model.Proxy, req *discovery.DiscoveryRequest) bool {
-	if req.TypeUrl != v3.HealthInfoType {
-		return true
-	}
-	if features.WorkloadEntryHealthChecks {
-		event := workloadentry.HealthEvent{}
-		event.Healthy = req.ErrorDetail == nil
-		if !event.Healthy {
-			event.Message = req.ErrorDetail.Message
+func (s *DiscoveryServer) shouldProcessRequest(con *Connection, req *discovery.DiscoveryRequest) bool {
+	switch req.TypeUrl {
+	case v3.HealthInfoType:
+		if features.WorkloadEntryHealthChecks {
+			event := workloadentry.HealthEvent{}
+			event.Healthy = req.ErrorDetail == nil
+			if !event.Healthy {
+				event.Message = req.ErrorDetail.Message
+			}
+			s.WorkloadEntryController.QueueWorkloadEntryHealth(con.proxy, event)
 		}
-		s.WorkloadEntryController.QueueWorkloadEntryHealth(proxy, event)
+		return false
+	case v3.OutlierEjectionsReportType:
+		s.reportOutlierEjections(con.ConID, con.Clusters(), req.ResourceNames)
+		return false
 	}
-	return false
+	return true
 }
 
 // DeltaAggregatedResources is not implemented.
@@ -700,6 +730,11 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 	// Send pushes to all generators
 	// Each Generator is responsible for determining if the push event requires a push
 	wrl, ignoreEvents := con.pushDetails()
+	if !pushEv.throttled && con.throttle.Throttle(throttledTypes(wrl, pushRequest), pushRequest) {
+		// The whole push is delayed, so that its types are still sent in order once released.
+		log.Debugf("THROTTLE for node:%s", con.proxy.ID)
+		return nil
+	}
 	for _, w := range wrl {
 		if !features.EnableFlowControl {
 			// Always send the push if flow control disabled
@@ -745,15 +780,16 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 
 // PushOrder defines the order that updates will be pushed in. Any types not listed here will be pushed in random
 // order after the types listed here
-var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.SecretType}
+var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.VirtualHostType, v3.SecretType}
 
 // KnownOrderedTypeUrls has typeUrls for which we know the order of push.
 var KnownOrderedTypeUrls = map[string]struct{}{
-	v3.ClusterType:  {},
-	v3.EndpointType: {},
-	v3.ListenerType: {},
-	v3.RouteType:    {},
-	v3.SecretType:   {},
+	v3.ClusterType:     {},
+	v3.EndpointType:    {},
+	v3.ListenerType:    {},
+	v3.RouteType:       {},
+	v3.VirtualHostType: {},
+	v3.SecretType:      {},
 }
 
 func reportAllEvents(s DistributionStatusCache, id, version string, ignored sets.Set) {
@@ -1004,5 +1040,5 @@ func orderWatchedResources(resources map[string]*model.WatchedResource) []*model
 }
 
 func (conn *Connection) Stop() {
-	close(conn.stop)
+	conn.stopOnce.Do(func() { close(conn.stop) })
 }
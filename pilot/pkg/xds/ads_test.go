@@ -1020,3 +1020,20 @@ func TestEdsCache(t *testing.T) {
 	assertEndpoints(ads)
 	t.Logf("endpoints: %+v", ads.GetEndpoints())
 }
+
+func TestPushOrder(t *testing.T) {
+	if len(xds.PushOrder) != len(xds.KnownOrderedTypeUrls) {
+		t.Fatalf("expected the ordered types %v to match the known ones %v", xds.PushOrder, xds.KnownOrderedTypeUrls)
+	}
+	order := map[string]int{}
+	for i, tp := range xds.PushOrder {
+		if _, f := xds.KnownOrderedTypeUrls[tp]; !f {
+			t.Fatalf("ordered type %s is not known", tp)
+		}
+		order[tp] = i
+	}
+	// Virtual hosts are only discovered through the route configurations referencing them.
+	if order[v3.VirtualHostType] < order[v3.RouteType] {
+		t.Fatalf("expected virtual hosts to be pushed after routes, got %v", xds.PushOrder)
+	}
+}
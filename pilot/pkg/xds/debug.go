@@ -37,6 +37,7 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
@@ -98,6 +99,8 @@ type AdsClient struct {
 	PeerAddress  string              `json:"address"`
 	Metadata     *model.NodeMetadata `json:"metadata,omitempty"`
 	Watches      map[string][]string `json:"watches,omitempty"`
+	// ThrottledPushes counts the pushes delayed by the per connection push rate limits, per type.
+	ThrottledPushes map[string]int64 `json:"throttledPushes,omitempty"`
 }
 
 // AdsClients is collection of AdsClient connected to this Istiod.
@@ -198,6 +201,8 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, internalMux, "/debug/push_status", "Last PushContext Details", s.pushStatusHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/pushcontext", "Debug support for current push context", s.pushContextHandler)
+	s.addDebugHandler(mux, internalMux, "/debug/debouncez", "Current push debounce decisions", s.debouncez)
+	s.addDebugHandler(mux, internalMux, "/debug/pushtrace", "Recent push traces, from the triggering event to the proxy ACKs", s.pushTraceHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/connections", "Info about the connected XDS clients", s.connectionsHandler)
 
 	s.addDebugHandler(mux, internalMux, "/debug/inject", "Active inject template", s.injectTemplateHandler(webhook))
@@ -523,9 +528,10 @@ func (s *DiscoveryServer) connectionsHandler(w http.ResponseWriter, req *http.Re
 
 	for _, c := range connections {
 		adsClient := AdsClient{
-			ConnectionID: c.ConID,
-			ConnectedAt:  c.Connect,
-			PeerAddress:  c.PeerAddr,
+			ConnectionID:    c.ConID,
+			ConnectedAt:     c.Connect,
+			PeerAddress:     c.PeerAddr,
+			ThrottledPushes: c.throttle.Throttled(),
 		}
 		adsClients.Connected = append(adsClients.Connected, adsClient)
 	}
@@ -789,6 +795,36 @@ func (s *DiscoveryServer) pushStatusHandler(w http.ResponseWriter, req *http.Req
 	_, _ = w.Write(out)
 }
 
+// debouncez dumps the current debounce settings, and the decisions of the adaptive debouncer if enabled
+func (s *DiscoveryServer) debouncez(w http.ResponseWriter, _ *http.Request) {
+	if s.debounceOptions.adaptive != nil {
+		writeJSON(w, s.debounceOptions.adaptive.status())
+		return
+	}
+	writeJSON(w, AdaptiveDebounceStatus{
+		Mode:        "fixed",
+		QuietPeriod: s.debounceOptions.debounceAfter.String(),
+		Min:         s.debounceOptions.debounceAfter.String(),
+		Max:         s.debounceOptions.debounceMax.String(),
+		Pending:     s.pushQueue.Pending(),
+	})
+}
+
+// pushTraceHandler dumps the recent push traces as OpenTelemetry spans. A single trace can be selected with ?trace=<id>.
+func (s *DiscoveryServer) pushTraceHandler(w http.ResponseWriter, req *http.Request) {
+	if !features.EnablePushTracing {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("Push tracing is disabled, set PILOT_ENABLE_PUSH_TRACING to enable it\n"))
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("Failed to parse request\n"))
+		return
+	}
+	writeJSON(w, jsonMarshalProto{pushtrace.Export(req.Form["trace"]...)})
+}
+
 // PushContextDebug holds debug information for push context.
 type PushContextDebug struct {
 	AuthorizationPolicies *model.AuthorizationPolicies
@@ -121,6 +121,10 @@ func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
 			if err != nil {
 				return err
 			}
+		case <-con.throttle.Ready():
+			if err := s.pushThrottled(con); err != nil {
+				return err
+			}
 		case <-con.stop:
 			return nil
 		}
@@ -149,6 +153,11 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 	// Send pushes to all generators
 	// Each Generator is responsible for determining if the push event requires a push
 	wrl, ignoreEvents := con.pushDetails()
+	if !pushEv.throttled && con.throttle.Throttle(throttledTypes(wrl, pushRequest), pushRequest) {
+		// The whole push is delayed, so that its types are still sent in order once released.
+		deltaLog.Debugf("THROTTLE for node:%s", con.proxy.ID)
+		return nil
+	}
 	for _, w := range wrl {
 		if !features.EnableFlowControl {
 			// Always send the push if flow control disabled
@@ -273,7 +282,7 @@ func (conn *Connection) sendDelta(res *discovery.DeltaDiscoveryResponse) error {
 // handles 'push' requests and close - the code will eventually call the 'push' code, and it needs more mutex
 // protection. Original code avoided the mutexes by doing both 'push' and 'process requests' in same thread.
 func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryRequest, con *Connection) error {
-	if !s.shouldProcessRequest(con.proxy, deltaToSotwRequest(req)) {
+	if !s.shouldProcessRequest(con, deltaToSotwRequest(req)) {
 		return nil
 	}
 	if strings.HasPrefix(req.TypeUrl, v3.DebugType) {
@@ -335,6 +344,7 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, deltaToSotwRequest(request))
 		}
+		con.recordAckTrace(request.TypeUrl, request.ResponseNonce, true)
 		con.proxy.Lock()
 		if w, f := con.proxy.WatchedResources[request.TypeUrl]; f {
 			w.NonceNacked = request.ResponseNonce
@@ -387,6 +397,7 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 	con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = deltaResources
 	con.proxy.Unlock()
+	con.recordAckTrace(request.TypeUrl, request.ResponseNonce, false)
 
 	oldAck := listEqualUnordered(previousResources, deltaResources)
 	// Spontaneous DeltaDiscoveryRequests from the client.
@@ -478,7 +489,15 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 	if subscribe == nil && isWildcardTypeURL(w.TypeUrl) {
 		// this is probably a bad idea...
 		con.proxy.Lock()
-		w.ResourceNames = currentResources
+		if usedDelta {
+			// A delta only contains the changed resources, the others are still there.
+			resourceNames := sets.NewSet(w.ResourceNames...)
+			resourceNames.Delete(deletedRes...)
+			resourceNames.Insert(currentResources...)
+			w.ResourceNames = resourceNames.SortedList()
+		} else {
+			w.ResourceNames = currentResources
+		}
 		con.proxy.Unlock()
 	}
 
@@ -501,6 +520,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		}
 		return err
 	}
+	con.recordPushTrace(w.TypeUrl, req, t0, len(res))
 
 	switch {
 	case logdata.Incremental:
@@ -532,6 +552,7 @@ func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connectio
 		deltaReqChan:  make(chan *discovery.DeltaDiscoveryRequest, 1),
 		errorChan:     make(chan error, 1),
 		blockedPushes: map[string]*model.PushRequest{},
+		throttle:      newPushThrottle(pushThrottleLimits),
 	}
 }
 
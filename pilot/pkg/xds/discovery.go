@@ -34,6 +34,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/core"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/grpcgen"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
@@ -70,6 +71,18 @@ type debounceOptions struct {
 
 	// enableEDSDebounce indicates whether EDS pushes should be debounced.
 	enableEDSDebounce bool
+
+	// adaptive, if set, tunes the quiet period between debounceAfter and debounceMax
+	// based on the observed event rate, push duration and push queue depth.
+	adaptive *adaptiveDebouncer
+}
+
+// quietPeriod returns how long no events must be observed before a push is triggered.
+func (o debounceOptions) quietPeriod() time.Duration {
+	if o.adaptive != nil {
+		return o.adaptive.quietPeriod()
+	}
+	return o.debounceAfter
 }
 
 // DiscoveryServer is Pilot's gRPC implementation for Envoy's xds APIs
@@ -151,6 +164,12 @@ type DiscoveryServer struct {
 	// Cache for XDS resources
 	Cache model.XdsCache
 
+	// Sharding restricts the proxies served by this replica. If nil, all proxies are served.
+	Sharding *Sharding
+
+	// OutlierEjections are the endpoints reported as ejected by the proxies, used by health-aware failover.
+	OutlierEjections *OutlierEjections
+
 	// JwtKeyResolver holds a reference to the JWT key resolver instance.
 	JwtKeyResolver *model.JwksResolver
 
@@ -203,8 +222,9 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 			debounceMax:       features.DebounceMax,
 			enableEDSDebounce: features.EnableEDSDebounce,
 		},
-		Cache:      model.DisabledCache{},
-		instanceID: instanceID,
+		Cache:            model.DisabledCache{},
+		instanceID:       instanceID,
+		OutlierEjections: NewOutlierEjections(features.HealthAwareFailoverMinReporters),
 	}
 
 	out.ClusterAliases = make(map[cluster.ID]cluster.ID)
@@ -218,6 +238,11 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 		out.Cache = model.NewXdsCache()
 	}
 
+	if features.EnableAdaptiveDebounce {
+		out.debounceOptions.adaptive = newAdaptiveDebouncer(features.DebounceAfter, features.DebounceMax,
+			features.AdaptiveDebounceQueueScale, out.pushQueue.Pending)
+	}
+
 	out.ConfigGenerator = core.NewConfigGenerator(plugins, out.Cache)
 
 	return out
@@ -269,6 +294,12 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
+	if features.XDSCacheSnapshotPath != "" {
+		go s.runCacheSnapshots(features.XDSCacheSnapshotPath, features.XDSCacheSnapshotInterval, stopCh)
+	}
+	if features.EnablePushTracing && features.PushTracingOTLPEndpoint != "" {
+		go pushtrace.RunExporter(features.PushTracingOTLPEndpoint, stopCh)
+	}
 }
 
 func (s *DiscoveryServer) getNonK8sRegistries() []serviceregistry.Instance {
@@ -353,6 +384,7 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	if err != nil {
 		return
 	}
+	pushtrace.Record(req.Traces, "init_push_context", t0, map[string]string{"version": versionLocal})
 	initContextTime := time.Since(t0)
 	log.Debugf("InitContext %v for push took %s", versionLocal, initContextTime)
 	pushContextInitTime.Record(initContextTime.Seconds())
@@ -415,7 +447,11 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 	freeCh := make(chan struct{}, 1)
 
 	push := func(req *model.PushRequest, debouncedEvents int) {
+		t0 := time.Now()
 		pushFn(req)
+		if opts.adaptive != nil {
+			opts.adaptive.recordPush(time.Since(t0))
+		}
 		updateSent.Add(int64(debouncedEvents))
 		freeCh <- struct{}{}
 	}
@@ -423,8 +459,9 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 	pushWorker := func() {
 		eventDelay := time.Since(startDebounce)
 		quietTime := time.Since(lastConfigUpdateTime)
+		quietPeriod := opts.quietPeriod()
 		// it has been too long or quiet enough
-		if eventDelay >= opts.debounceMax || quietTime >= opts.debounceAfter {
+		if eventDelay >= opts.debounceMax || quietTime >= quietPeriod {
 			if req != nil {
 				pushCounter++
 				if req.ConfigsUpdated == nil {
@@ -436,13 +473,16 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 						pushCounter, debouncedEvents, configsUpdated(req),
 						quietTime, eventDelay, req.Full)
 				}
+				pushtrace.Record(req.Traces, "debounce", startDebounce, map[string]string{
+					"events": strconv.Itoa(debouncedEvents),
+				})
 				free = false
 				go push(req, debouncedEvents)
 				req = nil
 				debouncedEvents = 0
 			}
 		} else {
-			timeChan = time.After(opts.debounceAfter - quietTime)
+			timeChan = time.After(quietPeriod - quietTime)
 		}
 	}
 
@@ -466,8 +506,11 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 			}
 
 			lastConfigUpdateTime = time.Now()
+			if opts.adaptive != nil {
+				opts.adaptive.recordEvent(lastConfigUpdateTime)
+			}
 			if debouncedEvents == 0 {
-				timeChan = time.After(opts.debounceAfter)
+				timeChan = time.After(opts.quietPeriod())
 				startDebounce = lastConfigUpdateTime
 			}
 			debouncedEvents++
@@ -571,6 +614,12 @@ func (s *DiscoveryServer) initPushContext(req *model.PushRequest, oldPushContext
 	return push, nil
 }
 
+// SetPushClassNamespaceLabels makes the push queue select the PushClass of proxies from the labels of their
+// namespace, when their workload does not set one.
+func (s *DiscoveryServer) SetPushClassNamespaceLabels(namespaceLabels NamespaceLabels) {
+	s.pushQueue.SetNamespaceLabels(namespaceLabels)
+}
+
 func (s *DiscoveryServer) sendPushes(stopCh <-chan struct{}) {
 	doSendPushes(stopCh, s.concurrentPushLimit, s.pushQueue)
 }
@@ -582,10 +631,13 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 	s.Generators[v3.ClusterType] = &CdsGenerator{Server: s}
 	s.Generators[v3.ListenerType] = &LdsGenerator{Server: s}
 	s.Generators[v3.RouteType] = &RdsGenerator{Server: s}
+	s.Generators[v3.VirtualHostType] = &VhdsGenerator{Server: s}
 	s.Generators[v3.EndpointType] = edsGen
 	s.Generators[v3.NameTableType] = &NdsGenerator{Server: s}
 	s.Generators[v3.ExtensionConfigurationType] = &EcdsGenerator{Server: s}
 	s.Generators[v3.ProxyConfigType] = &PcdsGenerator{Server: s, TrustBundle: env.TrustBundle}
+	s.Generators[v3.OpenTelemetryMetricsType] = &OpenTelemetryMetricsGenerator{Server: s}
+	s.Generators[v3.OutlierEjectionsType] = &OutlierEjectionsGenerator{Server: s}
 
 	s.Generators["grpc"] = &grpcgen.GrpcConfigGenerator{}
 	s.Generators["grpc/"+v3.EndpointType] = edsGen
@@ -601,6 +653,12 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 	s.Generators["event"] = s.StatusGen
 	s.Generators[TypeDebug] = NewDebugGen(s, systemNameSpace)
 	s.Generators[v3.BootstrapType] = &BootstrapGenerator{Server: s}
+
+	for _, g := range registeredGenerators() {
+		if err := s.RegisterGenerator(g); err != nil {
+			log.Warnf("failed to register custom generator: %v", err)
+		}
+	}
 }
 
 // shutdown shuts down DiscoveryServer components.
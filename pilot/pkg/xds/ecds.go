@@ -41,13 +41,16 @@ func ecdsNeedsPush(req *model.PushRequest) bool {
 	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
-	// Only push if config updates is triggered by EnvoyFilter or WasmPlugin.
+	// Only push if config updates is triggered by EnvoyFilter, WasmPlugin or Telemetry, which sets the access log
+	// rate sampling.
 	for config := range req.ConfigsUpdated {
 		switch config.Kind {
 		case gvk.EnvoyFilter:
 			return true
 		case gvk.WasmPlugin:
 			return true
+		case gvk.Telemetry:
+			return true
 		}
 	}
 	return false
@@ -20,6 +20,7 @@ import (
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	any "google.golang.org/protobuf/types/known/anypb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	networkingapi "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
@@ -27,6 +28,7 @@ import (
 	networking "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/pushtrace"
 	"istio.io/istio/pilot/pkg/util/sets"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
@@ -68,7 +70,7 @@ func (s *DiscoveryServer) UpdateServiceShards(push *model.PushContext) error {
 			}
 			endpoints := make([]*model.IstioEndpoint, 0)
 			for _, port := range svc.Ports {
-				if port.Protocol == protocol.UDP {
+				if port.Protocol == protocol.UDP && !features.EnableUDPListeners {
 					continue
 				}
 
@@ -117,6 +119,10 @@ func (s *DiscoveryServer) EDSUpdate(shard model.ShardKey, serviceName string, na
 				Namespace: namespace,
 			}: {}},
 			Reason: []model.TriggerReason{model.EndpointUpdate},
+			Traces: pushtrace.Start("endpoint.event", map[string]string{
+				"service": namespace + "/" + serviceName,
+				"cluster": string(shard.Cluster()),
+			}),
 		})
 	}
 }
@@ -360,6 +366,9 @@ func (s *DiscoveryServer) llbEndpointAndOptionsForCluster(b EndpointBuilder) ([]
 	}
 
 	svcPort, f := b.service.Ports.GetByPort(b.port)
+	if !f && features.EnableUDPListeners {
+		svcPort, f = b.service.Ports.GetUDPByPort(b.port)
+	}
 	if !f {
 		// Shouldn't happen here
 		log.Debugf("can not find the service port %d for cluster %s", b.port, b.clusterName)
@@ -414,10 +423,97 @@ func (s *DiscoveryServer) generateEndpoints(b EndpointBuilder) *endpoint.Cluster
 			}
 		}
 		loadbalancer.ApplyLocalityLBSetting(l, wrappedLocalityLbEndpoints, b.locality, b.proxy.Metadata.Labels, lbSetting, enableFailover)
+		if features.EnableHealthAwareFailover && enableFailover && lbSetting.GetDistribute() == nil {
+			ejected := func(ep *model.IstioEndpoint) bool {
+				return s.OutlierEjections.Ejected(b.hostname, ep)
+			}
+			degraded := loadbalancer.DegradedRegions(wrappedLocalityLbEndpoints, ejected, features.HealthAwareFailoverMinHealthyPercent)
+			loadbalancer.ApplyHealthAwareFailover(l, degraded)
+		}
 	}
 	return l
 }
 
+// udpWeightScale scales the weights of the endpoints of weighted UDP routes, so that the share of each destination is
+// kept when it is split across its endpoints.
+const udpWeightScale = 1000
+
+// generateUDPWeightedEndpoints builds the endpoints of a cluster serving a weighted UDP route. udp_proxy only forwards
+// to a single cluster, so the endpoints of the destination clusters are merged, and their weights are scaled for each
+// destination to receive its share of the traffic regardless of its number of endpoints. The locality load balancing
+// settings of the destinations do not apply to these clusters.
+func (s *DiscoveryServer) generateUDPWeightedEndpoints(proxy *model.Proxy, push *model.PushContext, clusterName string,
+	destinations []networking.UDPWeightedDestination) *endpoint.ClusterLoadAssignment {
+	l := buildEmptyClusterLoadAssignment(clusterName)
+	localities := map[string]*endpoint.LocalityLbEndpoints{}
+	for _, d := range destinations {
+		cla := s.generateEndpoints(NewEndpointBuilder(d.Cluster, proxy, push))
+		total := uint64(0)
+		for _, llbEndpoints := range cla.GetEndpoints() {
+			for _, ep := range llbEndpoints.LbEndpoints {
+				total += uint64(endpointWeight(ep))
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		for _, llbEndpoints := range cla.Endpoints {
+			key := util.LocalityToString(llbEndpoints.Locality)
+			merged := localities[key]
+			if merged == nil {
+				merged = &endpoint.LocalityLbEndpoints{Locality: llbEndpoints.Locality}
+				localities[key] = merged
+				l.Endpoints = append(l.Endpoints, merged)
+			}
+			for _, ep := range llbEndpoints.LbEndpoints {
+				weight := uint64(endpointWeight(ep)) * uint64(d.Weight) * udpWeightScale / total
+				if weight == 0 {
+					weight = 1
+				}
+				merged.LbEndpoints = append(merged.LbEndpoints, &endpoint.LbEndpoint{
+					HostIdentifier:      ep.HostIdentifier,
+					HealthStatus:        ep.HealthStatus,
+					Metadata:            ep.Metadata,
+					LoadBalancingWeight: &wrappers.UInt32Value{Value: uint32(weight)},
+				})
+			}
+		}
+	}
+	return l
+}
+
+func endpointWeight(ep *endpoint.LbEndpoint) uint32 {
+	if w := ep.GetLoadBalancingWeight().GetValue(); w > 0 {
+		return w
+	}
+	return 1
+}
+
+// buildUDPWeightedEndpoints builds the endpoints of a cluster serving a weighted UDP route, if one of its destinations
+// is in the updated services, or on full pushes if they are nil. It reports the cluster as removed if the proxy does
+// not serve the route anymore, that is if it has no destinations.
+func (eds *EdsGenerator) buildUDPWeightedEndpoints(proxy *model.Proxy, push *model.PushContext, clusterName string,
+	destinations []networking.UDPWeightedDestination, updatedServices map[string]struct{}) (resource *discovery.Resource, removed bool) {
+	if len(destinations) == 0 {
+		return nil, true
+	}
+	if updatedServices != nil {
+		updated := false
+		for _, d := range destinations {
+			_, _, hostname, _ := model.ParseSubsetKey(d.Cluster)
+			if _, ok := updatedServices[string(hostname)]; ok {
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			return nil, false
+		}
+	}
+	l := eds.Server.generateUDPWeightedEndpoints(proxy, push, clusterName, destinations)
+	return &discovery.Resource{Name: l.ClusterName, Resource: util.MessageToAny(l)}, false
+}
+
 // EdsGenerator implements the new Generate method for EDS, using the in-memory, optimized endpoint
 // storage in DiscoveryServer.
 type EdsGenerator struct {
@@ -558,7 +654,20 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 	empty := 0
 	cached := 0
 	regenerated := 0
+	// The weighted UDP routes of the proxy are only computed if it watches one of their clusters.
+	var udpWeighted map[string][]networking.UDPWeightedDestination
 	for _, clusterName := range w.ResourceNames {
+		if networking.IsUDPWeightedCluster(clusterName) {
+			if udpWeighted == nil {
+				udpWeighted = networking.UDPWeightedDestinations(proxy, push)
+			}
+			if resource, _ := eds.buildUDPWeightedEndpoints(proxy, push, clusterName, udpWeighted[clusterName],
+				edsUpdatedServices); resource != nil {
+				resources = append(resources, resource)
+				regenerated++
+			}
+			continue
+		}
 		if edsUpdatedServices != nil {
 			_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 			if _, ok := edsUpdatedServices[string(hostname)]; !ok {
@@ -607,8 +716,22 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 	empty := 0
 	cached := 0
 	regenerated := 0
+	var udpWeighted map[string][]networking.UDPWeightedDestination
 
 	for _, clusterName := range w.ResourceNames {
+		if networking.IsUDPWeightedCluster(clusterName) {
+			if udpWeighted == nil {
+				udpWeighted = networking.UDPWeightedDestinations(proxy, push)
+			}
+			resource, gone := eds.buildUDPWeightedEndpoints(proxy, push, clusterName, udpWeighted[clusterName], edsUpdatedServices)
+			if gone {
+				removed = append(removed, clusterName)
+			} else if resource != nil {
+				resources = append(resources, resource)
+				regenerated++
+			}
+			continue
+		}
 		// filter out eds that are not updated for clusters
 		_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 		if _, ok := edsUpdatedServices[string(hostname)]; !ok {
@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"sort"
 	"strconv"
+	"strings"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -70,6 +71,8 @@ type EndpointBuilder struct {
 	service         *model.Service
 	clusterLocal    bool
 	tunnelType      networking.TunnelType
+	// internalListeners is set if the proxy accepts endpoints addressing its internal listeners.
+	internalListeners bool
 
 	// These fields are provided for convenience only
 	subsetName string
@@ -100,6 +103,8 @@ func NewEndpointBuilder(clusterName string, proxy *model.Proxy, push *model.Push
 		destinationRule: dr,
 		tunnelType:      GetTunnelBuilderType(clusterName, proxy, push),
 
+		internalListeners: util.IsInternalListenerEnabled(proxy),
+
 		push:       push,
 		proxy:      proxy,
 		subsetName: subsetName,
@@ -131,6 +136,7 @@ func (b EndpointBuilder) Key() string {
 		strconv.FormatBool(b.clusterLocal),
 		util.LocalityToString(b.locality),
 		b.tunnelType.ToString(),
+		strconv.FormatBool(b.internalListeners),
 	}
 	if b.push != nil && b.push.AuthnPolicies != nil {
 		params = append(params, b.push.AuthnPolicies.GetVersion())
@@ -311,6 +317,9 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 			if !epLabels.HasSubsetOf(ep.Labels) {
 				continue
 			}
+			if !b.internalListeners && strings.HasPrefix(ep.Address, model.EnvoyInternalAddressPrefix) {
+				continue
+			}
 
 			locLbEps, found := localityEpMap[ep.Locality.Label]
 			if !found {
@@ -572,5 +581,8 @@ func lbEpKey(b *endpoint.LbEndpoint) string {
 	if addr := b.GetEndpoint().GetAddress().GetPipe(); addr != nil {
 		return addr.GetPath() + ":" + strconv.Itoa(int(addr.GetMode()))
 	}
+	if addr := b.GetEndpoint().GetAddress().GetEnvoyInternalAddress(); addr != nil {
+		return addr.GetServerListenerName()
+	}
 	return ""
 }
@@ -0,0 +1,208 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// OutlierEjections tracks the endpoints ejected by the outlier detection of the proxies, as reported by their
+// agents. They are aggregated with the health status of the endpoints by health-aware failover, so that traffic
+// moves away from the degraded regions for all the proxies, instead of each proxy detecting the failures on its
+// own. So that a single proxy can not move the traffic of the whole mesh, an endpoint is only ejected while at
+// least minReporters connected proxies report it, and the proxies can only report the endpoints of the clusters
+// they watch.
+type OutlierEjections struct {
+	minReporters int
+
+	mu sync.RWMutex
+	// ejected is keyed by ejectionKey, to the IDs of the connections reporting it.
+	ejected map[string]sets.Set
+	// reports are keyed by connection ID, to the ejectionKey of the endpoints reported by the connection and the
+	// hostnames of their services.
+	reports map[string]map[string]host.Name
+}
+
+// NewOutlierEjections creates the outlier ejections, ejecting the endpoints reported by at least minReporters
+// proxies.
+func NewOutlierEjections(minReporters int) *OutlierEjections {
+	if minReporters < 1 {
+		minReporters = 1
+	}
+	return &OutlierEjections{
+		minReporters: minReporters,
+		ejected:      map[string]sets.Set{},
+		reports:      map[string]map[string]host.Name{},
+	}
+}
+
+// Ejected checks if the endpoint of the service is reported as ejected by enough proxies.
+func (o *OutlierEjections) Ejected(hostname host.Name, ep *model.IstioEndpoint) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.ejected[ejectionKey(hostname, ep.Address, ep.EndpointPort)]) >= o.minReporters
+}
+
+// update replaces the endpoints reported as ejected by a connection, returning the hostnames of the services with
+// endpoints that are no longer, or newly, ejected.
+func (o *OutlierEjections) update(conID string, reported map[string]host.Name) sets.Set {
+	changed := sets.NewSet()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	previous := o.reports[conID]
+	for key, hostname := range previous {
+		if _, f := reported[key]; f {
+			continue
+		}
+		if len(o.ejected[key]) == o.minReporters {
+			changed.Insert(string(hostname))
+		}
+		o.ejected[key].Delete(conID)
+		if o.ejected[key].Empty() {
+			delete(o.ejected, key)
+		}
+	}
+	for key, hostname := range reported {
+		if _, f := previous[key]; f {
+			continue
+		}
+		if _, f := o.ejected[key]; !f {
+			o.ejected[key] = sets.NewSet()
+		}
+		o.ejected[key].Insert(conID)
+		if len(o.ejected[key]) == o.minReporters {
+			changed.Insert(string(hostname))
+		}
+	}
+	if len(reported) == 0 {
+		delete(o.reports, conID)
+	} else {
+		o.reports[conID] = reported
+	}
+	return changed
+}
+
+// ejectionKey identifies an endpoint of a service, so that the proxies can not eject the endpoints of other
+// services by reporting their addresses in the clusters they watch.
+func ejectionKey(hostname host.Name, address string, port uint32) string {
+	return string(hostname) + "/" + net.JoinHostPort(address, strconv.Itoa(int(port)))
+}
+
+// parseOutlierEjections returns the endpoints of an outlier ejections report, keyed by ejectionKey, to the
+// hostnames of their services. Only the hosts of the outbound clusters watched by the proxy are kept.
+func parseOutlierEjections(watched []string, names []string) map[string]host.Name {
+	clusters := sets.NewSet(watched...)
+	out := make(map[string]host.Name, len(names))
+	for _, name := range names {
+		i := strings.Index(name, "/")
+		if i < 0 || !clusters.Contains(name[:i]) {
+			continue
+		}
+		direction, _, hostname, _ := model.ParseSubsetKey(name[:i])
+		if direction != model.TrafficDirectionOutbound || hostname == "" {
+			continue
+		}
+		address, port, err := net.SplitHostPort(name[i+1:])
+		if err != nil {
+			continue
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		out[ejectionKey(hostname, address, uint32(p))] = hostname
+	}
+	return out
+}
+
+// reportOutlierEjections replaces the endpoints reported as ejected by the agent of a connection, watching the
+// given EDS clusters. When health-aware failover is enabled, the endpoints of the services whose ejections changed
+// are pushed again.
+func (s *DiscoveryServer) reportOutlierEjections(conID string, watched []string, names []string) {
+	s.pushOutlierEjections(s.OutlierEjections.update(conID, parseOutlierEjections(watched, names)))
+}
+
+func (s *DiscoveryServer) pushOutlierEjections(hostnames sets.Set) {
+	if len(hostnames) == 0 || !features.EnableHealthAwareFailover {
+		return
+	}
+	push := s.globalPushContext()
+	configs := map[model.ConfigKey]struct{}{}
+	for hostname := range hostnames {
+		for namespace := range push.ServiceIndex.HostnameAndNamespace[host.Name(hostname)] {
+			configs[model.ConfigKey{Kind: gvk.ServiceEntry, Name: hostname, Namespace: namespace}] = struct{}{}
+		}
+	}
+	if len(configs) == 0 {
+		return
+	}
+	// Like for the endpoint updates of the registries, clear the cache right away so that no response is generated
+	// with the previous ejections.
+	s.Cache.Clear(configs)
+	s.ConfigUpdate(&model.PushRequest{
+		Full:           false,
+		ConfigsUpdated: configs,
+		Reason:         []model.TriggerReason{model.EndpointUpdate},
+	})
+}
+
+// OutlierEjectionsGenerator tells the agents how often to report the hosts ejected by the outlier detection of
+// their proxy. The agents only read the outlier detection state of their proxy while health-aware failover uses
+// it.
+type OutlierEjectionsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var _ model.XdsResourceGenerator = &OutlierEjectionsGenerator{}
+
+// outlierEjectionsNeedsPush only sends the report interval when the agent requests it, as it only depends on the
+// settings of istiod.
+func outlierEjectionsNeedsPush(req *model.PushRequest) bool {
+	if req == nil {
+		return true
+	}
+	for _, reason := range req.Reason {
+		if reason == model.ProxyRequest {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a Struct with the report interval, or an empty one.
+func (e *OutlierEjectionsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if !outlierEjectionsNeedsPush(req) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	target := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	if features.EnableHealthAwareFailover {
+		target.Fields["reportInterval"] = structpb.NewStringValue(features.HealthAwareFailoverReportInterval.String())
+	}
+	return model.Resources{&discovery.Resource{Resource: util.MessageToAny(target)}}, model.DefaultXdsLogDetails, nil
+}
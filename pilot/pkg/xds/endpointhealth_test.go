@@ -0,0 +1,239 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const failoverConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: failover
+spec:
+  host: failover.example.com
+  trafficPolicy:
+    outlierDetection:
+      consecutive5xxErrors: 5
+`
+
+func TestHealthAwareFailover(t *testing.T) {
+	old := features.EnableHealthAwareFailover
+	features.EnableHealthAwareFailover = true
+	t.Cleanup(func() { features.EnableHealthAwareFailover = old })
+
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: failoverConfig,
+		DiscoveryServerModifier: func(s *DiscoveryServer) {
+			s.MemRegistry.AddHTTPService("failover.example.com", "10.10.0.1", 80)
+			s.OutlierEjections = NewOutlierEjections(2)
+		},
+	})
+	proxy := s.SetupProxy(&model.Proxy{Locality: &core.Locality{Region: "region1", Zone: "zone1"}})
+	setHealth := func(health ...model.HealthStatus) {
+		t.Helper()
+		var endpoints []*model.IstioEndpoint
+		for i, address := range []string{"1.1.1.1", "1.1.1.2", "2.2.2.2"} {
+			locality := "region1/zone1"
+			if i == 2 {
+				locality = "region2/zone2"
+			}
+			endpoints = append(endpoints, &model.IstioEndpoint{
+				Address:         address,
+				EndpointPort:    80,
+				ServicePortName: "http-main",
+				Locality:        model.Locality{Label: locality},
+				HealthStatus:    health[i],
+			})
+		}
+		s.MemRegistry.SetEndpoints("failover.example.com", "", endpoints)
+	}
+	expectPriorities := func(want map[string]uint32) {
+		t.Helper()
+		got := map[string]uint32{}
+		for _, cla := range s.Endpoints(proxy) {
+			if cla.ClusterName != "outbound|80||failover.example.com" {
+				continue
+			}
+			for _, llb := range cla.Endpoints {
+				got[util.LocalityToString(llb.Locality)] = llb.Priority
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected priorities %v, got %v", want, got)
+		}
+	}
+
+	setHealth(model.Healthy, model.Healthy, model.Healthy)
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+
+	// Half of the local endpoints unhealthy is still healthy enough.
+	setHealth(model.UnHealthy, model.Healthy, model.Healthy)
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+
+	setHealth(model.UnHealthy, model.UnHealthy, model.Healthy)
+	expectPriorities(map[string]uint32{"region1/zone1": 1, "region2/zone2": 0})
+
+	// Every locality is degraded, there is nowhere better to send traffic.
+	setHealth(model.UnHealthy, model.UnHealthy, model.UnHealthy)
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+
+	setHealth(model.Healthy, model.Healthy, model.Healthy)
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+
+	// The endpoints ejected by the outlier detection of enough proxies are unhealthy too, a single proxy is not
+	// trusted to degrade a region.
+	watched := []string{"outbound|80||failover.example.com"}
+	local := []string{"outbound|80||failover.example.com/1.1.1.1:80", "outbound|80||failover.example.com/1.1.1.2:80"}
+	s.Discovery.reportOutlierEjections("proxy-1", watched, local)
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+	s.Discovery.reportOutlierEjections("proxy-2", watched, append(local, "inbound|80||/2.2.2.2:80"))
+	expectPriorities(map[string]uint32{"region1/zone1": 1, "region2/zone2": 0})
+
+	// The proxies can not report the endpoints of the clusters they do not watch: otherwise every region would be
+	// degraded, and the priorities restored.
+	remote := []string{"outbound|80||failover.example.com/2.2.2.2:80"}
+	s.Discovery.reportOutlierEjections("proxy-3", []string{"outbound|80||other.example.com"}, remote)
+	s.Discovery.reportOutlierEjections("proxy-4", []string{"outbound|80||other.example.com"}, remote)
+	expectPriorities(map[string]uint32{"region1/zone1": 1, "region2/zone2": 0})
+
+	// The ejections are dropped with the reports, or the connections, of the proxies.
+	s.Discovery.reportOutlierEjections("proxy-2", watched, local[:1])
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+	s.Discovery.reportOutlierEjections("proxy-2", watched, local)
+	expectPriorities(map[string]uint32{"region1/zone1": 1, "region2/zone2": 0})
+	s.Discovery.pushOutlierEjections(s.Discovery.OutlierEjections.update("proxy-1", nil))
+	expectPriorities(map[string]uint32{"region1/zone1": 0, "region2/zone2": 1})
+}
+
+func TestOutlierEjections(t *testing.T) {
+	o := NewOutlierEjections(2)
+	ep := &model.IstioEndpoint{Address: "1.1.1.1", EndpointPort: 80}
+	report := map[string]host.Name{ejectionKey("a.example.com", "1.1.1.1", 80): "a.example.com"}
+
+	// A single reporter does not eject the endpoint.
+	if changed := o.update("proxy-1", report); len(changed) != 0 {
+		t.Fatalf("expected no change, got %v", changed)
+	}
+	if o.Ejected("a.example.com", ep) {
+		t.Fatalf("expected the endpoint reported by a single proxy not to be ejected")
+	}
+	if changed := o.update("proxy-2", report); !changed.Contains("a.example.com") {
+		t.Fatalf("expected a.example.com to change, got %v", changed)
+	}
+	if !o.Ejected("a.example.com", ep) {
+		t.Fatalf("expected the endpoint reported by two proxies to be ejected")
+	}
+	// The same address is not ejected for the other services.
+	if o.Ejected("b.example.com", ep) {
+		t.Fatalf("expected the endpoint of another service not to be ejected")
+	}
+	if changed := o.update("proxy-1", nil); !changed.Contains("a.example.com") || o.Ejected("a.example.com", ep) {
+		t.Fatalf("expected the endpoint not to be ejected anymore, got %v", changed)
+	}
+}
+
+func TestParseOutlierEjections(t *testing.T) {
+	watched := []string{
+		"outbound|80||a.example.com",
+		"outbound|80|v1|b.example.com",
+		"outbound_.80_._.c.example.com",
+		"inbound|80||",
+	}
+	got := parseOutlierEjections(watched, []string{
+		"outbound|80||a.example.com/1.1.1.1:80",
+		// The clusters not watched by the proxy are ignored.
+		"outbound|80||d.example.com/1.1.1.6:80",
+		"outbound|80|v1|b.example.com/[2001:db8::1]:8080",
+		"outbound_.80_._.c.example.com/1.1.1.3:80",
+		"inbound|80||/1.1.1.4:80",
+		"BlackHoleCluster/1.1.1.5:80",
+		"invalid",
+	})
+	want := map[string]host.Name{
+		"a.example.com/1.1.1.1:80":         "a.example.com",
+		"b.example.com/[2001:db8::1]:8080": "b.example.com",
+		"c.example.com/1.1.1.3:80":         "c.example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestOutlierEjectionsReport(t *testing.T) {
+	old := features.EnableHealthAwareFailover
+	features.EnableHealthAwareFailover = true
+	t.Cleanup(func() { features.EnableHealthAwareFailover = old })
+
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		DiscoveryServerModifier: func(s *DiscoveryServer) {
+			s.MemRegistry.AddHTTPService("failover.example.com", "10.10.0.1", 80)
+			s.OutlierEjections = NewOutlierEjections(1)
+		},
+	})
+	ads := s.ConnectADS().WithType(v3.OutlierEjectionsType)
+	res := ads.RequestResponseAck(t, nil)
+	target := &structpb.Struct{}
+	if err := res.Resources[0].UnmarshalTo(target); err != nil {
+		t.Fatal(err)
+	}
+	if got := target.Fields["reportInterval"].GetStringValue(); got != features.HealthAwareFailoverReportInterval.String() {
+		t.Fatalf("expected report interval %v, got %v", features.HealthAwareFailoverReportInterval, got)
+	}
+
+	ep := &model.IstioEndpoint{Address: "1.1.1.1", EndpointPort: 80}
+	// The proxy can only report the endpoints of the clusters it watches.
+	ads.WithType(v3.EndpointType).RequestResponseAck(t, &discovery.DiscoveryRequest{
+		ResourceNames: []string{"outbound|80||failover.example.com"},
+	})
+	ads.Request(t, &discovery.DiscoveryRequest{
+		TypeUrl:       v3.OutlierEjectionsReportType,
+		ResourceNames: []string{"outbound|80||failover.example.com/1.1.1.1:80"},
+	})
+	retry.UntilSuccessOrFail(t, func() error {
+		if !s.Discovery.OutlierEjections.Ejected("failover.example.com", ep) {
+			return fmt.Errorf("expected %v to be ejected", ep.Address)
+		}
+		return nil
+	})
+	// The report is not a subscription, only the endpoints of the service are pushed again.
+	if res := ads.ExpectResponse(t); res.TypeUrl != v3.EndpointType {
+		t.Fatalf("expected the endpoints to be pushed again, got %v", res.TypeUrl)
+	}
+	ads.ExpectNoResponse(t)
+
+	// The ejections of a proxy are dropped with its connection.
+	ads.Cleanup()
+	retry.UntilSuccessOrFail(t, func() error {
+		if s.Discovery.OutlierEjections.Ejected("failover.example.com", ep) {
+			return fmt.Errorf("expected %v not to be ejected", ep.Address)
+		}
+		return nil
+	})
+}
@@ -83,6 +83,8 @@ type FakeOptions struct {
 	// If provided, this mesh config will be used
 	MeshConfig      *meshconfig.MeshConfig
 	NetworksWatcher mesh.NetworksWatcher
+	// If provided, these extension providers will be used
+	ExtensionProviders []*model.ExtensionProvider
 
 	// Callback to modify the server before it is started
 	DiscoveryServerModifier func(s *DiscoveryServer)
@@ -216,6 +218,7 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 		ConfigTemplateInput: opts.ConfigTemplateInput,
 		MeshConfig:          opts.MeshConfig,
 		NetworksWatcher:     opts.NetworksWatcher,
+		ExtensionProviders:  opts.ExtensionProviders,
 		ServiceRegistries:   registries,
 		PushContextLock:     &s.updateMutex,
 		ConfigStoreCaches:   []model.ConfigStoreCache{ingr},
@@ -435,7 +438,12 @@ func (f *FakeDiscoveryServer) Connect(p *model.Proxy, watch []string, wait []str
 
 func (f *FakeDiscoveryServer) Endpoints(p *model.Proxy) []*endpoint.ClusterLoadAssignment {
 	loadAssignments := make([]*endpoint.ClusterLoadAssignment, 0)
+	udpWeighted := v1alpha3.UDPWeightedDestinations(p, f.PushContext())
 	for _, c := range xdstest.ExtractEdsClusterNames(f.Clusters(p)) {
+		if v1alpha3.IsUDPWeightedCluster(c) {
+			loadAssignments = append(loadAssignments, f.Discovery.generateUDPWeightedEndpoints(p, f.PushContext(), c, udpWeighted[c]))
+			continue
+		}
 		loadAssignments = append(loadAssignments, f.Discovery.generateEndpoints(NewEndpointBuilder(c, p, f.PushContext())))
 	}
 	return loadAssignments
@@ -15,12 +15,15 @@
 package filters
 
 import (
+	udpa "github.com/cncf/xds/go/udpa/type/v1"
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	cors "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cors/v3"
+	extproc "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
 	fault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	grpcstats "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_stats/v3"
 	grpcweb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_web/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	router "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	httpwasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
 	httpinspector "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/http_inspector/v3"
@@ -30,12 +33,14 @@ import (
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	alpn "istio.io/api/envoy/config/filter/http/alpn/v2alpha1"
 	"istio.io/api/envoy/config/filter/network/metadata_exchange"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	extprocconfig "istio.io/istio/pkg/config/extproc"
 )
 
 const (
@@ -48,6 +53,15 @@ const (
 	MxFilterName          = "istio.metadata_exchange"
 	StatsFilterName       = "istio.stats"
 	StackdriverFilterName = "istio.stackdriver"
+
+	OnDemandFilterName = "envoy.filters.http.on_demand"
+	onDemandType       = "type.googleapis.com/envoy.extensions.filters.http.on_demand.v3.OnDemand"
+
+	LocalRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+	LocalRateLimitStatPrefix = "http_local_rate_limiter"
+
+	// ExtProcFilterName prefixes the names of the ext_proc filters, which are suffixed with their provider.
+	ExtProcFilterName = "envoy.filters.http.ext_proc"
 )
 
 // Define static filters to be reused across the codebase. This avoids duplicate marshaling/unmarshaling
@@ -65,6 +79,13 @@ var (
 			TypedConfig: util.MessageToAny(&fault.HTTPFault{}),
 		},
 	}
+	// LocalRateLimit has no token bucket, and only limits the routes configuring one.
+	LocalRateLimit = &hcm.HttpFilter{
+		Name: LocalRateLimitFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: util.MessageToAny(&localratelimit.LocalRateLimit{StatPrefix: LocalRateLimitStatPrefix}),
+		},
+	}
 	Router = &hcm.HttpFilter{
 		Name: wellknown.Router,
 		ConfigType: &hcm.HttpFilter_TypedConfig{
@@ -149,6 +170,9 @@ var (
 	}
 
 	HTTPMx = buildHTTPMxFilter()
+
+	// OnDemand requests virtual hosts (VHDS) and clusters (ODCDS) that are not known to the proxy over ADS.
+	OnDemand = buildOnDemandFilter()
 )
 
 func BuildRouterFilter(ctx *RouterFilterContext) *hcm.HttpFilter {
@@ -190,3 +214,41 @@ func buildHTTPMxFilter() *hcm.HttpFilter {
 		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(httpMxConfigProto)},
 	}
 }
+
+func buildOnDemandFilter() *hcm.HttpFilter {
+	// The ODCDS config is not available in the vendored OnDemand proto yet, so it is set through a TypedStruct.
+	value, _ := structpb.NewStruct(map[string]interface{}{
+		"odcds": map[string]interface{}{
+			"source": map[string]interface{}{
+				"ads":                  map[string]interface{}{},
+				"resource_api_version": "V3",
+			},
+			"timeout": "5s",
+		},
+	})
+	return &hcm.HttpFilter{
+		Name: OnDemandFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: util.MessageToAny(&udpa.TypedStruct{
+				TypeUrl: onDemandType,
+				Value:   value,
+			}),
+		},
+	}
+}
+
+// BuildExtProcProcessingMode translates the processing mode of an external processor. Unset modes keep the
+// Envoy defaults.
+func BuildExtProcProcessingMode(in *extprocconfig.ProcessingMode) *extproc.ProcessingMode {
+	if in == nil {
+		return nil
+	}
+	return &extproc.ProcessingMode{
+		RequestHeaderMode:   extproc.ProcessingMode_HeaderSendMode(extproc.ProcessingMode_HeaderSendMode_value[in.RequestHeaders]),
+		ResponseHeaderMode:  extproc.ProcessingMode_HeaderSendMode(extproc.ProcessingMode_HeaderSendMode_value[in.ResponseHeaders]),
+		RequestBodyMode:     extproc.ProcessingMode_BodySendMode(extproc.ProcessingMode_BodySendMode_value[in.RequestBody]),
+		ResponseBodyMode:    extproc.ProcessingMode_BodySendMode(extproc.ProcessingMode_BodySendMode_value[in.ResponseBody]),
+		RequestTrailerMode:  extproc.ProcessingMode_HeaderSendMode(extproc.ProcessingMode_HeaderSendMode_value[in.RequestTrailers]),
+		ResponseTrailerMode: extproc.ProcessingMode_HeaderSendMode(extproc.ProcessingMode_HeaderSendMode_value[in.ResponseTrailers]),
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"sync"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+)
+
+// CustomGenerator serves an additional xDS type from istiod, without changes to the built-in generators.
+type CustomGenerator struct {
+	// TypeURL is the xDS type served by the generator. Like the other istio types, it uses wildcard semantics:
+	// a request with no resource names does not unsubscribe.
+	TypeURL string
+	// New builds the generator for a server. The XdsCache of the server is given so that the generator can cache
+	// its resources, keyed by model.XdsCacheEntry implementations: entries are invalidated when the configs they
+	// depend on change, as for the built-in generators.
+	New func(cache model.XdsCache) model.XdsResourceGenerator
+	// DependentTypes are the config kinds whose changes require the resources to be generated again. Full pushes
+	// that only update other kinds are skipped. If empty, every full push generates the resources.
+	DependentTypes []config.GroupVersionKind
+}
+
+var (
+	customGeneratorsMu sync.Mutex
+	customGenerators   []CustomGenerator
+)
+
+// RegisterGenerator registers a custom generator, installed on every server by InitGenerators. It should be
+// called from an init function, before the server is created.
+func RegisterGenerator(g CustomGenerator) error {
+	if err := validateCustomGenerator(g); err != nil {
+		return err
+	}
+	customGeneratorsMu.Lock()
+	defer customGeneratorsMu.Unlock()
+	for _, existing := range customGenerators {
+		if existing.TypeURL == g.TypeURL {
+			return fmt.Errorf("a generator is already registered for %s", g.TypeURL)
+		}
+	}
+	customGenerators = append(customGenerators, g)
+	return nil
+}
+
+func registeredGenerators() []CustomGenerator {
+	customGeneratorsMu.Lock()
+	defer customGeneratorsMu.Unlock()
+	return append([]CustomGenerator(nil), customGenerators...)
+}
+
+func validateCustomGenerator(g CustomGenerator) error {
+	if g.TypeURL == "" {
+		return fmt.Errorf("generator has no type URL")
+	}
+	if g.New == nil {
+		return fmt.Errorf("generator for %s has no constructor", g.TypeURL)
+	}
+	return nil
+}
+
+// RegisterGenerator installs a custom generator on the server. It must be called before the server is started.
+// Built-in generators cannot be replaced.
+func (s *DiscoveryServer) RegisterGenerator(g CustomGenerator) error {
+	if err := validateCustomGenerator(g); err != nil {
+		return err
+	}
+	if _, f := s.Generators[g.TypeURL]; f {
+		return fmt.Errorf("a generator is already registered for %s", g.TypeURL)
+	}
+	s.Generators[g.TypeURL] = newCustomGenerator(g.New(s.Cache), g.DependentTypes)
+	return nil
+}
+
+// customGeneratorWrapper skips the generation of custom resources for pushes that do not affect them.
+type customGeneratorWrapper struct {
+	gen   model.XdsResourceGenerator
+	types map[config.GroupVersionKind]struct{}
+}
+
+var _ model.XdsDeltaResourceGenerator = &customGeneratorWrapper{}
+
+func newCustomGenerator(gen model.XdsResourceGenerator, types []config.GroupVersionKind) *customGeneratorWrapper {
+	w := &customGeneratorWrapper{gen: gen, types: make(map[config.GroupVersionKind]struct{}, len(types))}
+	for _, t := range types {
+		w.types[t] = struct{}{}
+	}
+	return w
+}
+
+func (g *customGeneratorWrapper) needsPush(req *model.PushRequest) bool {
+	if req == nil {
+		return true
+	}
+	if !req.Full {
+		// Incremental pushes only update endpoints.
+		return false
+	}
+	if len(req.ConfigsUpdated) == 0 || len(g.types) == 0 {
+		return true
+	}
+	for config := range req.ConfigsUpdated {
+		if _, f := g.types[config.Kind]; f {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *customGeneratorWrapper) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if !g.needsPush(req) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	return g.gen.Generate(proxy, push, w, req)
+}
+
+func (g *customGeneratorWrapper) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, req *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !g.needsPush(req) {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+	if dg, ok := g.gen.(model.XdsDeltaResourceGenerator); ok {
+		return dg.GenerateDeltas(proxy, push, req, w)
+	}
+	res, logs, err := g.gen.Generate(proxy, push, w, req)
+	return res, nil, logs, false, err
+}
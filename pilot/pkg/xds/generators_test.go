@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.uber.org/atomic"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+const customTypeURL = "type.googleapis.com/istio.test.Custom"
+
+type countingGenerator struct {
+	calls *atomic.Int32
+}
+
+func (g countingGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	g.calls.Inc()
+	return model.Resources{&discovery.Resource{
+		Name:     "custom",
+		Resource: util.MessageToAny(wrappers.String(proxy.ID)),
+	}}, model.DefaultXdsLogDetails, nil
+}
+
+func TestCustomGenerator(t *testing.T) {
+	calls := atomic.NewInt32(0)
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	gen := CustomGenerator{
+		TypeURL:        customTypeURL,
+		New:            func(model.XdsCache) model.XdsResourceGenerator { return countingGenerator{calls: calls} },
+		DependentTypes: []config.GroupVersionKind{gvk.EnvoyFilter},
+	}
+	if err := s.Discovery.RegisterGenerator(gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Discovery.RegisterGenerator(gen); err == nil {
+		t.Fatalf("expected duplicate registration to fail")
+	}
+	if err := s.Discovery.RegisterGenerator(CustomGenerator{TypeURL: v3.ClusterType, New: gen.New}); err == nil {
+		t.Fatalf("expected built-in generators to not be replaced")
+	}
+
+	ads := s.ConnectADS().WithType(customTypeURL)
+	res := ads.RequestResponseAck(t, nil)
+	if len(res.Resources) != 1 || calls.Load() != 1 {
+		t.Fatalf("expected the custom resource to be generated once, got %v after %d calls", res.Resources, calls.Load())
+	}
+
+	// Updates of kinds the generator does not depend on are skipped.
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: "default"}: {}},
+	})
+	ads.ExpectNoResponse(t)
+
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "default"}: {}},
+	})
+	ads.ExpectResponse(t)
+	if calls.Load() != 2 {
+		t.Fatalf("expected the custom resource to be generated again, got %d calls", calls.Load())
+	}
+}
+
+func TestCustomGeneratorNeedsPush(t *testing.T) {
+	gen := newCustomGenerator(nil, []config.GroupVersionKind{gvk.EnvoyFilter})
+	key := func(kind config.GroupVersionKind) map[model.ConfigKey]struct{} {
+		return map[model.ConfigKey]struct{}{{Kind: kind, Name: "name", Namespace: "default"}: {}}
+	}
+	cases := []struct {
+		name string
+		req  *model.PushRequest
+		want bool
+	}{
+		{"request", nil, true},
+		{"incremental", &model.PushRequest{Full: false}, false},
+		{"full push of everything", &model.PushRequest{Full: true}, true},
+		{"dependent kind", &model.PushRequest{Full: true, ConfigsUpdated: key(gvk.EnvoyFilter)}, true},
+		{"unrelated kind", &model.PushRequest{Full: true, ConfigsUpdated: key(gvk.VirtualService)}, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gen.needsPush(tt.req); got != tt.want {
+				t.Fatalf("expected needsPush %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
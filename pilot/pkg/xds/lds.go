@@ -27,7 +27,7 @@ type LdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &LdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &LdsGenerator{}
 
 // Map of all configs that do not impact LDS
 var skippedLdsConfigs = map[model.NodeType]map[config.GroupVersionKind]struct{}{
@@ -83,3 +83,23 @@ func (l LdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	}
 	return resources, model.DefaultXdsLogDetails, nil
 }
+
+// GenerateDeltas for LDS only sends the listeners of the ports affected by service changes.
+func (l LdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, req *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !ldsNeedsPush(proxy, req) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	listeners, removed, usedDelta := l.Server.ConfigGenerator.BuildDeltaListeners(proxy, push, req, w)
+	if usedDelta && len(listeners) == 0 && len(removed) == 0 {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+	resources := model.Resources{}
+	for _, c := range listeners {
+		resources = append(resources, &discovery.Resource{
+			Name:     c.Name,
+			Resource: util.MessageToAny(c),
+		})
+	}
+	return resources, removed, model.DefaultXdsLogDetails, usedDelta, nil
+}
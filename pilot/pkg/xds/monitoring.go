@@ -30,6 +30,7 @@ var (
 	nodeTag    = monitoring.MustCreateLabel("node")
 	typeTag    = monitoring.MustCreateLabel("type")
 	versionTag = monitoring.MustCreateLabel("version")
+	classTag   = monitoring.MustCreateLabel("class")
 
 	// pilot_total_xds_rejects should be used instead. This is for backwards compatibility
 	cdsReject = monitoring.NewGauge(
@@ -72,6 +73,23 @@ var (
 		monitoring.WithLabels(typeTag),
 	)
 
+	shardRejects = monitoring.NewSum(
+		"pilot_xds_shard_rejects_total",
+		"Total number of XDS connections rejected or redirected because the proxy belongs to a shard served by another replica.",
+	)
+
+	shardUnowned = monitoring.NewSum(
+		"pilot_xds_shard_unowned_total",
+		"Total number of XDS connections served although the proxy belongs to a shard served by another replica, "+
+			"because it does not follow redirections.",
+	)
+
+	totalThrottledPushes = monitoring.NewSum(
+		"pilot_xds_throttled_pushes_total",
+		"Total number of XDS pushes that are delayed by the per connection push rate limits.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	// Number of delayed pushes that we pushed prematurely as a failsafe.
 	// This indicates that either the failsafe timeout is too aggressive or there is a deadlock
 	totalDelayedPushTimeouts = monitoring.NewSum(
@@ -144,6 +162,25 @@ var (
 		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
 	)
 
+	pushQueueDepth = monitoring.NewGauge(
+		"pilot_push_queue_depth",
+		"Number of proxies waiting in the push queue, labeled by push class.",
+		monitoring.WithLabels(classTag),
+	)
+
+	// only supported dimension is millis, unfortunately. default to unitdimensionless.
+	pushQueueTime = monitoring.NewDistribution(
+		"pilot_push_queue_class_time",
+		"Time in seconds, a proxy is in the push queue before being dequeued, labeled by push class.",
+		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(classTag),
+	)
+
+	debounceQuietPeriod = monitoring.NewGauge(
+		"pilot_debounce_quiet_period_seconds",
+		"Current quiet period in seconds used by the adaptive debouncer before triggering a push.",
+	)
+
 	pushTriggers = monitoring.NewSum(
 		"pilot_push_triggers",
 		"Total number of times a push was triggered, labeled by reason for the push.",
@@ -271,6 +308,14 @@ func incrementXDSRejects(xdsType string, node, errCode string) {
 	}
 }
 
+func recordPushQueueDepth(class PushClass, depth int) {
+	pushQueueDepth.With(classTag.Value(class.String())).Record(float64(depth))
+}
+
+func recordPushQueueTime(class PushClass, duration time.Duration) {
+	pushQueueTime.With(classTag.Value(class.String())).Record(duration.Seconds())
+}
+
 func recordSendTime(duration time.Duration) {
 	sendTime.Record(duration.Seconds())
 }
@@ -295,6 +340,9 @@ func init() {
 		pushTime,
 		proxiesConvergeDelay,
 		proxiesQueueTime,
+		pushQueueDepth,
+		pushQueueTime,
+		debounceQuietPeriod,
 		pushContextErrors,
 		totalXDSInternalErrors,
 		inboundUpdates,
@@ -302,6 +350,9 @@ func init() {
 		sendTime,
 		totalDelayedPushes,
 		totalDelayedPushTimeouts,
+		totalThrottledPushes,
+		shardRejects,
+		shardUnowned,
 		pilotSDSCertificateErrors,
 		configSizeBytes,
 	)
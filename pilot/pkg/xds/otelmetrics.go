@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// OpenTelemetryMetricsGenerator tells the agents which OpenTelemetry collector, selected by the Telemetries of
+// their workload, the metrics of their proxy are exported to. The agents only read the metrics of their proxy
+// while there is one.
+type OpenTelemetryMetricsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var _ model.XdsResourceGenerator = &OpenTelemetryMetricsGenerator{}
+
+func otelMetricsNeedsPush(req *model.PushRequest) bool {
+	if req == nil {
+		return true
+	}
+	if !req.Full {
+		return false
+	}
+	if len(req.ConfigsUpdated) == 0 {
+		return true
+	}
+	for config := range req.ConfigsUpdated {
+		if config.Kind == gvk.Telemetry {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a Struct with the address and push interval of the collector of the proxy, or an empty one.
+func (e *OpenTelemetryMetricsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if !otelMetricsNeedsPush(req) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	target := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	if p := push.Telemetry.MetricsExport(proxy); p != nil {
+		metrics := p.OpenTelemetry.Metrics
+		target.Fields["address"] = structpb.NewStringValue(p.OpenTelemetry.Address())
+		target.Fields["pushInterval"] = structpb.NewStringValue(metrics.PushIntervalDuration().String())
+		target.Fields["tlsMode"] = structpb.NewStringValue(metrics.TLS.GetMode())
+		if ca := metrics.TLS.GetCaCertificates(); ca != "" {
+			target.Fields["caCertificates"] = structpb.NewStringValue(ca)
+		}
+		if sni := metrics.TLS.GetSni(); sni != "" {
+			target.Fields["sni"] = structpb.NewStringValue(sni)
+		}
+	}
+	return model.Resources{&discovery.Resource{Resource: util.MessageToAny(target)}}, model.DefaultXdsLogDetails, nil
+}
@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+func TestOpenTelemetryMetrics(t *testing.T) {
+	providers := xdstest.ExtensionProviders(t, `
+- {name: otel, opentelemetry: {service: otel.istio-system.svc.cluster.local, port: 4317, metrics: {pushInterval: 30s}}}
+- name: otel-external
+  opentelemetry:
+    service: otel.example.com
+    port: 4317
+    metrics: {tls: {mode: SIMPLE, caCertificates: /etc/otel/ca.pem, sni: collector.example.com}}
+`)
+	telemetry := `
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: otel
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: exported
+  metrics:
+  - providers:
+    - name: otel
+---
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: otel-external
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: external
+  metrics:
+  - providers:
+    - name: otel-external
+`
+	cases := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]interface{}
+	}{
+		{
+			name:   "selected",
+			labels: map[string]string{"app": "exported"},
+			want: map[string]interface{}{
+				"address":      "otel.istio-system.svc.cluster.local:4317",
+				"pushInterval": "30s",
+				"tlsMode":      "ISTIO_MUTUAL",
+			},
+		},
+		{
+			name:   "simple tls",
+			labels: map[string]string{"app": "external"},
+			want: map[string]interface{}{
+				"address":        "otel.example.com:4317",
+				"pushInterval":   "15s",
+				"tlsMode":        "SIMPLE",
+				"caCertificates": "/etc/otel/ca.pem",
+				"sni":            "collector.example.com",
+			},
+		},
+		{
+			name:   "not selected",
+			labels: map[string]string{"app": "other"},
+			want:   map[string]interface{}{},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: telemetry, ExtensionProviders: providers})
+			ads := s.ConnectADS().WithType(v3.OpenTelemetryMetricsType)
+			res := ads.RequestResponseAck(t, &discovery.DiscoveryRequest{
+				Node: &corev3.Node{
+					Id:       ads.ID,
+					Metadata: model.NodeMetadata{Labels: tt.labels}.ToStruct(),
+				},
+			})
+			if len(res.Resources) != 1 {
+				t.Fatalf("expected a single resource, got %d", len(res.Resources))
+			}
+			target := &structpb.Struct{}
+			if err := res.Resources[0].UnmarshalTo(target); err != nil {
+				t.Fatal(err)
+			}
+			got := target.AsMap()
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
@@ -15,11 +15,115 @@
 package xds
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// PushClass groups proxies in the PushQueue. Each class has its own FIFO, and classes are
+// dequeued in proportion to their weight, so that e.g. gateways converge first during large pushes.
+type PushClass int
+
+const (
+	SidecarPushClass PushClass = iota
+	ProxylessPushClass
+	GatewayPushClass
+	numPushClasses
+)
+
+// PushClassLabel can be set on a workload, or on its namespace, to explicitly select the PushClass of
+// its proxy. The label of the workload takes precedence over the one of the namespace.
+const PushClassLabel = "istio.io/push-class"
+
+var pushClassNames = [numPushClasses]string{
+	SidecarPushClass:   "sidecar",
+	ProxylessPushClass: "proxyless",
+	GatewayPushClass:   "gateway",
+}
+
+func (c PushClass) String() string {
+	return pushClassNames[c]
+}
+
+func parsePushClass(s string) (PushClass, bool) {
+	for i, n := range pushClassNames {
+		if n == s {
+			return PushClass(i), true
+		}
+	}
+	return 0, false
+}
+
+// DefaultPushClassWeights are the weights used when PILOT_PUSH_CLASS_WEIGHTS is not set.
+var DefaultPushClassWeights = [numPushClasses]int{
+	SidecarPushClass:   1,
+	ProxylessPushClass: 2,
+	GatewayPushClass:   8,
+}
+
+// ParsePushClassWeights parses weights of the form "gateway=8,proxyless=2,sidecar=1".
+// Classes that are not specified keep their default weight.
+func ParsePushClassWeights(s string) ([numPushClasses]int, error) {
+	weights := DefaultPushClassWeights
+	if s == "" {
+		return weights, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return weights, fmt.Errorf("invalid push class weight %q, expected <class>=<weight>", kv)
+		}
+		class, ok := parsePushClass(parts[0])
+		if !ok {
+			return weights, fmt.Errorf("unknown push class %q", parts[0])
+		}
+		w, err := strconv.Atoi(parts[1])
+		if err != nil || w <= 0 {
+			return weights, fmt.Errorf("invalid weight %q for push class %v: must be a positive integer", parts[1], class)
+		}
+		weights[class] = w
+	}
+	return weights, nil
+}
+
+// NamespaceLabels returns the labels of a namespace, or nil if it is unknown.
+type NamespaceLabels func(namespace string) map[string]string
+
+// pushClassOf determines the PushClass of a connection. namespaceLabels may be nil.
+func pushClassOf(con *Connection, namespaceLabels NamespaceLabels) PushClass {
+	proxy := con.proxy
+	if proxy == nil {
+		return SidecarPushClass
+	}
+	if proxy.Metadata != nil {
+		if c, ok := parsePushClass(proxy.Metadata.Labels[PushClassLabel]); ok {
+			return c
+		}
+	}
+	if namespaceLabels != nil && proxy.ConfigNamespace != "" {
+		if c, ok := parsePushClass(namespaceLabels(proxy.ConfigNamespace)[PushClassLabel]); ok {
+			return c
+		}
+	}
+	if proxy.Type == model.Router {
+		return GatewayPushClass
+	}
+	if proxy.IsProxylessGrpc() {
+		return ProxylessPushClass
+	}
+	return SidecarPushClass
+}
+
+type queuedConnection struct {
+	con      *Connection
+	enqueued time.Time
+}
+
 type PushQueue struct {
 	cond *sync.Cond
 
@@ -27,8 +131,16 @@ type PushQueue struct {
 	// the PushRequest will be merged.
 	pending map[*Connection]*model.PushRequest
 
-	// queue maintains ordering of the queue
-	queue []*Connection
+	// queues maintains ordering of the queue, per PushClass
+	queues [numPushClasses][]queuedConnection
+
+	// weights is the relative share of dequeues for each PushClass.
+	weights [numPushClasses]int
+	// credits implements smooth weighted round-robin between non-empty classes.
+	credits [numPushClasses]int
+
+	// namespaceLabels looks up the PushClassLabel of the namespace of proxies, if set.
+	namespaceLabels NamespaceLabels
 
 	// processing stores all connections that have been Dequeue(), but not MarkDone().
 	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
@@ -39,13 +151,31 @@ type PushQueue struct {
 }
 
 func NewPushQueue() *PushQueue {
+	weights, err := ParsePushClassWeights(features.PushClassWeights)
+	if err != nil {
+		log.Warnf("invalid PILOT_PUSH_CLASS_WEIGHTS, using defaults: %v", err)
+		weights = DefaultPushClassWeights
+	}
+	return NewWeightedPushQueue(weights)
+}
+
+// NewWeightedPushQueue returns a PushQueue that dequeues each PushClass according to the given weights.
+func NewWeightedPushQueue(weights [numPushClasses]int) *PushQueue {
 	return &PushQueue{
 		pending:    make(map[*Connection]*model.PushRequest),
 		processing: make(map[*Connection]*model.PushRequest),
+		weights:    weights,
 		cond:       sync.NewCond(&sync.Mutex{}),
 	}
 }
 
+// SetNamespaceLabels sets the lookup used to select the PushClass of proxies from the labels of their namespace.
+func (p *PushQueue) SetNamespaceLabels(namespaceLabels NamespaceLabels) {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	p.namespaceLabels = namespaceLabels
+}
+
 // Enqueue will mark a proxy as pending a push. If it is already pending, pushInfo will be merged.
 // ServiceEntry updates will be added together, and full will be set if either were full
 func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
@@ -68,31 +198,75 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 	}
 
 	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	p.push(con)
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
 
+// push adds the connection to the queue of its class. Must be called with the lock held.
+func (p *PushQueue) push(con *Connection) {
+	class := pushClassOf(con, p.namespaceLabels)
+	p.queues[class] = append(p.queues[class], queuedConnection{con: con, enqueued: time.Now()})
+	recordPushQueueDepth(class, len(p.queues[class]))
+}
+
+// next selects the class to dequeue from, using smooth weighted round-robin across the
+// non-empty classes. This guarantees each class gets its share without starving any of them.
+// Must be called with the lock held, and at least one class must be non-empty.
+func (p *PushQueue) next() PushClass {
+	total := 0
+	best := PushClass(-1)
+	for c := PushClass(0); c < numPushClasses; c++ {
+		if len(p.queues[c]) == 0 {
+			continue
+		}
+		p.credits[c] += p.weights[c]
+		total += p.weights[c]
+		if best < 0 || p.credits[c] > p.credits[best] {
+			best = c
+		}
+	}
+	p.credits[best] -= total
+	return best
+}
+
+func (p *PushQueue) len() int {
+	n := 0
+	for _, q := range p.queues {
+		n += len(q)
+	}
+	return n
+}
+
 // Remove a proxy from the queue. If there are no proxies ready to be removed, this will block
 func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shutdown bool) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
 	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	for p.len() == 0 && !p.shuttingDown {
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
+	if p.len() == 0 {
 		// We must be shutting down.
 		return nil, nil, true
 	}
 
-	con = p.queue[0]
+	class := p.next()
+	queue := p.queues[class]
+	head := queue[0]
+	con = head.con
 	// The underlying array will still exist, despite the slice changing, so the object may not GC without this
 	// See https://github.com/grpc/grpc-go/issues/4758
-	p.queue[0] = nil
-	p.queue = p.queue[1:]
+	queue[0] = queuedConnection{}
+	p.queues[class] = queue[1:]
+	if len(p.queues[class]) == 0 {
+		// Don't carry credit or debt over once a class drains.
+		p.credits[class] = 0
+	}
+	recordPushQueueDepth(class, len(p.queues[class]))
+	recordPushQueueTime(class, time.Since(head.enqueued))
 
 	request = p.pending[con]
 	delete(p.pending, con)
@@ -113,7 +287,7 @@ func (p *PushQueue) MarkDone(con *Connection) {
 	// This means we need to add it back to the queue.
 	if request != nil {
 		p.pending[con] = request
-		p.queue = append(p.queue, con)
+		p.push(con)
 		p.cond.Signal()
 	}
 }
@@ -122,7 +296,18 @@ func (p *PushQueue) MarkDone(con *Connection) {
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return p.len()
+}
+
+// PendingByClass returns the number of pending proxies in each PushClass.
+func (p *PushQueue) PendingByClass() map[string]int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	res := make(map[string]int, numPushClasses)
+	for c, q := range p.queues {
+		res[PushClass(c).String()] = len(q)
+	}
+	return res
 }
 
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
@@ -371,6 +371,128 @@ func TestProxyQueue(t *testing.T) {
 	})
 }
 
+func TestPushQueueClasses(t *testing.T) {
+	mkCon := func(name string, proxy *model.Proxy) *Connection {
+		return &Connection{ConID: name, proxy: proxy}
+	}
+	sidecars := make([]*Connection, 0, 10)
+	for i := 0; i < 10; i++ {
+		sidecars = append(sidecars, mkCon(fmt.Sprintf("sidecar-%d", i), &model.Proxy{Type: model.SidecarProxy}))
+	}
+	gateway := mkCon("gateway", &model.Proxy{Type: model.Router})
+	proxyless := mkCon("proxyless", &model.Proxy{Type: model.SidecarProxy, Metadata: &model.NodeMetadata{Generator: "grpc"}})
+	labeled := mkCon("labeled", &model.Proxy{Type: model.SidecarProxy, Metadata: &model.NodeMetadata{
+		Labels: map[string]string{PushClassLabel: "gateway"},
+	}})
+	inLabeledNamespace := mkCon("labeled-namespace", &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "critical"})
+	overridden := mkCon("overridden", &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "critical", Metadata: &model.NodeMetadata{
+		Labels: map[string]string{PushClassLabel: "sidecar"},
+	}})
+	namespaceLabels := func(ns string) map[string]string {
+		if ns == "critical" {
+			return map[string]string{PushClassLabel: "gateway"}
+		}
+		return nil
+	}
+
+	t.Run("classification", func(t *testing.T) {
+		for _, tt := range []struct {
+			con  *Connection
+			want PushClass
+		}{
+			{&Connection{}, SidecarPushClass},
+			{sidecars[0], SidecarPushClass},
+			{gateway, GatewayPushClass},
+			{proxyless, ProxylessPushClass},
+			{labeled, GatewayPushClass},
+			{inLabeledNamespace, GatewayPushClass},
+			{overridden, SidecarPushClass},
+		} {
+			if got := pushClassOf(tt.con, namespaceLabels); got != tt.want {
+				t.Errorf("%v: got class %v, want %v", tt.con.ConID, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("namespace label", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		p.SetNamespaceLabels(namespaceLabels)
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(inLabeledNamespace, &model.PushRequest{})
+		if got := p.PendingByClass(); got["gateway"] != 1 || got["sidecar"] != 1 {
+			t.Fatalf("unexpected pending: %v", got)
+		}
+		ExpectDequeue(t, p, inLabeledNamespace)
+		ExpectDequeue(t, p, sidecars[0])
+	})
+
+	t.Run("gateways overtake sidecars", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		for _, s := range sidecars {
+			p.Enqueue(s, &model.PushRequest{})
+		}
+		p.Enqueue(gateway, &model.PushRequest{})
+
+		// The gateway was enqueued last, but its weight lets it be dequeued right away.
+		ExpectDequeue(t, p, gateway)
+		for _, s := range sidecars {
+			ExpectDequeue(t, p, s)
+		}
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("weighted fair dequeue", func(t *testing.T) {
+		p := NewWeightedPushQueue([numPushClasses]int{SidecarPushClass: 1, ProxylessPushClass: 1, GatewayPushClass: 2})
+		defer p.ShutDown()
+		gateways := make([]*Connection, 0, 4)
+		for i := 0; i < 4; i++ {
+			gateways = append(gateways, mkCon(fmt.Sprintf("gateway-%d", i), &model.Proxy{Type: model.Router}))
+		}
+		for i := 0; i < 4; i++ {
+			p.Enqueue(sidecars[i], &model.PushRequest{})
+			p.Enqueue(gateways[i], &model.PushRequest{})
+		}
+		if got := p.PendingByClass(); got["gateway"] != 4 || got["sidecar"] != 4 || got["proxyless"] != 0 {
+			t.Fatalf("unexpected pending: %v", got)
+		}
+		// Sidecars are not starved: with 2:1 weights they get every third dequeue.
+		for _, want := range []*Connection{
+			gateways[0], sidecars[0], gateways[1], gateways[2], sidecars[1], gateways[3],
+			sidecars[2], sidecars[3],
+		} {
+			ExpectDequeue(t, p, want)
+		}
+		ExpectTimeout(t, p)
+	})
+}
+
+func TestParsePushClassWeights(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    [numPushClasses]int
+		wantErr bool
+	}{
+		{in: "", want: DefaultPushClassWeights},
+		{in: "sidecar=3", want: [numPushClasses]int{SidecarPushClass: 3, ProxylessPushClass: 2, GatewayPushClass: 8}},
+		{in: "gateway=10, proxyless=5,sidecar=1", want: [numPushClasses]int{SidecarPushClass: 1, ProxylessPushClass: 5, GatewayPushClass: 10}},
+		{in: "waypoint=1", wantErr: true},
+		{in: "gateway=0", wantErr: true},
+		{in: "gateway", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePushClassWeights(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 // TestPushQueueLeak is a regression test for https://github.com/grpc/grpc-go/issues/4758
 func TestPushQueueLeak(t *testing.T) {
 	ds := NewFakeDiscoveryServer(t, FakeOptions{})
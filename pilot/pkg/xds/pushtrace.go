@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strconv"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/pushtrace"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// recordPushTrace records the generation of a response sent to the proxy in the traces of the push, and keeps
+// them so that the ACK of the response can be recorded too.
+func (conn *Connection) recordPushTrace(typeURL string, req *model.PushRequest, start time.Time, resources int) {
+	if req == nil || len(req.Traces) == 0 {
+		return
+	}
+	pushtrace.Record(req.Traces, "xds.push", start, map[string]string{
+		"proxy":     conn.proxy.ID,
+		"type":      v3.GetShortType(typeURL),
+		"resources": strconv.Itoa(resources),
+	})
+	conn.proxy.Lock()
+	if w := conn.proxy.WatchedResources[typeURL]; w != nil {
+		w.LastTraces = req.Traces
+	}
+	conn.proxy.Unlock()
+}
+
+// recordAckTrace records the ACK, or NACK, of the last response sent to the proxy in the traces of its push.
+func (conn *Connection) recordAckTrace(typeURL string, nonce string, nack bool) {
+	conn.proxy.Lock()
+	w := conn.proxy.WatchedResources[typeURL]
+	if w == nil || len(w.LastTraces) == 0 || w.NonceSent != nonce {
+		conn.proxy.Unlock()
+		return
+	}
+	traces, sent := w.LastTraces, w.LastSent
+	w.LastTraces = nil
+	conn.proxy.Unlock()
+
+	name := "xds.ack"
+	if nack {
+		name = "xds.nack"
+	}
+	pushtrace.Record(traces, name, sent, map[string]string{
+		"proxy": conn.proxy.ID,
+		"type":  v3.GetShortType(typeURL),
+		"nonce": nonce,
+	})
+}
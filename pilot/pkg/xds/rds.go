@@ -24,7 +24,7 @@ type RdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &RdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &RdsGenerator{}
 
 // Map of all configs that do not impact RDS
 var skippedRdsConfigs = map[config.GroupVersionKind]struct{}{
@@ -67,3 +67,13 @@ func (c RdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	resources, logDetails := c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, req, w.ResourceNames)
 	return resources, logDetails, nil
 }
+
+// GenerateDeltas for RDS only rebuilds the routes of the ports affected by service and routing changes.
+func (c RdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, req *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !rdsNeedsPush(req) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	resources, removed, logDetails, usedDelta := c.Server.ConfigGenerator.BuildDeltaHTTPRoutes(proxy, req, w.ResourceNames)
+	return resources, removed, logDetails, usedDelta, nil
+}
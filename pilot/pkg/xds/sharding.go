@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"istio.io/api/label"
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const (
+	// ShardByNamespace assigns proxies to shards based on their namespace.
+	ShardByNamespace = "namespace"
+	// ShardByRevision assigns proxies to shards based on the revision, or revision tag, they were injected with.
+	ShardByRevision = "revision"
+)
+
+// ShardOwner tells which shards this replica is responsible for, and which replica owns the others.
+type ShardOwner interface {
+	Owns(shard int) bool
+	// OwnerAddress returns the address of the replica owning the shard, or an empty string if unknown.
+	OwnerAddress(shard int) string
+}
+
+// Sharding splits the proxies of the mesh between the istiod replicas of a revision, so that each replica only
+// serves, and generates config for, the proxies of the shards it owns. Proxies connecting through an istio-agent
+// to a replica that does not own their shard are redirected to the replica that does: their agent reconnects to
+// the address of the owner, on the port of its discovery address. The other clients, such as proxyless gRPC,
+// do not follow redirections and are served by the replica they connect to.
+// Every replica still watches all the config and builds the service and config indexes of the PushContext. When
+// sharding by namespace, the sidecar scopes of the namespaces of the other shards are only computed if one of
+// their proxies connects anyway, and the XDS cache only holds the config of the proxies served.
+type Sharding struct {
+	// Shards is the number of shards.
+	Shards int
+	// By is the proxy property used to assign it to a shard, either ShardByNamespace or ShardByRevision.
+	By    string
+	Owner ShardOwner
+}
+
+// NewSharding validates the sharding settings.
+func NewSharding(shards int, by string, owner ShardOwner) (*Sharding, error) {
+	if shards < 2 {
+		return nil, fmt.Errorf("sharding requires at least 2 shards, got %d", shards)
+	}
+	if by != ShardByNamespace && by != ShardByRevision {
+		return nil, fmt.Errorf("unknown shard key %q, expected %s or %s", by, ShardByNamespace, ShardByRevision)
+	}
+	return &Sharding{Shards: shards, By: by, Owner: owner}, nil
+}
+
+// ShardOf returns the shard of a proxy.
+func (sh *Sharding) ShardOf(proxy *model.Proxy) int {
+	if sh.By == ShardByRevision {
+		return sh.shardOfKey(proxyRevision(proxy))
+	}
+	return sh.shardOfKey(proxy.ConfigNamespace)
+}
+
+func (sh *Sharding) shardOfKey(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(sh.Shards))
+}
+
+// Owns checks if this replica serves the proxy. A nil Sharding serves every proxy.
+func (sh *Sharding) Owns(proxy *model.Proxy) bool {
+	if sh == nil {
+		return true
+	}
+	return sh.Owner.Owns(sh.ShardOf(proxy))
+}
+
+// ServesNamespace checks if this replica serves the proxies of a namespace. When sharding by revision, the
+// proxies of a namespace may belong to any shard, so every namespace is served.
+func (sh *Sharding) ServesNamespace(namespace string) bool {
+	if sh == nil || sh.By != ShardByNamespace {
+		return true
+	}
+	return sh.Owner.Owns(sh.shardOfKey(namespace))
+}
+
+// proxyRevision returns the revision tag the proxy was injected with, or its revision.
+func proxyRevision(proxy *model.Proxy) string {
+	if proxy.Metadata == nil {
+		return ""
+	}
+	if rev := proxy.Metadata.Labels[label.IoIstioRev.Name]; rev != "" {
+		return rev
+	}
+	return proxy.Metadata.IstioRevision
+}
+
+// checkShard redirects proxies outside of the shards of this replica to the replica owning their shard. If the
+// owner is not known yet, the proxy is only rejected. Clients that do not follow redirections are served.
+func (s *DiscoveryServer) checkShard(proxy *model.Proxy) error {
+	if s.Sharding.Owns(proxy) {
+		return nil
+	}
+	shard := s.Sharding.ShardOf(proxy)
+	if proxy.Metadata == nil || !bool(proxy.Metadata.XdsRedirect) {
+		log.Debugf("ADS: serving %s outside of the shards of this istiod, it does not follow redirections", proxy.ID)
+		shardUnowned.Increment()
+		return nil
+	}
+	shardRejects.Increment()
+	return istiogrpc.RedirectError(s.Sharding.Owner.OwnerAddress(shard),
+		"proxy %s belongs to shard %d, which is not served by this istiod", proxy.ID, shard)
+}
+
+// OnShardChange disconnects the proxies of a shard this replica no longer owns, so that they reconnect to its
+// new owner, and recomputes the sidecar scopes of the namespaces now served.
+func (s *DiscoveryServer) OnShardChange(shard int, owned bool) {
+	if s.Sharding == nil {
+		return
+	}
+	if !owned {
+		for _, con := range s.Clients() {
+			if s.Sharding.ShardOf(con.proxy) == shard && con.proxy.Metadata != nil && bool(con.proxy.Metadata.XdsRedirect) {
+				log.Infof("ADS: disconnecting %s, shard %d is no longer owned", con.ConID, shard)
+				con.Stop()
+			}
+		}
+	}
+	if s.Sharding.By == ShardByNamespace {
+		s.ConfigUpdate(&model.PushRequest{Full: true, Reason: []model.TriggerReason{model.GlobalUpdate}})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"istio.io/api/label"
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// fakeShardOwner maps the shards to the address of their owner, empty for the shards this replica owns.
+type fakeShardOwner map[int]string
+
+func (f fakeShardOwner) Owns(shard int) bool {
+	owner, ok := f[shard]
+	return ok && owner == ""
+}
+
+func (f fakeShardOwner) OwnerAddress(shard int) string {
+	return f[shard]
+}
+
+func TestNewSharding(t *testing.T) {
+	if _, err := NewSharding(1, ShardByNamespace, fakeShardOwner{}); err == nil {
+		t.Fatalf("expected a single shard to be rejected")
+	}
+	if _, err := NewSharding(2, "cluster", fakeShardOwner{}); err == nil {
+		t.Fatalf("expected unknown shard key to be rejected")
+	}
+	if _, err := NewSharding(2, ShardByRevision, fakeShardOwner{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	byNamespace, _ := NewSharding(4, ShardByNamespace, fakeShardOwner{})
+	byRevision, _ := NewSharding(4, ShardByRevision, fakeShardOwner{})
+	proxy := func(ns, tag, rev string) *model.Proxy {
+		return &model.Proxy{
+			ConfigNamespace: ns,
+			Metadata: &model.NodeMetadata{
+				Labels:        map[string]string{label.IoIstioRev.Name: tag},
+				IstioRevision: rev,
+			},
+		}
+	}
+
+	if byNamespace.ShardOf(proxy("a", "", "")) != byNamespace.ShardOf(proxy("a", "canary", "1-13")) {
+		t.Fatalf("expected proxies of the same namespace to be in the same shard")
+	}
+	if byRevision.ShardOf(proxy("a", "canary", "1-13")) != byRevision.ShardOf(proxy("b", "canary", "1-14")) {
+		t.Fatalf("expected proxies of the same revision tag to be in the same shard")
+	}
+	if byRevision.ShardOf(proxy("a", "", "1-13")) != byRevision.ShardOf(proxy("b", "", "1-13")) {
+		t.Fatalf("expected proxies without a tag to be sharded by revision")
+	}
+	shards := map[int]bool{}
+	for _, ns := range []string{"a", "b", "c", "d", "default", "istio-system"} {
+		s := byNamespace.ShardOf(proxy(ns, "", ""))
+		if s < 0 || s >= 4 {
+			t.Fatalf("shard %d out of range", s)
+		}
+		shards[s] = true
+	}
+	if len(shards) < 2 {
+		t.Fatalf("expected namespaces to be spread across shards, got %v", shards)
+	}
+}
+
+func TestServesNamespace(t *testing.T) {
+	owner := fakeShardOwner{}
+	byNamespace, _ := NewSharding(2, ShardByNamespace, owner)
+	byRevision, _ := NewSharding(2, ShardByRevision, owner)
+	shard := byNamespace.ShardOf(&model.Proxy{ConfigNamespace: "default"})
+
+	if byNamespace.ServesNamespace("default") {
+		t.Fatalf("expected the namespaces of other shards not to be served")
+	}
+	if !byRevision.ServesNamespace("default") {
+		t.Fatalf("expected every namespace to be served when sharding by revision")
+	}
+	owner[shard] = ""
+	if !byNamespace.ServesNamespace("default") {
+		t.Fatalf("expected the namespaces of owned shards to be served")
+	}
+}
+
+func TestShardedConnection(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	owner := fakeShardOwner{}
+	s.Discovery.Sharding, _ = NewSharding(2, ShardByNamespace, owner)
+	shard := s.Discovery.Sharding.ShardOf(&model.Proxy{ConfigNamespace: "default"})
+	redirectable := model.NodeMetadata{XdsRedirect: true}
+
+	// The proxy is rejected while the owner of its shard is not known.
+	ads := s.ConnectADS().WithMetadata(redirectable)
+	ads.Request(t, nil)
+	err := ads.ExpectError(t)
+	if err == nil {
+		t.Fatalf("expected proxy outside of the shards of the replica to be rejected")
+	}
+	if got := istiogrpc.RedirectHost(err); got != "" {
+		t.Fatalf("expected no redirect without a known owner, got %q", got)
+	}
+
+	// The proxy is redirected to the owner of its shard.
+	owner[shard] = "10.0.0.2"
+	ads = s.ConnectADS().WithMetadata(redirectable)
+	ads.Request(t, nil)
+	if got := istiogrpc.RedirectHost(ads.ExpectError(t)); got != "10.0.0.2" {
+		t.Fatalf("expected proxy to be redirected to 10.0.0.2, got %q", got)
+	}
+
+	// Clients that do not follow redirections are served.
+	ads = s.ConnectADS()
+	ads.RequestResponseAck(t, nil)
+	ads.Cleanup()
+
+	owner[shard] = ""
+	ads = s.ConnectADS().WithMetadata(redirectable)
+	ads.RequestResponseAck(t, nil)
+
+	// Losing the shard disconnects the proxy.
+	s.Discovery.OnShardChange(shard, false)
+	if err := ads.ExpectError(t); err == nil {
+		t.Fatalf("expected proxy to be disconnected")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// PushThrottleLimits configures the rate at which pushes are sent over a single connection.
+type PushThrottleLimits struct {
+	// QPS is the rate of pushes of all types. Zero means unlimited.
+	QPS float64
+	// TypeQPS is the rate of pushes per type, keyed by the short type name, such as "eds".
+	TypeQPS map[string]float64
+	// Burst is the number of pushes, overall and per type, that can be sent at once.
+	Burst int
+}
+
+// Enabled checks if any limit is configured.
+func (l PushThrottleLimits) Enabled() bool {
+	return l.QPS > 0 || len(l.TypeQPS) > 0
+}
+
+// ParsePushThrottleTypeQPS parses per type rates of the form "eds=1,cds=0.5".
+func ParsePushThrottleTypeQPS(s string) (map[string]float64, error) {
+	out := map[string]float64{}
+	if s == "" {
+		return out, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid push rate %q, expected <type>=<qps>", kv)
+		}
+		qps, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || qps <= 0 {
+			return nil, fmt.Errorf("invalid push rate %q for type %v: must be a positive number", parts[1], parts[0])
+		}
+		out[strings.ToLower(parts[0])] = qps
+	}
+	return out, nil
+}
+
+// pushThrottleLimits are the limits applied to every connection.
+var pushThrottleLimits = loadPushThrottleLimits()
+
+func loadPushThrottleLimits() PushThrottleLimits {
+	typeQPS, err := ParsePushThrottleTypeQPS(features.ConnectionPushTypeQPS)
+	if err != nil {
+		log.Warnf("invalid PILOT_CONNECTION_PUSH_TYPE_QPS, ignoring per type limits: %v", err)
+		typeQPS = nil
+	}
+	return PushThrottleLimits{
+		QPS:     features.ConnectionPushQPS,
+		TypeQPS: typeQPS,
+		Burst:   features.ConnectionPushBurst,
+	}
+}
+
+// pushThrottle rate limits the pushes sent over a connection. Pushes that exceed the limits are not dropped:
+// they are merged, and the merged push is sent as soon as the limits allow it, so that the proxy always
+// eventually receives the latest state. The whole push of a connection is throttled at once, rather than each
+// of its types, so that the types are still sent in PushOrder.
+// A nil pushThrottle does not limit anything.
+type pushThrottle struct {
+	mu sync.Mutex
+	// limiter limits the pushes of all types. It is nil if unlimited.
+	limiter  *rate.Limiter
+	limiters map[string]*rate.Limiter
+	// pending holds the throttled push, if any.
+	pending *model.PushRequest
+	// throttled counts the throttled pushes, keyed by TypeUrl.
+	throttled map[string]int64
+	// ready is signaled when the pending push can be sent.
+	ready   chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+func newPushThrottle(limits PushThrottleLimits) *pushThrottle {
+	if !limits.Enabled() {
+		return nil
+	}
+	burst := limits.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	t := &pushThrottle{
+		limiters:  map[string]*rate.Limiter{},
+		throttled: map[string]int64{},
+		ready:     make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	if limits.QPS > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(limits.QPS), burst)
+	}
+	for typ, qps := range limits.TypeQPS {
+		t.limiters[typ] = rate.NewLimiter(rate.Limit(qps), burst)
+	}
+	return t
+}
+
+// throttledTypes returns the types a push may send: all the watched types for full pushes, and only
+// endpoints for incremental ones.
+func throttledTypes(wrl []*model.WatchedResource, req *model.PushRequest) []string {
+	types := make([]string, 0, len(wrl))
+	for _, w := range wrl {
+		if req.Full || w.TypeUrl == v3.EndpointType {
+			types = append(types, w.TypeUrl)
+		}
+	}
+	return types
+}
+
+// Throttle checks if a push sending the given types must be delayed. If so, the push is merged into the pending
+// push, which can be sent with Pop once Ready is signaled.
+func (t *pushThrottle) Throttle(typeURLs []string, req *model.PushRequest) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending != nil {
+		// A push is already scheduled, it will carry this one as well.
+		t.pending = t.pending.CopyMerge(req)
+		t.recordThrottledLocked(typeURLs)
+		return true
+	}
+
+	var delay time.Duration
+	now := time.Now()
+	limiters := []*rate.Limiter{t.limiter}
+	for _, typeURL := range typeURLs {
+		limiters = append(limiters, t.limiters[v3.GetMetricType(typeURL)])
+	}
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		if d := l.ReserveN(now, 1).DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	if delay == 0 {
+		return false
+	}
+	t.pending = req
+	t.recordThrottledLocked(typeURLs)
+	time.AfterFunc(delay, func() {
+		select {
+		case t.ready <- struct{}{}:
+		case <-t.stopped:
+		}
+	})
+	return true
+}
+
+func (t *pushThrottle) recordThrottledLocked(typeURLs []string) {
+	for _, typeURL := range typeURLs {
+		t.throttled[typeURL]++
+		totalThrottledPushes.With(typeTag.Value(v3.GetMetricType(typeURL))).Increment()
+	}
+}
+
+// Ready is signaled when the pending push can be sent.
+func (t *pushThrottle) Ready() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.ready
+}
+
+// Pop returns the pending push, if any.
+func (t *pushThrottle) Pop() *model.PushRequest {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	req := t.pending
+	t.pending = nil
+	return req
+}
+
+// Throttled returns the number of throttled pushes, keyed by short type name.
+func (t *pushThrottle) Throttled() map[string]int64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int64, len(t.throttled))
+	for typeURL, n := range t.throttled {
+		out[v3.GetShortType(typeURL)] = n
+	}
+	return out
+}
+
+// Stop releases the pending pushes once the connection is closed.
+func (t *pushThrottle) Stop() {
+	if t == nil {
+		return
+	}
+	t.stop.Do(func() { close(t.stopped) })
+}
+
+// pushThrottled sends the pending throttled push, once the throttle allows it.
+func (s *DiscoveryServer) pushThrottled(con *Connection) error {
+	req := con.throttle.Pop()
+	if req == nil {
+		return nil
+	}
+	pushEv := &Event{pushRequest: req, throttled: true}
+	if con.deltaStream != nil {
+		return s.pushConnectionDelta(con, pushEv)
+	}
+	return s.pushConnection(con, pushEv)
+}
@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestParsePushThrottleTypeQPS(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{in: "", want: map[string]float64{}},
+		{in: "eds=1", want: map[string]float64{"eds": 1}},
+		{in: "EDS=2, cds=0.5", want: map[string]float64{"eds": 2, "cds": 0.5}},
+		{in: "eds=0", wantErr: true},
+		{in: "eds=fast", wantErr: true},
+		{in: "eds", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePushThrottleTypeQPS(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushThrottleDisabled(t *testing.T) {
+	throttle := newPushThrottle(PushThrottleLimits{Burst: 10})
+	if throttle != nil {
+		t.Fatalf("expected no throttle without limits")
+	}
+	if throttle.Throttle([]string{v3.EndpointType}, &model.PushRequest{}) {
+		t.Fatalf("expected a nil throttle to allow every push")
+	}
+	if throttle.Ready() != nil || throttle.Throttled() != nil {
+		t.Fatalf("expected a nil throttle to have no pending pushes")
+	}
+	throttle.Stop()
+}
+
+func TestPushThrottle(t *testing.T) {
+	throttle := newPushThrottle(PushThrottleLimits{TypeQPS: map[string]float64{"eds": 20}, Burst: 1})
+	defer throttle.Stop()
+
+	key := func(name string) map[model.ConfigKey]struct{} {
+		return map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: name, Namespace: "default"}: {}}
+	}
+	if throttle.Throttle([]string{v3.EndpointType}, &model.PushRequest{ConfigsUpdated: key("a")}) {
+		t.Fatalf("expected the first push to be allowed")
+	}
+	if throttle.Throttle([]string{v3.ClusterType, v3.ListenerType}, &model.PushRequest{Full: true}) {
+		t.Fatalf("expected pushes of types without limits to be allowed")
+	}
+	if !throttle.Throttle([]string{v3.EndpointType}, &model.PushRequest{ConfigsUpdated: key("b")}) {
+		t.Fatalf("expected the second push to be throttled")
+	}
+	// Once a push is pending, every push of the connection waits for it, so that types are sent in order.
+	if !throttle.Throttle([]string{v3.ClusterType, v3.EndpointType}, &model.PushRequest{Full: true, ConfigsUpdated: key("c")}) {
+		t.Fatalf("expected the third push to be throttled")
+	}
+	if got := throttle.Throttled(); !reflect.DeepEqual(got, map[string]int64{"EDS": 2, "CDS": 1}) {
+		t.Fatalf("expected 2 throttled EDS pushes and 1 CDS push, got %v", got)
+	}
+
+	select {
+	case <-throttle.Ready():
+	case <-time.After(time.Second):
+		t.Fatalf("expected throttled push to be released")
+	}
+	req := throttle.Pop()
+	want := key("b")
+	for k := range key("c") {
+		want[k] = struct{}{}
+	}
+	if !req.Full || !reflect.DeepEqual(req.ConfigsUpdated, want) {
+		t.Fatalf("expected throttled pushes to be merged, got %+v", req)
+	}
+	if throttle.Pop() != nil {
+		t.Fatalf("expected no pending push")
+	}
+}
+
+func TestThrottledTypes(t *testing.T) {
+	wrl := []*model.WatchedResource{{TypeUrl: v3.ClusterType}, {TypeUrl: v3.EndpointType}, {TypeUrl: v3.ListenerType}}
+	if got := throttledTypes(wrl, &model.PushRequest{Full: true}); !reflect.DeepEqual(got,
+		[]string{v3.ClusterType, v3.EndpointType, v3.ListenerType}) {
+		t.Fatalf("expected full pushes to send every watched type, got %v", got)
+	}
+	if got := throttledTypes(wrl, &model.PushRequest{}); !reflect.DeepEqual(got, []string{v3.EndpointType}) {
+		t.Fatalf("expected incremental pushes to only send endpoints, got %v", got)
+	}
+}
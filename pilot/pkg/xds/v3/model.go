@@ -30,6 +30,7 @@ const (
 	RouteType                  = resource.RouteType
 	SecretType                 = resource.SecretType
 	ExtensionConfigurationType = resource.ExtensionConfigType
+	VirtualHostType            = apiTypePrefix + "envoy.config.route.v3.VirtualHost"
 
 	NameTableType   = apiTypePrefix + "istio.networking.nds.v1.NameTable"
 	HealthInfoType  = apiTypePrefix + "istio.v1.HealthInformation"
@@ -37,6 +38,16 @@ const (
 	// DebugType requests debug info from istio, a secured implementation for istio debug interface.
 	DebugType     = "istio.io/debug"
 	BootstrapType = apiTypePrefix + "envoy.config.bootstrap.v3.Bootstrap"
+	// OpenTelemetryMetricsType requests the OpenTelemetry collector the agent forwards the metrics of the proxy to,
+	// as a Struct with the address, pushInterval, tlsMode, and optional caCertificates and sni of the collector,
+	// empty if the proxy does not export metrics.
+	OpenTelemetryMetricsType = "istio.io/opentelemetry-metrics"
+	// OutlierEjectionsType requests how often the agent reports the hosts ejected by the outlier detection of the
+	// proxy, as a Struct with the reportInterval, empty if istiod does not use them.
+	OutlierEjectionsType = "istio.io/outlier-ejections"
+	// OutlierEjectionsReportType reports the hosts ejected by the outlier detection of the proxy, as
+	// "<cluster>/<address>:<port>" resource names. Every report replaces the previous one of the connection.
+	OutlierEjectionsReportType = "istio.io/outlier-ejections-report"
 
 	// nolint
 	HttpProtocolOptionsType = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
@@ -51,6 +62,8 @@ func GetShortType(typeURL string) string {
 		return "LDS"
 	case RouteType:
 		return "RDS"
+	case VirtualHostType:
+		return "VHDS"
 	case EndpointType:
 		return "EDS"
 	case SecretType:
@@ -75,6 +88,8 @@ func GetMetricType(typeURL string) string {
 		return "lds"
 	case RouteType:
 		return "rds"
+	case VirtualHostType:
+		return "vhds"
 	case EndpointType:
 		return "eds"
 	case SecretType:
@@ -0,0 +1,34 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// VhdsGenerator generates the virtual hosts that sidecars discovering them on demand ask for.
+type VhdsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var _ model.XdsResourceGenerator = &VhdsGenerator{}
+
+func (c VhdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if !proxy.OnDemandXds() || !rdsNeedsPush(req) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	return c.Server.ConfigGenerator.BuildOnDemandVirtualHosts(proxy, req, w.ResourceNames), model.DefaultXdsLogDetails, nil
+}
@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/file"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// cacheSnapshotPollInterval is how often we check whether the first push context is ready to restore a snapshot.
+var cacheSnapshotPollInterval = time.Second
+
+// cacheSnapshotQuietPeriod is how long a snapshot waits for the updates of the state its version was computed from.
+// The config stores and registries are updated before they request the push clearing the cache, so a snapshot is
+// only written if no update is requested within this period.
+var cacheSnapshotQuietPeriod = 100 * time.Millisecond
+
+// runCacheSnapshots restores the XDS cache from disk once the first push context has been computed, and then
+// periodically persists it so a restarted istiod does not need to regenerate everything at once.
+func (s *DiscoveryServer) runCacheSnapshots(path string, interval time.Duration, stopCh <-chan struct{}) {
+	snapshotter, ok := s.Cache.(model.XdsCacheSnapshotter)
+	if !ok {
+		return
+	}
+
+	poll := time.NewTicker(cacheSnapshotPollInterval)
+	for !s.IsServerReady() || !s.globalPushContext().InitDone.Load() {
+		select {
+		case <-poll.C:
+		case <-stopCh:
+			poll.Stop()
+			return
+		}
+	}
+	poll.Stop()
+	s.restoreCacheSnapshot(snapshotter, path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.writeCacheSnapshot(snapshotter, path)
+		case <-stopCh:
+			// Persist on shutdown as well, so a rollout restores the most recent state.
+			s.writeCacheSnapshot(snapshotter, path)
+			return
+		}
+	}
+}
+
+func (s *DiscoveryServer) restoreCacheSnapshot(snapshotter model.XdsCacheSnapshotter, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed to open xds cache snapshot %v: %v", path, err)
+		}
+		return
+	}
+	defer f.Close()
+	t0 := time.Now()
+	n, err := snapshotter.RestoreSnapshot(f, s.cacheSnapshotVersion)
+	if err != nil {
+		log.Infof("discarding xds cache snapshot %v: %v", path, err)
+		return
+	}
+	log.Infof("restored %d xds cache entries from %v in %v", n, path, time.Since(t0))
+}
+
+// writeCacheSnapshot persists the cache, tagged with the version of the current state. As the version is computed
+// from the config stores and registries, and the cache is only cleared by the debounced pushes, the snapshot is
+// only written if the pushes of all the updates had cleared the cache, and no update was requested while it was
+// built: otherwise stale entries could be restored as valid ones. The snapshot is then retried on the next tick.
+func (s *DiscoveryServer) writeCacheSnapshot(snapshotter model.XdsCacheSnapshotter, path string) {
+	inbound := s.InboundUpdates.Load()
+	if s.CommittedUpdates.Load() < inbound {
+		log.Debugf("skipping xds cache snapshot: updates are waiting for their push")
+		return
+	}
+	version := s.cacheSnapshotVersion()
+	buf := &bytes.Buffer{}
+	if err := snapshotter.WriteSnapshot(buf, version); err != nil {
+		log.Warnf("failed to build xds cache snapshot: %v", err)
+		return
+	}
+	time.Sleep(cacheSnapshotQuietPeriod)
+	if s.InboundUpdates.Load() != inbound {
+		log.Debugf("skipping xds cache snapshot: the state changed while it was built")
+		return
+	}
+	if err := file.AtomicWrite(path, buf.Bytes(), 0o600); err != nil {
+		log.Warnf("failed to write xds cache snapshot %v: %v", path, err)
+		return
+	}
+	log.Debugf("wrote xds cache snapshot %v (%d bytes)", path, buf.Len())
+}
+
+// cacheSnapshotVersion computes a fingerprint of the state the XDS cache is derived from: configuration,
+// services, endpoints and mesh config. Unlike PushVersion, it is stable across restarts, so a snapshot
+// is only restored if nothing changed while istiod was down.
+func (s *DiscoveryServer) cacheSnapshotVersion() string {
+	var lines []string
+
+	if store := s.Env.IstioConfigStore; store != nil {
+		for _, schema := range store.Schemas().All() {
+			gvk := schema.Resource().GroupVersionKind()
+			cfgs, err := store.List(gvk, model.NamespaceAll)
+			if err != nil {
+				// Make sure a failed listing can never match a snapshot.
+				lines = append(lines, "error/"+gvk.String()+"/"+strconv.FormatInt(time.Now().UnixNano(), 10))
+				continue
+			}
+			for _, c := range cfgs {
+				lines = append(lines, "config/"+gvk.String()+"/"+c.Namespace+"/"+c.Name+"/"+c.ResourceVersion)
+			}
+		}
+	}
+
+	push := s.globalPushContext()
+	for _, svc := range push.GetAllServices() {
+		line := fmt.Sprintf("service/%s/%s/%s/%v/%v", svc.Hostname, svc.Attributes.Namespace,
+			svc.DefaultAddress, svc.Resolution, svc.ClusterVIPs.GetAddresses())
+		for _, p := range svc.Ports {
+			line += fmt.Sprintf("/%s:%d:%s", p.Name, p.Port, p.Protocol)
+		}
+		lines = append(lines, line)
+	}
+
+	s.mutex.RLock()
+	for hostname, byNamespace := range s.EndpointShardsByService {
+		for ns, shards := range byNamespace {
+			shards.mutex.RLock()
+			for shard, eps := range shards.Shards {
+				for _, ep := range eps {
+					lines = append(lines, fmt.Sprintf("endpoint/%s/%s/%s/%s:%d/%s/%v/%s/%s/%v", hostname, ns, shard,
+						ep.Address, ep.EndpointPort, ep.ServicePortName, ep.HealthStatus, ep.Network, ep.Locality.Label, ep.Labels))
+				}
+			}
+			shards.mutex.RUnlock()
+		}
+	}
+	s.mutex.RUnlock()
+
+	if mesh := s.Env.Mesh(); mesh != nil {
+		js, _ := gogoprotomarshal.ToJSON(mesh)
+		lines = append(lines, "mesh/"+js)
+	}
+
+	sort.Strings(lines)
+	hash := sha256.New()
+	for _, l := range lines {
+		hash.Write([]byte(l))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
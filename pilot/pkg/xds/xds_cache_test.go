@@ -15,14 +15,18 @@
 package xds
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
 	any "google.golang.org/protobuf/types/known/anypb"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -254,3 +258,168 @@ func TestXdsCache(t *testing.T) {
 		}
 	})
 }
+
+func TestXdsCacheSnapshot(t *testing.T) {
+	ep1 := EndpointBuilder{
+		clusterName: "outbound|1||foo.com",
+		service: &model.Service{
+			Hostname: "foo.com",
+		},
+	}
+	ep2 := EndpointBuilder{
+		clusterName: "outbound|2||foo.com",
+		service: &model.Service{
+			Hostname: "foo.com",
+		},
+	}
+	src := model.NewLenientXdsCache()
+	start := time.Now()
+	src.Add(ep1, &model.PushRequest{Start: start}, any1)
+	src.Add(ep2, &model.PushRequest{Start: start}, any2)
+	buf := &bytes.Buffer{}
+	if err := src.(model.XdsCacheSnapshotter).WriteSnapshot(buf, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	t.Run("restore", func(t *testing.T) {
+		c := model.NewLenientXdsCache()
+		n, err := c.(model.XdsCacheSnapshotter).RestoreSnapshot(bytes.NewReader(snapshot), func() string { return "v1" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Fatalf("expected 2 restored entries, got %d", n)
+		}
+		if got, _ := c.Get(ep1); !proto.Equal(got, any1) {
+			t.Fatalf("unexpected result: %v, want %v", got, any1)
+		}
+		if got, _ := c.Get(ep2); !proto.Equal(got, any2) {
+			t.Fatalf("unexpected result: %v, want %v", got, any2)
+		}
+		// Dependent config indexes must be restored so invalidation still works.
+		c.Clear(map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com"}: {}})
+		if len(c.Keys()) != 0 {
+			t.Fatalf("expected cache to be cleared, got keys %v", c.Keys())
+		}
+	})
+
+	t.Run("version mismatch", func(t *testing.T) {
+		c := model.NewLenientXdsCache()
+		if _, err := c.(model.XdsCacheSnapshotter).RestoreSnapshot(bytes.NewReader(snapshot), func() string { return "v2" }); err == nil {
+			t.Fatalf("expected stale snapshot to be rejected")
+		}
+		if len(c.Keys()) != 0 {
+			t.Fatalf("expected no keys, got %v", c.Keys())
+		}
+	})
+
+	t.Run("existing entries are kept", func(t *testing.T) {
+		c := model.NewLenientXdsCache()
+		c.Add(ep1, &model.PushRequest{Start: time.Now()}, any2)
+		n, err := c.(model.XdsCacheSnapshotter).RestoreSnapshot(bytes.NewReader(snapshot), func() string { return "v1" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("expected 1 restored entry, got %d", n)
+		}
+		if got, _ := c.Get(ep1); got != any2 {
+			t.Fatalf("unexpected result: %v, want %v", got, any2)
+		}
+	})
+}
+
+func TestRunCacheSnapshots(t *testing.T) {
+	poll, quiet := cacheSnapshotPollInterval, cacheSnapshotQuietPeriod
+	cacheSnapshotPollInterval, cacheSnapshotQuietPeriod = time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		cacheSnapshotPollInterval, cacheSnapshotQuietPeriod = poll, quiet
+	})
+	ep := EndpointBuilder{
+		clusterName: "outbound|1||foo.com",
+		service: &model.Service{
+			Hostname: "foo.com",
+		},
+	}
+	newServer := func(t *testing.T, configs string) *DiscoveryServer {
+		return NewFakeDiscoveryServer(t, FakeOptions{
+			ConfigString: configs,
+			DiscoveryServerModifier: func(s *DiscoveryServer) {
+				s.Cache = model.NewLenientXdsCache()
+			},
+		}).Discovery
+	}
+	run := func(t *testing.T, s *DiscoveryServer, path string) {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			s.runCacheSnapshots(path, 10*time.Millisecond, stop)
+			close(done)
+		}()
+		t.Cleanup(func() {
+			close(stop)
+			<-done
+		})
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+	s := newServer(t, "")
+	s.Cache.Add(ep, &model.PushRequest{Start: time.Now()}, any1)
+	// An update waiting for its push means the cache may hold entries that are stale for the current state.
+	s.InboundUpdates.Inc()
+	run(t, s, path)
+	time.Sleep(100 * time.Millisecond)
+	if exists(path) {
+		t.Fatalf("expected no snapshot while an update is pending")
+	}
+	s.CommittedUpdates.Inc()
+	retry.UntilOrFail(t, func() bool { return exists(path) }, retry.Delay(10*time.Millisecond))
+
+	t.Run("restore", func(t *testing.T) {
+		s := newServer(t, "")
+		run(t, s, path)
+		retry.UntilOrFail(t, func() bool {
+			got, f := s.Cache.Get(ep)
+			return f && proto.Equal(got, any1)
+		}, retry.Delay(10*time.Millisecond))
+	})
+
+	t.Run("changed state", func(t *testing.T) {
+		s := newServer(t, `apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: foo
+  namespace: default
+spec:
+  hosts: [foo.com]
+  ports:
+  - number: 1
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`)
+		// Copy the snapshot, so this server does not overwrite it before restoring it.
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed := filepath.Join(t.TempDir(), "snapshot")
+		if err := os.WriteFile(changed, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		run(t, s, changed)
+		// Wait for the snapshot written after the restore attempt.
+		retry.UntilOrFail(t, func() bool {
+			cur, err := os.ReadFile(changed)
+			return err == nil && !bytes.Equal(cur, b)
+		}, retry.Delay(10*time.Millisecond))
+		if _, f := s.Cache.Get(ep); f {
+			t.Fatalf("expected snapshot of a different state not to be restored")
+		}
+	})
+}
@@ -136,6 +136,7 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		}
 		return err
 	}
+	con.recordPushTrace(w.TypeUrl, req, t0, len(res))
 
 	switch {
 	case logdata.Incremental:
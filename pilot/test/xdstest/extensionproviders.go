@@ -0,0 +1,30 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xdstest
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test"
+)
+
+// ExtensionProviders parses the extension providers from the given YAML list.
+func ExtensionProviders(t test.Failer, value string) []*model.ExtensionProvider {
+	t.Helper()
+	providers, err := model.ParseExtensionProviders(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return providers
+}
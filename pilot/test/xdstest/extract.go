@@ -26,6 +26,7 @@ import (
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	udpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
@@ -176,6 +177,22 @@ func ExtractTCPProxy(t test.Failer, fcs *listener.FilterChain) *tcpproxy.TcpProx
 	return nil
 }
 
+// ExtractUDPProxy returns the udp_proxy listener filter of a UDP listener.
+func ExtractUDPProxy(t test.Failer, l *listener.Listener) *udpproxy.UdpProxyConfig {
+	for _, lf := range l.ListenerFilters {
+		if lf.Name == "envoy.filters.udp_listener.udp_proxy" {
+			udpProxy := &udpproxy.UdpProxyConfig{}
+			if lf.GetTypedConfig() != nil {
+				if err := lf.GetTypedConfig().UnmarshalTo(udpProxy); err != nil {
+					t.Fatalf("failed to unmarshal udp proxy: %v", err)
+				}
+			}
+			return udpProxy
+		}
+	}
+	return nil
+}
+
 func ExtractHTTPConnectionManager(t test.Failer, fcs *listener.FilterChain) *hcm.HttpConnectionManager {
 	for _, fc := range fcs.Filters {
 		if fc.Name == wellknown.HTTPConnectionManager {
@@ -110,6 +110,12 @@ func (cfg Config) toTemplateParams() (map[string]interface{}, error) {
 		}
 	}
 
+	// Internal listeners require the bootstrap extension, which Envoy only loads at startup. The node metadata
+	// advertises it to istiod.
+	if cfg.Metadata.InternalListeners {
+		opts = append(opts, option.InternalListeners(true))
+	}
+
 	// Support passing extra info from node environment as metadata
 	opts = append(opts, getNodeMetadataOptions(cfg.Node)...)
 
@@ -550,6 +556,7 @@ func GetNodeMetaData(options MetadataOptions) (*model.Node, error) {
 	}
 	meta.EnvoyStatusPort = options.EnvoyStatusPort
 	meta.EnvoyPrometheusPort = options.EnvoyPrometheusPort
+	meta.InternalListeners = model.StringBool(features.EnableInternalListeners)
 
 	meta.ProxyConfig = (*model.NodeMetaProxyConfig)(options.ProxyConfig)
 
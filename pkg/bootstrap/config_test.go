@@ -24,6 +24,7 @@ import (
 	"k8s.io/kubectl/pkg/util/fieldpath"
 
 	"istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/util/protomarshal"
@@ -103,6 +104,21 @@ func TestGetNodeMetaData(t *testing.T) {
 	g.Expect(node.RawMetadata["WORKLOAD_NAME"]).To(Equal(expectWorkloadName))
 }
 
+func TestGetNodeMetaDataInternalListeners(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		old := features.EnableInternalListeners
+		features.EnableInternalListeners = enabled
+		node, err := GetNodeMetaData(MetadataOptions{ID: "test", ProxyConfig: &v1alpha1.ProxyConfig{}})
+		features.EnableInternalListeners = old
+
+		g := NewWithT(t)
+		g.Expect(err).Should(BeNil())
+		g.Expect(bool(node.Metadata.InternalListeners)).To(Equal(enabled))
+		_, found := ConvertNodeToXDSNode(node).GetMetadata().GetFields()["INTERNAL_LISTENERS"]
+		g.Expect(found).To(Equal(enabled))
+	}
+}
+
 func TestConvertNodeMetadata(t *testing.T) {
 	node := &model.Node{
 		ID: "test",
@@ -240,6 +240,10 @@ func STSEnabled(value bool) Instance {
 	return newOption("sts", value)
 }
 
+func InternalListeners(value bool) Instance {
+	return newOptionOrSkipIfZero("internal_listeners", value)
+}
+
 func ProvCert(value string) Instance {
 	return newOption("provisioned_cert", value)
 }
@@ -651,6 +651,12 @@ func TestOptions(t *testing.T) {
 			option:   option.GCPProjectID("project"),
 			expected: "project",
 		},
+		{
+			testName: "internal listeners",
+			key:      "internal_listeners",
+			option:   option.InternalListeners(true),
+			expected: true,
+		},
 		{
 			testName: "tracing tls nil",
 			key:      "tracing_tls",
@@ -0,0 +1,244 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accesslogging defines the access logging options of Telemetry resources: sampling, JSON format
+// presets and overrides for the gateway, sidecar inbound and sidecar outbound listeners.
+package accesslogging
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotation sets, on a Telemetry resource, the access logging options of the workloads it applies to. The
+// value is a YAML or JSON Config, for example:
+//
+//	telemetry.istio.io/accessLogging: |
+//	  sampling:
+//	    percentage: 10
+//	  format: ecs
+//	  listenerClasses:
+//	    gateway:
+//	      sampling:
+//	        percentage: 100
+//	    sidecarInbound:
+//	      filter:
+//	        expression: response.code >= 500
+//
+// The options of a workload Telemetry override the ones of the namespace Telemetry, which override the ones
+// of the root namespace Telemetry. Sampling with ratePerSecond only applies to the HTTP requests: a proxy logs
+// at most that many requests per second for each class of listeners, and the TCP connections are all logged.
+const Annotation = "telemetry.istio.io/accessLogging"
+
+// ListenerClass is a class of listeners whose access logs can be configured separately.
+type ListenerClass string
+
+const (
+	// Gateway are the listeners of gateways.
+	Gateway ListenerClass = "gateway"
+	// SidecarInbound are the inbound listeners of sidecars.
+	SidecarInbound ListenerClass = "sidecarInbound"
+	// SidecarOutbound are the outbound listeners of sidecars.
+	SidecarOutbound ListenerClass = "sidecarOutbound"
+)
+
+// JSON format presets of the file access logs.
+const (
+	// FormatIstio is the default JSON format of Istio.
+	FormatIstio = "istio"
+	// FormatECS follows the Elastic Common Schema.
+	FormatECS = "ecs"
+	// FormatOpenTelemetry follows the OpenTelemetry log data model and its HTTP semantic conventions.
+	FormatOpenTelemetry = "opentelemetry"
+)
+
+var formats = map[string]bool{
+	FormatIstio:         true,
+	FormatECS:           true,
+	FormatOpenTelemetry: true,
+}
+
+// Config are the access logging options of a Telemetry.
+type Config struct {
+	// Sampling samples the access logs. All the entries are logged if it is not set.
+	Sampling *Sampling `json:"sampling,omitempty"`
+	// Format is the name of the JSON format preset used by the file access logs, instead of their own format.
+	Format string `json:"format,omitempty"`
+	// ListenerClasses override the options for some classes of listeners.
+	ListenerClasses map[ListenerClass]*Settings `json:"listenerClasses,omitempty"`
+}
+
+// Settings are the access logging options of a class of listeners.
+type Settings struct {
+	// Sampling overrides the sampling of the Config.
+	Sampling *Sampling `json:"sampling,omitempty"`
+	// Format overrides the format of the Config.
+	Format string `json:"format,omitempty"`
+	// Filter overrides the filter of the Telemetry access logging.
+	Filter *Filter `json:"filter,omitempty"`
+}
+
+// Bounds of the ratePerSecond of the Sampling.
+const (
+	MinRatePerSecond = 0.001
+	MaxRatePerSecond = 1000000
+)
+
+// Sampling selects the entries logged, either a percentage of them or up to a rate.
+type Sampling struct {
+	// Percentage is the percentage of the entries logged, from 0 to 100. For HTTP requests, the decision is
+	// based on the request ID so that the proxies on the path of a request log it consistently.
+	Percentage *float64 `json:"percentage,omitempty"`
+	// RatePerSecond is the number of HTTP requests logged per second by a proxy across the listeners of the
+	// class, the others are not logged. Rates above 1 are rounded to a whole number of requests per second.
+	RatePerSecond *float64 `json:"ratePerSecond,omitempty"`
+}
+
+// Filter selects the entries logged.
+type Filter struct {
+	// Expression is a CEL expression selecting the entries logged.
+	Expression string `json:"expression"`
+}
+
+// Parse parses the value of the Annotation.
+func Parse(value string) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict([]byte(value), cfg); err != nil {
+		return nil, fmt.Errorf("invalid access logging options: %v", err)
+	}
+	return cfg, nil
+}
+
+// FromAnnotations returns the options set with the Annotation, or nil if it is not set.
+func FromAnnotations(annotations map[string]string) (*Config, error) {
+	value, f := annotations[Annotation]
+	if !f {
+		return nil, nil
+	}
+	return Parse(value)
+}
+
+// Validate checks the options are well formed.
+func (c *Config) Validate() (errs error) {
+	if c == nil {
+		return nil
+	}
+	errs = validateSettings(errs, "", Settings{Sampling: c.Sampling, Format: c.Format})
+	for class, s := range c.ListenerClasses {
+		switch class {
+		case Gateway, SidecarInbound, SidecarOutbound:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("unknown listener class %q, expected one of %s, %s or %s",
+				class, Gateway, SidecarInbound, SidecarOutbound))
+			continue
+		}
+		if s != nil {
+			errs = validateSettings(errs, class, *s)
+		}
+	}
+	return
+}
+
+func validateSettings(errs error, class ListenerClass, s Settings) error {
+	prefix := ""
+	if class != "" {
+		prefix = fmt.Sprintf("listener class %s: ", class)
+	}
+	if s.Sampling != nil {
+		switch {
+		case (s.Sampling.Percentage == nil) == (s.Sampling.RatePerSecond == nil):
+			errs = multierror.Append(errs, fmt.Errorf("%ssampling must set one of percentage or ratePerSecond", prefix))
+		case s.Sampling.Percentage != nil && (*s.Sampling.Percentage < 0 || *s.Sampling.Percentage > 100):
+			errs = multierror.Append(errs, fmt.Errorf("%ssampling percentage %v must be in the range [0, 100]",
+				prefix, *s.Sampling.Percentage))
+		case s.Sampling.RatePerSecond != nil &&
+			!(*s.Sampling.RatePerSecond >= MinRatePerSecond && *s.Sampling.RatePerSecond <= MaxRatePerSecond):
+			errs = multierror.Append(errs, fmt.Errorf("%ssampling ratePerSecond %v must be in the range [%v, %v]",
+				prefix, *s.Sampling.RatePerSecond, MinRatePerSecond, MaxRatePerSecond))
+		}
+	}
+	if s.Format != "" && !formats[s.Format] {
+		errs = multierror.Append(errs, fmt.Errorf("%sunknown format %q, expected one of %s, %s or %s",
+			prefix, s.Format, FormatIstio, FormatECS, FormatOpenTelemetry))
+	}
+	if s.Filter != nil && s.Filter.Expression == "" {
+		errs = multierror.Append(errs, fmt.Errorf("%sfilter expression must not be empty", prefix))
+	}
+	return errs
+}
+
+// Merge returns the options of parent overridden by the ones set in child. The options are not modified.
+func Merge(parent, child *Config) *Config {
+	if parent == nil {
+		return child
+	}
+	if child == nil {
+		return parent
+	}
+	out := &Config{
+		Sampling:        parent.Sampling,
+		Format:          parent.Format,
+		ListenerClasses: map[ListenerClass]*Settings{},
+	}
+	if child.Sampling != nil {
+		out.Sampling = child.Sampling
+	}
+	if child.Format != "" {
+		out.Format = child.Format
+	}
+	for class, s := range parent.ListenerClasses {
+		out.ListenerClasses[class] = s
+	}
+	for class, s := range child.ListenerClasses {
+		if s == nil {
+			continue
+		}
+		merged := Settings{}
+		if p := out.ListenerClasses[class]; p != nil {
+			merged = *p
+		}
+		if s.Sampling != nil {
+			merged.Sampling = s.Sampling
+		}
+		if s.Format != "" {
+			merged.Format = s.Format
+		}
+		if s.Filter != nil {
+			merged.Filter = s.Filter
+		}
+		out.ListenerClasses[class] = &merged
+	}
+	return out
+}
+
+// ForClass returns the options applying to the given class of listeners. Its Filter is nil if the filter of
+// the Telemetry access logging applies.
+func (c *Config) ForClass(class ListenerClass) Settings {
+	if c == nil {
+		return Settings{}
+	}
+	out := Settings{Sampling: c.Sampling, Format: c.Format}
+	if s := c.ListenerClasses[class]; s != nil {
+		if s.Sampling != nil {
+			out.Sampling = s.Sampling
+		}
+		if s.Format != "" {
+			out.Format = s.Format
+		}
+		out.Filter = s.Filter
+	}
+	return out
+}
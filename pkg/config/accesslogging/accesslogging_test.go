@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslogging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{
+			name: "valid",
+			value: `
+sampling:
+  percentage: 10
+format: ecs
+listenerClasses:
+  gateway:
+    sampling:
+      percentage: 100
+  sidecarInbound:
+    filter:
+      expression: response.code >= 500
+`,
+		},
+		{
+			name:  "unknown field",
+			value: `{sampling: {ratio: 0.1}}`,
+			err:   "invalid access logging options",
+		},
+		{
+			name:  "rate per second",
+			value: `{listenerClasses: {gateway: {sampling: {ratePerSecond: 10}}}}`,
+		},
+		{
+			name:  "missing percentage",
+			value: `{sampling: {}}`,
+			err:   "sampling must set one of percentage or ratePerSecond",
+		},
+		{
+			name:  "percentage and rate per second",
+			value: `{sampling: {percentage: 10, ratePerSecond: 10}}`,
+			err:   "sampling must set one of percentage or ratePerSecond",
+		},
+		{
+			name:  "zero rate per second",
+			value: `{listenerClasses: {gateway: {sampling: {ratePerSecond: 0}}}}`,
+			err:   "listener class gateway: sampling ratePerSecond 0 must be in the range [0.001, 1000000]",
+		},
+		{
+			name:  "percentage out of range",
+			value: `{listenerClasses: {gateway: {sampling: {percentage: -1}}}}`,
+			err:   "listener class gateway: sampling percentage -1 must be in the range [0, 100]",
+		},
+		{
+			name:  "unknown format",
+			value: `{format: logfmt}`,
+			err:   "unknown format",
+		},
+		{
+			name:  "unknown listener class",
+			value: `{listenerClasses: {waypoint: {format: ecs}}}`,
+			err:   "unknown listener class",
+		},
+		{
+			name:  "empty filter",
+			value: `{listenerClasses: {sidecarOutbound: {filter: {}}}}`,
+			err:   "filter expression must not be empty",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(tt.value)
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected valid options, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	parent, err := Parse(`
+sampling: {percentage: 10}
+format: ecs
+listenerClasses:
+  gateway: {sampling: {percentage: 100}}
+  sidecarInbound: {filter: {expression: response.code >= 500}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := Parse(`
+format: opentelemetry
+listenerClasses:
+  sidecarInbound: {sampling: {percentage: 50}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := Merge(parent, child)
+
+	gateway := merged.ForClass(Gateway)
+	if *gateway.Sampling.Percentage != 100 || gateway.Format != FormatOpenTelemetry || gateway.Filter != nil {
+		t.Fatalf("unexpected gateway options %+v", gateway)
+	}
+	inbound := merged.ForClass(SidecarInbound)
+	if *inbound.Sampling.Percentage != 50 || inbound.Filter == nil || inbound.Filter.Expression != "response.code >= 500" {
+		t.Fatalf("expected the inbound options to be merged, got %+v", inbound)
+	}
+	outbound := merged.ForClass(SidecarOutbound)
+	if *outbound.Sampling.Percentage != 10 || outbound.Filter != nil {
+		t.Fatalf("unexpected outbound options %+v", outbound)
+	}
+	if parent.Format != FormatECS || parent.ListenerClasses[SidecarInbound].Sampling != nil {
+		t.Fatalf("expected the parent options not to be modified")
+	}
+	if got := (*Config)(nil).ForClass(Gateway); got != (Settings{}) {
+		t.Fatalf("expected no options, got %+v", got)
+	}
+}
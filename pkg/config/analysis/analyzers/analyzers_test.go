@@ -78,6 +78,7 @@ var testGrid = []testCase{
 			{msg.UnknownAnnotation, "Service httpbin"},
 			{msg.InvalidAnnotation, "Pod invalid-annotations"},
 			{msg.MisplacedAnnotation, "Pod grafana-test"},
+			{msg.MisplacedAnnotation, "Pod rate-limit"},
 			{msg.MisplacedAnnotation, "Deployment fortio-deploy"},
 			{msg.MisplacedAnnotation, "Namespace staging"},
 			{msg.DeprecatedAnnotation, "Deployment fortio-deploy"},
@@ -93,6 +94,7 @@ var testGrid = []testCase{
 			{msg.AlphaAnnotation, "Deployment fortio-deploy"},
 			{msg.AlphaAnnotation, "Pod invalid-annotations"},
 			{msg.AlphaAnnotation, "Pod invalid-annotations"},
+			{msg.AlphaAnnotation, "Pod rate-limit"},
 			{msg.AlphaAnnotation, "Service httpbin"},
 		},
 		skipAll: true,
@@ -330,6 +332,15 @@ var testGrid = []testCase{
 			{msg.JwtClaimBasedRoutingWithoutRequestAuthN, "VirtualService foo"},
 		},
 	},
+	{
+		name:       "virtualServiceRateLimit",
+		inputFiles: []string{"testdata/virtualservice_ratelimit.yaml"},
+		analyzer:   &virtualservice.RateLimitAnalyzer{},
+		expected: []message{
+			{msg.InvalidAnnotation, "VirtualService invalid"},
+			{msg.VirtualServiceRateLimitRouteNotFound, "VirtualService unknown-route"},
+		},
+	},
 	{
 		name:       "serviceMultipleDeployments",
 		inputFiles: []string{"testdata/deployment-multi-service.yaml"},
@@ -21,6 +21,7 @@ import (
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/util"
 	"istio.io/istio/pkg/config/analysis/msg"
+	configannotations "istio.io/istio/pkg/config/annotations"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -112,6 +113,13 @@ outer:
 		}
 
 		attachesTo := resourceTypesAsStrings(annotationDef.Resources)
+		validationFunction := inject.AnnotationValidation[ann]
+		if a := configannotations.Lookup(ann); a != nil {
+			for _, k := range a.Kinds {
+				attachesTo = append(attachesTo, k.Kind)
+			}
+			validationFunction = a.Validate
+		}
 		if !contains(attachesTo, kind) {
 			m := msg.NewMisplacedAnnotation(r, ann, strings.Join(attachesTo, ", "))
 			util.AddLineNumber(r, ann, m)
@@ -120,7 +128,6 @@ outer:
 			continue
 		}
 
-		validationFunction := inject.AnnotationValidation[ann]
 		if validationFunction != nil {
 			if err := validationFunction(value); err != nil {
 				m := msg.NewInvalidAnnotation(r, ann, err.Error())
@@ -168,6 +175,9 @@ func lookupAnnotation(ann string) *annotation.Instance {
 			return candidate
 		}
 	}
+	if a := configannotations.Lookup(ann); a != nil {
+		return &a.Instance
+	}
 
 	return nil
 }
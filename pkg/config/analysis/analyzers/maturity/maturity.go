@@ -21,6 +21,7 @@ import (
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/util"
 	"istio.io/istio/pkg/config/analysis/msg"
+	configannotations "istio.io/istio/pkg/config/annotations"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -120,6 +121,9 @@ func lookupAnnotation(ann string) *annotation.Instance {
 			return candidate
 		}
 	}
+	if a := configannotations.Lookup(ann); a != nil {
+		return &a.Instance
+	}
 
 	return nil
 }
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualservice
+
+import (
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/ratelimit"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// RateLimitAnalyzer checks the rate limit policies of virtual services
+type RateLimitAnalyzer struct{}
+
+var _ analysis.Analyzer = &RateLimitAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *RateLimitAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "virtualservice.RateLimitAnalyzer",
+		Description: "Checks the rate limit policies of virtual services",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *RateLimitAnalyzer) Analyze(ctx analysis.Context) {
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), func(r *resource.Instance) bool {
+		a.analyzeVirtualService(r, ctx)
+		return true
+	})
+}
+
+func (a *RateLimitAnalyzer) analyzeVirtualService(r *resource.Instance, ctx analysis.Context) {
+	p, err := ratelimit.FromAnnotations(r.Metadata.Annotations)
+	if err == nil && p != nil {
+		err = p.Validate()
+	}
+	if err != nil {
+		m := msg.NewInvalidAnnotation(r, ratelimit.Annotation, err.Error())
+		util.AddLineNumber(r, ratelimit.Annotation, m)
+		ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
+		return
+	}
+	if p == nil {
+		return
+	}
+
+	vs := r.Message.(*v1alpha3.VirtualService)
+	routes := map[string]struct{}{}
+	for _, route := range vs.GetHttp() {
+		routes[route.GetName()] = struct{}{}
+	}
+	for _, route := range p.Routes {
+		if _, f := routes[route]; !f {
+			m := msg.NewVirtualServiceRateLimitRouteNotFound(r, route)
+			util.AddLineNumber(r, ratelimit.Annotation, m)
+			ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
+		}
+	}
+}
@@ -196,6 +196,10 @@ var (
 	// ExternalNameServiceTypeInvalidPortName defines a diag.MessageType for message "ExternalNameServiceTypeInvalidPortName".
 	// Description: Proxy may prevent tcp named ports and unmatched traffic for ports serving TCP protocol from being forwarded correctly for ExternalName services.
 	ExternalNameServiceTypeInvalidPortName = diag.NewMessageType(diag.Warning, "IST0150", "Port name for ExternalName service is invalid. Proxy may prevent tcp named ports and unmatched traffic for ports serving TCP protocol from being forwarded correctly")
+
+	// VirtualServiceRateLimitRouteNotFound defines a diag.MessageType for message "VirtualServiceRateLimitRouteNotFound".
+	// Description: The rate limit policy of a virtual service applies to a route it does not define.
+	VirtualServiceRateLimitRouteNotFound = diag.NewMessageType(diag.Warning, "IST0151", "The rate limit policy applies to the HTTP route %s, which is not defined in the virtual service.")
 )
 
 // All returns a list of all known message types.
@@ -248,6 +252,7 @@ func All() []*diag.MessageType {
 		NamespaceInjectionEnabledByDefault,
 		JwtClaimBasedRoutingWithoutRequestAuthN,
 		ExternalNameServiceTypeInvalidPortName,
+		VirtualServiceRateLimitRouteNotFound,
 	}
 }
 
@@ -719,3 +724,12 @@ func NewExternalNameServiceTypeInvalidPortName(r *resource.Instance) diag.Messag
 		r,
 	)
 }
+
+// NewVirtualServiceRateLimitRouteNotFound returns a new diag.Message based on VirtualServiceRateLimitRouteNotFound.
+func NewVirtualServiceRateLimitRouteNotFound(r *resource.Instance, route string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceRateLimitRouteNotFound,
+		r,
+		route,
+	)
+}
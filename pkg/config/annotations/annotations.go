@@ -0,0 +1,256 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package annotations registers the annotations configuring the features the Istio APIs do not expose yet.
+// They are described like the annotations of istio.io/api, and are all validated through Validate, which is
+// called by the config validation of the resources they apply to.
+package annotations
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/accesslogging"
+	"istio.io/istio/pkg/config/certprovider"
+	"istio.io/istio/pkg/config/extproc"
+	"istio.io/istio/pkg/config/loadbalancing"
+	"istio.io/istio/pkg/config/ratelimit"
+	"istio.io/istio/pkg/config/redisroute"
+	"istio.io/istio/pkg/config/rpcroute"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/tracesampling"
+)
+
+// Instance is an annotation registered by Istio outside of istio.io/api.
+type Instance struct {
+	annotation.Instance
+	// Kinds are the Istio config kinds the annotation applies to, in addition to the Kubernetes resources.
+	Kinds []config.GroupVersionKind
+	// Validate checks the value of the annotation, if set.
+	Validate func(value string) error
+}
+
+var (
+	RateLimit = register(&Instance{
+		Instance: annotation.Instance{
+			Name: ratelimit.Annotation,
+			Description: "Sets the rate limit policy of the HTTP routes of a VirtualService. The value is a YAML " +
+				"or JSON rate limit policy. Requires PILOT_ENABLE_RATE_LIMIT. Global limits use an envoyRateLimit " +
+				"provider of PILOT_EXTENSION_PROVIDERS, which istiod only reads when it starts.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.VirtualService},
+		Validate: func(value string) error {
+			p, err := ratelimit.Parse(value)
+			if err != nil {
+				return err
+			}
+			return p.Validate()
+		},
+	})
+
+	RPCMethodRoutes = register(&Instance{
+		Instance: annotation.Instance{
+			Name: rpcroute.MethodRoutesAnnotation,
+			Description: "Routes, on a VirtualService routing a Dubbo or Thrift port, the calls of service methods to " +
+				"subsets of the first destination. The value is a comma separated list of service/method=subset " +
+				"pairs, where the service or the method may be *. Requires PILOT_ENABLE_DUBBO_FILTER or " +
+				"PILOT_ENABLE_THRIFT_FILTER.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.VirtualService},
+		Validate: func(value string) error {
+			_, err := rpcroute.ParseMethodRoutes(value)
+			return err
+		},
+	})
+
+	ExtProcRoutes = register(&Instance{
+		Instance: annotation.Instance{
+			Name: extproc.RouteAnnotation,
+			Description: "Overrides, on a VirtualService bound to gateways, how the external processors attached " +
+				"to the gateways process its HTTP routes. The value is a YAML or JSON list of route overrides.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.VirtualService},
+		Validate: func(value string) error {
+			overrides, err := extproc.ParseRouteOverrides(value)
+			if err != nil {
+				return err
+			}
+			return extproc.ValidateRouteOverrides(overrides)
+		},
+	})
+
+	RedisKeyPrefixRoutes = register(&Instance{
+		Instance: annotation.Instance{
+			Name: redisroute.KeyPrefixRoutesAnnotation,
+			Description: "Lists, on a VirtualService routing a Redis port, the key prefixes routed to a subset of " +
+				"the destination, as comma separated prefix=subset pairs.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.VirtualService},
+		Validate: func(value string) error {
+			_, err := redisroute.ParseKeyPrefixRoutes(value)
+			return err
+		},
+	})
+
+	RedisMirrors = register(&Instance{
+		Instance: annotation.Instance{
+			Name: redisroute.MirrorsAnnotation,
+			Description: "Lists, on a VirtualService routing a Redis port, the destinations receiving a copy of " +
+				"the requests, as comma separated host[/subset]=percent pairs.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.VirtualService},
+		Validate: func(value string) error {
+			_, err := redisroute.ParseMirrors(value)
+			return err
+		},
+	})
+
+	GRPCCertificateProvider = register(&Instance{
+		Instance: annotation.Instance{
+			Name: certprovider.Annotation,
+			Description: "Names, on a DestinationRule, the certificate provider of the gRPC bootstrap used by " +
+				"proxyless gRPC clients for its SIMPLE and MUTUAL TLS settings, instead of the provider serving " +
+				"the workload certificates. Use it to validate servers with a CA other than the mesh CA.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.DestinationRule},
+		Validate: func(value string) error {
+			_, err := certprovider.Parse(value)
+			return err
+		},
+	})
+
+	ActiveRequestBias = register(&Instance{
+		Instance: annotation.Instance{
+			Name: loadbalancing.ActiveRequestBiasAnnotation,
+			Description: "Sets, on a DestinationRule, the active request bias of its LEAST_REQUEST clusters, " +
+				"overriding PILOT_LEAST_REQUEST_ACTIVE_REQUEST_BIAS. The value is a non-negative number.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.DestinationRule},
+		Validate: func(value string) error {
+			_, err := loadbalancing.ParseActiveRequestBias(value)
+			return err
+		},
+	})
+
+	TracingSamplingRules = register(&Instance{
+		Instance: annotation.Instance{
+			Name: tracesampling.Annotation,
+			Description: "Sets, on a Telemetry, the trace sampling rules of the workloads it applies to. The value " +
+				"is a YAML or JSON list of at most 10 rules. The source principal of a rule only matches the inbound " +
+				"requests received with Istio mTLS.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.Telemetry},
+		Validate: func(value string) error {
+			rules, err := tracesampling.Parse(value)
+			if err != nil {
+				return err
+			}
+			return tracesampling.Validate(rules)
+		},
+	})
+
+	AccessLogging = register(&Instance{
+		Instance: annotation.Instance{
+			Name: accesslogging.Annotation,
+			Description: "Sets, on a Telemetry, the access logging options of the workloads it applies to. The " +
+				"value is YAML or JSON access logging options. Sampling is set as a percentage, or as a " +
+				"ratePerSecond that only applies to the HTTP requests.",
+			FeatureStatus: annotation.Alpha,
+		},
+		Kinds: []config.GroupVersionKind{gvk.Telemetry},
+		Validate: func(value string) error {
+			cfg, err := accesslogging.Parse(value)
+			if err != nil {
+				return err
+			}
+			return cfg.Validate()
+		},
+	})
+)
+
+var registry = map[string]*Instance{}
+
+func register(a *Instance) *Instance {
+	if _, f := registry[a.Name]; f {
+		panic(fmt.Sprintf("annotation %s registered twice", a.Name))
+	}
+	registry[a.Name] = a
+	return a
+}
+
+// All returns the registered annotations, sorted by name.
+func All() []*Instance {
+	out := make([]*Instance, 0, len(registry))
+	for _, a := range registry {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Lookup returns the registered annotation with the given name, or nil if there is none.
+func Lookup(name string) *Instance {
+	return registry[name]
+}
+
+// AppliesTo checks if the annotation can be set on configs of the given kind.
+func (a *Instance) AppliesTo(kind config.GroupVersionKind) bool {
+	for _, k := range a.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the registered annotations of a config of the given kind: they must apply to the kind, and
+// have a valid value. The other annotations are ignored.
+func Validate(kind config.GroupVersionKind, annotations map[string]string) (errs error) {
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := registry[name]
+		if a == nil {
+			continue
+		}
+		if !a.AppliesTo(kind) {
+			errs = multierror.Append(errs, fmt.Errorf("annotation %s does not apply to %s", name, kind.Kind))
+			continue
+		}
+		if a.Validate == nil {
+			continue
+		}
+		if err := a.Validate(annotations[name]); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid annotation %s: %v", name, err))
+		}
+	}
+	return
+}
@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		kind        config.GroupVersionKind
+		annotations map[string]string
+		err         string
+	}{
+		{
+			name:        "unregistered",
+			kind:        gvk.VirtualService,
+			annotations: map[string]string{"networking.istio.io/unknown": "foo"},
+		},
+		{
+			name:        "valid",
+			kind:        gvk.VirtualService,
+			annotations: map[string]string{RateLimit.Name: "{local: {maxTokens: 10, fillInterval: 1s}}"},
+		},
+		{
+			name:        "invalid",
+			kind:        gvk.VirtualService,
+			annotations: map[string]string{RateLimit.Name: "{routes: [a]}"},
+			err:         "invalid annotation networking.istio.io/rateLimit",
+		},
+		{
+			name:        "invalid rpc method routes",
+			kind:        gvk.VirtualService,
+			annotations: map[string]string{RPCMethodRoutes.Name: "svc/get=v2,svc/get=v3"},
+			err:         "duplicate rpc method route",
+		},
+		{
+			name:        "invalid redis mirror",
+			kind:        gvk.VirtualService,
+			annotations: map[string]string{RedisMirrors.Name: "shadow.bar=0"},
+			err:         "percent must be between 1 and 100",
+		},
+		{
+			name:        "invalid grpc certificate provider",
+			kind:        gvk.DestinationRule,
+			annotations: map[string]string{GRPCCertificateProvider.Name: "external ca"},
+			err:         "invalid certificate provider",
+		},
+		{
+			name:        "invalid active request bias",
+			kind:        gvk.DestinationRule,
+			annotations: map[string]string{ActiveRequestBias.Name: "-1"},
+			err:         "must be a non-negative number",
+		},
+		{
+			name:        "misplaced",
+			kind:        gvk.DestinationRule,
+			annotations: map[string]string{RateLimit.Name: "{local: {maxTokens: 10, fillInterval: 1s}}"},
+			err:         "annotation networking.istio.io/rateLimit does not apply to DestinationRule",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.kind, tt.annotations)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	all := All()
+	for i, a := range all {
+		if Lookup(a.Name) != a {
+			t.Errorf("expected %s to be registered", a.Name)
+		}
+		if a.Description == "" {
+			t.Errorf("expected %s to be documented", a.Name)
+		}
+		if i > 0 && all[i-1].Name >= a.Name {
+			t.Errorf("expected annotations sorted by name, got %s before %s", all[i-1].Name, a.Name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certprovider defines how a DestinationRule selects the certificate provider proxyless gRPC clients
+// use for its SIMPLE and MUTUAL TLS settings.
+package certprovider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Annotation names, on a DestinationRule, the certificate provider instance of the gRPC bootstrap used by
+// proxyless gRPC clients for its SIMPLE and MUTUAL TLS settings. gRPC clients only read certificates from the
+// providers of their bootstrap, so the credentialName and certificate files of the TLS settings do not apply
+// to them. The provider supplies the root certificate validating the server, and for MUTUAL the client
+// certificate. Without the annotation, the provider serving the workload certificates is used, which only
+// validates servers with a certificate issued by the mesh CA.
+const Annotation = "networking.istio.io/grpcCertificateProvider"
+
+// FromAnnotations returns the certificate provider set with the Annotation, or def if it is not set or
+// invalid.
+func FromAnnotations(annotations map[string]string, def string) string {
+	value, f := annotations[Annotation]
+	if !f {
+		return def
+	}
+	provider, err := Parse(value)
+	if err != nil {
+		return def
+	}
+	return provider
+}
+
+// Parse parses the value of the Annotation.
+func Parse(value string) (string, error) {
+	if value == "" || strings.ContainsAny(value, " \t\n") {
+		return "", fmt.Errorf("invalid certificate provider %q: must be a non-empty name without spaces", value)
+	}
+	return value, nil
+}
@@ -0,0 +1,45 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certprovider
+
+import (
+	"testing"
+)
+
+func TestFromAnnotations(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		valid       bool
+	}{
+		{"unset", nil, "default", true},
+		{"set", map[string]string{Annotation: "external-ca"}, "external-ca", true},
+		{"empty", map[string]string{Annotation: ""}, "default", false},
+		{"spaces", map[string]string{Annotation: "external ca"}, "default", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromAnnotations(tt.annotations, "default"); got != tt.want {
+				t.Errorf("expected provider %q, got %q", tt.want, got)
+			}
+			if value, f := tt.annotations[Annotation]; f {
+				if _, err := Parse(value); (err == nil) != tt.valid {
+					t.Errorf("expected valid %v, got %v", tt.valid, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package extproc defines how the external processors of envoyExtProc extension providers process the HTTP
+// routes of a VirtualService.
+package extproc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+)
+
+// RouteAnnotation overrides, on a VirtualService bound to gateways, how the external processors attached to the
+// gateways process its HTTP routes. The value is a YAML or JSON list of RouteOverride, for example:
+//
+//	networking.istio.io/extProcRoutes: |
+//	  - provider: transform
+//	    routes: [health]
+//	    disabled: true
+//	  - provider: transform
+//	    routes: [upload]
+//	    processingMode:
+//	      requestBody: STREAMED
+const RouteAnnotation = "networking.istio.io/extProcRoutes"
+
+var (
+	headerModes = []string{"DEFAULT", "SEND", "SKIP"}
+	bodyModes   = []string{"NONE", "STREAMED", "BUFFERED", "BUFFERED_PARTIAL"}
+)
+
+// ProcessingMode selects the parts of the requests and responses sent to the external processor. The header
+// and trailer modes are DEFAULT, SEND or SKIP, and the body modes NONE, STREAMED, BUFFERED or
+// BUFFERED_PARTIAL. Unset modes use the Envoy defaults: headers are sent, bodies and trailers are not.
+type ProcessingMode struct {
+	RequestHeaders   string `json:"requestHeaders,omitempty"`
+	ResponseHeaders  string `json:"responseHeaders,omitempty"`
+	RequestBody      string `json:"requestBody,omitempty"`
+	ResponseBody     string `json:"responseBody,omitempty"`
+	RequestTrailers  string `json:"requestTrailers,omitempty"`
+	ResponseTrailers string `json:"responseTrailers,omitempty"`
+}
+
+// RouteOverride overrides how an external processor processes some HTTP routes.
+type RouteOverride struct {
+	// Provider is the name of the envoyExtProc extension provider.
+	Provider string `json:"provider"`
+	// Routes are the names of the HTTP routes the override applies to. If empty, it applies to all of them.
+	Routes []string `json:"routes,omitempty"`
+	// Disabled skips the external processor for the routes.
+	Disabled bool `json:"disabled,omitempty"`
+	// ProcessingMode replaces the processing mode of the provider for the routes.
+	ProcessingMode *ProcessingMode `json:"processingMode,omitempty"`
+}
+
+// ParseRouteOverrides parses the value of the RouteAnnotation.
+func ParseRouteOverrides(value string) ([]RouteOverride, error) {
+	var overrides []RouteOverride
+	if err := yaml.UnmarshalStrict([]byte(value), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid ext_proc route overrides: %v", err)
+	}
+	return overrides, nil
+}
+
+// RouteOverridesFromAnnotations returns the overrides set with the RouteAnnotation, or nil if it is not set.
+func RouteOverridesFromAnnotations(annotations map[string]string) ([]RouteOverride, error) {
+	value, f := annotations[RouteAnnotation]
+	if !f {
+		return nil, nil
+	}
+	return ParseRouteOverrides(value)
+}
+
+// ValidateRouteOverrides checks the overrides are well formed.
+func ValidateRouteOverrides(overrides []RouteOverride) (errs error) {
+	for _, o := range overrides {
+		if o.Provider == "" {
+			errs = multierror.Append(errs, errors.New("ext_proc route override must set a provider"))
+		}
+		if o.Disabled == (o.ProcessingMode != nil) {
+			errs = multierror.Append(errs, fmt.Errorf("ext_proc route override of provider %s must set one of disabled or processingMode",
+				o.Provider))
+		}
+		if err := o.ProcessingMode.Validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return
+}
+
+// AppliesTo checks if the override applies to the HTTP route with the given name.
+func (o RouteOverride) AppliesTo(route string) bool {
+	if len(o.Routes) == 0 {
+		return true
+	}
+	for _, r := range o.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the modes are known.
+func (m *ProcessingMode) Validate() (errs error) {
+	if m == nil {
+		return nil
+	}
+	for _, h := range []struct{ name, mode string }{
+		{"requestHeaders", m.RequestHeaders},
+		{"responseHeaders", m.ResponseHeaders},
+		{"requestTrailers", m.RequestTrailers},
+		{"responseTrailers", m.ResponseTrailers},
+	} {
+		if h.mode != "" && !contains(headerModes, h.mode) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid %s mode %q, expected one of %v", h.name, h.mode, headerModes))
+		}
+	}
+	for _, b := range []struct{ name, mode string }{
+		{"requestBody", m.RequestBody},
+		{"responseBody", m.ResponseBody},
+	} {
+		if b.mode != "" && !contains(bodyModes, b.mode) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid %s mode %q, expected one of %v", b.name, b.mode, bodyModes))
+		}
+	}
+	return
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteOverrides(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{
+			name: "valid",
+			value: `
+- provider: transform
+  routes: [health]
+  disabled: true
+- provider: transform
+  routes: [upload]
+  processingMode:
+    requestBody: STREAMED
+    responseHeaders: SKIP
+`,
+		},
+		{
+			name:  "unknown field",
+			value: `[{provider: transform, disable: true}]`,
+			err:   "invalid ext_proc route overrides",
+		},
+		{
+			name:  "missing provider",
+			value: `[{disabled: true}]`,
+			err:   "must set a provider",
+		},
+		{
+			name:  "no override",
+			value: `[{provider: transform, routes: [a]}]`,
+			err:   "must set one of disabled or processingMode",
+		},
+		{
+			name:  "disabled and processing mode",
+			value: `[{provider: transform, disabled: true, processingMode: {requestBody: BUFFERED}}]`,
+			err:   "must set one of disabled or processingMode",
+		},
+		{
+			name:  "invalid mode",
+			value: `[{provider: transform, processingMode: {requestBody: SEND}}]`,
+			err:   "invalid requestBody mode",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := ParseRouteOverrides(tt.value)
+			if err == nil {
+				err = ValidateRouteOverrides(o)
+			}
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected valid overrides, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 func TestConvertProtocol(t *testing.T) {
 	https := "https"
+	dubbo := "dubbo"
 	cases := []struct {
 		name          string
 		port          int32
@@ -58,6 +59,17 @@ func TestConvertProtocol(t *testing.T) {
 			appProto:      &https,
 			expectedProto: protocol.HTTPS,
 		},
+		{
+			name:          "resolves kafka from port name",
+			portName:      "kafka-broker",
+			expectedProto: protocol.Kafka,
+		},
+		{
+			name:          "resolves dubbo from appProto",
+			portName:      "tcp-rpc",
+			appProto:      &dubbo,
+			expectedProto: protocol.Dubbo,
+		},
 		{
 			name:          "resolves grpc-web",
 			portName:      "grpc-web-x",
@@ -0,0 +1,50 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadbalancing defines the load balancer settings of a DestinationRule that its API does not expose.
+package loadbalancing
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ActiveRequestBiasAnnotation sets, on a DestinationRule, the active request bias of its LEAST_REQUEST
+// clusters, overriding PILOT_LEAST_REQUEST_ACTIVE_REQUEST_BIAS. The value is a non-negative number: higher
+// values favor the endpoints with fewer active requests over the endpoint weights, while 0 picks the
+// endpoints by weight only.
+const ActiveRequestBiasAnnotation = "networking.istio.io/activeRequestBias"
+
+// ActiveRequestBias returns the active request bias set with the ActiveRequestBiasAnnotation, or def if it is
+// not set or invalid.
+func ActiveRequestBias(annotations map[string]string, def float64) float64 {
+	value, f := annotations[ActiveRequestBiasAnnotation]
+	if !f {
+		return def
+	}
+	bias, err := ParseActiveRequestBias(value)
+	if err != nil {
+		return def
+	}
+	return bias
+}
+
+// ParseActiveRequestBias parses the value of the ActiveRequestBiasAnnotation.
+func ParseActiveRequestBias(value string) (float64, error) {
+	bias, err := strconv.ParseFloat(value, 64)
+	if err != nil || bias < 0 {
+		return 0, fmt.Errorf("invalid active request bias %q: must be a non-negative number", value)
+	}
+	return bias, nil
+}
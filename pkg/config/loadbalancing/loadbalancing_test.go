@@ -0,0 +1,46 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancing
+
+import (
+	"testing"
+)
+
+func TestActiveRequestBias(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        float64
+		valid       bool
+	}{
+		{"unset", nil, 1.0, true},
+		{"set", map[string]string{ActiveRequestBiasAnnotation: "1.5"}, 1.5, true},
+		{"weights only", map[string]string{ActiveRequestBiasAnnotation: "0"}, 0, true},
+		{"negative", map[string]string{ActiveRequestBiasAnnotation: "-1"}, 1.0, false},
+		{"not a number", map[string]string{ActiveRequestBiasAnnotation: "high"}, 1.0, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ActiveRequestBias(tt.annotations, 1.0); got != tt.want {
+				t.Errorf("expected bias %v, got %v", tt.want, got)
+			}
+			if value, f := tt.annotations[ActiveRequestBiasAnnotation]; f {
+				if _, err := ParseActiveRequestBias(value); (err == nil) != tt.valid {
+					t.Errorf("expected valid %v, got %v", tt.valid, err)
+				}
+			}
+		})
+	}
+}
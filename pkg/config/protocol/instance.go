@@ -42,7 +42,7 @@ const (
 	// TLS traffic is assumed to contain SNI as part of the handshake.
 	TLS Instance = "TLS"
 	// UDP declares that the port uses UDP.
-	// Note that UDP protocol is not currently supported by the proxy.
+	// Note that UDP protocol is only supported by the proxy when PILOT_ENABLE_UDP_LISTENERS is set.
 	UDP Instance = "UDP"
 	// Mongo declares that the port carries MongoDB traffic.
 	Mongo Instance = "Mongo"
@@ -50,6 +50,12 @@ const (
 	Redis Instance = "Redis"
 	// MySQL declares that the port carries MySQL traffic.
 	MySQL Instance = "MySQL"
+	// Kafka declares that the port carries Kafka traffic.
+	Kafka Instance = "Kafka"
+	// Dubbo declares that the port carries Dubbo traffic.
+	Dubbo Instance = "Dubbo"
+	// Thrift declares that the port carries Thrift traffic.
+	Thrift Instance = "Thrift"
 	// Unsupported - value to signify that the protocol is unsupported.
 	Unsupported Instance = "UnsupportedProtocol"
 )
@@ -81,6 +87,12 @@ func Parse(s string) Instance {
 		return Redis
 	case "mysql":
 		return MySQL
+	case "kafka":
+		return Kafka
+	case "dubbo":
+		return Dubbo
+	case "thrift":
+		return Thrift
 	}
 
 	return Unsupported
@@ -109,7 +121,7 @@ func (i Instance) IsHTTP() bool {
 // IsTCP is true for protocols that use TCP as transport protocol
 func (i Instance) IsTCP() bool {
 	switch i {
-	case TCP, HTTPS, TLS, Mongo, Redis, MySQL:
+	case TCP, HTTPS, TLS, Mongo, Redis, MySQL, Kafka, Dubbo, Thrift:
 		return true
 	default:
 		return false
@@ -58,6 +58,12 @@ func TestParse(t *testing.T) {
 		{"mysql", protocol.MySQL},
 		{"MYSQL", protocol.MySQL},
 		{"MySQL", protocol.MySQL},
+		{"kafka", protocol.Kafka},
+		{"Kafka", protocol.Kafka},
+		{"dubbo", protocol.Dubbo},
+		{"DUBBO", protocol.Dubbo},
+		{"thrift", protocol.Thrift},
+		{"Thrift", protocol.Thrift},
 		{"", protocol.Unsupported},
 		{"SMTP", protocol.Unsupported},
 	}
@@ -0,0 +1,277 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit defines the rate limit policy of the HTTP routes of a VirtualService.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotation sets, on a VirtualService, the rate limit policy of its HTTP routes. The value is a YAML or JSON
+// Policy, for example:
+//
+//	networking.istio.io/rateLimit: |
+//	  routes: [api]
+//	  descriptors:
+//	  - entries:
+//	    - type: header
+//	      header: x-user-id
+//	      key: user
+//	  local:
+//	    maxTokens: 100
+//	    fillInterval: 1s
+//	  global:
+//	    provider: ratelimit
+const Annotation = "networking.istio.io/rateLimit"
+
+// EntryType is the request attribute a descriptor entry is built from.
+type EntryType string
+
+const (
+	// EntryHost is the authority of the request.
+	EntryHost EntryType = "host"
+	// EntryRoute is the name of the route, or of the VirtualService if the route has no name.
+	EntryRoute EntryType = "route"
+	// EntryHeader is the value of a request header.
+	EntryHeader EntryType = "header"
+	// EntryRemoteAddress is the address of the client. Its key is always remote_address.
+	EntryRemoteAddress EntryType = "remoteAddress"
+)
+
+// minFillInterval is the smallest fill interval Envoy accepts for local token buckets.
+const minFillInterval = 50 * time.Millisecond
+
+// Policy is the rate limit policy of a VirtualService.
+type Policy struct {
+	// Routes are the names of the HTTP routes the policy applies to. If empty, it applies to all of them.
+	Routes []string `json:"routes,omitempty"`
+	// Descriptors are built for each request, and matched against the local token buckets or sent to the
+	// rate limit service.
+	Descriptors []Descriptor `json:"descriptors,omitempty"`
+	// Local limits the requests handled by each proxy independently.
+	Local *Local `json:"local,omitempty"`
+	// Global limits the requests across all proxies, using a rate limit service.
+	Global *Global `json:"global,omitempty"`
+}
+
+// Descriptor describes how a rate limit descriptor is built from a request. It is only built if all of its
+// entries are.
+type Descriptor struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Entry describes how an entry of a descriptor is built from a request.
+type Entry struct {
+	Type EntryType `json:"type"`
+	// Header is the request header of header entries. The entry is not built if the header is missing.
+	Header string `json:"header,omitempty"`
+	// Key is the key of the entry. It defaults to the type, or to the header name for header entries.
+	Key string `json:"key,omitempty"`
+}
+
+// TokenBucket is a token bucket; a request is allowed if it can take a token from it.
+type TokenBucket struct {
+	// MaxTokens is the size of the bucket, and the number of tokens it starts with.
+	MaxTokens uint32 `json:"maxTokens"`
+	// TokensPerFill is the number of tokens added at each fill. Defaults to 1.
+	TokensPerFill uint32 `json:"tokensPerFill,omitempty"`
+	// FillInterval is the interval between fills, for example 1s.
+	FillInterval string `json:"fillInterval"`
+}
+
+// Local configures local rate limiting.
+type Local struct {
+	// TokenBucket limits the requests of the route.
+	TokenBucket
+	// Descriptors limit the requests with a given descriptor using their own token bucket instead.
+	Descriptors []LocalDescriptor `json:"descriptors,omitempty"`
+}
+
+// LocalDescriptor limits the requests matching a descriptor.
+type LocalDescriptor struct {
+	// Entries must all be found, in this order, in one of the descriptors built for the request.
+	Entries []LocalDescriptorEntry `json:"entries"`
+	TokenBucket
+}
+
+// LocalDescriptorEntry is the key and value of an entry of a descriptor.
+type LocalDescriptorEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Global configures global rate limiting.
+type Global struct {
+	// Provider is the name of the envoyRateLimit extension provider enforcing the limits.
+	Provider string `json:"provider"`
+}
+
+// Parse parses the value of the Annotation.
+func Parse(value string) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict([]byte(value), p); err != nil {
+		return nil, fmt.Errorf("invalid rate limit policy: %v", err)
+	}
+	return p, nil
+}
+
+// FromAnnotations returns the policy set with the Annotation, or nil if it is not set.
+func FromAnnotations(annotations map[string]string) (*Policy, error) {
+	value, f := annotations[Annotation]
+	if !f {
+		return nil, nil
+	}
+	return Parse(value)
+}
+
+// AppliesTo checks if the policy applies to the HTTP route with the given name.
+func (p *Policy) AppliesTo(route string) bool {
+	if len(p.Routes) == 0 {
+		return true
+	}
+	for _, r := range p.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the policy is well formed.
+func (p *Policy) Validate() (errs error) {
+	if p.Local == nil && p.Global == nil {
+		errs = multierror.Append(errs, errors.New("rate limit policy must set local or global"))
+	}
+	for _, d := range p.Descriptors {
+		if len(d.Entries) == 0 {
+			errs = multierror.Append(errs, errors.New("rate limit descriptor must have entries"))
+		}
+		for _, e := range d.Entries {
+			errs = appendErr(errs, e.validate())
+		}
+	}
+	if p.Local != nil {
+		errs = appendErr(errs, p.Local.validate())
+		if len(p.Local.Descriptors) > 0 && len(p.Descriptors) == 0 {
+			errs = multierror.Append(errs, errors.New("local rate limit descriptors require descriptors"))
+		}
+	}
+	if p.Global != nil {
+		if p.Global.Provider == "" {
+			errs = multierror.Append(errs, errors.New("global rate limit must set a provider"))
+		}
+		if len(p.Descriptors) == 0 {
+			errs = multierror.Append(errs, errors.New("global rate limit requires descriptors"))
+		}
+	}
+	return
+}
+
+// DescriptorKey returns the key of the entry.
+func (e Entry) DescriptorKey() string {
+	switch {
+	case e.Type == EntryRemoteAddress:
+		return "remote_address"
+	case e.Key != "":
+		return e.Key
+	case e.Type == EntryHeader:
+		return e.Header
+	}
+	return string(e.Type)
+}
+
+func (e Entry) validate() error {
+	switch e.Type {
+	case EntryHost, EntryRoute:
+	case EntryHeader:
+		if e.Header == "" {
+			return errors.New("header descriptor entry must set a header")
+		}
+	case EntryRemoteAddress:
+		if e.Key != "" {
+			return errors.New("remoteAddress descriptor entry can not set a key")
+		}
+	default:
+		return fmt.Errorf("unknown descriptor entry type %q, expected host, route, header or remoteAddress", e.Type)
+	}
+	if e.Type != EntryHeader && e.Header != "" {
+		return fmt.Errorf("%s descriptor entry can not set a header", e.Type)
+	}
+	return nil
+}
+
+func (l *Local) validate() (errs error) {
+	errs = appendErr(errs, l.TokenBucket.validate())
+	interval, _ := time.ParseDuration(l.FillInterval)
+	for _, d := range l.Descriptors {
+		if len(d.Entries) == 0 {
+			errs = multierror.Append(errs, errors.New("local rate limit descriptor must have entries"))
+		}
+		for _, e := range d.Entries {
+			if e.Key == "" || e.Value == "" {
+				errs = multierror.Append(errs, errors.New("local rate limit descriptor entries must set a key and a value"))
+			}
+		}
+		if err := d.TokenBucket.validate(); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		// Envoy only refills the descriptor buckets along with the default one.
+		if di, _ := time.ParseDuration(d.FillInterval); interval > 0 && di%interval != 0 {
+			errs = multierror.Append(errs, fmt.Errorf("local rate limit descriptor fill interval %s must be a multiple of %s",
+				d.FillInterval, l.FillInterval))
+		}
+	}
+	return
+}
+
+func (b TokenBucket) validate() (errs error) {
+	if b.MaxTokens == 0 {
+		errs = multierror.Append(errs, errors.New("token bucket maxTokens must be positive"))
+	}
+	interval, err := time.ParseDuration(b.FillInterval)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid token bucket fillInterval %q: %v", b.FillInterval, err))
+	} else if interval < minFillInterval {
+		errs = multierror.Append(errs, fmt.Errorf("token bucket fillInterval must be at least %v", minFillInterval))
+	}
+	return
+}
+
+// FillDuration returns the fill interval of the bucket.
+func (b TokenBucket) FillDuration() time.Duration {
+	d, _ := time.ParseDuration(b.FillInterval)
+	return d
+}
+
+// Fill returns the number of tokens added at each fill.
+func (b TokenBucket) Fill() uint32 {
+	if b.TokensPerFill == 0 {
+		return 1
+	}
+	return b.TokensPerFill
+}
+
+func appendErr(errs error, err error) error {
+	if err == nil {
+		return errs
+	}
+	return multierror.Append(errs, err)
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{
+			name: "local",
+			value: `
+local:
+  maxTokens: 10
+  fillInterval: 1s
+`,
+		},
+		{
+			name: "local with descriptors",
+			value: `
+descriptors:
+- entries:
+  - type: header
+    header: x-user-id
+    key: user
+local:
+  maxTokens: 10
+  fillInterval: 1s
+  descriptors:
+  - entries:
+    - key: user
+      value: alice
+    maxTokens: 100
+    fillInterval: 2s
+`,
+		},
+		{
+			name: "global",
+			value: `
+descriptors:
+- entries:
+  - type: host
+  - type: route
+  - type: remoteAddress
+global:
+  provider: ratelimit
+`,
+		},
+		{
+			name:  "unknown field",
+			value: `locl: {}`,
+			err:   "invalid rate limit policy",
+		},
+		{
+			name:  "empty",
+			value: `routes: [a]`,
+			err:   "must set local or global",
+		},
+		{
+			name: "global without descriptors",
+			value: `
+global:
+  provider: ratelimit
+`,
+			err: "requires descriptors",
+		},
+		{
+			name: "short fill interval",
+			value: `
+local:
+  maxTokens: 10
+  fillInterval: 10ms
+`,
+			err: "fillInterval must be at least",
+		},
+		{
+			name: "descriptor fill interval",
+			value: `
+local:
+  maxTokens: 10
+  fillInterval: 1s
+  descriptors:
+  - entries:
+    - key: user
+      value: alice
+    maxTokens: 100
+    fillInterval: 1500ms
+`,
+			err: "must be a multiple of 1s",
+		},
+		{
+			name: "header entry without header",
+			value: `
+descriptors:
+- entries:
+  - type: header
+global:
+  provider: ratelimit
+`,
+			err: "must set a header",
+		},
+		{
+			name: "unknown entry",
+			value: `
+descriptors:
+- entries:
+  - type: path
+global:
+  provider: ratelimit
+`,
+			err: "unknown descriptor entry type",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.value)
+			if err == nil {
+				err = p.Validate()
+			}
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected a valid policy, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestDescriptorKey(t *testing.T) {
+	cases := map[Entry]string{
+		{Type: EntryHost}:                                     "host",
+		{Type: EntryRoute, Key: "api"}:                        "api",
+		{Type: EntryHeader, Header: "x-user-id"}:              "x-user-id",
+		{Type: EntryHeader, Header: "x-user-id", Key: "user"}: "user",
+		{Type: EntryRemoteAddress}:                            "remote_address",
+	}
+	for e, want := range cases {
+		if got := e.DescriptorKey(); got != want {
+			t.Errorf("%v: expected key %q, got %q", e, want, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redisroute defines how a VirtualService routing a Redis port splits the keys across the subsets
+// of its destination and mirrors the requests to shadow destinations.
+package redisroute
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// KeyPrefixRoutesAnnotation lists, on a VirtualService routing a Redis port, the key prefixes routed to a
+// subset of the destination instead of its catch-all cluster. The format is a comma separated list of
+// prefix=subset pairs, for example "user:=v2,session:=v2".
+const KeyPrefixRoutesAnnotation = "networking.istio.io/redisKeyPrefixRoutes"
+
+// MirrorsAnnotation lists, on a VirtualService routing a Redis port, the destinations receiving a copy of
+// the requests. The format is a comma separated list of host[/subset]=percent pairs, for example
+// "shadow.redis.svc.cluster.local=100,redis.redis.svc.cluster.local/v2=10". The mirrors apply to the
+// catch-all route and to every key prefix route.
+const MirrorsAnnotation = "networking.istio.io/redisMirrors"
+
+// KeyPrefixRoute routes the keys starting with Prefix to a subset of the destination.
+type KeyPrefixRoute struct {
+	Prefix string
+	Subset string
+}
+
+// Mirror sends Percent percent of the requests to a subset of Host.
+type Mirror struct {
+	Host    string
+	Subset  string
+	Percent uint32
+}
+
+// KeyPrefixRoutesFromAnnotations returns the routes set with the KeyPrefixRoutesAnnotation.
+func KeyPrefixRoutesFromAnnotations(annotations map[string]string) ([]KeyPrefixRoute, error) {
+	return ParseKeyPrefixRoutes(annotations[KeyPrefixRoutesAnnotation])
+}
+
+// ParseKeyPrefixRoutes parses the value of the KeyPrefixRoutesAnnotation.
+func ParseKeyPrefixRoutes(value string) ([]KeyPrefixRoute, error) {
+	var out []KeyPrefixRoute
+	var errs error
+	seen := map[string]bool{}
+	for _, entry := range splitEntries(value) {
+		prefix, subset, err := splitPair(entry)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid redis key prefix route %q: %v", entry, err))
+			continue
+		}
+		if seen[prefix] {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate redis key prefix %q", prefix))
+			continue
+		}
+		seen[prefix] = true
+		out = append(out, KeyPrefixRoute{Prefix: prefix, Subset: subset})
+	}
+	return out, errs
+}
+
+// MirrorsFromAnnotations returns the mirrors set with the MirrorsAnnotation.
+func MirrorsFromAnnotations(annotations map[string]string) ([]Mirror, error) {
+	return ParseMirrors(annotations[MirrorsAnnotation])
+}
+
+// ParseMirrors parses the value of the MirrorsAnnotation.
+func ParseMirrors(value string) ([]Mirror, error) {
+	var out []Mirror
+	var errs error
+	for _, entry := range splitEntries(value) {
+		destination, percent, err := splitPair(entry)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid redis mirror %q: %v", entry, err))
+			continue
+		}
+		p, err := strconv.ParseUint(percent, 10, 32)
+		if err != nil || p == 0 || p > 100 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid redis mirror %q: percent must be between 1 and 100", entry))
+			continue
+		}
+		m := Mirror{Host: destination, Percent: uint32(p)}
+		if i := strings.Index(destination, "/"); i >= 0 {
+			m.Host, m.Subset = destination[:i], destination[i+1:]
+			if m.Host == "" || m.Subset == "" {
+				errs = multierror.Append(errs, fmt.Errorf("invalid redis mirror %q: expected host[/subset]", entry))
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out, errs
+}
+
+func splitEntries(value string) []string {
+	var out []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// splitPair splits an entry at its last '=', so that key prefixes may contain one.
+func splitPair(entry string) (string, string, error) {
+	i := strings.LastIndex(entry, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("expected key=value")
+	}
+	key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+	if key == "" || value == "" {
+		return "", "", fmt.Errorf("expected key=value")
+	}
+	return key, value, nil
+}
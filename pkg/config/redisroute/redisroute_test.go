@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisroute
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyPrefixRoutes(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []KeyPrefixRoute
+		err   string
+	}{
+		{"", nil, ""},
+		{"a=b", []KeyPrefixRoute{{"a", "b"}}, ""},
+		{"a:=b, c=d,", []KeyPrefixRoute{{"a:", "b"}, {"c", "d"}}, ""},
+		{"a==b", []KeyPrefixRoute{{"a=", "b"}}, ""},
+		{"a=b,=b", []KeyPrefixRoute{{"a", "b"}}, "invalid redis key prefix route"},
+		{"a=", nil, "invalid redis key prefix route"},
+		{"a", nil, "invalid redis key prefix route"},
+		{"a=b,a=c", []KeyPrefixRoute{{"a", "b"}}, "duplicate redis key prefix"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := KeyPrefixRoutesFromAnnotations(map[string]string{KeyPrefixRoutesAnnotation: tt.value})
+			checkError(t, err, tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMirrors(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []Mirror
+		err   string
+	}{
+		{"", nil, ""},
+		{"shadow.com=100", []Mirror{{"shadow.com", "", 100}}, ""},
+		{"shadow.com=100, redis.com/v2=10", []Mirror{{"shadow.com", "", 100}, {"redis.com", "v2", 10}}, ""},
+		{"shadow.com", nil, "invalid redis mirror"},
+		{"shadow.com=0", nil, "percent must be between 1 and 100"},
+		{"shadow.com=101", nil, "percent must be between 1 and 100"},
+		{"shadow.com=ten", nil, "percent must be between 1 and 100"},
+		{"redis.com/=10", nil, "expected host[/subset]"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := MirrorsFromAnnotations(map[string]string{MirrorsAnnotation: tt.value})
+			checkError(t, err, tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
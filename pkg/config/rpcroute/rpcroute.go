@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpcroute defines how a VirtualService routing a Dubbo or Thrift port sends the calls of some service
+// methods to the subsets of its destination.
+package rpcroute
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// MethodRoutesAnnotation lists, on a VirtualService routing a Dubbo or Thrift port, the calls routed to a subset
+// of the destination instead of the destinations of the route. The format is a comma separated list of
+// service/method=subset pairs, for example "com.example.UserService/getUser=v2,com.example.OrderService/*=v2".
+// The method may be "*" to match every method of the service, and the service may be "*" to match the method of
+// every service. Dubbo services are interfaces, and Thrift services are the services of the multiplexed protocol.
+// The calls are matched against the routes in order.
+const MethodRoutesAnnotation = "networking.istio.io/rpcMethodRoutes"
+
+// Wildcard matches every service or method.
+const Wildcard = "*"
+
+// MethodRoute routes the calls of a method of a service to a subset of the destination.
+type MethodRoute struct {
+	Service string
+	Method  string
+	Subset  string
+}
+
+// MethodRoutesFromAnnotations returns the routes set with the MethodRoutesAnnotation.
+func MethodRoutesFromAnnotations(annotations map[string]string) ([]MethodRoute, error) {
+	return ParseMethodRoutes(annotations[MethodRoutesAnnotation])
+}
+
+// ParseMethodRoutes parses the value of the MethodRoutesAnnotation.
+func ParseMethodRoutes(value string) ([]MethodRoute, error) {
+	var out []MethodRoute
+	var errs error
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		r, err := parseMethodRoute(entry)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid rpc method route %q: %v", entry, err))
+			continue
+		}
+		key := r.Service + "/" + r.Method
+		if seen[key] {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate rpc method route %q", key))
+			continue
+		}
+		seen[key] = true
+		out = append(out, r)
+	}
+	return out, errs
+}
+
+func parseMethodRoute(entry string) (MethodRoute, error) {
+	i := strings.LastIndex(entry, "=")
+	if i < 0 {
+		return MethodRoute{}, fmt.Errorf("expected service/method=subset")
+	}
+	call, subset := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+	j := strings.LastIndex(call, "/")
+	if j < 0 || subset == "" {
+		return MethodRoute{}, fmt.Errorf("expected service/method=subset")
+	}
+	r := MethodRoute{Service: strings.TrimSpace(call[:j]), Method: strings.TrimSpace(call[j+1:]), Subset: subset}
+	if r.Service == "" || r.Method == "" {
+		return MethodRoute{}, fmt.Errorf("expected service/method=subset")
+	}
+	if r.Service == Wildcard && r.Method == Wildcard {
+		return MethodRoute{}, fmt.Errorf("the service and the method cannot both be %s, route the destination instead", Wildcard)
+	}
+	return r, nil
+}
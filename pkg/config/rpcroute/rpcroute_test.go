@@ -0,0 +1,56 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcroute
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMethodRoutes(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []MethodRoute
+		err   string
+	}{
+		{"", nil, ""},
+		{"svc/get=v2", []MethodRoute{{"svc", "get", "v2"}}, ""},
+		{"com.example.Svc/get=v2, svc/*=v1, */ping=v3,", []MethodRoute{
+			{"com.example.Svc", "get", "v2"}, {"svc", "*", "v1"}, {"*", "ping", "v3"},
+		}, ""},
+		{"svc/get=v2,svc/get=v1", []MethodRoute{{"svc", "get", "v2"}}, "duplicate rpc method route"},
+		{"svc/get", nil, "invalid rpc method route"},
+		{"get=v2", nil, "invalid rpc method route"},
+		{"svc/=v2", nil, "invalid rpc method route"},
+		{"/get=v2", nil, "invalid rpc method route"},
+		{"svc/get=", nil, "invalid rpc method route"},
+		{"*/*=v2", nil, "cannot both be *"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := MethodRoutesFromAnnotations(map[string]string{MethodRoutesAnnotation: tt.value})
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
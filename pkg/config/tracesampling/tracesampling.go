@@ -0,0 +1,144 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracesampling defines the trace sampling rules of Telemetry resources, which override the tracing
+// sampling percentage for some routes, requests or clients.
+package tracesampling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotation sets, on a Telemetry resource, the trace sampling rules of the workloads it applies to. The value
+// is a YAML or JSON list of Rule, for example:
+//
+//	telemetry.istio.io/tracingSamplingRules: |
+//	  - match:
+//	      headers:
+//	        x-canary: "true"
+//	    randomSamplingPercentage: 100
+//	  - match:
+//	      route: health
+//	    randomSamplingPercentage: 0.1
+//
+// The rules of a workload Telemetry are evaluated before the ones of the namespace Telemetry, which are
+// evaluated before the ones of the root namespace Telemetry. Each rule adds a copy of the routes it applies to,
+// so a Telemetry sets at most MaxRules rules, and rules should be scoped to named routes when possible.
+const Annotation = "telemetry.istio.io/tracingSamplingRules"
+
+// MaxRules is the maximum number of rules of a Telemetry, and of a workload. Only the first MaxRules rules of
+// the Telemetries applying to a workload are evaluated.
+const MaxRules = 10
+
+// Rule sets the sampling percentage of the requests it matches. The first matching rule applies, and the
+// requests matching none use the sampling percentage of the tracing configuration.
+type Rule struct {
+	// Match selects the requests. All the conditions set must match, and an empty match selects all of them.
+	Match Match `json:"match,omitempty"`
+	// RandomSamplingPercentage is the percentage of the matched requests sampled, from 0 to 100.
+	RandomSamplingPercentage *float64 `json:"randomSamplingPercentage"`
+}
+
+// Match selects the requests of a Rule.
+type Match struct {
+	// Route is the name of an HTTP route of a VirtualService.
+	Route string `json:"route,omitempty"`
+	// Headers are request headers and their exact values.
+	Headers map[string]string `json:"headers,omitempty"`
+	// SourcePrincipal is the identity of the client, in the format <trust domain>/ns/<namespace>/sa/<service account>.
+	// On the outbound routes, it matches the identity of the workload. On the inbound routes, it matches the
+	// identity of the mTLS peer, and the requests received without Istio mTLS never match.
+	SourcePrincipal string `json:"sourcePrincipal,omitempty"`
+}
+
+// Parse parses the value of the Annotation.
+func Parse(value string) ([]Rule, error) {
+	var rules []Rule
+	if err := yaml.UnmarshalStrict([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid trace sampling rules: %v", err)
+	}
+	return rules, nil
+}
+
+// FromAnnotations returns the rules set with the Annotation, or nil if it is not set.
+func FromAnnotations(annotations map[string]string) ([]Rule, error) {
+	value, f := annotations[Annotation]
+	if !f {
+		return nil, nil
+	}
+	return Parse(value)
+}
+
+// Validate checks the rules are well formed.
+func Validate(rules []Rule) (errs error) {
+	if len(rules) > MaxRules {
+		errs = multierror.Append(errs, fmt.Errorf("at most %d trace sampling rules can be set, got %d", MaxRules, len(rules)))
+	}
+	for i, r := range rules {
+		if r.RandomSamplingPercentage == nil {
+			errs = multierror.Append(errs, fmt.Errorf("trace sampling rule %d must set randomSamplingPercentage", i))
+		} else if p := *r.RandomSamplingPercentage; p < 0 || p > 100 {
+			errs = multierror.Append(errs, fmt.Errorf("trace sampling rule %d: randomSamplingPercentage %v must be in the range [0, 100]", i, p))
+		}
+		for h := range r.Match.Headers {
+			if h == "" {
+				errs = multierror.Append(errs, fmt.Errorf("trace sampling rule %d: header name must not be empty", i))
+			}
+		}
+		if p := r.Match.SourcePrincipal; p != "" && len(strings.Split(p, "/")) != 5 {
+			errs = multierror.Append(errs, fmt.Errorf("trace sampling rule %d: invalid source principal %q, "+
+				"expected <trust domain>/ns/<namespace>/sa/<service account>", i, p))
+		}
+	}
+	return
+}
+
+// AppliesToRoute checks if the match selects the HTTP route with the given name. The routes of the HTTP routes
+// of VirtualServices are named <route> or <route>.<match>.
+func (m Match) AppliesToRoute(route string) bool {
+	return m.Route == "" || m.Route == route || strings.HasPrefix(route, m.Route+".")
+}
+
+// ForWorkload returns the rules applying to the outbound requests of the workload with the given identity. The
+// rules of other sources are removed, and the source principal is cleared from the others.
+func ForWorkload(rules []Rule, principal string) []Rule {
+	var out []Rule
+	for _, r := range rules {
+		if r.Match.SourcePrincipal != "" {
+			if r.Match.SourcePrincipal != principal {
+				continue
+			}
+			r.Match.SourcePrincipal = ""
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Key returns a string identifying the rules, which must be valid.
+func Key(rules []Rule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, r := range rules {
+		// Maps are printed in key order.
+		fmt.Fprintf(&sb, "%v/%v;", r.Match, *r.RandomSamplingPercentage)
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracesampling
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{
+			name: "valid",
+			value: `
+- match:
+    headers:
+      x-canary: "true"
+  randomSamplingPercentage: 100
+- match:
+    route: health
+  randomSamplingPercentage: 0.1
+- match:
+    sourcePrincipal: cluster.local/ns/tenant-a/sa/client
+  randomSamplingPercentage: 50
+`,
+		},
+		{
+			name:  "unknown field",
+			value: `[{match: {path: /health}, randomSamplingPercentage: 0}]`,
+			err:   "invalid trace sampling rules",
+		},
+		{
+			name:  "missing percentage",
+			value: `[{match: {route: health}}]`,
+			err:   "must set randomSamplingPercentage",
+		},
+		{
+			name:  "percentage out of range",
+			value: `[{randomSamplingPercentage: 101}]`,
+			err:   "must be in the range [0, 100]",
+		},
+		{
+			name:  "too many rules",
+			value: "[" + strings.Repeat("{randomSamplingPercentage: 1}, ", MaxRules) + "{randomSamplingPercentage: 1}]",
+			err:   "at most 10 trace sampling rules",
+		},
+		{
+			name:  "invalid principal",
+			value: `[{match: {sourcePrincipal: tenant-a}, randomSamplingPercentage: 100}]`,
+			err:   "invalid source principal",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := Parse(tt.value)
+			if err == nil {
+				err = Validate(rules)
+			}
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected valid rules, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestForWorkload(t *testing.T) {
+	rules, err := Parse(`
+- {match: {sourcePrincipal: cluster.local/ns/a/sa/client, route: orders}, randomSamplingPercentage: 100}
+- {match: {sourcePrincipal: cluster.local/ns/b/sa/client}, randomSamplingPercentage: 100}
+- {match: {route: health}, randomSamplingPercentage: 0}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ForWorkload(rules, "cluster.local/ns/a/sa/client")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 rules, got %v", got)
+	}
+	if got[0].Match.SourcePrincipal != "" || got[0].Match.Route != "orders" {
+		t.Fatalf("expected the source principal to be cleared, got %v", got[0].Match)
+	}
+	if !got[0].Match.AppliesToRoute("orders.v2") || got[0].Match.AppliesToRoute("ordersv2") {
+		t.Fatalf("expected the rule to apply to the matches of the orders route only")
+	}
+}
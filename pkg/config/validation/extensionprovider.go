@@ -26,7 +26,9 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 )
 
-func validateExtensionProviderService(service string) error {
+// ValidateExtensionProviderService validates the service of an extension provider, in the format of
+// [<Namespace>/]<Hostname>.
+func ValidateExtensionProviderService(service string) error {
 	if service == "" {
 		return fmt.Errorf("service must not be empty")
 	}
@@ -66,7 +68,7 @@ func ValidateExtensionProviderEnvoyExtAuthzHTTP(config *meshconfig.MeshConfig_Ex
 	if err := ValidatePort(int(config.Port)); err != nil {
 		errs = appendErrors(errs, err)
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := validateExtensionProviderEnvoyExtAuthzStatusOnError(config.StatusOnError); err != nil {
@@ -90,7 +92,7 @@ func ValidateExtensionProviderEnvoyExtAuthzGRPC(config *meshconfig.MeshConfig_Ex
 	if err := ValidatePort(int(config.Port)); err != nil {
 		errs = appendErrors(errs, fmt.Errorf("invalid service port: %v", err))
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := validateExtensionProviderEnvoyExtAuthzStatusOnError(config.StatusOnError); err != nil {
@@ -103,7 +105,7 @@ func validateExtensionProviderTracingZipkin(config *meshconfig.MeshConfig_Extens
 	if config == nil {
 		return fmt.Errorf("nil TracingZipkinProvider")
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := ValidatePort(int(config.Port)); err != nil {
@@ -116,7 +118,7 @@ func validateExtensionProviderTracingLightStep(config *meshconfig.MeshConfig_Ext
 	if config == nil {
 		return fmt.Errorf("nil TracingLightStepProvider")
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := ValidatePort(int(config.Port)); err != nil {
@@ -132,7 +134,7 @@ func validateExtensionProviderTracingDatadog(config *meshconfig.MeshConfig_Exten
 	if config == nil {
 		return fmt.Errorf("nil TracingDatadogProvider")
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := ValidatePort(int(config.Port)); err != nil {
@@ -145,7 +147,7 @@ func validateExtensionProviderTracingOpenCensusAgent(config *meshconfig.MeshConf
 	if config == nil {
 		return fmt.Errorf("nil OpenCensusAgent")
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := ValidatePort(int(config.Port)); err != nil {
@@ -158,7 +160,7 @@ func validateExtensionProviderTracingSkyWalking(config *meshconfig.MeshConfig_Ex
 	if config == nil {
 		return fmt.Errorf("nil TracingSkyWalkingProvider")
 	}
-	if err := validateExtensionProviderService(config.Service); err != nil {
+	if err := ValidateExtensionProviderService(config.Service); err != nil {
 		errs = appendErrors(errs, err)
 	}
 	if err := ValidatePort(int(config.Port)); err != nil {
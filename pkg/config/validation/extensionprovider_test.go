@@ -65,7 +65,7 @@ func TestValidateExtensionProviderService(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateExtensionProviderService(tt.service)
+			err := ValidateExtensionProviderService(tt.service)
 			valid := err == nil
 			if valid != tt.valid {
 				t.Errorf("Expected valid=%v, got valid=%v for %v", tt.valid, valid, tt.service)
@@ -32,6 +32,7 @@ import (
 	"github.com/gogo/protobuf/types"
 	"github.com/hashicorp/go-multierror"
 	"github.com/lestrrat-go/jwx/jwk"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
@@ -50,11 +51,13 @@ import (
 	"istio.io/istio/pilot/pkg/util/constant"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/annotations"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/security"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/config/xds"
@@ -74,6 +77,9 @@ const (
 	// ServiceEntry.Endpoint.Address message.
 	UnixAddressPrefix = "unix://"
 
+	// EnvoyInternalAddressPrefix is the prefix used to indicate an address is an Envoy internal listener.
+	EnvoyInternalAddressPrefix = "envoy://"
+
 	matchExact  = "exact:"
 	matchPrefix = "prefix:"
 )
@@ -442,6 +448,14 @@ func ValidateUnixAddress(addr string) error {
 	return nil
 }
 
+// ValidateInternalListenerName validates the name of an Envoy internal listener.
+func ValidateInternalListenerName(name string) error {
+	if !labels.IsDNS1123Label(name) {
+		return fmt.Errorf("internal listener name %q must be a valid DNS-1123 label", name)
+	}
+	return nil
+}
+
 // ValidateGateway checks gateway specifications
 var ValidateGateway = registerValidateFunc("ValidateGateway",
 	func(cfg config.Config) (Warning, error) {
@@ -548,6 +562,8 @@ func validateServerBind(port *networking.Port, bind string) (errs error) {
 		if port != nil && port.Number != 0 {
 			errs = appendErrors(errs, fmt.Errorf("port number must be 0 for unix domain socket: %v", port))
 		}
+	} else if strings.HasPrefix(bind, EnvoyInternalAddressPrefix) {
+		errs = appendErrors(errs, ValidateInternalListenerName(strings.TrimPrefix(bind, EnvoyInternalAddressPrefix)))
 	} else if len(bind) != 0 {
 		errs = appendErrors(errs, ValidateIPAddress(bind))
 	}
@@ -680,6 +696,7 @@ var ValidateDestinationRule = registerValidateFunc("ValidateDestinationRule",
 		}
 
 		v = appendValidation(v, validateExportTo(cfg.Namespace, rule.ExportTo, false))
+		v = appendValidation(v, annotations.Validate(gvk.DestinationRule, cfg.Annotations))
 		return v.Unwrap()
 	})
 
@@ -1212,7 +1229,9 @@ func validateSidecarEgressPortBindAndCaptureMode(port *networking.Port, bind str
 			ValidateProtocol(port.Protocol),
 			ValidatePort(int(port.Number)))
 
-		if len(bind) != 0 {
+		if strings.HasPrefix(bind, EnvoyInternalAddressPrefix) {
+			errs = appendErrors(errs, ValidateInternalListenerName(strings.TrimPrefix(bind, EnvoyInternalAddressPrefix)))
+		} else if len(bind) != 0 {
 			errs = appendErrors(errs, ValidateIPAddress(bind))
 		}
 	}
@@ -1341,6 +1360,11 @@ func validateLoadBalancer(settings *networking.LoadBalancerSettings) (errs error
 	if err := validateLocalityLbSetting(settings.LocalityLbSetting); err != nil {
 		errs = multierror.Append(errs, err)
 	}
+	if settings.WarmupDurationSecs != nil {
+		if err := ValidateDuration(settings.WarmupDurationSecs); err != nil {
+			errs = appendErrors(errs, fmt.Errorf("invalid warmup duration: %v", err))
+		}
+	}
 	return
 }
 
@@ -2087,6 +2111,7 @@ var ValidateVirtualService = registerValidateFunc("ValidateVirtualService",
 		}
 
 		errs = appendValidation(errs, validateExportTo(cfg.Namespace, virtualService.ExportTo, false))
+		errs = appendValidation(errs, annotations.Validate(gvk.VirtualService, cfg.Annotations))
 
 		warnUnused := func(ruleno, reason string) {
 			errs = appendValidation(errs, WrapWarning(&AnalysisAwareError{
@@ -2740,8 +2765,7 @@ func validateHTTPFaultInjectionAbort(abort *networking.HTTPFaultInjection_Abort)
 
 	switch abort.ErrorType.(type) {
 	case *networking.HTTPFaultInjection_Abort_GrpcStatus:
-		// TODO: gRPC status validation
-		errs = multierror.Append(errs, errors.New("gRPC abort fault injection not supported yet"))
+		errs = appendErrors(errs, validateGRPCStatus(abort.GetGrpcStatus()))
 	case *networking.HTTPFaultInjection_Abort_Http2Error:
 		// TODO: HTTP2 error validation
 		errs = multierror.Append(errs, errors.New("HTTP/2 abort fault injection not supported yet"))
@@ -2752,6 +2776,14 @@ func validateHTTPFaultInjectionAbort(abort *networking.HTTPFaultInjection_Abort)
 	return
 }
 
+func validateGRPCStatus(status string) error {
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(strconv.Quote(status))); err != nil {
+		return fmt.Errorf("gRPC status %q is not a gRPC status code name", status)
+	}
+	return nil
+}
+
 func validateHTTPStatus(status int32) error {
 	if status < 200 || status > 600 {
 		return fmt.Errorf("HTTP status %d is not in range 200-599", status)
@@ -3051,7 +3083,13 @@ var ValidateServiceEntry = registerValidateFunc("ValidateServiceEntry",
 			}
 		}
 
-		servicePortNumbers := make(map[uint32]bool)
+		// When UDP listeners are enabled, a port number may be defined once for UDP and once for another protocol,
+		// such as DNS on 53.
+		type portNumber struct {
+			number uint32
+			udp    bool
+		}
+		servicePortNumbers := make(map[portNumber]bool)
 		servicePorts := make(map[string]bool, len(serviceEntry.Ports))
 		for _, port := range serviceEntry.Ports {
 			if port == nil {
@@ -3062,10 +3100,11 @@ var ValidateServiceEntry = registerValidateFunc("ValidateServiceEntry",
 				errs = appendValidation(errs, fmt.Errorf("service entry port name %q already defined", port.Name))
 			}
 			servicePorts[port.Name] = true
-			if servicePortNumbers[port.Number] {
+			pn := portNumber{number: port.Number, udp: features.EnableUDPListeners && protocol.Parse(port.Protocol) == protocol.UDP}
+			if servicePortNumbers[pn] {
 				errs = appendValidation(errs, fmt.Errorf("service entry port %d already defined", port.Number))
 			}
-			servicePortNumbers[port.Number] = true
+			servicePortNumbers[pn] = true
 			if port.TargetPort != 0 {
 				errs = appendValidation(errs, ValidatePort(int(port.TargetPort)))
 			}
@@ -3090,6 +3129,11 @@ var ValidateServiceEntry = registerValidateFunc("ValidateServiceEntry",
 					if len(endpoint.Ports) != 0 {
 						errs = appendValidation(errs, fmt.Errorf("unix endpoint %s must not include ports", addr))
 					}
+				} else if strings.HasPrefix(addr, EnvoyInternalAddressPrefix) {
+					errs = appendValidation(errs, ValidateInternalListenerName(strings.TrimPrefix(addr, EnvoyInternalAddressPrefix)))
+					if len(endpoint.Ports) != 0 {
+						errs = appendValidation(errs, fmt.Errorf("internal endpoint %s must not include ports", addr))
+					}
 				} else {
 					errs = appendValidation(errs, ValidateIPAddress(addr))
 
@@ -3467,6 +3511,7 @@ var ValidateTelemetry = registerValidateFunc("ValidateTelemetry",
 			validateTelemetryMetrics(spec.Metrics),
 			validateTelemetryTracing(spec.Tracing),
 			validateTelemetryAccessLogging(spec.AccessLogging),
+			annotations.Validate(gvk.Telemetry, cfg.Annotations),
 		)
 		return errs.Unwrap()
 	})
@@ -33,7 +33,13 @@ import (
 	api "istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/accesslogging"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/extproc"
+	"istio.io/istio/pkg/config/loadbalancing"
+	"istio.io/istio/pkg/config/ratelimit"
+	"istio.io/istio/pkg/config/redisroute"
+	"istio.io/istio/pkg/config/tracesampling"
 )
 
 const (
@@ -1084,6 +1090,24 @@ func TestValidateServer(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"bind internal listener",
+			&networking.Server{
+				Hosts: []string{"foo.bar.com"},
+				Port:  &networking.Port{Number: 8080, Name: "http", Protocol: "http"},
+				Bind:  "envoy://authz",
+			},
+			"",
+		},
+		{
+			"bind invalid internal listener",
+			&networking.Server{
+				Hosts: []string{"foo.bar.com"},
+				Port:  &networking.Port{Number: 8080, Name: "http", Protocol: "http"},
+				Bind:  "envoy://Auth_Z",
+			},
+			"must be a valid DNS-1123 label",
+		},
 		{
 			"bind bad ip",
 			&networking.Server{
@@ -1123,7 +1147,7 @@ func TestValidateServerPort(t *testing.T) {
 		{
 			"invalid protocol",
 			&networking.Port{
-				Protocol: "kafka",
+				Protocol: "smtp",
 				Number:   1,
 				Name:     "Henry",
 			},
@@ -1715,6 +1739,16 @@ func TestValidateHTTPFaultInjectionAbort(t *testing.T) {
 				HttpStatus: 200,
 			},
 		}, valid: false},
+		{name: "valid grpc status", in: &networking.HTTPFaultInjection_Abort{
+			ErrorType: &networking.HTTPFaultInjection_Abort_GrpcStatus{
+				GrpcStatus: "UNAVAILABLE",
+			},
+		}, valid: true},
+		{name: "invalid grpc status", in: &networking.HTTPFaultInjection_Abort{
+			ErrorType: &networking.HTTPFaultInjection_Abort_GrpcStatus{
+				GrpcStatus: "14",
+			},
+		}, valid: false},
 	}
 
 	for _, tc := range testCases {
@@ -2796,6 +2830,106 @@ func TestValidateVirtualService(t *testing.T) {
 	}
 }
 
+func TestValidateVirtualServiceRateLimit(t *testing.T) {
+	vs := &networking.VirtualService{
+		Hosts: []string{"foo.bar"},
+		Http: []*networking.HTTPRoute{{
+			Name: "api",
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "foo.baz"},
+			}},
+		}},
+	}
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "local", value: "routes: [api]\nlocal: {maxTokens: 10, fillInterval: 1s}", valid: true},
+		{name: "global", value: "descriptors: [{entries: [{type: host}]}]\nglobal: {provider: ratelimit}", valid: true},
+		{name: "malformed", value: "local: [", valid: false},
+		{name: "no limit", value: "routes: [api]", valid: false},
+		{name: "invalid token bucket", value: "local: {fillInterval: 1s}", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{Annotations: map[string]string{ratelimit.Annotation: tc.value}},
+				Spec: vs,
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
+func TestValidateVirtualServiceExtProc(t *testing.T) {
+	vs := &networking.VirtualService{
+		Hosts: []string{"foo.bar"},
+		Http: []*networking.HTTPRoute{{
+			Name: "api",
+			Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "foo.baz"},
+			}},
+		}},
+	}
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "disabled", value: "[{provider: transform, routes: [api], disabled: true}]", valid: true},
+		{name: "processing mode", value: "[{provider: transform, processingMode: {requestBody: BUFFERED}}]", valid: true},
+		{name: "malformed", value: "{provider: transform}", valid: false},
+		{name: "invalid mode", value: "[{provider: transform, processingMode: {requestBody: ALL}}]", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{Annotations: map[string]string{extproc.RouteAnnotation: tc.value}},
+				Spec: vs,
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
+func TestValidateVirtualServiceRedisRoutes(t *testing.T) {
+	vs := &networking.VirtualService{
+		Hosts: []string{"redis.bar"},
+		Tcp: []*networking.TCPRoute{{
+			Route: []*networking.RouteDestination{{
+				Destination: &networking.Destination{Host: "redis.bar", Subset: "v1"},
+			}},
+		}},
+	}
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		valid       bool
+	}{
+		{name: "none", valid: true},
+		{
+			name: "valid",
+			annotations: map[string]string{
+				redisroute.KeyPrefixRoutesAnnotation: "user:=v2",
+				redisroute.MirrorsAnnotation:         "shadow.bar=100,redis.bar/v2=10",
+			},
+			valid: true,
+		},
+		{name: "malformed prefix route", annotations: map[string]string{redisroute.KeyPrefixRoutesAnnotation: "user:"}, valid: false},
+		{name: "invalid mirror percent", annotations: map[string]string{redisroute.MirrorsAnnotation: "shadow.bar=200"}, valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateVirtualService(config.Config{
+				Meta: config.Meta{Annotations: tc.annotations},
+				Spec: vs,
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
 func TestValidateWorkloadEntry(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -3005,6 +3139,29 @@ func checkValidationMessage(t *testing.T, gotWarning Warning, gotError error, wa
 	}
 }
 
+func TestValidateDestinationRuleActiveRequestBias(t *testing.T) {
+	dr := &networking.DestinationRule{Host: "reviews"}
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "bias", value: "1.5", valid: true},
+		{name: "weights only", value: "0", valid: true},
+		{name: "negative", value: "-1", valid: false},
+		{name: "not a number", value: "high", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{Annotations: map[string]string{loadbalancing.ActiveRequestBiasAnnotation: tc.value}},
+				Spec: dr,
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
 func TestValidateDestinationRule(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -3426,6 +3583,26 @@ func TestValidateLoadBalancer(t *testing.T) {
 			valid: true,
 		},
 
+		{
+			name: "valid load balancer with warmup duration", in: networking.LoadBalancerSettings{
+				LbPolicy: &networking.LoadBalancerSettings_Simple{
+					Simple: networking.LoadBalancerSettings_LEAST_REQUEST,
+				},
+				WarmupDurationSecs: &types.Duration{Seconds: 60},
+			},
+			valid: true,
+		},
+
+		{
+			name: "invalid load balancer with zero warmup duration", in: networking.LoadBalancerSettings{
+				LbPolicy: &networking.LoadBalancerSettings_Simple{
+					Simple: networking.LoadBalancerSettings_ROUND_ROBIN,
+				},
+				WarmupDurationSecs: &types.Duration{},
+			},
+			valid: false,
+		},
+
 		{
 			name: "invalid load balancer with consistentHash load balancing, missing ttl", in: networking.LoadBalancerSettings{
 				LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
@@ -3940,10 +4117,11 @@ func TestValidateEnvoyFilter(t *testing.T) {
 
 func TestValidateServiceEntries(t *testing.T) {
 	cases := []struct {
-		name    string
-		in      networking.ServiceEntry
-		valid   bool
-		warning bool
+		name         string
+		in           networking.ServiceEntry
+		udpListeners bool
+		valid        bool
+		warning      bool
 	}{
 		{
 			name: "discovery type DNS", in: networking.ServiceEntry{
@@ -4327,6 +4505,47 @@ func TestValidateServiceEntries(t *testing.T) {
 			valid: false,
 		},
 
+		{
+			name: "same port number for TCP and UDP", in: networking.ServiceEntry{
+				Hosts:     []string{"dns.example.com"},
+				Addresses: []string{"1.2.3.4"},
+				Ports: []*networking.Port{
+					{Number: 53, Protocol: "UDP", Name: "udp-dns"},
+					{Number: 53, Protocol: "TCP", Name: "tcp-dns"},
+				},
+				Resolution: networking.ServiceEntry_NONE,
+			},
+			udpListeners: true,
+			valid:        true,
+		},
+
+		{
+			name: "same port number for TCP and UDP without UDP listeners", in: networking.ServiceEntry{
+				Hosts:     []string{"dns.example.com"},
+				Addresses: []string{"1.2.3.4"},
+				Ports: []*networking.Port{
+					{Number: 53, Protocol: "UDP", Name: "udp-dns"},
+					{Number: 53, Protocol: "TCP", Name: "tcp-dns"},
+				},
+				Resolution: networking.ServiceEntry_NONE,
+			},
+			valid: false,
+		},
+
+		{
+			name: "conflicting UDP port numbers", in: networking.ServiceEntry{
+				Hosts:     []string{"dns.example.com"},
+				Addresses: []string{"1.2.3.4"},
+				Ports: []*networking.Port{
+					{Number: 53, Protocol: "UDP", Name: "udp-dns1"},
+					{Number: 53, Protocol: "udp", Name: "udp-dns2"},
+				},
+				Resolution: networking.ServiceEntry_NONE,
+			},
+			udpListeners: true,
+			valid:        false,
+		},
+
 		{
 			name: "unix socket", in: networking.ServiceEntry{
 				Hosts: []string{"uds.cluster.local"},
@@ -4383,6 +4602,45 @@ func TestValidateServiceEntries(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "internal listener", in: networking.ServiceEntry{
+				Hosts: []string{"chain.internal"},
+				Ports: []*networking.Port{
+					{Number: 80, Protocol: "http", Name: "http"},
+				},
+				Resolution: networking.ServiceEntry_STATIC,
+				Endpoints: []*networking.WorkloadEntry{
+					{Address: "envoy://authz"},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "internal listener, endpoint ports", in: networking.ServiceEntry{
+				Hosts: []string{"chain.internal"},
+				Ports: []*networking.Port{
+					{Number: 80, Protocol: "http", Name: "http"},
+				},
+				Resolution: networking.ServiceEntry_STATIC,
+				Endpoints: []*networking.WorkloadEntry{
+					{Address: "envoy://authz", Ports: map[string]uint32{"http": 8080}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "internal listener, empty name", in: networking.ServiceEntry{
+				Hosts: []string{"chain.internal"},
+				Ports: []*networking.Port{
+					{Number: 80, Protocol: "http", Name: "http"},
+				},
+				Resolution: networking.ServiceEntry_STATIC,
+				Endpoints: []*networking.WorkloadEntry{
+					{Address: "envoy://"},
+				},
+			},
+			valid: false,
+		},
 		{
 			name: "empty protocol", in: networking.ServiceEntry{
 				Hosts:     []string{"google.com"},
@@ -4463,6 +4721,10 @@ func TestValidateServiceEntries(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			features.EnableUDPListeners = c.udpListeners
+			defer func() {
+				features.EnableUDPListeners = false
+			}()
 			warning, err := ValidateServiceEntry(config.Config{
 				Meta: config.Meta{
 					Name:      someName,
@@ -5431,6 +5693,36 @@ func TestValidateSidecar(t *testing.T) {
 				},
 			},
 		}, true, false},
+		{"internal listener bind in outbound", &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{
+				{
+					Port: &networking.Port{
+						Protocol: "http",
+						Number:   9080,
+						Name:     "http",
+					},
+					Bind: "envoy://egress",
+					Hosts: []string{
+						"ns1/bar.com",
+					},
+				},
+			},
+		}, true, false},
+		{"invalid internal listener bind in outbound", &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{
+				{
+					Port: &networking.Port{
+						Protocol: "http",
+						Number:   9080,
+						Name:     "http",
+					},
+					Bind: "envoy://",
+					Hosts: []string{
+						"ns1/bar.com",
+					},
+				},
+			},
+		}, false, false},
 		{"UDS bind in outbound", &networking.Sidecar{
 			Egress: []*networking.IstioEgressListener{
 				{
@@ -6927,6 +7219,56 @@ func TestValidateTelemetry(t *testing.T) {
 	}
 }
 
+func TestValidateTelemetryTracingSampling(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "valid", value: "[{match: {route: health}, randomSamplingPercentage: 0.1}]", valid: true},
+		{name: "malformed", value: "{match: {route: health}}", valid: false},
+		{name: "invalid percentage", value: "[{randomSamplingPercentage: 200}]", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateTelemetry(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{tracesampling.Annotation: tc.value},
+				},
+				Spec: &telemetry.Telemetry{},
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
+func TestValidateTelemetryAccessLoggingOptions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "valid", value: "{sampling: {percentage: 1}, listenerClasses: {gateway: {format: ecs}}}", valid: true},
+		{name: "malformed", value: "[{sampling: {percentage: 1}}]", valid: false},
+		{name: "invalid format", value: "{format: xml}", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ValidateTelemetry(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{accesslogging.Annotation: tc.value},
+				},
+				Spec: &telemetry.Telemetry{},
+			})
+			checkValidation(t, warn, err, tc.valid, false)
+		})
+	}
+}
+
 func TestValidateProxyConfig(t *testing.T) {
 	tests := []struct {
 		name    string
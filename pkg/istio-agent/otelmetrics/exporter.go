@@ -0,0 +1,380 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otelmetrics exports the Istio metrics of the proxy to OpenTelemetry collectors.
+package otelmetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/pkg/log"
+)
+
+var exporterLog = log.RegisterScope("otelmetrics", "OpenTelemetry metrics exporter", 0)
+
+// IstioMetricPrefix is the prefix of the metrics generated by the stats filter.
+const IstioMetricPrefix = "istio_"
+
+const (
+	// TLSModeIstioMutual presents the certificate of the workload to the collector, and verifies the collector
+	// with the mesh CA.
+	TLSModeIstioMutual = "ISTIO_MUTUAL"
+	// TLSModeSimple verifies the collector with the configured CA certificates, or the system ones.
+	TLSModeSimple = "SIMPLE"
+	// TLSModeDisable exports the metrics in plaintext.
+	TLSModeDisable = "DISABLE"
+)
+
+// Collector is the OpenTelemetry collector the metrics are exported to.
+type Collector struct {
+	// Address is the host and port of the collector.
+	Address string
+	// PushInterval is the interval between two exports.
+	PushInterval time.Duration
+	// TLSMode is TLSModeIstioMutual, TLSModeSimple or TLSModeDisable. Defaults to TLSModeIstioMutual.
+	TLSMode string
+	// CACertificates is the path of the CA certificates verifying the collector with TLSModeSimple.
+	CACertificates string
+	// SNI is the server name verified with TLSModeSimple. Defaults to the host of the collector.
+	SNI string
+}
+
+// Exporter exports the Istio metrics of the proxy to the OpenTelemetry collector istiod selects for the
+// workload. It reads the Prometheus stats of the proxy every push interval, and only while a collector is
+// selected, so that the proxies whose metrics are not exported do not pay for it.
+type Exporter struct {
+	statsURL string
+	client   *http.Client
+	// secrets provides the certificate of the workload and the mesh CA, for TLSModeIstioMutual.
+	secrets security.SecretManager
+	// start is the start time of the cumulative metrics.
+	start time.Time
+
+	mu        sync.Mutex
+	collector Collector
+	conn      *grpc.ClientConn
+	stop      chan struct{}
+}
+
+// NewExporter creates an exporter reading the stats of the proxy from the given Prometheus endpoint. It does
+// not export anything until a collector is set with Update. secrets may be nil if the workload has no mesh
+// certificate, in which case only the TLSModeSimple and TLSModeDisable collectors are supported.
+func NewExporter(statsURL string, secrets security.SecretManager) *Exporter {
+	return &Exporter{
+		statsURL: statsURL,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		secrets:  secrets,
+		start:    time.Now(),
+	}
+}
+
+// Update sets the collector the metrics are exported to. The export stops if its address is empty.
+func (e *Exporter) Update(c Collector) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c == e.collector {
+		return nil
+	}
+	e.closeLocked()
+	if c.Address == "" {
+		exporterLog.Infof("not exporting the proxy metrics")
+		return nil
+	}
+	if c.PushInterval <= 0 {
+		return fmt.Errorf("invalid push interval %v", c.PushInterval)
+	}
+	creds, err := e.transportCredentials(c)
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.Dial(c.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	exporterLog.Infof("exporting the proxy metrics to %s every %v", c.Address, c.PushInterval)
+	e.collector, e.conn, e.stop = c, conn, make(chan struct{})
+	go e.run(colmetrics.NewMetricsServiceClient(conn), c.PushInterval, e.stop)
+	return nil
+}
+
+func (e *Exporter) transportCredentials(c Collector) (credentials.TransportCredentials, error) {
+	switch c.TLSMode {
+	case TLSModeDisable:
+		return insecure.NewCredentials(), nil
+	case TLSModeSimple:
+		config := &tls.Config{
+			ServerName: c.SNI,
+			MinVersion: tls.VersionTLS12,
+		}
+		if config.ServerName == "" {
+			host, _, err := net.SplitHostPort(c.Address)
+			if err != nil {
+				return nil, err
+			}
+			config.ServerName = host
+		}
+		if c.CACertificates != "" {
+			pem, err := os.ReadFile(c.CACertificates)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no CA certificates in %s", c.CACertificates)
+			}
+		}
+		return credentials.NewTLS(config), nil
+	case "", TLSModeIstioMutual:
+		if e.secrets == nil {
+			return nil, fmt.Errorf("the mesh certificates of the workload are not available for the %s mode", TLSModeIstioMutual)
+		}
+		return credentials.NewTLS(&tls.Config{
+			GetClientCertificate: e.workloadCertificate,
+			// The mesh certificates identify workloads rather than hostnames, so the collector is verified against
+			// the mesh CA in VerifyPeerCertificate instead.
+			InsecureSkipVerify:    true, // nolint: gosec
+			VerifyPeerCertificate: e.verifyMeshCertificate,
+			// Like the other mesh traffic, so that the sidecar of the collector terminates the mutual TLS.
+			NextProtos: []string{"istio", "h2"},
+			MinVersion: tls.VersionTLS12,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported TLS mode %q", c.TLSMode)
+	}
+}
+
+func (e *Exporter) workloadCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	item, err := e.secrets.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(item.CertificateChain, item.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// verifyMeshCertificate verifies the certificate chain of the collector with the mesh CA.
+func (e *Exporter) verifyMeshCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("the collector presented no certificate")
+	}
+	root, err := e.secrets.GenerateSecret(security.RootCertReqResourceName)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(root.RootCert) {
+		return fmt.Errorf("no mesh CA certificates")
+	}
+	intermediates := x509.NewCertPool()
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			leaf = cert
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// Close stops the export.
+func (e *Exporter) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closeLocked()
+}
+
+func (e *Exporter) closeLocked() {
+	if e.stop != nil {
+		close(e.stop)
+		_ = e.conn.Close()
+	}
+	e.collector, e.conn, e.stop = Collector{}, nil, nil
+}
+
+func (e *Exporter) run(client colmetrics.MetricsServiceClient, pushInterval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(pushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), pushInterval)
+			if err := e.export(ctx, client); err != nil {
+				exporterLog.Warnf("failed to export the proxy metrics: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (e *Exporter) export(ctx context.Context, client colmetrics.MetricsServiceClient) error {
+	families, err := e.scrape(ctx)
+	if err != nil {
+		return err
+	}
+	req := Convert(families, e.start, time.Now())
+	if len(req.ResourceMetrics) == 0 {
+		return nil
+	}
+	_, err = client.Export(ctx, req)
+	return err
+}
+
+func (e *Exporter) scrape(ctx context.Context) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.statsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read the proxy stats: %v", resp.Status)
+	}
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// Convert returns an OTLP request with the Istio metrics of the given Prometheus metric families. The counters
+// and histograms of the proxy are cumulative since start.
+func Convert(families map[string]*dto.MetricFamily, start, now time.Time) *colmetrics.ExportMetricsServiceRequest {
+	names := make([]string, 0, len(families))
+	for name := range families {
+		if strings.HasPrefix(name, IstioMetricPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	startNano, nowNano := uint64(start.UnixNano()), uint64(now.UnixNano())
+	ilm := &metrics.InstrumentationLibraryMetrics{}
+	for _, name := range names {
+		if m := convertFamily(families[name], startNano, nowNano); m != nil {
+			ilm.Metrics = append(ilm.Metrics, m)
+		}
+	}
+	req := &colmetrics.ExportMetricsServiceRequest{}
+	if len(ilm.Metrics) > 0 {
+		req.ResourceMetrics = []*metrics.ResourceMetrics{{InstrumentationLibraryMetrics: []*metrics.InstrumentationLibraryMetrics{ilm}}}
+	}
+	return req
+}
+
+func convertFamily(mf *dto.MetricFamily, start, now uint64) *metrics.Metric {
+	out := &metrics.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := &metrics.Sum{IsMonotonic: true, AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+		for _, m := range mf.GetMetric() {
+			sum.DataPoints = append(sum.DataPoints, numberDataPoint(m, m.GetCounter().GetValue(), start, now))
+		}
+		out.Data = &metrics.Metric_Sum{Sum: sum}
+	case dto.MetricType_GAUGE:
+		gauge := &metrics.Gauge{}
+		for _, m := range mf.GetMetric() {
+			gauge.DataPoints = append(gauge.DataPoints, numberDataPoint(m, m.GetGauge().GetValue(), start, now))
+		}
+		out.Data = &metrics.Metric_Gauge{Gauge: gauge}
+	case dto.MetricType_HISTOGRAM:
+		histogram := &metrics.Histogram{AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+		for _, m := range mf.GetMetric() {
+			histogram.DataPoints = append(histogram.DataPoints, histogramDataPoint(m, start, now))
+		}
+		out.Data = &metrics.Metric_Histogram{Histogram: histogram}
+	default:
+		// The stats filter only generates counters, gauges and histograms.
+		return nil
+	}
+	return out
+}
+
+func numberDataPoint(m *dto.Metric, value float64, start, now uint64) *metrics.NumberDataPoint {
+	return &metrics.NumberDataPoint{
+		Attributes:        attributes(m),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Value:             &metrics.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramDataPoint converts the cumulative Prometheus buckets into the OTLP ones, which count the values
+// between two bounds, the last one counting the values above the last bound.
+func histogramDataPoint(m *dto.Metric, start, now uint64) *metrics.HistogramDataPoint {
+	h := m.GetHistogram()
+	dp := &metrics.HistogramDataPoint{
+		Attributes:        attributes(m),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Count:             h.GetSampleCount(),
+		Sum:               h.GetSampleSum(),
+	}
+	var previous uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+		dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-previous)
+		previous = b.GetCumulativeCount()
+	}
+	dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-previous)
+	return dp
+}
+
+func attributes(m *dto.Metric) []*common.KeyValue {
+	out := make([]*common.KeyValue, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		out = append(out, &common.KeyValue{
+			Key:   l.GetName(),
+			Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: l.GetValue()}},
+		})
+	}
+	return out
+}
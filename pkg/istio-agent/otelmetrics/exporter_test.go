@@ -0,0 +1,295 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelmetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const stats = `# TYPE envoy_cluster_upstream_rq_total counter
+envoy_cluster_upstream_rq_total{cluster_name="xds-grpc"} 3
+# TYPE istio_requests_total counter
+istio_requests_total{response_code="200",reporter="destination"} 7
+# TYPE istio_build gauge
+istio_build{component="proxy"} 1
+# TYPE istio_request_duration_milliseconds histogram
+istio_request_duration_milliseconds_bucket{reporter="destination",le="10"} 2
+istio_request_duration_milliseconds_bucket{reporter="destination",le="100"} 5
+istio_request_duration_milliseconds_bucket{reporter="destination",le="+Inf"} 6
+istio_request_duration_milliseconds_sum{reporter="destination"} 420
+istio_request_duration_milliseconds_count{reporter="destination"} 6
+`
+
+type collector struct {
+	colmetrics.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	received [][]string
+}
+
+func (c *collector) Export(_ context.Context, req *colmetrics.ExportMetricsServiceRequest) (*colmetrics.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.received = append(c.received, metricNames(req))
+	return &colmetrics.ExportMetricsServiceResponse{}, nil
+}
+
+func (c *collector) Received() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.received
+}
+
+func startCollector(t *testing.T, opts ...grpc.ServerOption) (*collector, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{}
+	s := grpc.NewServer(opts...)
+	colmetrics.RegisterMetricsServiceServer(s, c)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+	return c, l.Addr().String()
+}
+
+func metricNames(req *colmetrics.ExportMetricsServiceRequest) []string {
+	var names []string
+	for _, rm := range req.GetResourceMetrics() {
+		for _, ilm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range ilm.GetMetrics() {
+				names = append(names, m.GetName())
+			}
+		}
+	}
+	return names
+}
+
+func TestConvert(t *testing.T) {
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, now := time.Unix(100, 0), time.Unix(160, 0)
+	req := Convert(families, start, now)
+	want := []string{"istio_build", "istio_request_duration_milliseconds", "istio_requests_total"}
+	if got := metricNames(req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	ms := req.ResourceMetrics[0].InstrumentationLibraryMetrics[0].Metrics
+
+	if g := ms[0].GetGauge(); g == nil || g.DataPoints[0].GetAsDouble() != 1 {
+		t.Errorf("expected a gauge, got %v", ms[0])
+	}
+
+	h := ms[1].GetHistogram()
+	if h == nil || h.AggregationTemporality != metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("expected a cumulative histogram, got %v", ms[1])
+	}
+	dp := h.DataPoints[0]
+	if !reflect.DeepEqual(dp.ExplicitBounds, []float64{10, 100}) || !reflect.DeepEqual(dp.BucketCounts, []uint64{2, 3, 1}) {
+		t.Errorf("expected bounds [10 100] and counts [2 3 1], got %v and %v", dp.ExplicitBounds, dp.BucketCounts)
+	}
+	if dp.Count != 6 || dp.Sum != 420 {
+		t.Errorf("expected count 6 and sum 420, got %v and %v", dp.Count, dp.Sum)
+	}
+
+	s := ms[2].GetSum()
+	if s == nil || !s.IsMonotonic || s.AggregationTemporality != metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("expected a cumulative monotonic sum, got %v", ms[2])
+	}
+	p := s.DataPoints[0]
+	if p.GetAsDouble() != 7 || p.StartTimeUnixNano != uint64(start.UnixNano()) || p.TimeUnixNano != uint64(now.UnixNano()) {
+		t.Errorf("unexpected data point %v", p)
+	}
+	attrs := map[string]string{}
+	for _, kv := range p.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if want := map[string]string{"response_code": "200", "reporter": "destination"}; !reflect.DeepEqual(attrs, want) {
+		t.Errorf("expected attributes %v, got %v", want, attrs)
+	}
+
+	if got := Convert(nil, start, now); len(got.ResourceMetrics) != 0 {
+		t.Errorf("expected no resource metrics, got %v", got)
+	}
+}
+
+func startProxy(t *testing.T) string {
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, stats)
+	}))
+	t.Cleanup(envoy.Close)
+	return envoy.URL + "/stats/prometheus"
+}
+
+func expectExports(t *testing.T, c *collector, n int) {
+	t.Helper()
+	retry.UntilSuccessOrFail(t, func() error {
+		if len(c.Received()) < n {
+			return fmt.Errorf("expected %d exports, got %v", n, c.Received())
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+	want := []string{"istio_build", "istio_request_duration_milliseconds", "istio_requests_total"}
+	if got := c.Received()[0]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	c, address := startCollector(t)
+	e := NewExporter(startProxy(t), nil)
+	t.Cleanup(e.Close)
+
+	if err := e.Update(Collector{Address: address, PushInterval: 50 * time.Millisecond, TLSMode: TLSModeDisable}); err != nil {
+		t.Fatal(err)
+	}
+	expectExports(t, c, 2)
+
+	// No collector is selected for the workload anymore.
+	if err := e.Update(Collector{}); err != nil {
+		t.Fatal(err)
+	}
+	// Let an export in flight complete.
+	time.Sleep(100 * time.Millisecond)
+	exported := len(c.Received())
+	time.Sleep(200 * time.Millisecond)
+	if got := len(c.Received()); got != exported {
+		t.Fatalf("expected no export once the collector is unselected, got %d more", got-exported)
+	}
+}
+
+// fakeSecrets provides the mesh certificates of the workload.
+type fakeSecrets struct {
+	dir string
+}
+
+func (f fakeSecrets) GenerateSecret(resourceName string) (*security.SecretItem, error) {
+	read := func(name string) []byte {
+		b, err := os.ReadFile(path.Join(f.dir, name))
+		if err != nil {
+			panic(err)
+		}
+		return b
+	}
+	if resourceName == security.RootCertReqResourceName {
+		return &security.SecretItem{ResourceName: resourceName, RootCert: read("root-cert.pem")}, nil
+	}
+	return &security.SecretItem{
+		ResourceName:     resourceName,
+		CertificateChain: read("cert-chain.pem"),
+		PrivateKey:       read("key.pem"),
+	}, nil
+}
+
+func serverCredentials(t *testing.T, dir string, clientCAs bool) grpc.ServerOption {
+	cert, err := tls.LoadX509KeyPair(path.Join(dir, "cert-chain.pem"), path.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAs {
+		root, err := os.ReadFile(path.Join(dir, "root-cert.pem"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AppendCertsFromPEM(root)
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return grpc.Creds(credentials.NewTLS(config))
+}
+
+func TestExporterTLS(t *testing.T) {
+	// The mesh certificates only have a SPIFFE identity, the DNS ones the hostname of the collector.
+	mesh := path.Join(env.IstioSrc, "tests/testdata/certs/default")
+	dns := path.Join(env.IstioSrc, "tests/testdata/certs/dns")
+	t.Run("istio mutual", func(t *testing.T) {
+		c, address := startCollector(t, serverCredentials(t, mesh, true))
+		e := NewExporter(startProxy(t), fakeSecrets{dir: mesh})
+		t.Cleanup(e.Close)
+		if err := e.Update(Collector{Address: address, PushInterval: 50 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+		expectExports(t, c, 1)
+	})
+	t.Run("istio mutual without mesh certificates", func(t *testing.T) {
+		e := NewExporter(startProxy(t), nil)
+		t.Cleanup(e.Close)
+		if err := e.Update(Collector{Address: "127.0.0.1:4317", PushInterval: time.Second}); err == nil {
+			t.Fatal("expected an error without mesh certificates")
+		}
+	})
+	t.Run("simple", func(t *testing.T) {
+		c, address := startCollector(t, serverCredentials(t, dns, false))
+		e := NewExporter(startProxy(t), nil)
+		t.Cleanup(e.Close)
+		if err := e.Update(Collector{
+			Address:        address,
+			PushInterval:   50 * time.Millisecond,
+			TLSMode:        TLSModeSimple,
+			CACertificates: path.Join(dns, "root-cert.pem"),
+			SNI:            "server.default.svc",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		expectExports(t, c, 1)
+	})
+	t.Run("simple with untrusted collector", func(t *testing.T) {
+		c, address := startCollector(t, serverCredentials(t, dns, false))
+		e := NewExporter(startProxy(t), nil)
+		t.Cleanup(e.Close)
+		if err := e.Update(Collector{
+			Address:        address,
+			PushInterval:   50 * time.Millisecond,
+			TLSMode:        TLSModeSimple,
+			CACertificates: path.Join(dns, "fake-root-cert.pem"),
+			SNI:            "server.default.svc",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if got := c.Received(); len(got) != 0 {
+			t.Fatalf("expected no export to an untrusted collector, got %v", got)
+		}
+	})
+}
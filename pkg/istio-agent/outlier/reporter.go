@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outlier reports the hosts ejected by the outlier detection of the proxy to istiod.
+package outlier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	admin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+
+	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/pkg/log"
+)
+
+var reporterLog = log.RegisterScope("outlier", "Outlier ejections reporter", 0)
+
+// outboundClusterPrefix is the prefix of the names of the outbound clusters, the only ones istiod aggregates the
+// ejections of.
+const outboundClusterPrefix = "outbound"
+
+// Reporter reads the outlier detection state of the proxy every report interval, and reports the ejected hosts
+// when they changed. It only reads the state of the proxy while istiod sets a report interval, so that the proxies
+// do not pay for it when istiod does not use it.
+type Reporter struct {
+	clustersURL string
+	client      *http.Client
+	report      func(ejected []string)
+
+	mu       sync.Mutex
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// NewReporter creates a reporter reading the clusters of the proxy from the given admin endpoint, and passing the
+// ejected hosts to report. It does not read anything until an interval is set with Update.
+func NewReporter(clustersURL string, report func(ejected []string)) *Reporter {
+	return &Reporter{
+		clustersURL: clustersURL,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		report:      report,
+	}
+}
+
+// Update sets the interval between two reads of the outlier detection state of the proxy. The reports stop if the
+// interval is 0.
+func (r *Reporter) Update(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if interval == r.interval {
+		return
+	}
+	r.closeLocked()
+	if interval <= 0 {
+		reporterLog.Infof("not reporting the outlier ejections of the proxy")
+		return
+	}
+	reporterLog.Infof("reporting the outlier ejections of the proxy every %v", interval)
+	r.interval, r.stop = interval, make(chan struct{})
+	go r.run(interval, r.stop)
+}
+
+// Close stops the reports.
+func (r *Reporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeLocked()
+}
+
+func (r *Reporter) closeLocked() {
+	if r.stop != nil {
+		close(r.stop)
+	}
+	r.interval, r.stop = 0, nil
+}
+
+func (r *Reporter) run(interval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var last []string
+	for {
+		select {
+		case <-stop:
+			if len(last) > 0 {
+				r.report(nil)
+			}
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			ejected, err := r.read(ctx)
+			cancel()
+			if err != nil {
+				reporterLog.Warnf("failed to read the outlier ejections of the proxy: %v", err)
+				continue
+			}
+			if !equal(ejected, last) {
+				r.report(ejected)
+				last = ejected
+			}
+		}
+	}
+}
+
+func (r *Reporter) read(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.clustersURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read the proxy clusters: %v", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	clusters := &admin.Clusters{}
+	if err := protomarshal.UnmarshalAllowUnknown(body, clusters); err != nil {
+		return nil, err
+	}
+	return Ejected(clusters), nil
+}
+
+// Ejected returns the hosts of the outbound clusters ejected by the outlier detection, as sorted
+// "<cluster>/<address>:<port>" names.
+func Ejected(clusters *admin.Clusters) []string {
+	var out []string
+	for _, cluster := range clusters.GetClusterStatuses() {
+		if !strings.HasPrefix(cluster.GetName(), outboundClusterPrefix) {
+			continue
+		}
+		for _, h := range cluster.GetHostStatuses() {
+			address := h.GetAddress().GetSocketAddress()
+			if !h.GetHealthStatus().GetFailedOutlierCheck() || address == nil {
+				continue
+			}
+			out = append(out, cluster.GetName()+"/"+
+				net.JoinHostPort(address.GetAddress(), strconv.Itoa(int(address.GetPortValue()))))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
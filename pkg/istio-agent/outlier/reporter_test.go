@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outlier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const clusters = `{
+ "cluster_statuses": [
+  {
+   "name": "outbound|80||a.example.com",
+   "host_statuses": [
+    {
+     "address": {"socket_address": {"address": "1.1.1.1", "port_value": 80}},
+     "health_status": {"failed_outlier_check": %t, "eds_health_status": "HEALTHY"}
+    },
+    {
+     "address": {"socket_address": {"address": "1.1.1.2", "port_value": 80}},
+     "health_status": {"eds_health_status": "HEALTHY"}
+    },
+    {
+     "address": {"socket_address": {"address": "2001:db8::1", "port_value": 80}},
+     "health_status": {"failed_outlier_check": true, "eds_health_status": "HEALTHY"}
+    }
+   ]
+  },
+  {
+   "name": "inbound|80||",
+   "host_statuses": [
+    {
+     "address": {"socket_address": {"address": "10.0.0.1", "port_value": 80}},
+     "health_status": {"failed_outlier_check": true, "eds_health_status": "HEALTHY"}
+    }
+   ]
+  }
+ ]
+}`
+
+func TestReporter(t *testing.T) {
+	var mu sync.Mutex
+	ejected := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, clusters, ejected)
+	}))
+	defer server.Close()
+
+	var reports [][]string
+	r := NewReporter(server.URL, func(e []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, e)
+	})
+	defer r.Close()
+	expectReports := func(want ...[]string) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(reports, want) {
+				return fmt.Errorf("expected reports %v, got %v", want, reports)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second))
+	}
+
+	r.Update(10 * time.Millisecond)
+	first := []string{"outbound|80||a.example.com/1.1.1.1:80", "outbound|80||a.example.com/[2001:db8::1]:80"}
+	expectReports(first)
+
+	// Only the changes are reported.
+	mu.Lock()
+	ejected = false
+	mu.Unlock()
+	second := []string{"outbound|80||a.example.com/[2001:db8::1]:80"}
+	expectReports(first, second)
+
+	// The ejections are cleared when istiod no longer uses them.
+	r.Update(0)
+	expectReports(first, second, nil)
+}
@@ -30,6 +30,7 @@ import (
 	"sync"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	gogotypes "github.com/gogo/protobuf/types"
 	"go.uber.org/atomic"
@@ -42,6 +43,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	any "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
@@ -52,6 +54,8 @@ import (
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	"istio.io/istio/pkg/istio-agent/health"
 	"istio.io/istio/pkg/istio-agent/metrics"
+	"istio.io/istio/pkg/istio-agent/otelmetrics"
+	"istio.io/istio/pkg/istio-agent/outlier"
 	istiokeepalive "istio.io/istio/pkg/keepalive"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/uds"
@@ -120,6 +124,15 @@ type XdsProxy struct {
 	ecdsLastNonce         atomic.String
 	downstreamGrpcOptions []grpc.ServerOption
 	istiodSAN             string
+	otelMetricsExporter   *otelmetrics.Exporter
+	outlierReporter       *outlier.Reporter
+	// outlierEjections is the last report of the hosts ejected by the outlier detection of the proxy, sent again
+	// on every reconnection like the initial requests.
+	outlierEjections []string
+
+	// redirectAddress is the address of the istiod replica serving this proxy, if istiod redirected it there.
+	// It is used instead of istiodAddress until the connection to the replica fails.
+	redirectAddress atomic.String
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent", 0)
@@ -185,6 +198,43 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 			return ia.secretCache.UpdateConfigTrustBundle(trustBundle)
 		}
 	}
+	if !ia.EnvoyDisabled() {
+		// The metrics are read from the proxy and exported only while istiod selects a collector.
+		var secrets security.SecretManager
+		if ia.secretCache != nil {
+			secrets = ia.secretCache
+		}
+		proxy.otelMetricsExporter = otelmetrics.NewExporter(fmt.Sprintf("http://%s:%d/stats/prometheus",
+			localHostAddr, ia.proxyConfig.ProxyAdminPort), secrets)
+		proxy.handlers[v3.OpenTelemetryMetricsType] = func(resp *any.Any) error {
+			var target structpb.Struct
+			if err := resp.UnmarshalTo(&target); err != nil {
+				log.Errorf("failed to unmarshal OpenTelemetry metrics collector: %v", err)
+				return err
+			}
+			pushInterval, _ := time.ParseDuration(target.Fields["pushInterval"].GetStringValue())
+			return proxy.otelMetricsExporter.Update(otelmetrics.Collector{
+				Address:        target.Fields["address"].GetStringValue(),
+				PushInterval:   pushInterval,
+				TLSMode:        target.Fields["tlsMode"].GetStringValue(),
+				CACertificates: target.Fields["caCertificates"].GetStringValue(),
+				SNI:            target.Fields["sni"].GetStringValue(),
+			})
+		}
+		// The outlier detection state is read from the proxy and reported only while istiod uses it.
+		proxy.outlierReporter = outlier.NewReporter(fmt.Sprintf("http://%s:%d/clusters?format=json",
+			localHostAddr, ia.proxyConfig.ProxyAdminPort), proxy.ReportOutlierEjections)
+		proxy.handlers[v3.OutlierEjectionsType] = func(resp *any.Any) error {
+			var target structpb.Struct
+			if err := resp.UnmarshalTo(&target); err != nil {
+				log.Errorf("failed to unmarshal outlier ejections report interval: %v", err)
+				return err
+			}
+			reportInterval, _ := time.ParseDuration(target.Fields["reportInterval"].GetStringValue())
+			proxy.outlierReporter.Update(reportInterval)
+			return nil
+		}
+	}
 
 	proxyLog.Infof("Initializing with upstream address %q and cluster %q", proxy.istiodAddress, proxy.clusterID)
 
@@ -258,6 +308,30 @@ func (p *XdsProxy) PersistRequest(req *discovery.DiscoveryRequest) {
 	}
 }
 
+// ReportOutlierEjections sends the hosts ejected by the outlier detection of the proxy to the currently connected
+// istiod. Like with PersistRequest, the last report is sent again on any reconnection.
+func (p *XdsProxy) ReportOutlierEjections(ejected []string) {
+	p.connectedMutex.Lock()
+	p.outlierEjections = ejected
+	con := p.connected
+	p.connectedMutex.Unlock()
+
+	if con == nil {
+		return
+	}
+	if con.deltaRequestsChan != nil {
+		con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+			TypeUrl:                v3.OutlierEjectionsReportType,
+			ResourceNamesSubscribe: ejected,
+		})
+	} else {
+		con.sendRequest(&discovery.DiscoveryRequest{
+			TypeUrl:       v3.OutlierEjectionsReportType,
+			ResourceNames: ejected,
+		})
+	}
+}
+
 func (p *XdsProxy) UnregisterStream(c *ProxyConnection) {
 	p.connectedMutex.Lock()
 	defer p.connectedMutex.Unlock()
@@ -334,7 +408,7 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 
 	upstreamConn, err := p.buildUpstreamConn(ctx)
 	if err != nil {
-		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
+		proxyLog.Errorf("failed to connect to upstream %s: %v", p.upstreamAddress(), err)
 		metrics.IstiodConnectionFailures.Increment()
 		return err
 	}
@@ -355,7 +429,42 @@ func (p *XdsProxy) buildUpstreamConn(ctx context.Context) (*grpc.ClientConn, err
 	opts = append(opts, p.istiodDialOptions...)
 	p.optsMutex.RUnlock()
 
-	return grpc.DialContext(ctx, p.istiodAddress, opts...)
+	return grpc.DialContext(ctx, p.upstreamAddress(), opts...)
+}
+
+// upstreamAddress returns the address of the istiod to connect to.
+func (p *XdsProxy) upstreamAddress() string {
+	if address := p.redirectAddress.Load(); address != "" {
+		return address
+	}
+	return p.istiodAddress
+}
+
+// advertiseRedirect tells istiod, through the node metadata, that the agent follows its redirections.
+func advertiseRedirect(node *core.Node) {
+	if node == nil {
+		return
+	}
+	if node.Metadata == nil {
+		node.Metadata = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	}
+	node.Metadata.Fields["XDS_REDIRECT"] = structpb.NewStringValue("true")
+}
+
+// redirect follows the redirection of istiod to the replica serving this proxy, on the port of the discovery
+// address, if the error is one. Otherwise, unless the connection terminated normally, the next connection goes
+// to the discovery address again.
+func (p *XdsProxy) redirect(err error) bool {
+	if host := istiogrpc.RedirectHost(err); host != "" {
+		if _, port, splitErr := net.SplitHostPort(p.istiodAddress); splitErr == nil {
+			p.redirectAddress.Store(net.JoinHostPort(host, port))
+			return true
+		}
+	}
+	if !istiogrpc.IsExpectedGRPCError(err) {
+		p.redirectAddress.Store("")
+	}
+	return false
 }
 
 func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds discovery.AggregatedDiscoveryServiceClient) error {
@@ -364,12 +473,14 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
+		p.redirect(err)
 		// Increase metric when xds connection error, for example: forgot to restart ingressgateway or sidecar after changing root CA.
 		metrics.IstiodConnectionErrors.Increment()
 		return err
 	}
-	proxyLog.Infof("connected to upstream XDS server: %s", p.istiodAddress)
-	defer proxyLog.Debugf("disconnected from XDS server: %s", p.istiodAddress)
+	address := p.upstreamAddress()
+	proxyLog.Infof("connected to upstream XDS server: %s", address)
+	defer proxyLog.Debugf("disconnected from XDS server: %s", address)
 
 	con.upstream = upstream
 
@@ -399,7 +510,10 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 		select {
 		case err := <-con.upstreamError:
 			// error from upstream Istiod.
-			if istiogrpc.IsExpectedGRPCError(err) {
+			if p.redirect(err) {
+				proxyLog.Infof("upstream [%d] redirected to %s: %v", con.conID, p.upstreamAddress(), err)
+				metrics.IstiodConnectionCancellations.Increment()
+			} else if istiogrpc.IsExpectedGRPCError(err) {
 				proxyLog.Debugf("upstream [%d] terminated with status %v", con.conID, err)
 				metrics.IstiodConnectionCancellations.Increment()
 			} else {
@@ -440,6 +554,7 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 			}
 
 			// forward to istiod
+			advertiseRedirect(req.Node)
 			con.sendRequest(req)
 			if !initialRequestsSent.Load() && req.TypeUrl == v3.ListenerType {
 				// fire off an initial NDS request
@@ -454,6 +569,18 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 						TypeUrl: v3.ProxyConfigType,
 					})
 				}
+				// fire off an initial request for the OpenTelemetry metrics collector
+				if _, f := p.handlers[v3.OpenTelemetryMetricsType]; f {
+					con.sendRequest(&discovery.DiscoveryRequest{
+						TypeUrl: v3.OpenTelemetryMetricsType,
+					})
+				}
+				// fire off an initial request for the outlier ejections report interval
+				if _, f := p.handlers[v3.OutlierEjectionsType]; f {
+					con.sendRequest(&discovery.DiscoveryRequest{
+						TypeUrl: v3.OutlierEjectionsType,
+					})
+				}
 				// set flag before sending the initial request to prevent race.
 				initialRequestsSent.Store(true)
 				// Fire of a configured initial request, if there is one
@@ -462,6 +589,13 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 				if initialRequest != nil {
 					con.sendRequest(initialRequest)
 				}
+				// Send the last outlier ejections again, if there are some
+				if len(p.outlierEjections) > 0 {
+					con.sendRequest(&discovery.DiscoveryRequest{
+						TypeUrl:       v3.OutlierEjectionsReportType,
+						ResourceNames: p.outlierEjections,
+					})
+				}
 				p.connectedMutex.RUnlock()
 			}
 		}
@@ -471,8 +605,8 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 	for {
 		select {
 		case req := <-con.requestsChan:
-			if req.TypeUrl == v3.HealthInfoType && !initialRequestsSent.Load() {
-				// only send healthcheck probe after LDS request has been sent
+			if (req.TypeUrl == v3.HealthInfoType || req.TypeUrl == v3.OutlierEjectionsReportType) && !initialRequestsSent.Load() {
+				// only send healthcheck probe and outlier ejections after LDS request has been sent
 				continue
 			}
 			proxyLog.Debugf("request for type url %s", req.TypeUrl)
@@ -609,6 +743,12 @@ func (p *XdsProxy) close() {
 	if p.downstreamListener != nil {
 		_ = p.downstreamListener.Close()
 	}
+	if p.otelMetricsExporter != nil {
+		p.otelMetricsExporter.Close()
+	}
+	if p.outlierReporter != nil {
+		p.outlierReporter.Close()
+	}
 }
 
 func (p *XdsProxy) initDownstreamServer() error {
@@ -65,6 +65,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 		// Send initial request
 		p.connectedMutex.RLock()
 		initialRequest := p.initialDeltaRequest
+		outlierEjections := p.outlierEjections
 		p.connectedMutex.RUnlock()
 
 		for {
@@ -78,6 +79,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 				return
 			}
 			// forward to istiod
+			advertiseRedirect(req.Node)
 			con.sendDeltaRequest(req)
 			if !initialRequestsSent && req.TypeUrl == v3.ListenerType {
 				// fire off an initial NDS request
@@ -92,10 +94,29 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 						TypeUrl: v3.ProxyConfigType,
 					})
 				}
+				// fire off an initial request for the OpenTelemetry metrics collector
+				if _, f := p.handlers[v3.OpenTelemetryMetricsType]; f {
+					con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+						TypeUrl: v3.OpenTelemetryMetricsType,
+					})
+				}
+				// fire off an initial request for the outlier ejections report interval
+				if _, f := p.handlers[v3.OutlierEjectionsType]; f {
+					con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+						TypeUrl: v3.OutlierEjectionsType,
+					})
+				}
 				// Fire of a configured initial request, if there is one
 				if initialRequest != nil {
 					con.sendDeltaRequest(initialRequest)
 				}
+				// Send the last outlier ejections again, if there are some
+				if len(outlierEjections) > 0 {
+					con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+						TypeUrl:                v3.OutlierEjectionsReportType,
+						ResourceNamesSubscribe: outlierEjections,
+					})
+				}
 				initialRequestsSent = true
 			}
 		}
@@ -105,7 +126,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 	defer cancel()
 	upstreamConn, err := p.buildUpstreamConn(ctx)
 	if err != nil {
-		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
+		proxyLog.Errorf("failed to connect to upstream %s: %v", p.upstreamAddress(), err)
 		metrics.IstiodConnectionFailures.Increment()
 		return err
 	}
@@ -126,12 +147,14 @@ func (p *XdsProxy) HandleDeltaUpstream(ctx context.Context, con *ProxyConnection
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create delta upstream grpc client: %v", err)
+		p.redirect(err)
 		// Increase metric when xds connection error, for example: forgot to restart ingressgateway or sidecar after changing root CA.
 		metrics.IstiodConnectionErrors.Increment()
 		return err
 	}
-	proxyLog.Infof("connected to delta upstream XDS server: %s", p.istiodAddress)
-	defer proxyLog.Debugf("disconnected from delta XDS server: %s", p.istiodAddress)
+	address := p.upstreamAddress()
+	proxyLog.Infof("connected to delta upstream XDS server: %s", address)
+	defer proxyLog.Debugf("disconnected from delta XDS server: %s", address)
 
 	con.upstreamDeltas = deltaUpstream
 
@@ -161,7 +184,10 @@ func (p *XdsProxy) HandleDeltaUpstream(ctx context.Context, con *ProxyConnection
 		select {
 		case err := <-con.upstreamError:
 			// error from upstream Istiod.
-			if istiogrpc.IsExpectedGRPCError(err) {
+			if p.redirect(err) {
+				proxyLog.Infof("upstream redirected to %s: %v", p.upstreamAddress(), err)
+				metrics.IstiodConnectionCancellations.Increment()
+			} else if istiogrpc.IsExpectedGRPCError(err) {
 				proxyLog.Debugf("upstream terminated with status %v", err)
 				metrics.IstiodConnectionCancellations.Increment()
 			} else {
@@ -102,6 +102,53 @@ func TestXdsProxyBasicFlow(t *testing.T) {
 	})
 }
 
+// shardOwner owns no shard, and redirects all the proxies to the same replica.
+type shardOwner string
+
+func (o shardOwner) Owns(int) bool {
+	return false
+}
+
+func (o shardOwner) OwnerAddress(int) string {
+	return string(o)
+}
+
+// Validates that the proxy follows the redirection to the istiod replica serving it.
+func TestXdsProxyRedirect(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	proxy.istiodAddress = "istiod.istio-system.svc:15012"
+	sharded := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	sharded.Discovery.Sharding, _ = xds.NewSharding(2, xds.ShardByNamespace, shardOwner("10.0.0.2"))
+	owner := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	proxy.istiodDialOptions = []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(_ context.Context, address string) (net.Conn, error) {
+			if address == "10.0.0.2:15012" {
+				return owner.BufListener.Dial()
+			}
+			return sharded.BufListener.Dial()
+		}),
+	}
+	conn := setupDownstreamConnection(t, proxy)
+
+	downstream := stream(t, conn)
+	sendDownstreamWithoutResponse(t, downstream)
+	if _, err := downstream.Recv(); err == nil {
+		t.Fatalf("expected the replica not serving the proxy to reject it")
+	}
+	if got := proxy.upstreamAddress(); got != "10.0.0.2:15012" {
+		t.Fatalf("expected the proxy to be redirected to 10.0.0.2:15012, got %q", got)
+	}
+
+	// Envoy reconnects, and the proxy connects to the replica serving it.
+	downstream = stream(t, conn)
+	sendDownstreamWithNode(t, downstream, model.NodeMetadata{
+		Namespace:   "default",
+		InstanceIPs: []string{"1.1.1.1"},
+	})
+}
+
 // Validates the proxy health checking updates
 func TestXdsProxyHealthCheck(t *testing.T) {
 	healthy := &discovery.DiscoveryRequest{TypeUrl: v3.HealthInfoType}
@@ -60,6 +60,9 @@ func newPortGenerator() *portGenerator {
 			protocol.Mongo:   tcpBase,
 			protocol.MySQL:   tcpBase,
 			protocol.Redis:   tcpBase,
+			protocol.Kafka:   tcpBase,
+			protocol.Dubbo:   tcpBase,
+			protocol.Thrift:  tcpBase,
 			protocol.UDP:     tcpBase,
 		},
 		used: make(map[int]struct{}),
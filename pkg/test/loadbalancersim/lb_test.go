@@ -216,6 +216,91 @@ func TestLoadBalancing(t *testing.T) {
 	}
 }
 
+func TestSlowStart(t *testing.T) {
+	serviceTime := 20 * time.Millisecond
+	clientRPS := 1500
+	clientRequests := 3000
+	activeRequestBias := 1.0
+	coldStart := mesh.ColdStart{
+		Duration: 2 * time.Second,
+		Penalty:  5,
+	}
+	zone := locality.Parse("us-east/ny")
+
+	slowStartCases := []struct {
+		name      string
+		slowStart loadbalancer.SlowStartSettings
+	}{
+		{
+			name: "slow start off",
+		},
+		{
+			name: "slow start on",
+			slowStart: loadbalancer.SlowStartSettings{
+				Window:     coldStart.Duration,
+				Aggression: 1.0,
+			},
+		},
+	}
+
+	for _, slowStartCase := range slowStartCases {
+		slowStartCase := slowStartCase
+		t.Run(slowStartCase.name, func(t *testing.T) {
+			algorithmCases := []struct {
+				name  string
+				newLB func(conns []*loadbalancer.WeightedConnection) network.Connection
+			}{
+				{
+					name: "round robin",
+					newLB: func(conns []*loadbalancer.WeightedConnection) network.Connection {
+						return loadbalancer.NewSlowStartRoundRobin(conns, slowStartCase.slowStart)
+					},
+				},
+				{
+					name: "least request",
+					newLB: func(conns []*loadbalancer.WeightedConnection) network.Connection {
+						return loadbalancer.NewLeastRequest(loadbalancer.LeastRequestSettings{
+							Connections:       conns,
+							ActiveRequestBias: activeRequestBias,
+							SlowStart:         slowStartCase.slowStart,
+						})
+					},
+				},
+			}
+			for _, algorithmCase := range algorithmCases {
+				algorithmCase := algorithmCase
+				t.Run(algorithmCase.name, func(t *testing.T) {
+					m := mesh.New(mesh.Settings{})
+					defer m.ShutDown()
+
+					tm := &testMetrics{
+						hasQueueLatency: true,
+						algorithm:       algorithmCase.name,
+						topology:        "scale up",
+					}
+
+					_ = m.NewClient(mesh.ClientSettings{
+						RPS:      clientRPS,
+						Locality: zone,
+					})
+
+					// Half of the nodes were just added, and are slower until they are warm.
+					m.NewNodes(3, serviceTime, true, zone)
+					m.NewColdNodes(3, coldStart, serviceTime, true, zone)
+
+					runTest(t, testSettings{
+						mesh:                  m,
+						clientRequests:        clientRequests,
+						activeRequestBias:     activeRequestBias,
+						newWeightedConnection: loadbalancer.EquallyWeightedConnectionFactory(),
+						newLB:                 algorithmCase.newLB,
+					}, tm)
+				})
+			}
+		})
+	}
+}
+
 func toggleStrUpper(on bool) string {
 	return strings.ToUpper(toggleStr(on))
 }
@@ -26,6 +26,7 @@ import (
 type LeastRequestSettings struct {
 	Connections       []*WeightedConnection
 	ActiveRequestBias float64
+	SlowStart         SlowStartSettings
 }
 
 func NewLeastRequest(s LeastRequestSettings) network.Connection {
@@ -35,11 +36,12 @@ func NewLeastRequest(s LeastRequestSettings) network.Connection {
 
 	conn := newLBConnection("LeastRequestLB", s.Connections)
 
-	if conn.AllWeightsEqual() {
+	// Slow start changes the weights over time, so it always needs the weighted algorithm.
+	if conn.AllWeightsEqual() && !s.SlowStart.Enabled() {
 		return newUnweightedLeastRequest(conn)
 	}
 
-	return newWeightedLeastRequest(conn, s.ActiveRequestBias)
+	return newWeightedLeastRequest(conn, s.ActiveRequestBias, s.SlowStart)
 }
 
 type unweightedLeastRequest struct {
@@ -87,14 +89,16 @@ func (lb *unweightedLeastRequest) Request(onDone func()) {
 type weightedLeastRequest struct {
 	*weightedConnections
 	activeRequestBias float64
+	slowStart         SlowStartSettings
 	edf               *EDF
 	edfMutex          sync.Mutex
 }
 
-func newWeightedLeastRequest(conn *weightedConnections, activeRequestBias float64) network.Connection {
+func newWeightedLeastRequest(conn *weightedConnections, activeRequestBias float64, slowStart SlowStartSettings) network.Connection {
 	lb := &weightedLeastRequest{
 		weightedConnections: conn,
 		activeRequestBias:   activeRequestBias,
+		slowStart:           slowStart,
 		edf:                 NewEDF(),
 	}
 
@@ -119,7 +123,7 @@ func (lb *weightedLeastRequest) Request(onDone func()) {
 func (lb *weightedLeastRequest) calcEDFWeight(_ float64, value interface{}) float64 {
 	conn := value.(*WeightedConnection)
 
-	weight := float64(conn.Weight)
+	weight := lb.slowStart.weight(conn, time.Now())
 	if lb.activeRequestBias >= 1.0 {
 		weight /= float64(conn.ActiveRequests() + 1)
 	} else if lb.activeRequestBias > 0.0 {
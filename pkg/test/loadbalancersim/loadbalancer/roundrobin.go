@@ -17,6 +17,7 @@ package loadbalancer
 import (
 	"math/rand"
 	"sync"
+	"time"
 
 	"istio.io/istio/pkg/test/loadbalancersim/network"
 )
@@ -56,3 +57,39 @@ func (lb *roundRobin) Request(onDone func()) {
 
 	lb.doRequest(selected, onDone)
 }
+
+// NewSlowStartRoundRobin creates a weighted round robin load balancer, which progressively increases the weight of
+// the new endpoints during the slow start window, as Envoy does.
+func NewSlowStartRoundRobin(conns []*WeightedConnection, slowStart SlowStartSettings) network.Connection {
+	lb := &slowStartRoundRobin{
+		weightedConnections: newLBConnection("SlowStartRoundRobinLB", conns),
+		slowStart:           slowStart,
+		edf:                 NewEDF(),
+	}
+
+	// Add all endpoints to the EDF scheduler.
+	now := time.Now()
+	for _, c := range conns {
+		lb.edf.Add(slowStart.weight(c, now), c)
+	}
+
+	return lb
+}
+
+type slowStartRoundRobin struct {
+	*weightedConnections
+	slowStart SlowStartSettings
+	edf       *EDF
+	edfMutex  sync.Mutex
+}
+
+func (lb *slowStartRoundRobin) Request(onDone func()) {
+	// Pick the next endpoint and re-add it with its current weight.
+	lb.edfMutex.Lock()
+	selected := lb.edf.PickAndAdd(func(_ float64, value interface{}) float64 {
+		return lb.slowStart.weight(value.(*WeightedConnection), time.Now())
+	}).(*WeightedConnection)
+	lb.edfMutex.Unlock()
+
+	lb.doRequest(selected, onDone)
+}
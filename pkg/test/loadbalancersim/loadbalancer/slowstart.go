@@ -0,0 +1,55 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package loadbalancer
+
+import (
+	"math"
+	"time"
+)
+
+// minSlowStartWeightPercent is the minimum share of its weight an endpoint gets in the slow start window.
+const minSlowStartWeightPercent = 0.1
+
+// SlowStartSettings models the slow start mode of the Envoy ROUND_ROBIN and LEAST_REQUEST load balancers, which
+// progressively increases the weight of new endpoints over a warm-up window.
+type SlowStartSettings struct {
+	// Window is the warm-up duration of new endpoints. Slow start is disabled if zero.
+	Window time.Duration
+	// Aggression controls the speed of the weight increase, 1.0 being linear.
+	Aggression float64
+}
+
+func (s SlowStartSettings) Enabled() bool {
+	return s.Window > 0
+}
+
+// weight returns the weight of the connection, scaled down while its endpoint is in the slow start window.
+func (s SlowStartSettings) weight(c *WeightedConnection, now time.Time) float64 {
+	weight := float64(c.Weight)
+	if !s.Enabled() || c.StartTime.IsZero() {
+		return weight
+	}
+	elapsed := now.Sub(c.StartTime)
+	if elapsed >= s.Window {
+		return weight
+	}
+
+	aggression := s.Aggression
+	if aggression <= 0 {
+		aggression = 1.0
+	}
+	factor := math.Pow(math.Max(float64(elapsed), 0)/float64(s.Window), 1/aggression)
+	return weight * math.Max(factor, minSlowStartWeightPercent)
+}
@@ -15,6 +15,8 @@
 package loadbalancer
 
 import (
+	"time"
+
 	mesh2 "istio.io/istio/pkg/test/loadbalancersim/mesh"
 	network2 "istio.io/istio/pkg/test/loadbalancersim/network"
 	"istio.io/istio/pkg/test/loadbalancersim/timeseries"
@@ -23,6 +25,8 @@ import (
 type WeightedConnection struct {
 	network2.Connection
 	Weight uint32
+	// StartTime is the time the destination started, used by slow start. Zero if it was already warm.
+	StartTime time.Time
 }
 
 type weightedConnections struct {
@@ -82,6 +86,7 @@ func EquallyWeightedConnectionFactory() WeightedConnectionFactory {
 		return &WeightedConnection{
 			Connection: src.Mesh().NewConnection(src, dest),
 			Weight:     1,
+			StartTime:  dest.StartTime(),
 		}
 	}
 }
@@ -102,6 +107,7 @@ func PriorityWeightedConnectionFactory(selectPriority PrioritySelector, priority
 		return &WeightedConnection{
 			Connection: src.Mesh().NewConnection(src, dest),
 			Weight:     weight,
+			StartTime:  dest.StartTime(),
 		}
 	}
 }
@@ -90,6 +90,20 @@ func (m *Instance) NewNodes(count int, serviceTime time.Duration, enableQueueLat
 	return out
 }
 
+// NewColdNodes creates nodes that just started, and are slower until they are warm.
+func (m *Instance) NewColdNodes(count int, coldStart ColdStart, serviceTime time.Duration, enableQueueLatency bool,
+	locality locality.Instance) Nodes {
+	out := make(Nodes, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%s_cold_%d", locality, i)
+		out = append(out, newColdNode(name, coldStart, serviceTime, enableQueueLatency, locality))
+	}
+
+	m.nodes = append(m.nodes, out...)
+
+	return out
+}
+
 func (m *Instance) NewClient(s ClientSettings) *Client {
 	c := &Client{
 		mesh: m,
@@ -28,6 +28,15 @@ var _ network.Connection = &Node{}
 
 const maxQLatency = 30 * time.Second
 
+// ColdStart models nodes that are slower right after they start, while they warm up their caches, connection pools
+// or JIT compiled code.
+type ColdStart struct {
+	// Duration is the time it takes for the node to reach its steady-state service time.
+	Duration time.Duration
+	// Penalty is the factor applied to the service time when the node starts, decreasing linearly to 1 over Duration.
+	Penalty float64
+}
+
 type Node struct {
 	locality        locality.Instance
 	helper          *network.ConnectionHelper
@@ -36,6 +45,8 @@ type Node struct {
 	qLatencyEnabled bool
 	qLength         timeseries.Instance
 	qLatency        timeseries.Instance
+	coldStart       ColdStart
+	startTime       time.Time
 }
 
 func newNode(name string, serviceTime time.Duration, enableQueueLatency bool, l locality.Instance) *Node {
@@ -48,6 +59,19 @@ func newNode(name string, serviceTime time.Duration, enableQueueLatency bool, l
 	}
 }
 
+func newColdNode(name string, coldStart ColdStart, serviceTime time.Duration, enableQueueLatency bool, l locality.Instance) *Node {
+	n := newNode(name, serviceTime, enableQueueLatency, l)
+	n.coldStart = coldStart
+	n.startTime = time.Now()
+	return n
+}
+
+// StartTime returns the time a cold node started, or zero for the nodes that were already warm when the simulation
+// began.
+func (n *Node) StartTime() time.Time {
+	return n.startTime
+}
+
 func (n *Node) Name() string {
 	return n.helper.Name()
 }
@@ -70,7 +94,22 @@ func (n *Node) calcRequestDuration() time.Duration {
 	n.qLength.AddObservation(float64(qLen), tnow)
 	n.qLatency.AddObservation(qLatency.Seconds(), tnow)
 
-	return n.serviceTime + qLatency
+	return n.calcServiceTime(tnow) + qLatency
+}
+
+func (n *Node) calcServiceTime(now time.Time) time.Duration {
+	if n.coldStart.Duration <= 0 || n.coldStart.Penalty <= 1 {
+		return n.serviceTime
+	}
+	elapsed := now.Sub(n.startTime)
+	if elapsed >= n.coldStart.Duration {
+		return n.serviceTime
+	}
+
+	// Decrease the penalty linearly until the node is warm.
+	remaining := 1 - float64(elapsed)/float64(n.coldStart.Duration)
+	factor := 1 + (n.coldStart.Penalty-1)*remaining
+	return time.Duration(float64(n.serviceTime) * factor)
 }
 
 func (n *Node) calcQLatency(qlen int) time.Duration {
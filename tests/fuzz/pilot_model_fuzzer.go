@@ -40,6 +40,9 @@ var protocols = []protocol.Instance{
 	protocol.Mongo,
 	protocol.Redis,
 	protocol.MySQL,
+	protocol.Kafka,
+	protocol.Dubbo,
+	protocol.Thrift,
 }
 
 // Creates a new fuzzed ServiceInstance